@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"node-test/pkg/client"
+)
+
+func runBucket(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) == 0 {
+		return &usageError{message: usages["bucket"]}
+	}
+
+	switch args[0] {
+	case "create", "set":
+		return cl.saveBucket(ctx, args[0], args[1:])
+	case "ls":
+		if len(args) != 1 {
+			return &usageError{message: usages["bucket"]}
+		}
+		buckets, err := cl.client.Buckets(ctx)
+		if err != nil {
+			return fmt.Errorf("list buckets: %w", err)
+		}
+		return cl.printBuckets(buckets)
+	case "info":
+		if len(args) != 2 {
+			return &usageError{message: usages["bucket"]}
+		}
+		bucket, err := cl.client.Bucket(ctx, args[1])
+		if err != nil {
+			return fmt.Errorf("get bucket %v: %w", args[1], err)
+		}
+		return cl.printBucket(bucket)
+	case "rm":
+		if len(args) != 2 {
+			return &usageError{message: usages["bucket"]}
+		}
+		if err := cl.client.DeleteBucket(ctx, args[1]); err != nil {
+			return fmt.Errorf("remove bucket %v: %w", args[1], err)
+		}
+		return cl.printResult(map[string]string{"bucket": args[1], "status": "deleted"}, "deleted "+args[1])
+	default:
+		return &usageError{message: usages["bucket"]}
+	}
+}
+
+// saveBucket creates the bucket or changes settings which are set by flags, other settings are kept
+func (cl *cli) saveBucket(ctx context.Context, action string, args []string) error {
+
+	flags := flag.NewFlagSet("bucket "+action, flag.ContinueOnError)
+	replicas := flags.Int("replicas", 0, "copies of every chunk on distinct nodes")
+	versioning := flags.Bool("versioning", false, "keep previous versions of overwritten and deleted keys")
+	maxVersions := flags.Int("max-versions", 0, "noncurrent versions kept for every key, 0 keeps all of them")
+	retention := flags.Duration("retention", 0, "time files are kept before they can be deleted")
+	expireAfter := flags.Duration("expire-after", 0, "remove new files when the time elapses, 0 keeps them")
+	noncurrentExpireAfter := flags.Duration("noncurrent-expire-after", 0, "remove noncurrent versions when the time elapses, 0 keeps them")
+	chunkSize := flags.Int64("chunk-size", 0, "size of fixed chunks of new files in bytes, 0 uses the master size")
+	compression := flags.String("compression", "", "codec of new files: none, gzip, zstd or snappy, the master codec when empty")
+	maxBytes := flags.Int64("max-bytes", 0, "limit of stored bytes, 0 means no limit")
+	maxFiles := flags.Int64("max-files", 0, "limit of stored files, 0 means no limit")
+	access := flags.String("access", client.AccessPrivate, "access of new files: private, public or shared")
+	share := flags.String("share", "", "comma separated principals which new shared files are shared with")
+
+	if err := flags.Parse(args); err != nil {
+		return &usageError{message: err.Error()}
+	}
+	if flags.NArg() != 1 {
+		return &usageError{message: usages["bucket"]}
+	}
+
+	bucket := &client.Bucket{Name: flags.Arg(0)}
+	if action == "set" {
+		current, err := cl.client.Bucket(ctx, bucket.Name)
+		if err != nil {
+			return fmt.Errorf("get bucket %v: %w", bucket.Name, err)
+		}
+		bucket = current
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "replicas":
+			bucket.Replicas = *replicas
+		case "versioning":
+			bucket.Versioning = *versioning
+		case "max-versions":
+			bucket.MaxVersions = *maxVersions
+		case "retention":
+			bucket.Retention = *retention
+		case "expire-after":
+			bucket.ExpireAfter = *expireAfter
+		case "noncurrent-expire-after":
+			bucket.NoncurrentExpireAfter = *noncurrentExpireAfter
+		case "chunk-size":
+			bucket.ChunkSize = *chunkSize
+		case "compression":
+			bucket.Compression = *compression
+		case "max-bytes":
+			bucket.MaxBytes = *maxBytes
+		case "max-files":
+			bucket.MaxFiles = *maxFiles
+		case "access":
+			bucket.DefaultAccess = *access
+		case "share":
+			bucket.DefaultSharedWith = strings.Split(*share, ",")
+		}
+	})
+
+	var err error
+	if action == "create" {
+		bucket, err = cl.client.CreateBucket(ctx, bucket)
+	} else {
+		bucket, err = cl.client.UpdateBucket(ctx, bucket)
+	}
+	if err != nil {
+		return fmt.Errorf("%s bucket %v: %w", action, flags.Arg(0), err)
+	}
+
+	return cl.printBucket(bucket)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"node-test/pkg/client"
+)
+
+type (
+	// cli keeps the settings shared by all commands
+	cli struct {
+		client *client.Client
+		json   bool
+		quiet  bool
+		out    io.Writer
+	}
+
+	usageError struct {
+		message string
+	}
+)
+
+func (e *usageError) Error() string {
+	return "usage: " + e.message
+}
+
+func (cl *cli) progress(label string, total int64) *progress {
+	bar := newProgress(label, total)
+	bar.hidden = cl.quiet
+
+	return bar
+}
+
+// stat returns the file referenced by its id or by the bucket/key path
+func (cl *cli) stat(ctx context.Context, ref string) (*client.File, error) {
+	if bucket, key, ok := strings.Cut(ref, "/"); ok {
+		return cl.client.Resolve(ctx, bucket, key)
+	}
+
+	return cl.client.Stat(ctx, ref)
+}
+
+// fileID returns the id of the file referenced by its id or by the bucket/key path
+func (cl *cli) fileID(ctx context.Context, ref string) (string, error) {
+	if !strings.Contains(ref, "/") {
+		return ref, nil
+	}
+
+	file, err := cl.stat(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	return file.ID, nil
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"node-test/pkg/client"
+)
+
+func runList(ctx context.Context, cl *cli, args []string) error {
+
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	limit := flags.Int64("limit", 100, "page size")
+	offset := flags.Int64("offset", 0, "count of files to skip")
+	all := flags.Bool("all", false, "walk all pages")
+	bucket := flags.String("bucket", "", "list only files of the bucket")
+
+	if err := flags.Parse(args); err != nil {
+		return &usageError{message: err.Error()}
+	}
+	if flags.NArg() > 1 {
+		return &usageError{message: usages["ls"]}
+	}
+
+	opts := client.ListOptions{Bucket: *bucket, Prefix: flags.Arg(0), Offset: *offset, Limit: *limit}
+
+	if *all {
+		files, err := cl.client.ListAll(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return cl.printFiles(&client.FileList{Files: files})
+	}
+
+	page, err := cl.client.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	return cl.printFiles(page)
+}
+
+func runStat(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) == 0 {
+		return &usageError{message: usages["stat"]}
+	}
+
+	for _, ref := range args {
+		file, err := cl.stat(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if err := cl.printFile(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runRemove(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) == 0 {
+		return &usageError{message: usages["rm"]}
+	}
+
+	for _, ref := range args {
+		// the key of the bucket which keeps versions gets the delete marker, its versions are removed by ids
+		if bucket, key, ok := strings.Cut(ref, "/"); ok {
+			marker, err := cl.client.DeleteKey(ctx, bucket, key)
+			if err != nil {
+				return fmt.Errorf("remove %v: %w", ref, err)
+			}
+			if marker != nil {
+				if err := cl.printResult(map[string]string{"upload_id": marker.ID, "status": "delete marker"}, "delete marker "+marker.ID); err != nil {
+					return err
+				}
+				continue
+			}
+		} else if err := cl.client.Delete(ctx, ref); err != nil {
+			return fmt.Errorf("remove %v: %w", ref, err)
+		}
+		if err := cl.printResult(map[string]string{"upload_id": ref, "status": "deleted"}, "deleted "+ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runShare(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) < 2 {
+		return &usageError{message: usages["share"]}
+	}
+
+	access := args[1]
+	switch access {
+	case client.AccessPrivate, client.AccessPublic:
+		if len(args) > 2 {
+			return &usageError{message: "principals can be listed only for the shared access"}
+		}
+	case client.AccessShared:
+	default:
+		return &usageError{message: usages["share"]}
+	}
+
+	id, err := cl.fileID(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("share %v: %w", args[0], err)
+	}
+
+	file, err := cl.client.Share(ctx, id, access, args[2:]...)
+	if err != nil {
+		return fmt.Errorf("share %v: %w", args[0], err)
+	}
+
+	return cl.printFile(file)
+}
+
+func runCat(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) != 1 {
+		return &usageError{message: usages["cat"]}
+	}
+
+	id, err := cl.fileID(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	rc, err := cl.client.Download(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = io.Copy(os.Stdout, rc)
+
+	return err
+}
+
+func runVerify(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) == 0 {
+		return &usageError{message: usages["verify"]}
+	}
+
+	var failed error
+	for _, ref := range args {
+		file, err := cl.stat(ctx, ref)
+		if err != nil {
+			return err
+		}
+		id := file.ID
+
+		bar := cl.progress("verify", file.Size)
+		bar.Start()
+		_, err = cl.client.Verify(ctx, id, client.DownloadOptions{Progress: bar.Add})
+		bar.Stop()
+
+		status := "ok"
+		switch {
+		case errors.Is(err, client.ErrChecksumMismatch), errors.Is(err, client.ErrDecrypt):
+			status = "mismatch"
+			failed = fmt.Errorf("file %v: %w", id, err)
+		case err != nil:
+			return err
+		}
+
+		err = cl.printResult(map[string]string{
+			"upload_id": id,
+			"status":    status,
+			"checksum":  file.Checksum,
+		}, fmt.Sprintf("%s %s", status, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return failed
+}
+
+func runVersions(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) != 1 {
+		return &usageError{message: usages["versions"]}
+	}
+
+	bucket, key, ok := strings.Cut(args[0], "/")
+	if !ok {
+		return &usageError{message: usages["versions"]}
+	}
+
+	versions, err := cl.client.Versions(ctx, bucket, key)
+	if err != nil {
+		return fmt.Errorf("get versions of %v: %w", args[0], err)
+	}
+
+	return cl.printVersions(versions)
+}
+
+// runRestore uploads the content of the previous version as the latest version of its key
+func runRestore(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) != 1 {
+		return &usageError{message: usages["restore"]}
+	}
+
+	version, err := cl.client.Stat(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if version.Bucket == "" || version.DeleteMarker {
+		return fmt.Errorf("restore %v: not a version of the bucket key", args[0])
+	}
+
+	rc, err := cl.client.Download(ctx, version.ID)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	bar := cl.progress("restore", version.ContentSize())
+	bar.Start()
+	defer bar.Stop()
+
+	file, err := cl.client.Upload(ctx, rc, client.UploadOptions{
+		Bucket:   version.Bucket,
+		Name:     version.Name,
+		Size:     version.ContentSize(),
+		Progress: bar.Add,
+	})
+	if err != nil {
+		return fmt.Errorf("restore %v: %w", args[0], err)
+	}
+
+	return cl.printFile(file)
+}
+
+func runQuota(ctx context.Context, cl *cli, args []string) error {
+
+	flags := flag.NewFlagSet("quota", flag.ContinueOnError)
+	maxBytes := flags.Int64("max-bytes", 0, "set the limit of stored bytes, 0 removes the limit")
+	maxFiles := flags.Int64("max-files", 0, "set the limit of stored files, 0 removes the limit")
+
+	if err := flags.Parse(args); err != nil {
+		return &usageError{message: err.Error()}
+	}
+
+	if flags.NArg() == 0 {
+		if flags.NFlag() > 0 {
+			return &usageError{message: usages["quota"]}
+		}
+
+		quotas, err := cl.client.Usage(ctx)
+		if err != nil {
+			return fmt.Errorf("get quota: %w", err)
+		}
+		return cl.printQuotas(quotas)
+	}
+
+	scope := flags.Arg(0)
+	if flags.NArg() != 2 || (scope != client.QuotaUser && scope != client.QuotaTenant && scope != client.QuotaBucket) {
+		return &usageError{message: usages["quota"]}
+	}
+
+	quota, err := cl.client.Quota(ctx, scope, flags.Arg(1))
+	if err != nil {
+		return fmt.Errorf("get quota: %w", err)
+	}
+
+	if flags.NFlag() > 0 {
+		// the limit which isn't set by flags is kept
+		limits := [2]int64{quota.MaxBytes, quota.MaxFiles}
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "max-bytes":
+				limits[0] = *maxBytes
+			case "max-files":
+				limits[1] = *maxFiles
+			}
+		})
+
+		if quota, err = cl.client.SetQuota(ctx, scope, flags.Arg(1), limits[0], limits[1]); err != nil {
+			return fmt.Errorf("set quota: %w", err)
+		}
+	}
+
+	return cl.printQuotas([]*client.Quota{quota})
+}
+
+func runNodes(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) != 0 {
+		return &usageError{message: usages["nodes"]}
+	}
+
+	nodes, err := cl.client.Nodes(ctx)
+	if err != nil {
+		return fmt.Errorf("get nodes: %w", err)
+	}
+
+	return cl.printNodes(nodes)
+}
+
+// runDedup prints deduplication stats of the file, stats of all files without arguments
+func runDedup(ctx context.Context, cl *cli, args []string) error {
+
+	if len(args) > 1 {
+		return &usageError{message: usages["dedup"]}
+	}
+
+	if len(args) == 0 {
+		stats, err := cl.client.Dedup(ctx)
+		if err != nil {
+			return fmt.Errorf("get dedup stats: %w", err)
+		}
+		return cl.printDedup(stats)
+	}
+
+	file, err := cl.stat(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	stats, err := cl.client.FileDedup(ctx, file.ID)
+	if err != nil {
+		return fmt.Errorf("get dedup stats of %v: %w", args[0], err)
+	}
+
+	return cl.printDedup(stats)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+
+	"node-test/pkg/client"
+	httpLib "node-test/pkg/http"
+)
+
+const (
+	envPrefix         = "MASTER_CLIENT"
+	defaultConfigName = ".master-client.yaml"
+	defaultHost       = "127.0.0.1:8080"
+)
+
+// clientConfig is read from the config file and overridden by MASTER_CLIENT_* env vars
+type clientConfig struct {
+	Host  string
+	Token string
+	TLS   bool
+	CA    string // CA file, the system roots are used when it's empty
+	// KeyFile or Passphrase enables the client-side encryption, the key is derived from one of them
+	KeyFile    string
+	Passphrase string
+}
+
+func loadConfig(fileName string) (*clientConfig, error) {
+
+	v := viper.New()
+	v.SetDefault("host", defaultHost)
+	v.SetDefault("token", "")
+	v.SetDefault("tls", false)
+	v.SetDefault("ca", "")
+	v.SetDefault("keyfile", "")
+	v.SetDefault("passphrase", "")
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	explicit := fileName != ""
+	if !explicit {
+		fileName = os.Getenv(envPrefix + "_CONFIG")
+		explicit = fileName != ""
+	}
+	if !explicit {
+		if home, err := os.UserHomeDir(); err == nil {
+			fileName = filepath.Join(home, defaultConfigName)
+		}
+	}
+
+	if fileName != "" {
+		_, err := os.Stat(fileName)
+		switch {
+		case err == nil:
+			v.SetConfigFile(fileName)
+			if err := v.ReadInConfig(); err != nil {
+				return nil, err
+			}
+		case explicit || !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+	}
+
+	var cfg clientConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// options converts the config to options of the SDK client
+func (cfg *clientConfig) options() ([]client.Option, error) {
+
+	var opts []client.Option
+	if cfg.Token != "" {
+		opts = append(opts, client.WithToken(cfg.Token))
+	}
+
+	encryption, err := cfg.encryption()
+	if err != nil {
+		return nil, err
+	}
+	if encryption != nil {
+		opts = append(opts, client.WithEncryption(encryption))
+	}
+
+	if !cfg.TLS && cfg.CA == "" {
+		return opts, nil
+	}
+
+	tlsConfig, err := httpLib.TLSConfig{CAFile: cfg.CA}.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
+
+	return append(opts,
+		client.WithTLS(),
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+		client.WithDialer(&dialer),
+	), nil
+}
+
+// encryption returns the client-side encryption configured with the key file or the passphrase
+func (cfg *clientConfig) encryption() (*client.Encryption, error) {
+
+	switch {
+	case cfg.KeyFile != "" && cfg.Passphrase != "":
+		return nil, errors.New("either the key file or the passphrase can be configured")
+	case cfg.KeyFile != "":
+		key, err := readSecret(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		return client.KeyEncryption(key)
+	case cfg.Passphrase != "":
+		return client.PassphraseEncryption(cfg.Passphrase)
+	default:
+		return nil, nil
+	}
+}
+
+// readSecret reads the key or the passphrase from the file without the trailing line break
+func readSecret(fileName string) ([]byte, error) {
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(data, "\r\n"), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"node-test/pkg/client"
+)
+
+func runDownload(ctx context.Context, cl *cli, args []string) error {
+
+	flags := flag.NewFlagSet("download", flag.ContinueOnError)
+	parallel := flags.Int("parallel", 1, "count of concurrent connections per file")
+	output := flags.String("out", "", "output file, or directory with -r, the uploaded file name by default")
+	recursive := flags.Bool("r", false, "download all files which names start with the prefix")
+	bucket := flags.String("bucket", "", "download files of the bucket with -r")
+
+	if err := flags.Parse(args); err != nil {
+		return &usageError{message: err.Error()}
+	}
+	if flags.NArg() != 1 || *parallel < 1 || (*bucket != "" && !*recursive) {
+		return &usageError{message: usages["download"]}
+	}
+
+	if *recursive {
+		return cl.downloadPrefix(ctx, client.ListOptions{Bucket: *bucket, Prefix: flags.Arg(0)}, *output, *parallel)
+	}
+
+	return cl.download(ctx, flags.Arg(0), *output, *parallel)
+}
+
+func (cl *cli) download(ctx context.Context, ref, output string, parallel int) error {
+
+	file, err := cl.stat(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = filepath.Base(file.Name)
+	}
+
+	bar := cl.progress("download", file.ContentSize())
+	bar.Start()
+	defer bar.Stop()
+
+	return cl.downloadFile(ctx, file, output, parallel, bar)
+}
+
+// downloadPrefix downloads all files which names start with the prefix
+// and recreates their directory tree inside the output directory
+func (cl *cli) downloadPrefix(ctx context.Context, opts client.ListOptions, output string, parallel int) error {
+
+	if output == "" {
+		output = "."
+	}
+
+	files, err := cl.client.ListAll(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.ContentSize()
+	}
+
+	bar := cl.progress("download", totalSize)
+	bar.Start()
+	defer bar.Stop()
+
+	for _, file := range files {
+		target, err := localPath(output, file.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		if err := cl.downloadFile(ctx, file, target, parallel, bar); err != nil {
+			return fmt.Errorf("download %v: %w", file.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// downloadFile downloads the single file through parallel connections
+func (cl *cli) downloadFile(ctx context.Context, file *client.File, output string, parallel int, bar *progress) error {
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(file.ContentSize()); err != nil {
+		return err
+	}
+
+	_, err = cl.client.DownloadAt(ctx, file.ID, f, client.DownloadOptions{
+		Parallel: parallel,
+		Progress: bar.Add,
+	})
+
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localFile is the file to upload and its name in the storage
+type localFile struct {
+	path string
+	name string
+	size int64
+}
+
+// collectFiles resolves the file, the directory or the glob pattern into the list of files to upload.
+// Directories are walked recursively and their files keep the path relative to the directory parent.
+func collectFiles(pattern, prefix string) ([]*localFile, error) {
+
+	matches := []string{pattern}
+	if _, err := os.Stat(pattern); errors.Is(err, fs.ErrNotExist) {
+		matches, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %v: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %v", pattern)
+		}
+	}
+
+	var files []*localFile
+	for _, match := range matches {
+		root := filepath.Clean(match)
+		base := filepath.Dir(root)
+
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+
+			files = append(files, &localFile{
+				path: p,
+				name: path.Join(prefix, filepath.ToSlash(rel)),
+				size: info.Size(),
+			})
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// localPath converts the storage name into the path inside dir rejecting names which escape it
+func localPath(dir, name string) (string, error) {
+
+	clean := path.Clean("/" + name)
+	if clean == "/" || strings.Contains(name, "..") {
+		return "", fmt.Errorf("unsafe file name %q", name)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(clean[1:])), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTestFiles creates files of the names with the content of their name in dir
+func writeTestFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "docs/a.txt", "docs/b.log", "docs/sub/c.txt", "d.txt")
+
+	tests := []struct {
+		name    string
+		pattern string
+		prefix  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "file", pattern: "d.txt", want: []string{"d.txt"}},
+		{name: "file with prefix", pattern: "d.txt", prefix: "backup", want: []string{"backup/d.txt"}},
+		{name: "directory", pattern: "docs", want: []string{"docs/a.txt", "docs/b.log", "docs/sub/c.txt"}},
+		{name: "glob", pattern: "docs/*.txt", want: []string{"a.txt"}},
+		{name: "glob of directories", pattern: "doc*", prefix: "x", want: []string{"x/docs/a.txt", "x/docs/b.log", "x/docs/sub/c.txt"}},
+		{name: "no matches", pattern: "*.bin", wantErr: true},
+		{name: "bad pattern", pattern: "[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := collectFiles(filepath.Join(dir, tt.pattern), tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("collect files error = %v, want error %v", err, tt.wantErr)
+			}
+
+			var names []string
+			for _, f := range files {
+				names = append(names, f.name)
+				// every file contains its path relative to dir
+				rel, err := filepath.Rel(dir, f.path)
+				if err != nil || f.size != int64(len(rel)) {
+					t.Fatalf("size of %v = %v, want %v", f.path, f.size, len(rel))
+				}
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.want) {
+				t.Fatalf("names = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "a.txt", want: "a.txt"},
+		{name: "docs/a.txt", want: "docs/a.txt"},
+		{name: "/docs//a.txt", want: "docs/a.txt"},
+		{name: "../a.txt", wantErr: true},
+		{name: "docs/../../a.txt", wantErr: true},
+		{name: "/", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := localPath("out", tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("local path error = %v, want error %v", err, tt.wantErr)
+			}
+			if want := filepath.Join("out", filepath.FromSlash(tt.want)); !tt.wantErr && got != want {
+				t.Fatalf("local path = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestManifestWrite(t *testing.T) {
+
+	m := &manifest{}
+	m.add(&manifestEntry{Path: "a.txt", Name: "a.txt", UploadID: "1", Size: 10, SHA256: "aa"})
+	m.add(&manifestEntry{Path: "b.txt", Bucket: "docs", Name: "b.txt", UploadID: "2", Size: 5, SHA256: "bb"})
+	if m.TotalSize != 15 {
+		t.Fatalf("total size = %v, want 15", m.TotalSize)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "manifest.json")
+	if err := m.write(fileName); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var read manifest
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if read.TotalSize != m.TotalSize || len(read.Files) != 2 || *read.Files[1] != *m.Files[1] {
+		t.Fatalf("read manifest = %+v, want %+v", read, m)
+	}
+}
@@ -1,105 +1,203 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
-	"io"
-	"log"
-	"net/url"
+	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/websocket"
-
-	"node-test/internal/common/http"
+	"node-test/pkg/client"
+	"node-test/pkg/tracing"
 )
 
 const (
-	chunkSize = 50 * 1024 //50KB
+	exitOK             = 0
+	exitError          = 1
+	exitUsage          = 2
+	exitNotFound       = 3
+	exitVerifyMismatch = 4
+	exitUnauthorized   = 5
+	exitQuotaExceeded  = 6
+
+	// tracingFlushTimeout limits the export of spans of the command
+	tracingFlushTimeout = 5 * time.Second
 )
 
+var usages = map[string]string{
+	"upload":   "upload [-parallel n] [-bucket b] [-prefix p] [-ttl d] [-chunk-size n] [-compress codec] [-manifest file] <file|dir|glob>...",
+	"download": "download [-parallel n] [-out path] [-r [-bucket b]] <id|bucket/key|prefix>",
+	"ls":       "ls [-limit n] [-offset n] [-all] [-bucket b] [prefix]",
+	"stat":     "stat <id|bucket/key>...",
+	"rm":       "rm <id|bucket/key>...",
+	"versions": "versions <bucket/key>",
+	"restore":  "restore <version id>",
+	"share":    "share <id|bucket/key> private|public|shared [principal...]",
+	"cat":      "cat <id|bucket/key>",
+	"verify":   "verify <id|bucket/key>...",
+	"quota":    "quota [-max-bytes n] [-max-files n] [user|tenant|bucket <name>]",
+	"bucket":   "bucket create|set [-replicas n] [-versioning] [-max-versions n] [-retention d] [-expire-after d] [-noncurrent-expire-after d] [-chunk-size n] [-compression codec] [-max-bytes n] [-max-files n] [-access a] [-share p,...] <name> | bucket ls | bucket info|rm <name>",
+	"nodes":    "nodes",
+	"dedup":    "dedup [id|bucket/key]",
+}
+
+var commands = map[string]func(ctx context.Context, cl *cli, args []string) error{
+	"upload":   runUpload,
+	"download": runDownload,
+	"ls":       runList,
+	"stat":     runStat,
+	"rm":       runRemove,
+	"versions": runVersions,
+	"restore":  runRestore,
+	"share":    runShare,
+	"cat":      runCat,
+	"verify":   runVerify,
+	"quota":    runQuota,
+	"bucket":   runBucket,
+	"nodes":    runNodes,
+	"dedup":    runDedup,
+}
+
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
 
-	actionName := flag.String("action", "", "action name [upload,download]")
-	objName := flag.String("obj", "", "file name or id")
-	host := flag.String("host", "127.0.0.1:8080", "master server")
+	flags := flag.NewFlagSet("master-client", flag.ContinueOnError)
+	flags.Usage = func() { usage(flags) }
 
-	flag.Parse()
+	configName := flags.String("config", "", "client config file, $"+envPrefix+"_CONFIG or ~/"+defaultConfigName+" by default")
+	host := flags.String("host", "", "master server, "+defaultHost+" by default")
+	token := flags.String("token", "", "API key or JWT, $"+envPrefix+"_TOKEN by default")
+	secure := flags.Bool("tls", false, "connect to the master over https and wss")
+	caFile := flags.String("ca", "", "CA which signs the master certificate, implies -tls")
+	keyFile := flags.String("keyfile", "", "encrypt uploads and decrypt downloads with the key of at least 32 bytes from the file")
+	passphraseFile := flags.String("passphrase-file", "", "encrypt uploads and decrypt downloads with the passphrase from the file, $"+envPrefix+"_PASSPHRASE by default")
+	jsonOutput := flags.Bool("json", false, "print results as json")
+	quiet := flags.Bool("quiet", false, "don't render the progress bar")
+	traceEndpoint := flags.String("trace", "", "export spans of the command to the local OTLP gRPC collector, e.g. localhost:4317")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
 
-	if actionName == nil {
-		panic(errors.New("action hasn't been provided"))
+	if flags.NArg() == 0 {
+		usage(flags)
+		return exitUsage
 	}
 
-	if objName == nil {
-		panic(errors.New("object hasn't been provided"))
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flags.Arg(0))
+		usage(flags)
+		return exitUsage
 	}
 
-	switch *actionName {
-	case "upload":
-		err := upload(*objName, *host)
+	cfg, err := loadConfig(*configName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load config:", err)
+		return exitUsage
+	}
+	if *host != "" {
+		cfg.Host = *host
+	}
+	if *token != "" {
+		cfg.Token = *token
+	}
+	if *secure {
+		cfg.TLS = true
+	}
+	if *caFile != "" {
+		cfg.CA = *caFile
+	}
+	if *keyFile != "" {
+		cfg.KeyFile, cfg.Passphrase = *keyFile, ""
+	}
+	if *passphraseFile != "" {
+		passphrase, err := readSecret(*passphraseFile)
 		if err != nil {
-			log.Fatal(err)
+			fmt.Fprintln(os.Stderr, "read passphrase:", err)
+			return exitUsage
 		}
-	case "download":
-		download(*objName, *host)
-	default:
-		panic(errors.New("unregistered action name"))
-
+		cfg.KeyFile, cfg.Passphrase = "", string(passphrase)
 	}
 
-}
+	opts, err := cfg.options()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "configure client:", err)
+		return exitUsage
+	}
 
-func upload(fileName, host string) error {
+	cl := &cli{
+		client: client.New(cfg.Host, opts...),
+		json:   *jsonOutput,
+		quiet:  *quiet,
+		out:    os.Stdout,
+	}
 
-	log.Println("uploading file")
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	u := url.URL{Scheme: "ws", Host: host, Path: "/api/v1/storage/ws/upload"}
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	shutdownTracing, err := tracing.Setup(ctx, "master-client", tracing.Config{Endpoint: *traceEndpoint, Insecure: true})
 	if err != nil {
-		log.Fatal("dial:", err)
+		fmt.Fprintln(os.Stderr, "configure tracing:", err)
+		return exitUsage
 	}
-	defer conn.Close()
-
-	f, err := os.Open(fileName)
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer flushCancel()
+		_ = shutdownTracing(flushCtx)
+	}()
+
+	// requests of the command continue its trace on the master and nodes
+	ctx, span := tracing.Start(ctx, "master-client "+flags.Arg(0))
+	err = cmd(ctx, cl, flags.Args()[1:])
+	tracing.End(span, err)
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitCode(err)
 	}
 
-	stat, err := f.Stat()
-	if err != nil {
-		return err
+	return exitOK
+}
+
+func exitCode(err error) int {
+
+	switch {
+	case errors.As(err, new(*usageError)):
+		return exitUsage
+	case errors.Is(err, client.ErrChecksumMismatch), errors.Is(err, client.ErrDecrypt):
+		return exitVerifyMismatch
+	case errors.Is(err, client.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, client.ErrUnauthorized):
+		return exitUnauthorized
+	case errors.Is(err, client.ErrQuotaExceeded):
+		return exitQuotaExceeded
+	default:
+		return exitError
 	}
+}
+
+func usage(flags *flag.FlagSet) {
+
+	fmt.Fprintln(os.Stderr, "usage: master-client [flags] <command> [command flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
 
-	metadata := &http.ChunkMetadata{
-		TotalFileSize: stat.Size(),
-		Filename:      fileName,
+	names := make([]string, 0, len(usages))
+	for name := range usages {
+		names = append(names, name)
 	}
-	err = conn.WriteJSON(metadata)
-	if err != nil {
-		return err
-	}
-
-	buf := make([]byte, 0, chunkSize)
-	for {
-		n, err := f.Read(buf[:cap(buf)])
-		buf = buf[:n]
-		if n == 0 {
-			if err == nil {
-				log.Println(err)
-				continue
-			}
-			if err == io.EOF {
-				break
-			}
-		}
-		err = conn.WriteMessage(websocket.BinaryMessage, buf)
-		if err != nil {
-			log.Println(err)
-			break
-		}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+usages[name])
 	}
 
-	return nil
-
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	flags.PrintDefaults()
 }
-
-func download(fileName, host string) {}
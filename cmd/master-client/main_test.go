@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	commonErrors "node-test/internal/common/errors"
+	commonHttp "node-test/internal/common/http"
+	"node-test/internal/domain"
+	"node-test/pkg/client"
+)
+
+// newTestMaster starts the master which serves files by their ids, ids of failures are named after error codes
+func newTestMaster(t *testing.T) string {
+	t.Helper()
+
+	statuses := map[domain.ErrorCode]int{
+		domain.ErrorCodeNotFound:       http.StatusNotFound,
+		domain.ErrorCodeUnauthorized:   http.StatusUnauthorized,
+		domain.ErrorCodeQuotaExceeded:  http.StatusRequestEntityTooLarge,
+		domain.ErrorCodeInvalidRequest: http.StatusBadRequest,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id := path.Base(r.URL.Path)
+		if status, ok := statuses[domain.ErrorCode(id)]; ok {
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(commonErrors.ErrorResponse{Code: domain.ErrorCode(id), Message: id})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(commonHttp.FileInfo{UploadID: id, Filename: id + ".txt", Status: "committed"})
+	}))
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestExitCode(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "usage", err: &usageError{message: "ls"}, want: exitUsage},
+		{name: "not found", err: &client.Error{Code: client.CodeNotFound}, want: exitNotFound},
+		{name: "wrapped not found", err: fmt.Errorf("remove a: %w", &client.Error{StatusCode: http.StatusNotFound}), want: exitNotFound},
+		{name: "unauthorized", err: &client.Error{Code: client.CodeUnauthorized}, want: exitUnauthorized},
+		{name: "forbidden", err: &client.Error{Code: client.CodeForbidden}, want: exitUnauthorized},
+		{name: "quota exceeded", err: &client.Error{Code: client.CodeQuotaExceeded}, want: exitQuotaExceeded},
+		{name: "checksum mismatch", err: client.ErrChecksumMismatch, want: exitVerifyMismatch},
+		{name: "decrypt", err: fmt.Errorf("download: %w", client.ErrDecrypt), want: exitVerifyMismatch},
+		{name: "other", err: errors.New("failure"), want: exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Fatalf("exitCode(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	host := newTestMaster(t)
+	configName := filepath.Join(t.TempDir(), "client.yaml")
+	if err := os.WriteFile(configName, []byte("host: "+host+"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "stat", args: []string{"stat", "a1"}, want: exitOK},
+		{name: "not found", args: []string{"stat", "a1", string(domain.ErrorCodeNotFound)}, want: exitNotFound},
+		{name: "unauthorized", args: []string{"stat", string(domain.ErrorCodeUnauthorized)}, want: exitUnauthorized},
+		{name: "quota exceeded", args: []string{"stat", string(domain.ErrorCodeQuotaExceeded)}, want: exitQuotaExceeded},
+		{name: "rejected request", args: []string{"stat", string(domain.ErrorCodeInvalidRequest)}, want: exitError},
+		{name: "no command", want: exitUsage},
+		{name: "unknown command", args: []string{"mv", "a1"}, want: exitUsage},
+		{name: "unknown flag", args: []string{"-verbose", "stat", "a1"}, want: exitUsage},
+		{name: "command without arguments", args: []string{"stat"}, want: exitUsage},
+		{name: "missing config", args: []string{"-config", configName + ".missing", "stat", "a1"}, want: exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-config", configName, "-quiet", "-json"}, tt.args...)
+			if got := run(args); got != tt.want {
+				t.Fatalf("run(%v) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+type (
+	// manifest describes the files uploaded by the single run of the client
+	manifest struct {
+		CreatedAt time.Time        `json:"created_at"`
+		TotalSize int64            `json:"total_size"`
+		Files     []*manifestEntry `json:"files"`
+	}
+
+	manifestEntry struct {
+		Path     string `json:"path"`
+		Bucket   string `json:"bucket,omitempty"`
+		Name     string `json:"name"`
+		UploadID string `json:"upload_id"`
+		Size     int64  `json:"size"`
+		SHA256   string `json:"sha256"`
+	}
+)
+
+func (m *manifest) add(entry *manifestEntry) {
+	m.Files = append(m.Files, entry)
+	m.TotalSize += entry.Size
+}
+
+// write writes the manifest to the file
+func (m *manifest) write(fileName string) error {
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(m)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"node-test/pkg/client"
+)
+
+func (cl *cli) printJSON(v interface{}) error {
+	enc := json.NewEncoder(cl.out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// printResult prints v in the json mode and the text otherwise
+func (cl *cli) printResult(v interface{}, text string) error {
+	if cl.json {
+		return cl.printJSON(v)
+	}
+
+	_, err := fmt.Fprintln(cl.out, text)
+
+	return err
+}
+
+func (cl *cli) printFiles(list *client.FileList) error {
+
+	if cl.json {
+		return cl.printJSON(list)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSIZE\tCOMMITTED\tNAME")
+	for _, file := range list.Files {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", file.ID, file.ContentSize(), file.CommittedAt.Format(time.RFC3339), file.Name)
+	}
+	if list.NextOffset > 0 {
+		fmt.Fprintf(tw, "more files: -offset %d\n", list.NextOffset)
+	}
+
+	return tw.Flush()
+}
+
+func (cl *cli) printVersions(versions []*client.File) error {
+
+	if cl.json {
+		return cl.printJSON(versions)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSIZE\tCOMMITTED\tOWNER\tSTATE")
+	for _, version := range versions {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n",
+			version.ID, version.ContentSize(), version.CommittedAt.Format(time.RFC3339), version.Owner, versionState(version))
+	}
+
+	return tw.Flush()
+}
+
+// versionState describes the version of the bucket key
+func versionState(file *client.File) string {
+	switch {
+	case file.DeleteMarker:
+		return "delete marker"
+	case file.Latest:
+		return "latest"
+	default:
+		return "noncurrent"
+	}
+}
+
+func (cl *cli) printFile(file *client.File) error {
+
+	if cl.json {
+		return cl.printJSON(file)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "id:\t%s\n", file.ID)
+	if file.Bucket != "" {
+		fmt.Fprintf(tw, "bucket:\t%s\n", file.Bucket)
+		fmt.Fprintf(tw, "version:\t%s\n", versionState(file))
+	}
+	fmt.Fprintf(tw, "name:\t%s\n", file.Name)
+	fmt.Fprintf(tw, "size:\t%d\n", file.ContentSize())
+	if file.Encrypted() {
+		fmt.Fprintf(tw, "encryption:\tclient, %d bytes stored\n", file.Size)
+	}
+	if file.Chunking == client.ChunkingCDC {
+		fmt.Fprintf(tw, "chunks:\t%d content defined, up to %d\n", file.TotalChunks, file.ChunkSize)
+	} else {
+		fmt.Fprintf(tw, "chunks:\t%d x %d\n", file.TotalChunks, file.ChunkSize)
+	}
+	if file.Compression != "" {
+		fmt.Fprintf(tw, "compression:\t%s\n", file.Compression)
+	}
+	fmt.Fprintf(tw, "sha256:\t%s\n", file.Checksum)
+	fmt.Fprintf(tw, "status:\t%s\n", file.Status)
+	fmt.Fprintf(tw, "owner:\t%s\n", file.Owner)
+	fmt.Fprintf(tw, "access:\t%s\n", file.Access)
+	if len(file.SharedWith) > 0 {
+		fmt.Fprintf(tw, "shared with:\t%s\n", strings.Join(file.SharedWith, ", "))
+	}
+	fmt.Fprintf(tw, "created:\t%s\n", file.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "committed:\t%s\n", file.CommittedAt.Format(time.RFC3339))
+	if !file.ExpiresAt.IsZero() {
+		fmt.Fprintf(tw, "expires:\t%s\n", file.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return tw.Flush()
+}
+
+func (cl *cli) printManifest(m *manifest) error {
+
+	if cl.json {
+		return cl.printJSON(m)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSIZE\tNAME")
+	for _, entry := range m.Files {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", entry.UploadID, entry.Size, entry.Name)
+	}
+
+	return tw.Flush()
+}
+
+func (cl *cli) printBuckets(buckets []*client.Bucket) error {
+
+	if cl.json {
+		return cl.printJSON(buckets)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tOWNER\tREPLICAS\tVERSIONING\tRETENTION\tMAX BYTES\tMAX FILES\tACCESS")
+	for _, bucket := range buckets {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%t\t%s\t%s\t%s\t%s\n",
+			bucket.Name, bucket.Owner, bucket.Replicas, bucket.Versioning, bucket.Retention,
+			limitOf(bucket.MaxBytes), limitOf(bucket.MaxFiles), bucket.DefaultAccess)
+	}
+
+	return tw.Flush()
+}
+
+func (cl *cli) printBucket(bucket *client.Bucket) error {
+
+	if cl.json {
+		return cl.printJSON(bucket)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "name:\t%s\n", bucket.Name)
+	fmt.Fprintf(tw, "owner:\t%s\n", bucket.Owner)
+	if bucket.Tenant != "" {
+		fmt.Fprintf(tw, "tenant:\t%s\n", bucket.Tenant)
+	}
+	fmt.Fprintf(tw, "replicas:\t%d\n", bucket.Replicas)
+	fmt.Fprintf(tw, "versioning:\t%t\n", bucket.Versioning)
+	if bucket.Versioning {
+		fmt.Fprintf(tw, "max versions:\t%s\n", limitOf(int64(bucket.MaxVersions)))
+	}
+	fmt.Fprintf(tw, "retention:\t%s\n", bucket.Retention)
+	if bucket.ExpireAfter > 0 {
+		fmt.Fprintf(tw, "expire after:\t%s\n", bucket.ExpireAfter)
+	}
+	if bucket.NoncurrentExpireAfter > 0 {
+		fmt.Fprintf(tw, "noncurrent expire after:\t%s\n", bucket.NoncurrentExpireAfter)
+	}
+	if bucket.ChunkSize > 0 {
+		fmt.Fprintf(tw, "chunk size:\t%d\n", bucket.ChunkSize)
+	}
+	if bucket.Compression != "" {
+		fmt.Fprintf(tw, "compression:\t%s\n", bucket.Compression)
+	}
+	fmt.Fprintf(tw, "max bytes:\t%s\n", limitOf(bucket.MaxBytes))
+	fmt.Fprintf(tw, "max files:\t%s\n", limitOf(bucket.MaxFiles))
+	fmt.Fprintf(tw, "default access:\t%s\n", bucket.DefaultAccess)
+	if len(bucket.DefaultSharedWith) > 0 {
+		fmt.Fprintf(tw, "shared with:\t%s\n", strings.Join(bucket.DefaultSharedWith, ", "))
+	}
+	fmt.Fprintf(tw, "created:\t%s\n", bucket.CreatedAt.Format(time.RFC3339))
+
+	return tw.Flush()
+}
+
+func (cl *cli) printQuotas(quotas []*client.Quota) error {
+
+	if cl.json {
+		return cl.printJSON(quotas)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCOPE\tNAME\tBYTES\tFILES\tLIMITS")
+	for _, quota := range quotas {
+		limits := "default"
+		if quota.Custom {
+			limits = "custom"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			quota.Scope, quota.Name, usageOf(quota.UsedBytes, quota.MaxBytes), usageOf(quota.UsedFiles, quota.MaxFiles), limits)
+	}
+
+	return tw.Flush()
+}
+
+// limitOf formats the limit, zero limit means no limit
+func limitOf(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+
+	return fmt.Sprintf("%d", limit)
+}
+
+// usageOf formats the usage with its limit, zero limit means no limit
+func usageOf(used, limit int64) string {
+	if limit == 0 {
+		return fmt.Sprintf("%d/unlimited", used)
+	}
+
+	return fmt.Sprintf("%d/%d", used, limit)
+}
+
+func (cl *cli) printNodes(nodes []*client.Node) error {
+
+	if cl.json {
+		return cl.printJSON(nodes)
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tAVAILABLE\tREADY\tSIZE\tUSED\tFREE\tQUEUED\tRUNNING\tWAIT\tRUN\tERROR")
+	for _, node := range nodes {
+		fmt.Fprintf(tw, "%s\t%t\t%t\t%d\t%d\t%d\t%d\t%d/%d\t%s\t%s\t%s\n",
+			node.Address, node.Available, node.Ready, node.Size, node.Used, node.Free, node.Queue.Queued,
+			node.Queue.Running, node.Queue.Workers, node.Queue.WaitLatency, node.Queue.RunLatency, node.Error)
+	}
+
+	return tw.Flush()
+}
+
+func (cl *cli) printDedup(stats *client.DedupStats) error {
+
+	if cl.json {
+		return cl.printJSON(stats)
+	}
+
+	scope := stats.UploadID
+	if scope == "" {
+		scope = "all files"
+	}
+
+	tw := tabwriter.NewWriter(cl.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tLOGICAL\tSTORED\tRATIO\tCOMPRESSED\tCOMPRESSION")
+	fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%d\t%.1f%%\n", scope, stats.LogicalBytes, stats.StoredBytes, stats.Ratio*100,
+		stats.CompressedBytes, stats.CompressionRatio*100)
+
+	return tw.Flush()
+}
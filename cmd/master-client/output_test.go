@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"node-test/pkg/client"
+)
+
+func TestPrintJSON(t *testing.T) {
+
+	file := &client.File{ID: "a1", Bucket: "docs", Name: "a.txt", Size: 10, Status: "committed", Latest: true, CommittedAt: time.Unix(100, 0).UTC()}
+	files := &client.FileList{Files: []*client.File{file}, NextOffset: 1}
+	versions := []*client.File{file, {ID: "a0", Bucket: "docs", Name: "a.txt", DeleteMarker: true}}
+	result := map[string]string{"id": "a1"}
+
+	tests := []struct {
+		name  string
+		print func(cl *cli) error
+		want  interface{}
+	}{
+		{name: "file", print: func(cl *cli) error { return cl.printFile(file) }, want: file},
+		{name: "files", print: func(cl *cli) error { return cl.printFiles(files) }, want: files},
+		{name: "versions", print: func(cl *cli) error { return cl.printVersions(versions) }, want: versions},
+		{name: "result", print: func(cl *cli) error { return cl.printResult(result, "removed a1") }, want: result},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tt.print(&cli{json: true, out: &out}); err != nil {
+				t.Fatalf("print: %v", err)
+			}
+
+			got := decodeJSON(t, out.Bytes())
+			want, err := json.Marshal(tt.want)
+			if err != nil {
+				t.Fatalf("encode %v: %v", tt.want, err)
+			}
+			if !reflect.DeepEqual(got, decodeJSON(t, want)) {
+				t.Fatalf("printed %s, want %s", out.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestPrintText(t *testing.T) {
+
+	var out bytes.Buffer
+	cl := &cli{out: &out}
+
+	if err := cl.printResult(map[string]string{"id": "a1"}, "removed a1"); err != nil {
+		t.Fatalf("print result: %v", err)
+	}
+	if got := out.String(); got != "removed a1\n" {
+		t.Fatalf("printed %q, want the text", got)
+	}
+
+	out.Reset()
+	if err := cl.printFiles(&client.FileList{Files: []*client.File{{ID: "a1", Name: "a.txt"}}, NextOffset: 100}); err != nil {
+		t.Fatalf("print files: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[2], "-offset 100") {
+		t.Fatalf("printed files %q, want the header, the file and the next offset", lines)
+	}
+}
+
+// decodeJSON decodes the json output into generic values
+func decodeJSON(t *testing.T, data []byte) interface{} {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("output %q isn't json: %v", data, err)
+	}
+
+	return v
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressWidth    = 30
+	progressInterval = 200 * time.Millisecond
+)
+
+// progress renders the progress bar with throughput and ETA to stderr
+type progress struct {
+	label   string
+	total   int64
+	hidden  bool
+	done    atomic.Int64
+	started time.Time
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newProgress(label string, total int64) *progress {
+	return &progress{
+		label: label,
+		total: total,
+		stop:  make(chan struct{}),
+	}
+}
+
+func (p *progress) Start() {
+
+	p.started = time.Now()
+	if p.hidden {
+		return
+	}
+
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				p.render()
+				fmt.Fprintln(os.Stderr)
+				return
+			case <-ticker.C:
+				p.render()
+			}
+		}
+	}()
+}
+
+func (p *progress) Add(n int64) {
+	p.done.Add(n)
+}
+
+func (p *progress) Stop() {
+	if p.hidden {
+		return
+	}
+
+	close(p.stop)
+	p.wg.Wait()
+}
+
+func (p *progress) render() {
+
+	var (
+		done    = p.done.Load()
+		elapsed = time.Since(p.started).Seconds()
+		ratio   = 1.0
+		speed   float64
+		eta     = "--:--"
+	)
+
+	if p.total > 0 {
+		ratio = float64(done) / float64(p.total)
+	}
+	if elapsed > 0 {
+		speed = float64(done) / elapsed
+	}
+	if speed > 0 {
+		left := time.Duration(float64(p.total-done) / speed * float64(time.Second))
+		eta = fmt.Sprintf("%02d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+	}
+
+	filled := int(ratio * progressWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+
+	fmt.Fprintf(os.Stderr, "\r%s [%s] %5.1f%% %s/s ETA %s", p.label, bar, ratio*100, formatBytes(speed), eta)
+}
+
+func formatBytes(n float64) string {
+	const unit = 1024
+
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= unit && i < len(units)-1 {
+		n /= unit
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
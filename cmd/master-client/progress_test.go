@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestFormatBytes(t *testing.T) {
+
+	tests := []struct {
+		n    float64
+		want string
+	}{
+		{n: 0, want: "0.0 B"},
+		{n: 1023, want: "1023.0 B"},
+		{n: 1024, want: "1.0 KB"},
+		{n: 1536, want: "1.5 KB"},
+		{n: 5 << 20, want: "5.0 MB"},
+		{n: 3 << 30, want: "3.0 GB"},
+		{n: 2048 << 40, want: "2048.0 TB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Fatalf("formatBytes(%v) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
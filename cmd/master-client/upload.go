@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"node-test/pkg/client"
+)
+
+func runUpload(ctx context.Context, cl *cli, args []string) error {
+
+	flags := flag.NewFlagSet("upload", flag.ContinueOnError)
+	parallel := flags.Int("parallel", 1, "count of concurrent connections per file")
+	prefix := flags.String("prefix", "", "storage path prefix prepended to names of uploaded files")
+	bucket := flags.String("bucket", "", "bucket which keeps uploaded files with their names as keys")
+	manifestName := flags.String("manifest", "", "file to write the upload manifest")
+	ttl := flags.Duration("ttl", 0, "remove uploaded files when the time elapses, 0 keeps them")
+	chunkSize := flags.Int64("chunk-size", 0, "requested size of chunks in bytes, the bucket or master size by default")
+	compress := flags.String("compress", "", "codec of chunks: none, gzip, zstd or snappy, the bucket or master codec by default")
+
+	if err := flags.Parse(args); err != nil {
+		return &usageError{message: err.Error()}
+	}
+	if flags.NArg() == 0 || *parallel < 1 || *ttl < 0 || *chunkSize < 0 {
+		return &usageError{message: usages["upload"]}
+	}
+
+	var files []*localFile
+	for _, pattern := range flags.Args() {
+		matched, err := collectFiles(pattern, *prefix)
+		if err != nil {
+			return err
+		}
+		files = append(files, matched...)
+	}
+
+	result, err := cl.upload(ctx, files, client.UploadOptions{Bucket: *bucket, TTL: *ttl, ChunkSize: *chunkSize, Compression: *compress, Parallel: *parallel})
+	if err != nil {
+		return err
+	}
+
+	if *manifestName != "" {
+		if err := result.write(*manifestName); err != nil {
+			return fmt.Errorf("write manifest: %w", err)
+		}
+	}
+
+	return cl.printManifest(result)
+}
+
+// upload uploads files one by one with the options and returns the manifest of uploaded files
+func (cl *cli) upload(ctx context.Context, files []*localFile, opts client.UploadOptions) (*manifest, error) {
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.size
+	}
+
+	bar := cl.progress("upload", totalSize)
+	bar.Start()
+	defer bar.Stop()
+
+	result := &manifest{CreatedAt: time.Now().UTC()}
+	for _, file := range files {
+		uploaded, err := cl.uploadFile(ctx, file, opts, bar)
+		if err != nil {
+			return nil, fmt.Errorf("upload %v: %w", file.path, err)
+		}
+
+		result.add(&manifestEntry{
+			Path:     file.path,
+			Bucket:   uploaded.Bucket,
+			Name:     uploaded.Name,
+			UploadID: uploaded.ID,
+			Size:     uploaded.ContentSize(),
+			SHA256:   uploaded.Checksum,
+		})
+	}
+
+	return result, nil
+}
+
+func (cl *cli) uploadFile(ctx context.Context, file *localFile, opts client.UploadOptions, bar *progress) (*client.File, error) {
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	opts.Name = file.name
+	opts.Size = file.size
+	opts.Progress = bar.Add
+
+	return cl.client.UploadAt(ctx, f, opts)
+}
@@ -2,23 +2,35 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tel-io/tel/v2"
 	"go.uber.org/zap"
 
+	"node-test/internal/common/encryption"
 	"node-test/internal/common/pool"
+	"node-test/internal/domain"
 	"node-test/internal/gateway"
 	"node-test/internal/master/config"
 	masterRoutes "node-test/internal/master/handler/rest"
+	"node-test/internal/master/repository"
 	"node-test/internal/master/service"
 	"node-test/pkg/http"
+	"node-test/pkg/mongodb"
+	"node-test/pkg/tracing"
 )
 
 const (
 	appGracefulTimeout = 30 * time.Second
+	// tracingFlushTimeout limits the export of spans left at the exit
+	tracingFlushTimeout = 5 * time.Second
+	// uploadDrainTimeout is the part of the graceful timeout given to open uploads,
+	// the rest is left to store their chunks which are sent to nodes
+	uploadDrainTimeout = 20 * time.Second
 )
 
 func main() {
@@ -37,22 +49,130 @@ func main() {
 		return
 	}
 
-	pool := pool.NewWorkerPool(cfg.FileStorage.WorkerCount)
-	pool.Start(ctx)
+	shutdownTracing, err := tracing.Setup(ctx, "master", cfg.Tracing.External())
+	if err != nil {
+		sugar.Error("initialize tracing", tel.Error(err))
+		return
+	}
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			sugar.Error("flush spans", tel.Error(err))
+		}
+	}()
+
+	mongoStorage, err := mongodb.NewStorage(ctx, cfg.Mongo.External())
+	if err != nil {
+		sugar.Error("initialize mongo connection", tel.Error(err))
+		return
+	}
+	defer mongoStorage.Close()
 
-	storageGateway, err := gateway.NewStorageNodeGateway(cfg.FileStorage, pool)
+	catalogRepository, err := repository.NewCatalogRepository(ctx, mongoStorage.DB)
+	if err != nil {
+		sugar.Error("initialize catalog repository", tel.Error(err))
+		return
+	}
+
+	// workers outlive the signal, they stop once chunks of drained uploads are stored
+	workerPool := pool.NewWorkerPool(cfg.FileStorage.WorkerCount, cfg.FileStorage.Workers(), cfg.FileStorage.QueueDepth)
+	workerPool.Start(context.Background())
+	prometheus.MustRegister(pool.NewCollector(workerPool))
+
+	storageGateway, err := gateway.NewStorageNodeGateway(cfg.FileStorage, workerPool)
 	if err != nil {
 		sugar.Error("storage gateway", tel.Error(err))
 		return
 	}
 
-	storageService := service.NewStorageService(sugar, storageGateway)
+	var keyring *encryption.Keyring
+	if cfg.Encryption.KeyFile != "" {
+		keyring, err = encryption.LoadKeyring(cfg.Encryption.KeyFile)
+		if err != nil {
+			sugar.Error("load keyring", tel.Error(err))
+			return
+		}
+
+		// data keys wrapped by the previous primary key are re-wrapped in background
+		keyService := service.NewKeyService(sugar, catalogRepository, keyring)
+		go func() {
+			rotated, err := keyService.Rotate(ctx)
+			if err != nil {
+				sugar.Error("rotate data keys", tel.Error(err))
+				return
+			}
+			sugar.Info("data keys rotated", tel.Int64("count", rotated))
+		}()
+	}
+
+	quotaRepository := repository.NewQuotaRepository(mongoStorage.DB)
+	bucketRepository := repository.NewBucketRepository(mongoStorage.DB)
+
+	quotaService := service.NewQuotaService(
+		sugar,
+		quotaRepository,
+		cfg.Quotas.Defaults(),
+	)
+
+	chunking, err := cfg.FileStorage.Chunking.Params()
+	if err != nil {
+		sugar.Error("chunking params", tel.Error(err))
+		return
+	}
+
+	storageService := service.NewStorageService(
+		sugar,
+		storageGateway,
+		catalogRepository,
+		bucketRepository,
+		quotaService,
+		service.StorageServiceOptions{
+			EncryptionMode: domain.EncryptionMode(cfg.Encryption.Mode),
+			Keyring:        keyring,
+			Dedup:          cfg.FileStorage.Deduplication,
+			ChunkSize:      cfg.FileStorage.ChunkSize,
+			Chunking:       chunking,
+			Compression:    cfg.FileStorage.Compression,
+			UploadWindow:   cfg.FileStorage.Flow.Window,
+			UploadMemory:   cfg.FileStorage.Flow.Memory,
+		},
+	)
+
+	// files which TTL has elapsed and expired versions are removed in background
+	lifecycleService := service.NewLifecycleService(
+		sugar,
+		storageGateway,
+		catalogRepository,
+		bucketRepository,
+		quotaService,
+		cfg.Lifecycle.Interval,
+		cfg.Lifecycle.Batch,
+	)
+	go lifecycleService.Run(ctx)
+
+	clusterService := service.NewClusterService(sugar, storageGateway, catalogRepository, cfg.FileStorage.MinReadyNodes)
+
+	bucketService := service.NewBucketService(sugar, bucketRepository, catalogRepository, quotaRepository, len(cfg.FileStorage.Nodes))
 
 	routes := masterRoutes.MakeRoutes(&masterRoutes.RouterDependencies{
+		Auth:           cfg.Auth,
 		StorageService: storageService,
+		ClusterService: clusterService,
+		QuotaService:   quotaService,
+		BucketService:  bucketService,
 	})
 
-	srv, err := http.NewEchoServer(ctx, cfg.Server.Port, routes, cancel)
+	var tlsConfig *tls.Config
+	if serverTLS := cfg.Server.TLS.External(); serverTLS.Enabled() {
+		tlsConfig, err = serverTLS.ServerConfig()
+		if err != nil {
+			sugar.Error("initialize tls", tel.Error(err))
+			return
+		}
+	}
+
+	srv, err := http.NewEchoServer(ctx, cfg.Server.Port, routes, tlsConfig, cancel)
 	if err != nil {
 		sugar.Error("initialize server", tel.Error(err))
 		return
@@ -68,6 +188,20 @@ func main() {
 	stopCtx, stopCancel := context.WithTimeout(context.Background(), appGracefulTimeout)
 	defer stopCancel()
 
+	// new sessions are rejected while open uploads finish, uploads left at the deadline
+	// are interrupted and clients resume them after the restart
+	drainCtx, drainCancel := context.WithTimeout(stopCtx, uploadDrainTimeout)
+	defer drainCancel()
+
+	if err := storageService.Shutdown(drainCtx); err != nil {
+		sugar.Error("drain uploads", tel.Error(err))
+	}
+
 	// stop http server
 	srv.Stop(stopCtx)
+
+	// chunks sent to nodes are recorded in the catalog before the mongo connection is closed
+	if err := workerPool.Shutdown(stopCtx); err != nil {
+		sugar.Error("drain worker pool", tel.Error(err))
+	}
 }
@@ -2,23 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tel-io/tel/v2"
 	"go.uber.org/zap"
 
+	"node-test/internal/common/encryption"
 	"node-test/internal/node/config"
 	"node-test/internal/node/handler/rest"
 	"node-test/internal/node/repository"
 	"node-test/internal/node/service"
 	"node-test/pkg/http"
 	"node-test/pkg/mongodb"
+	"node-test/pkg/tracing"
 )
 
 const (
 	appGracefulTimeout = 30 * time.Second
+	// tracingFlushTimeout limits the export of spans left at the exit
+	tracingFlushTimeout = 5 * time.Second
 )
 
 func main() {
@@ -37,6 +44,19 @@ func main() {
 		return
 	}
 
+	shutdownTracing, err := tracing.Setup(ctx, "node", cfg.Tracing.External())
+	if err != nil {
+		sugar.Error("initialize tracing", tel.Error(err))
+		return
+	}
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			sugar.Error("flush spans", tel.Error(err))
+		}
+	}()
+
 	mongoStorage, err := mongodb.NewStorage(ctx, cfg.Mongo.External())
 	if err != nil {
 		sugar.Error("initialize mongo connection", tel.Error(err))
@@ -50,13 +70,42 @@ func main() {
 		return
 	}
 
-	nodeService := service.NewNodeService(cfg, sugar, fsRepository)
+	var keyring *encryption.Keyring
+	if cfg.Encryption.KeyFile != "" {
+		keyring, err = encryption.LoadKeyring(cfg.Encryption.KeyFile)
+		if err != nil {
+			sugar.Error("load keyring", tel.Error(err))
+			return
+		}
+	}
+
+	nodeService := service.NewNodeService(cfg, sugar, fsRepository, keyring)
+	prometheus.MustRegister(service.NewStateCollector(nodeService))
+
+	if keyring != nil {
+		// data keys wrapped by the previous primary key are re-wrapped in background
+		go func() {
+			rotated, err := nodeService.RotateKeys(ctx)
+			if err != nil {
+				sugar.Error("rotate data keys", tel.Error(err))
+				return
+			}
+			sugar.Info("data keys rotated", tel.Int64("count", rotated))
+		}()
+	}
+
+	tlsConfig, err := serverTLS(cfg.Server.TLS.External())
+	if err != nil {
+		sugar.Error("initialize tls", tel.Error(err))
+		return
+	}
 
 	routes := rest.MakeRoutes(&rest.RouterDependencies{
-		NodeService: nodeService,
+		NodeService:       nodeService,
+		RequireClientCert: cfg.Server.TLS.CAFile != "",
 	})
 
-	srv, err := http.NewEchoServer(ctx, cfg.Server.Port, routes, cancel)
+	srv, err := http.NewEchoServer(ctx, cfg.Server.Port, routes, tlsConfig, cancel)
 	if err != nil {
 		sugar.Error("initialize server", tel.Error(err))
 		return
@@ -74,4 +123,21 @@ func main() {
 
 	// stop http server
 	srv.Stop(stopCtx)
+
+	// chunks which handlers still write are finished before the mongo connection is closed
+	if err := fsRepository.Close(stopCtx); err != nil {
+		sugar.Error("finish upload streams", tel.Error(err))
+	}
+}
+
+// serverTLS builds the TLS config of the server, nil means plain http
+func serverTLS(cfg http.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled() {
+		if cfg.CAFile != "" {
+			return nil, errors.New("the client CA requires the server certificate")
+		}
+		return nil, nil
+	}
+
+	return cfg.ServerConfig()
 }
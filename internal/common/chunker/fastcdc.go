@@ -0,0 +1,205 @@
+package chunker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+const (
+	// DefaultMinSize is the minimal size of chunks when it isn't configured.
+	DefaultMinSize = 16 << 10
+	// DefaultAvgSize is the average size of chunks when it isn't configured.
+	DefaultAvgSize = 64 << 10
+	// DefaultMaxSize is the maximal size of chunks when it isn't configured.
+	DefaultMaxSize = 256 << 10
+
+	// minSizeLimit keeps chunks large enough for the rolling hash to find boundaries
+	minSizeLimit = 64
+	// gearSeed generates the gear table, boundaries of chunks and so deduplication depend on it
+	gearSeed = 0x9e3779b97f4a7c15
+)
+
+var gear = newGear(gearSeed)
+
+type (
+	// Params are sizes of content defined chunks in bytes, zero values mean defaults.
+	Params struct {
+		Min int
+		Avg int
+		Max int
+	}
+
+	// Chunker splits the content read from the reader into content defined chunks by FastCDC,
+	// so the insert into the content changes only chunks around it.
+	Chunker struct {
+		r     io.Reader
+		buf   []byte
+		start int
+		end   int
+		eof   bool
+		min   int
+		avg   int
+		max   int
+		maskS uint64
+		maskL uint64
+	}
+)
+
+// WithDefaults returns params where zero sizes are replaced by defaults.
+func (p Params) WithDefaults() Params {
+	if p.Min == 0 {
+		p.Min = DefaultMinSize
+	}
+	if p.Avg == 0 {
+		p.Avg = DefaultAvgSize
+	}
+	if p.Max == 0 {
+		p.Max = DefaultMaxSize
+	}
+
+	return p
+}
+
+// Validate checks that sizes grow from min to max.
+func (p Params) Validate() error {
+
+	if p.Min < minSizeLimit {
+		return fmt.Errorf("min chunk size %v is less than %v", p.Min, minSizeLimit)
+	}
+	if p.Avg <= p.Min || p.Max <= p.Avg {
+		return fmt.Errorf("chunk sizes must grow from min %v to avg %v to max %v", p.Min, p.Avg, p.Max)
+	}
+
+	return nil
+}
+
+// New creates the chunker of the content read from r, zero params mean defaults.
+func New(r io.Reader, params Params) (*Chunker, error) {
+
+	params = params.WithDefaults()
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the normalized chunking makes cuts before the average size harder and after it easier
+	level := bits.Len(uint(params.Avg)) - 1
+
+	return &Chunker{
+		r:     r,
+		buf:   make([]byte, 2*params.Max),
+		min:   params.Min,
+		avg:   params.Avg,
+		max:   params.Max,
+		maskS: mask(level + 1),
+		maskL: mask(level - 1),
+	}, nil
+}
+
+// Next returns the next chunk of the content, io.EOF is returned after the last chunk.
+// The chunk is the copy which the caller owns.
+func (c *Chunker) Next() ([]byte, error) {
+
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	size := c.cut(c.buf[c.start:c.end])
+	chunk := append([]byte(nil), c.buf[c.start:c.start+size]...)
+	c.start += size
+
+	return chunk, nil
+}
+
+// fill reads the content until the buffer keeps the max chunk or the content ends
+func (c *Chunker) fill() error {
+
+	if c.eof || c.end-c.start >= c.max {
+		return nil
+	}
+
+	// the unread tail moves to the start of the buffer
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+
+	for c.end < c.max {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cut returns the size of the chunk at the start of data
+func (c *Chunker) cut(data []byte) int {
+
+	size := len(data)
+	if size <= c.min {
+		return size
+	}
+	if size > c.max {
+		size = c.max
+	}
+
+	normal := c.avg
+	if size < normal {
+		normal = size
+	}
+
+	var (
+		fp uint64
+		i  = c.min
+	)
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < size; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+
+	return size
+}
+
+// mask selects the top bits of the fingerprint which depend on the latest bytes
+func mask(ones int) uint64 {
+	if ones <= 0 {
+		return 0
+	}
+	if ones >= 64 {
+		return ^uint64(0)
+	}
+
+	return ^uint64(0) << (64 - ones)
+}
+
+// newGear generates random values of bytes by splitmix64, the table must never change
+func newGear(seed uint64) [256]uint64 {
+
+	var table [256]uint64
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+
+	return table
+}
@@ -0,0 +1,182 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// randomContent returns the reproducible content of the size
+func randomContent(seed int64, size int) []byte {
+
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+
+	return data
+}
+
+func split(t *testing.T, data []byte, params Params) [][]byte {
+	t.Helper()
+
+	c, err := New(bytes.NewReader(data), params)
+	if err != nil {
+		t.Fatalf("new chunker: %v", err)
+	}
+
+	var chunks [][]byte
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("next chunk: %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestChunkerSizes(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		params Params
+		size   int
+	}{
+		{name: "defaults", params: Params{}, size: 8 << 20},
+		{name: "small chunks", params: Params{Min: 256, Avg: 1024, Max: 4096}, size: 1 << 20},
+		{name: "wide range", params: Params{Min: 1024, Avg: 16 << 10, Max: 1 << 20}, size: 8 << 20},
+		{name: "content shorter than min", params: Params{Min: 4096, Avg: 8192, Max: 16384}, size: 1000},
+		{name: "empty content", params: Params{}, size: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			params := tt.params.WithDefaults()
+			data := randomContent(1, tt.size)
+			chunks := split(t, data, tt.params)
+
+			if joined := bytes.Join(chunks, nil); !bytes.Equal(joined, data) {
+				t.Fatal("chunks don't make up the content")
+			}
+
+			for i, chunk := range chunks {
+				if len(chunk) > params.Max {
+					t.Fatalf("chunk %v has %v bytes, more than max %v", i, len(chunk), params.Max)
+				}
+				// only the last chunk may be shorter than min
+				if len(chunk) < params.Min && i != len(chunks)-1 {
+					t.Fatalf("chunk %v has %v bytes, less than min %v", i, len(chunk), params.Min)
+				}
+			}
+
+			// the average of random content stays within the half and the double of the configured one
+			if len(chunks) > 16 {
+				avg := tt.size / len(chunks)
+				if avg < params.Avg/2 || avg > params.Avg*2 {
+					t.Fatalf("average chunk has %v bytes, configured %v", avg, params.Avg)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkerInsertion(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		offset int
+		insert int
+	}{
+		{name: "single byte at the start", offset: 0, insert: 1},
+		{name: "bytes in the middle", offset: 4 << 20, insert: 100},
+		{name: "block near the end", offset: 7 << 20, insert: 64 << 10},
+	}
+
+	params := Params{Min: 2048, Avg: 8192, Max: 65536}
+	data := randomContent(2, 8<<20)
+	original := chunkSet(split(t, data, params))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			edited := append(append(append([]byte(nil), data[:tt.offset]...), randomContent(3, tt.insert)...), data[tt.offset:]...)
+			chunks := split(t, edited, params)
+
+			var changed int
+			for _, chunk := range chunks {
+				if _, ok := original[string(chunk)]; !ok {
+					changed++
+				}
+			}
+
+			// the insert changes chunks around it, the limit leaves room for chunks which the insert spans
+			limit := 3 + tt.insert/params.Min
+			if changed > limit {
+				t.Fatalf("%v of %v chunks changed, expected at most %v", changed, len(chunks), limit)
+			}
+		})
+	}
+}
+
+func TestChunkerDeterministic(t *testing.T) {
+
+	data := randomContent(4, 2<<20)
+	first := split(t, data, Params{})
+
+	// boundaries don't depend on how the reader returns the content
+	c, err := New(io.MultiReader(bytes.NewReader(data[:12345]), bytes.NewReader(data[12345:])), Params{})
+	if err != nil {
+		t.Fatalf("new chunker: %v", err)
+	}
+	for i := 0; ; i++ {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			if i != len(first) {
+				t.Fatalf("%v chunks, want %v", i, len(first))
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("next chunk: %v", err)
+		}
+		if i >= len(first) || !bytes.Equal(chunk, first[i]) {
+			t.Fatalf("chunk %v differs", i)
+		}
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		params Params
+		valid  bool
+	}{
+		{name: "defaults", params: Params{}.WithDefaults(), valid: true},
+		{name: "min below limit", params: Params{Min: 16, Avg: 1024, Max: 4096}},
+		{name: "avg not above min", params: Params{Min: 1024, Avg: 1024, Max: 4096}},
+		{name: "max not above avg", params: Params{Min: 1024, Avg: 4096, Max: 4096}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("validate: %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func chunkSet(chunks [][]byte) map[string]struct{} {
+
+	set := make(map[string]struct{}, len(chunks))
+	for _, chunk := range chunks {
+		set[string(chunk)] = struct{}{}
+	}
+
+	return set
+}
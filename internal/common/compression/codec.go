@@ -0,0 +1,213 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// None keeps chunks as is.
+	None = "none"
+	// Gzip compresses chunks by gzip.
+	Gzip = "gzip"
+	// Zstd compresses chunks by zstandard.
+	Zstd = "zstd"
+	// Snappy compresses chunks by snappy, it is the fastest codec with the lowest ratio.
+	Snappy = "snappy"
+)
+
+var (
+	ErrUnknownCodec = errors.New("unknown compression codec")
+	ErrTooLarge     = errors.New("decompressed data exceeds its size")
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		Gzip:   gzipCodec{},
+		Zstd:   newZstdCodec(),
+		Snappy: snappyCodec{},
+	}
+)
+
+type (
+	// Codec compresses chunks, the codec must be safe for concurrent use.
+	// Decompress fails with ErrTooLarge when the data exceeds the size.
+	Codec interface {
+		Compress(data []byte) ([]byte, error)
+		Decompress(data []byte, size int64) ([]byte, error)
+	}
+
+	gzipCodec struct{}
+
+	zstdCodec struct {
+		encoder *zstd.Encoder
+		decoder *zstd.Decoder
+	}
+
+	snappyCodec struct{}
+)
+
+// Register makes the codec available by the name, the codec with the same name is replaced.
+func Register(name string, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[name] = codec
+}
+
+// Valid reports whether the codec is registered, the empty name and None mean no compression.
+func Valid(name string) bool {
+	if name == "" || name == None {
+		return true
+	}
+
+	_, err := lookup(name)
+	return err == nil
+}
+
+// Compress compresses the data by the codec. The data is returned as is with the empty codec
+// when it doesn't shrink, so the codec of the returned data must be recorded.
+func Compress(name string, data []byte) ([]byte, string, error) {
+
+	if name == "" || name == None || len(data) == 0 {
+		return data, "", nil
+	}
+
+	codec, err := lookup(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	compressed, err := codec.Compress(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("compress by %v: %w", name, err)
+	}
+	if len(compressed) >= len(data) {
+		return data, "", nil
+	}
+
+	return compressed, name, nil
+}
+
+// Decompress restores the data compressed by the codec, the restored data must not exceed the size.
+func Decompress(name string, data []byte, size int64) ([]byte, error) {
+
+	if name == "" || name == None {
+		return data, nil
+	}
+
+	codec, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := codec.Decompress(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("decompress by %v: %w", name, err)
+	}
+
+	return restored, nil
+}
+
+func lookup(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+
+	return codec, nil
+}
+
+func (gzipCodec) Compress(data []byte) ([]byte, error) {
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data []byte, size int64) ([]byte, error) {
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	restored, err := io.ReadAll(io.LimitReader(r, size+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(restored)) > size {
+		return nil, ErrTooLarge
+	}
+
+	return restored, nil
+}
+
+func newZstdCodec() *zstdCodec {
+	// the encoder and the decoder without readers and writers only encode and decode whole buffers
+	encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
+	return &zstdCodec{encoder: encoder, decoder: decoder}
+}
+
+func (c *zstdCodec) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) Decompress(data []byte, size int64) ([]byte, error) {
+
+	var header zstd.Header
+	if err := header.Decode(data); err != nil {
+		return nil, err
+	}
+	if header.HasFCS && header.FrameContentSize > uint64(size) {
+		return nil, ErrTooLarge
+	}
+
+	restored, err := c.decoder.DecodeAll(data, make([]byte, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(restored)) > size {
+		return nil, ErrTooLarge
+	}
+
+	return restored, nil
+}
+
+func (snappyCodec) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCodec) Decompress(data []byte, size int64) ([]byte, error) {
+
+	length, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if int64(length) > size {
+		return nil, ErrTooLarge
+	}
+
+	return snappy.Decode(nil, data)
+}
@@ -0,0 +1,102 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// compressible returns the content which every codec shrinks
+func compressible(size int) []byte {
+	return bytes.Repeat([]byte("chunk of the uploaded file "), size/27+1)[:size]
+}
+
+// incompressible returns random content which no codec shrinks
+func incompressible(size int) []byte {
+
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		codec     string
+		data      []byte
+		wantCodec string // codec of the stored data, empty when it is stored as is
+	}{
+		{name: "gzip", codec: Gzip, data: compressible(64 << 10), wantCodec: Gzip},
+		{name: "zstd", codec: Zstd, data: compressible(64 << 10), wantCodec: Zstd},
+		{name: "snappy", codec: Snappy, data: compressible(64 << 10), wantCodec: Snappy},
+		{name: "none", codec: None, data: compressible(64 << 10)},
+		{name: "empty codec", codec: "", data: compressible(64 << 10)},
+		{name: "gzip incompressible", codec: Gzip, data: incompressible(64 << 10)},
+		{name: "zstd incompressible", codec: Zstd, data: incompressible(64 << 10)},
+		{name: "snappy incompressible", codec: Snappy, data: incompressible(64 << 10)},
+		{name: "zstd empty data", codec: Zstd, data: []byte{}},
+		{name: "snappy single byte", codec: Snappy, data: []byte{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			stored, codec, err := Compress(tt.codec, tt.data)
+			if err != nil {
+				t.Fatalf("compress: %v", err)
+			}
+			if codec != tt.wantCodec {
+				t.Fatalf("codec %q, want %q", codec, tt.wantCodec)
+			}
+			if codec == "" && !bytes.Equal(stored, tt.data) {
+				t.Fatal("data which doesn't shrink must be stored as is")
+			}
+			if codec != "" && len(stored) >= len(tt.data) {
+				t.Fatalf("compressed %v bytes to %v", len(tt.data), len(stored))
+			}
+
+			restored, err := Decompress(codec, stored, int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("decompress: %v", err)
+			}
+			if !bytes.Equal(restored, tt.data) {
+				t.Fatal("restored data differs")
+			}
+		})
+	}
+}
+
+func TestDecompressTooLarge(t *testing.T) {
+
+	data := compressible(64 << 10)
+
+	for _, name := range []string{Gzip, Zstd, Snappy} {
+		t.Run(name, func(t *testing.T) {
+
+			stored, codec, err := Compress(name, data)
+			if err != nil || codec != name {
+				t.Fatalf("compress: %v codec %q", err, codec)
+			}
+
+			if _, err := Decompress(codec, stored, int64(len(data))-1); !errors.Is(err, ErrTooLarge) {
+				t.Fatalf("decompress: %v, want %v", err, ErrTooLarge)
+			}
+		})
+	}
+}
+
+func TestUnknownCodec(t *testing.T) {
+
+	if Valid("lz4") {
+		t.Fatal("unknown codec is valid")
+	}
+	if _, _, err := Compress("lz4", compressible(1024)); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("compress: %v, want %v", err, ErrUnknownCodec)
+	}
+	if _, err := Decompress("lz4", compressible(1024), 1024); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("decompress: %v, want %v", err, ErrUnknownCodec)
+	}
+}
@@ -0,0 +1,39 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"strconv"
+)
+
+type (
+	// ChunkCipher encrypts chunks of the single upload with its data key.
+	// The upload id and the chunk number are authenticated, so chunks can't be swapped or moved to another upload.
+	ChunkCipher struct {
+		aead cipher.AEAD
+	}
+)
+
+// NewChunkCipher creates the cipher of the upload from its data key.
+func NewChunkCipher(dataKey []byte) (*ChunkCipher, error) {
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChunkCipher{aead: aead}, nil
+}
+
+// Seal returns the encrypted chunk data.
+func (c *ChunkCipher) Seal(uploadID string, chunkNumber int64, data []byte) ([]byte, error) {
+	return seal(c.aead, data, chunkAdditionalData(uploadID, chunkNumber))
+}
+
+// Open returns the decrypted chunk data.
+func (c *ChunkCipher) Open(uploadID string, chunkNumber int64, sealed []byte) ([]byte, error) {
+	return open(c.aead, sealed, chunkAdditionalData(uploadID, chunkNumber))
+}
+
+func chunkAdditionalData(uploadID string, chunkNumber int64) []byte {
+	return strconv.AppendInt([]byte(uploadID+":"), chunkNumber, 10)
+}
@@ -0,0 +1,111 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newTestChunkCipher(t *testing.T, dataKey []byte) *ChunkCipher {
+	t.Helper()
+
+	c, err := NewChunkCipher(dataKey)
+	if err != nil {
+		t.Fatalf("new chunk cipher: %v", err)
+	}
+
+	return c
+}
+
+func TestChunkCipherRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: []byte{}},
+		{name: "short", data: []byte("chunk")},
+		{name: "large", data: bytes.Repeat([]byte("0123456789"), 100<<10)},
+	}
+
+	c := newTestChunkCipher(t, testKey(1))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := c.Seal("upload", 3, tt.data)
+			if err != nil {
+				t.Fatalf("seal: %v", err)
+			}
+			if len(tt.data) > 0 && bytes.Contains(sealed, tt.data) {
+				t.Fatalf("sealed chunk contains the data")
+			}
+
+			data, err := c.Open("upload", 3, sealed)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Fatalf("opened %v bytes differ from %v sealed bytes", len(data), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestChunkCipherNonce(t *testing.T) {
+
+	c := newTestChunkCipher(t, testKey(1))
+
+	first, err := c.Seal("upload", 1, []byte("chunk"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	second, err := c.Seal("upload", 1, []byte("chunk"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Fatalf("equal chunks are sealed into the same ciphertext")
+	}
+}
+
+func TestChunkCipherOpenFailure(t *testing.T) {
+
+	c := newTestChunkCipher(t, testKey(1))
+	sealed, err := c.Seal("upload", 1, []byte("chunk data"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name        string
+		cipher      *ChunkCipher
+		uploadID    string
+		chunkNumber int64
+		sealed      []byte
+	}{
+		{name: "wrong key", cipher: newTestChunkCipher(t, testKey(2)), uploadID: "upload", chunkNumber: 1, sealed: sealed},
+		{name: "another upload", cipher: c, uploadID: "other", chunkNumber: 1, sealed: sealed},
+		{name: "another chunk number", cipher: c, uploadID: "upload", chunkNumber: 2, sealed: sealed},
+		{name: "tampered ciphertext", cipher: c, uploadID: "upload", chunkNumber: 1, sealed: tampered},
+		{name: "truncated ciphertext", cipher: c, uploadID: "upload", chunkNumber: 1, sealed: sealed[:len(sealed)-1]},
+		{name: "shorter than the nonce", cipher: c, uploadID: "upload", chunkNumber: 1, sealed: sealed[:4]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cipher.Open(tt.uploadID, tt.chunkNumber, tt.sealed); !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("open error = %v, want %v", err, ErrDecrypt)
+			}
+		})
+	}
+}
+
+func TestNewChunkCipherKeySize(t *testing.T) {
+
+	if _, err := NewChunkCipher(testKey(1)[:KeySize-1]); err == nil {
+		t.Fatalf("cipher is created from the short data key")
+	}
+}
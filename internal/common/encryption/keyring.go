@@ -0,0 +1,183 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"node-test/internal/domain"
+)
+
+const (
+	// KeySize is the size of keyring keys and data keys, AES-256 is used for both.
+	KeySize = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown keyring key")
+	ErrDecrypt    = errors.New("can't decrypt the data")
+)
+
+type (
+	// Keyring keeps master keys which wrap data keys of files.
+	// New data keys are wrapped by the primary key, others are kept to unwrap data keys until they are rotated.
+	Keyring struct {
+		primary string
+		keys    map[string]cipher.AEAD
+	}
+
+	// keyFile is the json document with base64 encoded keys, e.g.
+	// {"primary": "2024-06", "keys": {"2024-01": "...", "2024-06": "..."}}
+	keyFile struct {
+		Primary string            `json:"primary"`
+		Keys    map[string]string `json:"keys"`
+	}
+)
+
+// LoadKeyring reads the keyring from the local key file.
+func LoadKeyring(fileName string) (*Keyring, error) {
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode key file: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decode key %v: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	return NewKeyring(file.Primary, keys)
+}
+
+// NewKeyring creates the keyring from raw keys, the primary key must be one of them.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("%w: primary %q", ErrUnknownKey, primary)
+	}
+
+	keyring := &Keyring{
+		primary: primary,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", id, err)
+		}
+		keyring.keys[id] = aead
+	}
+
+	return keyring, nil
+}
+
+// Primary returns the id of the key which wraps new data keys.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// NewDataKey generates the random data key and wraps it by the primary key.
+func (k *Keyring) NewDataKey() ([]byte, *domain.WrappedKey, error) {
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("generate data key: %w", err)
+	}
+
+	wrapped, err := k.Wrap(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dataKey, wrapped, nil
+}
+
+// Wrap encrypts the data key by the primary key, the key id is authenticated as well.
+func (k *Keyring) Wrap(dataKey []byte) (*domain.WrappedKey, error) {
+
+	ciphertext, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.WrappedKey{KeyID: k.primary, Ciphertext: ciphertext}, nil
+}
+
+// Unwrap decrypts the data key by the key which wrapped it.
+func (k *Keyring) Unwrap(wrapped *domain.WrappedKey) ([]byte, error) {
+
+	aead, ok := k.keys[wrapped.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, wrapped.KeyID)
+	}
+
+	return open(aead, wrapped.Ciphertext, []byte(wrapped.KeyID))
+}
+
+// Rewrap wraps the data key by the primary key, data encrypted by the data key stays valid.
+func (k *Keyring) Rewrap(wrapped *domain.WrappedKey) (*domain.WrappedKey, error) {
+
+	dataKey, err := k.Unwrap(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.Wrap(dataKey)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %v bytes, got %v", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data with the random nonce which is prepended to the ciphertext
+func seal(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, data, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	data, err := aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return data, nil
+}
@@ -0,0 +1,188 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"node-test/internal/domain"
+)
+
+// testKey returns the key of the keyring filled with the byte
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func newTestKeyring(t *testing.T, primary string, keys map[string][]byte) *Keyring {
+	t.Helper()
+
+	keyring, err := NewKeyring(primary, keys)
+	if err != nil {
+		t.Fatalf("new keyring: %v", err)
+	}
+
+	return keyring
+}
+
+func TestNewKeyring(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		primary string
+		keys    map[string][]byte
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "valid", primary: "a", keys: map[string][]byte{"a": testKey(1), "b": testKey(2)}},
+		{name: "unknown primary", primary: "c", keys: map[string][]byte{"a": testKey(1)}, wantErr: true, wantIs: ErrUnknownKey},
+		{name: "short key", primary: "a", keys: map[string][]byte{"a": testKey(1)[:16]}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.primary, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("new keyring error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Fatalf("new keyring error = %v, want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestKeyringWrap(t *testing.T) {
+
+	keyring := newTestKeyring(t, "a", map[string][]byte{"a": testKey(1)})
+
+	dataKey, wrapped, err := keyring.NewDataKey()
+	if err != nil {
+		t.Fatalf("new data key: %v", err)
+	}
+	if len(dataKey) != KeySize {
+		t.Fatalf("data key has %v bytes, want %v", len(dataKey), KeySize)
+	}
+	if wrapped.KeyID != "a" {
+		t.Fatalf("key id = %v, want a", wrapped.KeyID)
+	}
+	if bytes.Contains(wrapped.Ciphertext, dataKey) {
+		t.Fatalf("wrapped key contains the data key")
+	}
+
+	unwrapped, err := keyring.Unwrap(wrapped)
+	if err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("unwrapped key differs from the data key")
+	}
+}
+
+func TestKeyringUnwrapFailure(t *testing.T) {
+
+	keyring := newTestKeyring(t, "a", map[string][]byte{"a": testKey(1), "b": testKey(2)})
+	other := newTestKeyring(t, "a", map[string][]byte{"a": testKey(3)})
+
+	_, wrapped, err := keyring.NewDataKey()
+	if err != nil {
+		t.Fatalf("new data key: %v", err)
+	}
+
+	tampered := bytes.Clone(wrapped.Ciphertext)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		wrapped *domain.WrappedKey
+		wantErr error
+	}{
+		{name: "wrong key", keyring: other, wrapped: wrapped, wantErr: ErrDecrypt},
+		{name: "unknown key", keyring: keyring, wrapped: &domain.WrappedKey{KeyID: "c", Ciphertext: wrapped.Ciphertext}, wantErr: ErrUnknownKey},
+		{name: "another key id", keyring: keyring, wrapped: &domain.WrappedKey{KeyID: "b", Ciphertext: wrapped.Ciphertext}, wantErr: ErrDecrypt},
+		{name: "tampered ciphertext", keyring: keyring, wrapped: &domain.WrappedKey{KeyID: "a", Ciphertext: tampered}, wantErr: ErrDecrypt},
+		{name: "short ciphertext", keyring: keyring, wrapped: &domain.WrappedKey{KeyID: "a", Ciphertext: tampered[:8]}, wantErr: ErrDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.keyring.Unwrap(tt.wrapped); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unwrap error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+
+	previous := newTestKeyring(t, "2024-01", map[string][]byte{"2024-01": testKey(1)})
+	dataKey, wrapped, err := previous.NewDataKey()
+	if err != nil {
+		t.Fatalf("new data key: %v", err)
+	}
+
+	// the new primary key is added, the previous one is kept to unwrap existing data keys
+	rotated := newTestKeyring(t, "2024-06", map[string][]byte{"2024-01": testKey(1), "2024-06": testKey(2)})
+
+	unwrapped, err := rotated.Unwrap(wrapped)
+	if err != nil {
+		t.Fatalf("unwrap by the previous key: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("unwrapped key differs from the data key")
+	}
+
+	rewrapped, err := rotated.Rewrap(wrapped)
+	if err != nil {
+		t.Fatalf("rewrap: %v", err)
+	}
+	if rewrapped.KeyID != "2024-06" {
+		t.Fatalf("rewrapped key id = %v, want 2024-06", rewrapped.KeyID)
+	}
+
+	// the previous key is dropped once data keys are rewrapped
+	current := newTestKeyring(t, "2024-06", map[string][]byte{"2024-06": testKey(2)})
+	unwrapped, err = current.Unwrap(rewrapped)
+	if err != nil {
+		t.Fatalf("unwrap rewrapped key: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("rewrapped key differs from the data key")
+	}
+	if _, err := current.Unwrap(wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("unwrap by the dropped key = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+
+	data, err := json.Marshal(keyFile{
+		Primary: "b",
+		Keys: map[string]string{
+			"a": base64.StdEncoding.EncodeToString(testKey(1)),
+			"b": base64.StdEncoding.EncodeToString(testKey(2)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("encode key file: %v", err)
+	}
+	fileName := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(fileName, data, 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+
+	keyring, err := LoadKeyring(fileName)
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	if keyring.Primary() != "b" {
+		t.Fatalf("primary = %v, want b", keyring.Primary())
+	}
+	if len(keyring.keys) != 2 {
+		t.Fatalf("keyring has %v keys, want 2", len(keyring.keys))
+	}
+}
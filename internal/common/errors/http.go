@@ -1,11 +1,189 @@
 package errors
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"node-test/internal/domain"
+)
+
+const (
+	// internalMessage replaces messages of internal errors, their details are logged by handlers
+	internalMessage = "internal error"
+
+	// maxCloseReasonSize is the limit of the close frame payload without the close code.
+	maxCloseReasonSize = 123
+
+	// closeReasonSeparator separates the error code from the message in the reason of the close frame
+	closeReasonSeparator = ": "
+
+	// closeCodeStatusBase is added to http statuses of errors which websocket close codes don't describe,
+	// codes from 4000 are reserved for applications.
+	closeCodeStatusBase = 4000
+)
+
 type (
+	// ErrorResponse is the body of failed requests, Code is one of domain error codes.
 	ErrorResponse struct {
-		Message string
+		Code    domain.ErrorCode `json:"code"`
+		Message string           `json:"message"`
 	}
 )
 
-func NewInternalError(err error) *ErrorResponse {
-	return &ErrorResponse{Message: err.Error()}
+// NewErrorResponse describes the error for clients, messages of internal errors aren't exposed.
+// Errors of storage nodes are described by the message of their code, since details hold addresses of nodes.
+func NewErrorResponse(err error) *ErrorResponse {
+
+	var codeErr *domain.Error
+	switch {
+	case !errors.As(err, &codeErr):
+		return &ErrorResponse{Code: domain.ErrorCodeInternal, Message: internalMessage}
+	case codeErr.Code == domain.ErrorCodeNodeUnavailable:
+		return &ErrorResponse{Code: codeErr.Code, Message: codeErr.Message}
+	}
+
+	return &ErrorResponse{Code: codeErr.Code, Message: err.Error()}
+}
+
+// Hidden reports whether details of errors of the code aren't sent to clients, handlers log them instead.
+func Hidden(code domain.ErrorCode) bool {
+	return code == domain.ErrorCodeInternal || code == domain.ErrorCodeNodeUnavailable
+}
+
+// Respond writes the error with the http status of its code, internal errors and errors of nodes
+// are logged and their details aren't sent to clients.
+func Respond(c echo.Context, err error) error {
+
+	code := domain.ErrorCodeOf(err)
+	if Hidden(code) {
+		c.Logger().Errorf("%v %v %v", c.Request().Method, c.Path(), err)
+	}
+
+	return c.JSON(Status(code), NewErrorResponse(err))
+}
+
+// RespondInvalidRequest writes the error of the request which can't be decoded.
+func RespondInvalidRequest(c echo.Context, err error) error {
+	return Respond(c, fmt.Errorf("%w: %v", domain.ErrInvalidRequest, err))
+}
+
+// HTTPErrorHandler writes errors of the router and middlewares, like unknown routes
+// and rejected bodies, in the same form as errors of handlers.
+func HTTPErrorHandler(err error, c echo.Context) {
+
+	if c.Response().Committed {
+		return
+	}
+
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		err = Respond(c, err)
+	} else if c.Request().Method == http.MethodHead {
+		err = c.NoContent(httpErr.Code)
+	} else {
+		// statuses of echo are kept, since codes don't describe every one of them
+		err = c.JSON(httpErr.Code, &ErrorResponse{Code: statusCode(httpErr.Code), Message: fmt.Sprint(httpErr.Message)})
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// statusCode returns the error code of the http status which echo reports
+func statusCode(status int) domain.ErrorCode {
+	switch {
+	case status == http.StatusUnauthorized:
+		return domain.ErrorCodeUnauthorized
+	case status == http.StatusForbidden:
+		return domain.ErrorCodeForbidden
+	case status == http.StatusNotFound:
+		return domain.ErrorCodeNotFound
+	case status == http.StatusRequestEntityTooLarge:
+		return domain.ErrorCodeQuotaExceeded
+	case status == http.StatusServiceUnavailable:
+		return domain.ErrorCodeUnavailable
+	case status >= http.StatusBadRequest && status < http.StatusInternalServerError:
+		return domain.ErrorCodeInvalidRequest
+	default:
+		return domain.ErrorCodeInternal
+	}
+}
+
+// Status returns the http status of the error code.
+func Status(code domain.ErrorCode) int {
+	switch code {
+	case domain.ErrorCodeInvalidRequest, domain.ErrorCodeInvalidChunk:
+		return http.StatusBadRequest
+	case domain.ErrorCodeUnauthorized:
+		return http.StatusUnauthorized
+	case domain.ErrorCodeForbidden:
+		return http.StatusForbidden
+	case domain.ErrorCodeNotFound:
+		return http.StatusNotFound
+	case domain.ErrorCodeConflict, domain.ErrorCodeChecksumMismatch:
+		return http.StatusConflict
+	case domain.ErrorCodeQuotaExceeded:
+		return http.StatusRequestEntityTooLarge
+	case domain.ErrorCodeInvalidRange:
+		return http.StatusRequestedRangeNotSatisfiable
+	case domain.ErrorCodeNodeUnavailable:
+		return http.StatusBadGateway
+	case domain.ErrorCodeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CloseCode returns the websocket close code of the error code, codes of client errors
+// which the protocol doesn't define are 4000 plus their http statuses.
+func CloseCode(code domain.ErrorCode) int {
+	switch code {
+	case domain.ErrorCodeInternal:
+		return websocket.CloseInternalServerErr
+	case domain.ErrorCodeInvalidChunk, domain.ErrorCodeChecksumMismatch:
+		return websocket.CloseInvalidFramePayloadData
+	case domain.ErrorCodeNodeUnavailable:
+		return websocket.CloseTryAgainLater
+	case domain.ErrorCodeUnavailable:
+		// the client resumes the interrupted stream once the master is restarted
+		return websocket.CloseServiceRestart
+	default:
+		return closeCodeStatusBase + Status(code)
+	}
+}
+
+// CloseReason is the reason of the close frame which reports the error, the code precedes the message.
+// The long reason is cut on the boundary of runes, so it stays valid UTF-8.
+func CloseReason(err error) string {
+
+	resp := NewErrorResponse(err)
+	reason := string(resp.Code) + closeReasonSeparator + resp.Message
+	if len(reason) > maxCloseReasonSize {
+		size := maxCloseReasonSize
+		for size > 0 && !utf8.RuneStart(reason[size]) {
+			size--
+		}
+		reason = reason[:size]
+	}
+
+	return reason
+}
+
+// ParseCloseReason splits the reason of the close frame into the error code and the message,
+// the reason without the code is the message of the internal error.
+func ParseCloseReason(reason string) *ErrorResponse {
+
+	code, message, ok := strings.Cut(reason, closeReasonSeparator)
+	if !ok || strings.ContainsAny(code, " ") {
+		return &ErrorResponse{Code: domain.ErrorCodeInternal, Message: reason}
+	}
+
+	return &ErrorResponse{Code: domain.ErrorCode(code), Message: message}
 }
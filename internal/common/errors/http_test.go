@@ -0,0 +1,239 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	"node-test/internal/domain"
+)
+
+// errNodeUnavailable is the error of storage nodes as the gateway declares it
+var errNodeUnavailable = domain.NewError(domain.ErrorCodeNodeUnavailable, "node unavailable")
+
+func TestNewErrorResponse(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorResponse
+	}{
+		{
+			name: "coded error with details",
+			err:  fmt.Errorf("%w: chunk 3", domain.ErrInvalidChunk),
+			want: ErrorResponse{Code: domain.ErrorCodeInvalidChunk, Message: "invalid chunk: chunk 3"},
+		},
+		{
+			name: "internal error",
+			err:  fmt.Errorf("decode file: unexpected EOF"),
+			want: ErrorResponse{Code: domain.ErrorCodeInternal, Message: internalMessage},
+		},
+		{
+			name: "node error",
+			err:  fmt.Errorf("%w: failed to send http request Get \"http://10.0.0.7:8081/api/v1/chunk\"", errNodeUnavailable),
+			want: ErrorResponse{Code: domain.ErrorCodeNodeUnavailable, Message: "node unavailable"},
+		},
+		{
+			name: "wrapped node error",
+			err:  fmt.Errorf("download chunk 2 %w", fmt.Errorf("%w: bad request status code 500", errNodeUnavailable)),
+			want: ErrorResponse{Code: domain.ErrorCodeNodeUnavailable, Message: "node unavailable"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewErrorResponse(tt.err); *got != tt.want {
+				t.Fatalf("NewErrorResponse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+
+	tests := []struct {
+		code domain.ErrorCode
+		want int
+	}{
+		{code: domain.ErrorCodeInternal, want: http.StatusInternalServerError},
+		{code: domain.ErrorCodeInvalidRequest, want: http.StatusBadRequest},
+		{code: domain.ErrorCodeInvalidChunk, want: http.StatusBadRequest},
+		{code: domain.ErrorCodeUnauthorized, want: http.StatusUnauthorized},
+		{code: domain.ErrorCodeForbidden, want: http.StatusForbidden},
+		{code: domain.ErrorCodeNotFound, want: http.StatusNotFound},
+		{code: domain.ErrorCodeConflict, want: http.StatusConflict},
+		{code: domain.ErrorCodeChecksumMismatch, want: http.StatusConflict},
+		{code: domain.ErrorCodeQuotaExceeded, want: http.StatusRequestEntityTooLarge},
+		{code: domain.ErrorCodeInvalidRange, want: http.StatusRequestedRangeNotSatisfiable},
+		{code: domain.ErrorCodeNodeUnavailable, want: http.StatusBadGateway},
+		{code: domain.ErrorCodeUnavailable, want: http.StatusServiceUnavailable},
+		{code: domain.ErrorCode("unknown"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			if got := Status(tt.code); got != tt.want {
+				t.Fatalf("Status(%v) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseCode(t *testing.T) {
+
+	tests := []struct {
+		code domain.ErrorCode
+		want int
+	}{
+		{code: domain.ErrorCodeInternal, want: websocket.CloseInternalServerErr},
+		{code: domain.ErrorCodeInvalidRequest, want: 4400},
+		{code: domain.ErrorCodeInvalidChunk, want: websocket.CloseInvalidFramePayloadData},
+		{code: domain.ErrorCodeUnauthorized, want: 4401},
+		{code: domain.ErrorCodeForbidden, want: 4403},
+		{code: domain.ErrorCodeNotFound, want: 4404},
+		{code: domain.ErrorCodeConflict, want: 4409},
+		{code: domain.ErrorCodeChecksumMismatch, want: websocket.CloseInvalidFramePayloadData},
+		{code: domain.ErrorCodeQuotaExceeded, want: 4413},
+		{code: domain.ErrorCodeInvalidRange, want: 4416},
+		{code: domain.ErrorCodeNodeUnavailable, want: websocket.CloseTryAgainLater},
+		{code: domain.ErrorCodeUnavailable, want: websocket.CloseServiceRestart},
+		{code: domain.ErrorCode("unknown"), want: 4500},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			if got := CloseCode(tt.code); got != tt.want {
+				t.Fatalf("CloseCode(%v) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+
+	tests := []struct {
+		status int
+		want   domain.ErrorCode
+	}{
+		{status: http.StatusUnauthorized, want: domain.ErrorCodeUnauthorized},
+		{status: http.StatusForbidden, want: domain.ErrorCodeForbidden},
+		{status: http.StatusNotFound, want: domain.ErrorCodeNotFound},
+		{status: http.StatusRequestEntityTooLarge, want: domain.ErrorCodeQuotaExceeded},
+		{status: http.StatusServiceUnavailable, want: domain.ErrorCodeUnavailable},
+		{status: http.StatusMethodNotAllowed, want: domain.ErrorCodeInvalidRequest},
+		{status: http.StatusInternalServerError, want: domain.ErrorCodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			if got := statusCode(tt.status); got != tt.want {
+				t.Fatalf("statusCode(%v) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseReason(t *testing.T) {
+
+	prefix := string(domain.ErrorCodeNotFound) + closeReasonSeparator
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "coded error",
+			err:  domain.NewError(domain.ErrorCodeNotFound, "file not found"),
+			want: "not_found: file not found",
+		},
+		{
+			name: "wrapped error",
+			err:  fmt.Errorf("%w: chunk 3", domain.ErrInvalidChunk),
+			want: "invalid_chunk: invalid chunk: chunk 3",
+		},
+		{
+			name: "internal message is hidden",
+			err:  fmt.Errorf("dial tcp: connection refused"),
+			want: "internal: " + internalMessage,
+		},
+		{
+			name: "node details are hidden",
+			err:  fmt.Errorf("store chunk 3 %w", fmt.Errorf("%w: dial tcp 10.0.0.7:8081: connection refused", errNodeUnavailable)),
+			want: "node_unavailable: node unavailable",
+		},
+		{
+			name: "long ascii message",
+			err:  domain.NewError(domain.ErrorCodeNotFound, strings.Repeat("a", 200)),
+			want: prefix + strings.Repeat("a", maxCloseReasonSize-len(prefix)),
+		},
+		{
+			name: "rune across the limit",
+			// the limit falls after the first byte of the last rune
+			err:  domain.NewError(domain.ErrorCodeNotFound, strings.Repeat("a", maxCloseReasonSize-len(prefix)-1)+"ж"),
+			want: prefix + strings.Repeat("a", maxCloseReasonSize-len(prefix)-1),
+		},
+		{
+			name: "runes of several bytes",
+			err:  domain.NewError(domain.ErrorCodeNotFound, strings.Repeat("€", 60)),
+			want: prefix + strings.Repeat("€", (maxCloseReasonSize-len(prefix))/len("€")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CloseReason(tt.err)
+			if got != tt.want {
+				t.Fatalf("CloseReason() = %q, want %q", got, tt.want)
+			}
+			if len(got) > maxCloseReasonSize {
+				t.Fatalf("reason of %v bytes exceeds %v", len(got), maxCloseReasonSize)
+			}
+			if !utf8.ValidString(got) {
+				t.Fatalf("reason %q isn't valid UTF-8", got)
+			}
+		})
+	}
+}
+
+func TestParseCloseReason(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		reason string
+		want   ErrorResponse
+	}{
+		{
+			name:   "code and message",
+			reason: "quota_exceeded: quota of the user is exceeded",
+			want:   ErrorResponse{Code: domain.ErrorCodeQuotaExceeded, Message: "quota of the user is exceeded"},
+		},
+		{
+			name:   "message with the separator",
+			reason: "invalid_chunk: invalid chunk: chunk 3",
+			want:   ErrorResponse{Code: domain.ErrorCodeInvalidChunk, Message: "invalid chunk: chunk 3"},
+		},
+		{
+			name:   "without the code",
+			reason: "connection reset",
+			want:   ErrorResponse{Code: domain.ErrorCodeInternal, Message: "connection reset"},
+		},
+		{
+			name:   "message which looks like the code",
+			reason: "read tcp: connection reset",
+			want:   ErrorResponse{Code: domain.ErrorCodeInternal, Message: "read tcp: connection reset"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseCloseReason(tt.reason); *got != tt.want {
+				t.Fatalf("ParseCloseReason(%q) = %+v, want %+v", tt.reason, *got, tt.want)
+			}
+		})
+	}
+}
@@ -8,10 +8,105 @@ type (
 		TotalFileSize int64  `json:"total_file_size" validate:"required"`
 		Filename      string `json:"filename" validate:"required"`
 		Data          []byte `json:"data" validate:"required"`
+		// Encrypt asks the node to encrypt the chunk at rest
+		Encrypt bool `json:"encrypt,omitempty"`
 	}
 
+	// ChunkMetadata opens the upload stream. Without UploadID the new session is created
+	// and committed after the whole file is received, otherwise the stream uploads
+	// chunks from FirstChunk to LastChunk of the existing session.
+	// The file of the bucket uses Filename as its key. The file expires when TTLSeconds elapse,
+	// the TTL can be sent by the X-File-TTL header as well. Compression selects the codec of chunks,
+	// the codec of the bucket or the master is used when it is empty. ChunkSize requests the size of fixed chunks,
+	// the session reports the size which the master has chosen. Frames of any size are cut into chunks.
+	// With Credits the client sends only bytes granted by UploadCredit messages, so it pauses while nodes are slow.
 	ChunkMetadata struct {
 		TotalFileSize int64  `json:"total_file_size" validate:"required"`
+		Bucket        string `json:"bucket,omitempty"`
 		Filename      string `json:"filename" validate:"required"`
+		Checksum      string `json:"checksum,omitempty"`
+		UploadID      string `json:"upload_id,omitempty"`
+		FirstChunk    int64  `json:"first_chunk,omitempty"`
+		LastChunk     int64  `json:"last_chunk,omitempty"`
+		TTLSeconds    int64  `json:"ttl_seconds,omitempty"`
+		ChunkSize     int64  `json:"chunk_size,omitempty"`
+		Compression   string `json:"compression,omitempty"`
+		Credits       bool   `json:"credits,omitempty"`
+		// Metadata is stored with the file as is, it must not contain secrets.
+		Metadata map[string]string `json:"metadata,omitempty"`
+	}
+
+	ChunkRequest struct {
+		UploadID    string `query:"upload_id" validate:"required"`
+		ChunkNumber int64  `query:"chunk_number" validate:"required"`
+	}
+
+	// DownloadRequest requests chunks from FirstChunk to LastChunk of the file, zero values mean the whole file.
+	// The file is referenced by UploadID or by Key inside Bucket.
+	DownloadRequest struct {
+		UploadID   string `query:"id"`
+		Bucket     string `query:"bucket"`
+		Key        string `query:"key"`
+		FirstChunk int64  `query:"from"`
+		LastChunk  int64  `query:"to"`
+	}
+
+	CommitRequest struct {
+		UploadID string `param:"id" json:"-" validate:"required"`
+		Checksum string `json:"checksum,omitempty"`
+	}
+
+	// AccessRequest changes who can read the file, SharedWith is used only with the shared access.
+	AccessRequest struct {
+		UploadID   string   `param:"id" json:"-" validate:"required"`
+		Access     string   `json:"access" validate:"required"`
+		SharedWith []string `json:"shared_with,omitempty"`
+	}
+
+	FileRequest struct {
+		UploadID string `param:"id" validate:"required"`
+	}
+
+	ListRequest struct {
+		Bucket string `query:"bucket"`
+		Prefix string `query:"prefix"`
+		Offset int64  `query:"offset"`
+		Limit  int64  `query:"limit"`
+	}
+
+	// ObjectRequest references the committed file by its key inside the bucket.
+	ObjectRequest struct {
+		Bucket string `param:"bucket" validate:"required"`
+		Key    string `param:"*" validate:"required"`
+	}
+
+	// BucketRequest creates the bucket or replaces its settings, zero limits mean no limit.
+	// ExpireAfterSeconds limits the TTL of new files, NoncurrentExpireAfterSeconds is the time noncurrent versions are kept.
+	BucketRequest struct {
+		Name                         string   `param:"bucket" json:"name" validate:"required"`
+		Replicas                     int      `json:"replicas"`
+		Versioning                   bool     `json:"versioning"`
+		MaxVersions                  int      `json:"max_versions"`
+		RetentionSeconds             int64    `json:"retention_seconds"`
+		ExpireAfterSeconds           int64    `json:"expire_after_seconds"`
+		NoncurrentExpireAfterSeconds int64    `json:"noncurrent_expire_after_seconds"`
+		ChunkSize                    int64    `json:"chunk_size,omitempty"`
+		Compression                  string   `json:"compression,omitempty"`
+		MaxBytes                     int64    `json:"max_bytes"`
+		MaxFiles                     int64    `json:"max_files"`
+		DefaultAccess                string   `json:"default_access,omitempty"`
+		DefaultSharedWith            []string `json:"default_shared_with,omitempty"`
+	}
+
+	// QuotaRequest sets limits of the user, the tenant or the bucket, zero limits mean no limit.
+	QuotaRequest struct {
+		Scope    string `param:"scope" json:"-" validate:"required"`
+		Name     string `param:"name" json:"-" validate:"required"`
+		MaxBytes int64  `json:"max_bytes"`
+		MaxFiles int64  `json:"max_files"`
+	}
+
+	DeleteRequest struct {
+		UploadID string `query:"upload_id" validate:"required"`
 	}
 )
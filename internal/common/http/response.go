@@ -0,0 +1,148 @@
+package http
+
+import (
+	"time"
+)
+
+type (
+	// UploadSession describes the new session. The session of the cdc chunking receives the whole content
+	// by the single stream, its TotalChunks is zero until the content is split and ChunkSize limits chunks.
+	// ChunkSize of fixed chunks is negotiated from the requested size, the size of the bucket or the master.
+	UploadSession struct {
+		UploadID    string `json:"upload_id"`
+		ChunkSize   int64  `json:"chunk_size"`
+		TotalChunks int64  `json:"total_chunks"`
+		Chunking    string `json:"chunking,omitempty"`
+	}
+
+	ChunkRange struct {
+		First int64 `json:"first"`
+		Last  int64 `json:"last"`
+	}
+
+	// SessionState describes the upload session and the chunks which are still missing.
+	SessionState struct {
+		UploadID      string       `json:"upload_id"`
+		TotalFileSize int64        `json:"total_file_size"`
+		ChunkSize     int64        `json:"chunk_size"`
+		TotalChunks   int64        `json:"total_chunks"`
+		Chunking      string       `json:"chunking,omitempty"`
+		Status        string       `json:"status"`
+		Missing       []ChunkRange `json:"missing"`
+	}
+
+	UploadResult struct {
+		UploadID       string `json:"upload_id"`
+		ChunksReceived int64  `json:"chunks_received"`
+	}
+
+	// UploadCredit allows the client which asked for credits to send Bytes more bytes of the content,
+	// credits are granted while the stream receives frames and the result follows them.
+	UploadCredit struct {
+		Bytes int64 `json:"credit_bytes"`
+	}
+
+	FileInfo struct {
+		UploadID      string            `json:"upload_id"`
+		Bucket        string            `json:"bucket,omitempty"`
+		Filename      string            `json:"filename"`
+		TotalFileSize int64             `json:"total_file_size"`
+		TotalChunks   int64             `json:"total_chunks"`
+		ChunkSize     int64             `json:"chunk_size"`
+		Chunking      string            `json:"chunking,omitempty"`
+		Compression   string            `json:"compression,omitempty"`
+		Checksum      string            `json:"checksum,omitempty"`
+		Status        string            `json:"status"`
+		Latest        bool              `json:"latest,omitempty"`
+		DeleteMarker  bool              `json:"delete_marker,omitempty"`
+		Owner         string            `json:"owner"`
+		Access        string            `json:"access"`
+		SharedWith    []string          `json:"shared_with,omitempty"`
+		Metadata      map[string]string `json:"metadata,omitempty"`
+		CreatedAt     time.Time         `json:"created_at"`
+		CommittedAt   time.Time         `json:"committed_at,omitempty"`
+		ExpiresAt     time.Time         `json:"expires_at,omitempty"`
+	}
+
+	// BucketInfo describes settings of the bucket, zero limits mean no limit.
+	BucketInfo struct {
+		Name                         string    `json:"name"`
+		Owner                        string    `json:"owner"`
+		Tenant                       string    `json:"tenant,omitempty"`
+		Replicas                     int       `json:"replicas"`
+		Versioning                   bool      `json:"versioning"`
+		MaxVersions                  int       `json:"max_versions"`
+		RetentionSeconds             int64     `json:"retention_seconds"`
+		ExpireAfterSeconds           int64     `json:"expire_after_seconds"`
+		NoncurrentExpireAfterSeconds int64     `json:"noncurrent_expire_after_seconds"`
+		ChunkSize                    int64     `json:"chunk_size,omitempty"`
+		Compression                  string    `json:"compression,omitempty"`
+		MaxBytes                     int64     `json:"max_bytes"`
+		MaxFiles                     int64     `json:"max_files"`
+		DefaultAccess                string    `json:"default_access"`
+		DefaultSharedWith            []string  `json:"default_shared_with,omitempty"`
+		CreatedAt                    time.Time `json:"created_at"`
+	}
+
+	// QuotaInfo describes limits and usage of the user, the tenant or the bucket,
+	// Custom is false when default limits apply.
+	QuotaInfo struct {
+		Scope     string `json:"scope"`
+		Name      string `json:"name"`
+		MaxBytes  int64  `json:"max_bytes"`
+		MaxFiles  int64  `json:"max_files"`
+		Custom    bool   `json:"custom"`
+		UsedBytes int64  `json:"used_bytes"`
+		UsedFiles int64  `json:"used_files"`
+	}
+
+	// DedupInfo describes bytes of the file or of all files, the empty UploadID means all files.
+	// LogicalBytes are uploaded bytes, StoredBytes are bytes which aren't shared with other chunks
+	// and CompressedBytes are bytes kept on nodes for them.
+	DedupInfo struct {
+		UploadID         string  `json:"upload_id,omitempty"`
+		LogicalBytes     int64   `json:"logical_bytes"`
+		StoredBytes      int64   `json:"stored_bytes"`
+		CompressedBytes  int64   `json:"compressed_bytes"`
+		DedupRatio       float64 `json:"dedup_ratio"`
+		CompressionRatio float64 `json:"compression_ratio"`
+	}
+
+	FileList struct {
+		Files      []*FileInfo `json:"files"`
+		NextOffset int64       `json:"next_offset,omitempty"`
+	}
+
+	NodeInfo struct {
+		Address   string        `json:"address"`
+		Size      int64         `json:"size"`
+		Free      int64         `json:"free"`
+		Used      int64         `json:"used"`
+		Available bool          `json:"available"`
+		Ready     bool          `json:"ready"`
+		Error     string        `json:"error,omitempty"`
+		Queue     NodeQueueInfo `json:"queue"`
+	}
+
+	// NodeQueueInfo describes requests of the master to the node, latencies are averages in milliseconds.
+	NodeQueueInfo struct {
+		Workers       int     `json:"workers"`
+		Queued        int     `json:"queued"`
+		Running       int     `json:"running"`
+		Completed     int64   `json:"completed"`
+		Failed        int64   `json:"failed"`
+		WaitLatencyMs float64 `json:"wait_latency_ms"`
+		RunLatencyMs  float64 `json:"run_latency_ms"`
+	}
+
+	// HealthResponse is the body of probes, Status is "ok" or "unavailable" and failed checks have errors.
+	HealthResponse struct {
+		Status string            `json:"status"`
+		Checks []HealthCheckInfo `json:"checks,omitempty"`
+	}
+
+	HealthCheckInfo struct {
+		Name  string `json:"name"`
+		Error string `json:"error,omitempty"`
+	}
+)
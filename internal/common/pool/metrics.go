@@ -0,0 +1,72 @@
+package pool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	queueDepthDesc = prometheus.NewDesc(
+		"master_queue_depth",
+		"Jobs waiting in queues of nodes by priorities.",
+		[]string{"node", "priority"}, nil,
+	)
+	queueBusyDesc = prometheus.NewDesc(
+		"master_queue_busy_workers",
+		"Workers of node queues which run jobs.",
+		[]string{"node"}, nil,
+	)
+	queueWorkersDesc = prometheus.NewDesc(
+		"master_queue_workers",
+		"Workers of node queues.",
+		[]string{"node"}, nil,
+	)
+	queueJobsDesc = prometheus.NewDesc(
+		"master_queue_jobs_total",
+		"Completed jobs of node queues.",
+		[]string{"node"}, nil,
+	)
+	queueFailedDesc = prometheus.NewDesc(
+		"master_queue_failed_jobs_total",
+		"Failed jobs of node queues.",
+		[]string{"node"}, nil,
+	)
+	queueRejectedDesc = prometheus.NewDesc(
+		"master_queue_rejected_jobs_total",
+		"Jobs rejected by full node queues.",
+		[]string{"node"}, nil,
+	)
+)
+
+type (
+	// collector exports stats of node queues when metrics are scraped
+	collector struct {
+		pool *Pool
+	}
+)
+
+// NewCollector creates the prometheus collector of queues of the pool.
+func NewCollector(wp *Pool) prometheus.Collector {
+	return &collector{pool: wp}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- queueBusyDesc
+	ch <- queueWorkersDesc
+	ch <- queueJobsDesc
+	ch <- queueFailedDesc
+	ch <- queueRejectedDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.pool.Stats() {
+		for priority, queued := range stats.Queued {
+			ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(queued), stats.Node, Priority(priority).String())
+		}
+		ch <- prometheus.MustNewConstMetric(queueBusyDesc, prometheus.GaugeValue, float64(stats.Running), stats.Node)
+		ch <- prometheus.MustNewConstMetric(queueWorkersDesc, prometheus.GaugeValue, float64(stats.Workers), stats.Node)
+		ch <- prometheus.MustNewConstMetric(queueJobsDesc, prometheus.CounterValue, float64(stats.Completed), stats.Node)
+		ch <- prometheus.MustNewConstMetric(queueFailedDesc, prometheus.CounterValue, float64(stats.Failed), stats.Node)
+		ch <- prometheus.MustNewConstMetric(queueRejectedDesc, prometheus.CounterValue, float64(stats.Rejected), stats.Node)
+	}
+}
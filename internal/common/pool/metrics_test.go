@@ -0,0 +1,120 @@
+package pool
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather collects metrics of the collector as values by their names with sorted labels
+func gather(t *testing.T, c prometheus.Collector) map[string]float64 {
+	t.Helper()
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatalf("register collector: %v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var labels []string
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+			sort.Strings(labels)
+
+			value := metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+			values[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = value
+		}
+	}
+
+	return values
+}
+
+func TestCollector(t *testing.T) {
+
+	wp := newTestPool(t, 1, map[string]int{"node-2": 2}, 2)
+	b := newBlocker()
+	defer close(b.release)
+
+	// node-1 runs one job, queues two and rejects the next one
+	if err := wp.Submit("node-1", PriorityNormal, b.job()); err != nil {
+		t.Fatalf("submit job: %v", err)
+	}
+	b.wait(t, 1)
+	for _, priority := range []Priority{PriorityInteractive, PriorityBackground} {
+		if err := wp.Submit("node-1", priority, b.job()); err != nil {
+			t.Fatalf("submit %v job: %v", priority, err)
+		}
+	}
+	if err := wp.Submit("node-1", PriorityNormal, b.job()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("submit to the full queue = %v, want %v", err, ErrQueueFull)
+	}
+
+	// node-2 completes the failed job and the successful one
+	for _, err := range []error{errors.New("failure"), nil} {
+		err := err
+		if err := wp.Submit("node-2", PriorityNormal, testJob(func() error { return err })); err != nil {
+			t.Fatalf("submit job: %v", err)
+		}
+	}
+	deadline := time.Now().Add(testTimeout)
+	for completed(wp, "node-2") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs of node-2 aren't completed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	values := gather(t, NewCollector(wp))
+
+	tests := []struct {
+		metric string
+		want   float64
+	}{
+		{metric: "master_queue_depth{node=node-1,priority=normal}", want: 0},
+		{metric: "master_queue_depth{node=node-1,priority=interactive}", want: 1},
+		{metric: "master_queue_depth{node=node-1,priority=background}", want: 1},
+		{metric: "master_queue_busy_workers{node=node-1}", want: 1},
+		{metric: "master_queue_workers{node=node-1}", want: 1},
+		{metric: "master_queue_rejected_jobs_total{node=node-1}", want: 1},
+		{metric: "master_queue_jobs_total{node=node-1}", want: 0},
+		{metric: "master_queue_busy_workers{node=node-2}", want: 0},
+		{metric: "master_queue_workers{node=node-2}", want: 2},
+		{metric: "master_queue_jobs_total{node=node-2}", want: 2},
+		{metric: "master_queue_failed_jobs_total{node=node-2}", want: 1},
+		{metric: "master_queue_rejected_jobs_total{node=node-2}", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			got, ok := values[tt.metric]
+			if !ok {
+				t.Fatalf("metric isn't collected, collected %v", values)
+			}
+			if got != tt.want {
+				t.Fatalf("value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// completed returns the count of completed jobs of the node
+func completed(wp *Pool, node string) int64 {
+	for _, stats := range wp.Stats() {
+		if stats.Node == node {
+			return stats.Completed
+		}
+	}
+
+	return 0
+}
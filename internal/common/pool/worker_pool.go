@@ -2,69 +2,358 @@ package pool
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// PriorityBackground runs jobs which nobody waits for, like rebalancing of chunks between nodes.
+	PriorityBackground Priority = iota
+	// PriorityNormal runs uploads of chunks.
+	PriorityNormal
+	// PriorityInteractive runs downloads which clients wait for, they go before other jobs of the node.
+	PriorityInteractive
+
+	priorities = 3
+
+	// drainInterval is the period of checks whether queues are empty while the pool shuts down
+	drainInterval = 50 * time.Millisecond
+)
+
+var (
+	// ErrQueueFull is returned by Submit when the queue of the node already holds the maximum of waiting jobs.
+	ErrQueueFull = errors.New("queue is full")
+	// ErrQueueClosed is returned by Submit when the queue of the node is stopped by the shutdown.
+	ErrQueueClosed = errors.New("queue is closed")
 )
 
 type (
+	// IJob is the job of the node queue, Fail is called instead of Do when the job
+	// is left in the queue which is stopped, so callers waiting for the job are notified.
 	IJob interface {
 		Do() error
+		Fail(err error)
 	}
 
-	Result struct {
-		Url       string
-		RequestID string
-		Error     error
-	}
+	// Priority orders jobs of the node queue, jobs of the same priority run in the order they are submitted.
+	Priority int
 
+	// Pool runs jobs of every node by workers of the node queue, so the slow or dead node
+	// occupies only its own workers. Submit doesn't block, it rejects jobs of the node
+	// which queue has reached the depth limit.
 	Pool struct {
-		taskQueue   chan IJob
-		resultChan  chan Result
+		ctx         context.Context
 		workerCount int
+		nodeWorkers map[string]int
+		queueDepth  int
+		queues      map[string]*queue
+		sync.Mutex
+	}
+
+	queue struct {
+		node    string
+		workers int
+		depth   int
+		jobs    [priorities][]*task
+		ready   *sync.Cond
+		closed  bool
+		running int
+		stats   QueueStats
+		sync.Mutex
 	}
 
-	Worker struct {
-		id        int
-		taskQueue <-chan IJob
-		result    chan<- Result
+	task struct {
+		job       IJob
+		submitted time.Time
+	}
+
+	// QueueStats describes jobs of the node queue, latencies are averages of completed jobs.
+	QueueStats struct {
+		Node        string
+		Workers     int
+		Queued      [priorities]int // waiting jobs by priority
+		Running     int
+		Completed   int64
+		Failed      int64
+		Rejected    int64         // jobs which Submit has rejected since the queue was full
+		WaitLatency time.Duration // from the submit to the start of the job
+		RunLatency  time.Duration
+		waitTotal   time.Duration
+		runTotal    time.Duration
 	}
 )
 
-func NewWorkerPool(workerCount int) *Pool {
+// NewWorkerPool creates the pool with workerCount workers for every node,
+// nodeWorkers overrides the count of workers of specific nodes.
+// Every node queue holds up to queueDepth waiting jobs, zero means queues are unbounded.
+func NewWorkerPool(workerCount int, nodeWorkers map[string]int, queueDepth int) *Pool {
+	if workerCount < 1 {
+		workerCount = 1
+	}
+
 	return &Pool{
-		taskQueue:   make(chan IJob),
-		resultChan:  make(chan Result),
+		ctx:         context.Background(),
 		workerCount: workerCount,
+		nodeWorkers: nodeWorkers,
+		queueDepth:  max(queueDepth, 0),
+		queues:      make(map[string]*queue),
 	}
 }
 
+// Start allows workers to run until the context is done, queues of nodes are created by their first jobs.
 func (wp *Pool) Start(ctx context.Context) {
-	for i := 0; i < wp.workerCount; i++ {
-		worker := Worker{id: i, taskQueue: wp.taskQueue, result: wp.resultChan}
-		worker.Start(ctx)
+	wp.Lock()
+	defer wp.Unlock()
+
+	wp.ctx = ctx
+}
+
+// Submit queues the job of the node with the priority,
+// the job isn't queued and ErrQueueFull is returned when the queue of the node is full.
+func (wp *Pool) Submit(node string, priority Priority, job IJob) error {
+
+	if priority < PriorityBackground || priority > PriorityInteractive {
+		priority = PriorityNormal
+	}
+
+	q := wp.queue(node)
+
+	q.Lock()
+	if q.closed {
+		q.Unlock()
+		return fmt.Errorf("%w: node %v", ErrQueueClosed, node)
+	}
+	if q.full() {
+		q.stats.Rejected++
+		q.Unlock()
+		return fmt.Errorf("%w: node %v has %v waiting jobs", ErrQueueFull, node, q.depth)
 	}
+	q.jobs[priority] = append(q.jobs[priority], &task{job: job, submitted: time.Now()})
+	q.Unlock()
+	q.ready.Signal()
+
+	return nil
 }
 
-func (w *Worker) Start(ctx context.Context) {
-	go func() {
-	sender:
-		for task := range w.taskQueue {
-			select {
-			case <-ctx.Done():
-				break sender
-			default:
-			}
-			err := task.Do()
-			if err != nil {
-				//w.result <- Result{Url: task.Url, Error: err}
-			}
+// Shutdown waits until workers run all submitted jobs and stops them,
+// jobs left in queues when ctx is done fail with the error of ctx.
+func (wp *Pool) Shutdown(ctx context.Context) error {
+
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	var err error
+	for err == nil && !wp.idle() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	wp.Lock()
+	queues := make([]*queue, 0, len(wp.queues))
+	for _, q := range wp.queues {
+		queues = append(queues, q)
+	}
+	wp.Unlock()
+
+	for _, q := range queues {
+		q.close(err)
+	}
+
+	return err
+}
+
+// idle reports whether queues have neither waiting nor running jobs
+func (wp *Pool) idle() bool {
+
+	for _, stats := range wp.Stats() {
+		if stats.Running > 0 || stats.Depth() > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Stats returns stats of queues of nodes which have received jobs, sorted by nodes.
+func (wp *Pool) Stats() []QueueStats {
+
+	wp.Lock()
+	queues := make([]*queue, 0, len(wp.queues))
+	for _, q := range wp.queues {
+		queues = append(queues, q)
+	}
+	wp.Unlock()
+
+	stats := make([]QueueStats, 0, len(queues))
+	for _, q := range queues {
+		stats = append(stats, q.snapshot())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Node < stats[j].Node
+	})
+
+	return stats
+}
 
+// queue returns the queue of the node, the new queue starts its workers
+func (wp *Pool) queue(node string) *queue {
+	wp.Lock()
+	defer wp.Unlock()
+
+	if q, ok := wp.queues[node]; ok {
+		return q
+	}
+
+	workers := wp.workerCount
+	if n, ok := wp.nodeWorkers[node]; ok && n > 0 {
+		workers = n
+	}
+
+	q := &queue{node: node, workers: workers, depth: wp.queueDepth}
+	q.ready = sync.NewCond(q)
+	wp.queues[node] = q
+
+	ctx := wp.ctx
+	context.AfterFunc(ctx, func() {
+		q.close(context.Cause(ctx))
+	})
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+// work runs jobs of the queue until it is closed
+func (q *queue) work() {
+	for {
+		t := q.next()
+		if t == nil {
+			return
+		}
+
+		started := time.Now()
+		err := t.job.Do()
+		q.done(started.Sub(t.submitted), time.Since(started), err)
+	}
+}
+
+// next waits for the job of the highest priority, nil means the queue is closed
+func (q *queue) next() *task {
+	q.Lock()
+	defer q.Unlock()
+
+	for {
+		if q.closed {
+			return nil
+		}
+		for priority := PriorityInteractive; priority >= PriorityBackground; priority-- {
+			if jobs := q.jobs[priority]; len(jobs) > 0 {
+				t := jobs[0]
+				jobs[0], q.jobs[priority] = nil, jobs[1:]
+				q.running++
+				return t
+			}
 		}
-	}()
+		q.ready.Wait()
+	}
 }
 
-func (wp *Pool) Submit(job IJob) {
-	wp.taskQueue <- job
+// full reports whether the queue holds the maximum of waiting jobs, the caller must hold the lock
+func (q *queue) full() bool {
+
+	if q.depth == 0 {
+		return false
+	}
+
+	var waiting int
+	for _, jobs := range q.jobs {
+		waiting += len(jobs)
+	}
+
+	return waiting >= q.depth
 }
 
-func (wp *Pool) GetResult() chan Result {
-	return wp.resultChan
+func (q *queue) done(wait, run time.Duration, err error) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.running--
+	q.stats.Completed++
+	if err != nil {
+		q.stats.Failed++
+	}
+	q.stats.waitTotal += wait
+	q.stats.runTotal += run
+}
+
+// close stops workers of the queue, waiting jobs fail with the error
+func (q *queue) close(err error) {
+	q.Lock()
+	q.closed = true
+	var left []*task
+	for priority, jobs := range q.jobs {
+		left = append(left, jobs...)
+		q.jobs[priority] = nil
+	}
+	q.Unlock()
+	q.ready.Broadcast()
+
+	if len(left) > 0 && err == nil {
+		err = ErrQueueClosed
+	}
+	for _, t := range left {
+		t.job.Fail(err)
+	}
+}
+
+func (q *queue) snapshot() QueueStats {
+	q.Lock()
+	defer q.Unlock()
+
+	stats := q.stats
+	stats.Node = q.node
+	stats.Workers = q.workers
+	stats.Running = q.running
+	for priority, jobs := range q.jobs {
+		stats.Queued[priority] = len(jobs)
+	}
+	if stats.Completed > 0 {
+		stats.WaitLatency = stats.waitTotal / time.Duration(stats.Completed)
+		stats.RunLatency = stats.runTotal / time.Duration(stats.Completed)
+	}
+
+	return stats
+}
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityBackground:
+		return "background"
+	case PriorityNormal:
+		return "normal"
+	case PriorityInteractive:
+		return "interactive"
+	}
+
+	return strconv.Itoa(int(p))
+}
+
+// Depth returns the count of waiting jobs of all priorities.
+func (s *QueueStats) Depth() int {
+
+	var depth int
+	for _, n := range s.Queued {
+		depth += n
+	}
+
+	return depth
 }
@@ -0,0 +1,413 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTimeout = time.Second
+
+type (
+	// testJob runs the function as the job of the pool
+	testJob func() error
+
+	// blocker holds jobs until it is released and counts jobs which run at once
+	blocker struct {
+		release chan struct{}
+		started chan struct{}
+		running int
+		peak    int
+		sync.Mutex
+	}
+)
+
+func (f testJob) Do() error {
+	return f()
+}
+
+func (f testJob) Fail(error) {}
+
+func newBlocker() *blocker {
+	return &blocker{
+		release: make(chan struct{}),
+		started: make(chan struct{}, 100),
+	}
+}
+
+// job returns the job which waits until the blocker is released
+func (b *blocker) job() IJob {
+	return testJob(func() error {
+		b.Lock()
+		b.running++
+		b.peak = max(b.peak, b.running)
+		b.Unlock()
+		b.started <- struct{}{}
+
+		<-b.release
+
+		b.Lock()
+		b.running--
+		b.Unlock()
+		return nil
+	})
+}
+
+// wait waits until n jobs have started
+func (b *blocker) wait(t *testing.T, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-b.started:
+		case <-time.After(testTimeout):
+			t.Fatalf("%v of %v jobs have started", i, n)
+		}
+	}
+}
+
+func newTestPool(t *testing.T, workerCount int, nodeWorkers map[string]int, queueDepth int) *Pool {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wp := NewWorkerPool(workerCount, nodeWorkers, queueDepth)
+	wp.Start(ctx)
+
+	return wp
+}
+
+func nodeStats(t *testing.T, wp *Pool, node string) QueueStats {
+	t.Helper()
+
+	for _, stats := range wp.Stats() {
+		if stats.Node == node {
+			return stats
+		}
+	}
+	t.Fatalf("no queue of node %v", node)
+
+	return QueueStats{}
+}
+
+func TestPoolNodeConcurrency(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		workerCount int
+		nodeWorkers map[string]int
+		node        string
+		want        int
+	}{
+		{name: "worker count", workerCount: 3, node: "a", want: 3},
+		{name: "minimum worker", workerCount: 0, node: "a", want: 1},
+		{name: "node override", workerCount: 3, nodeWorkers: map[string]int{"a": 2}, node: "a", want: 2},
+		{name: "override of another node", workerCount: 3, nodeWorkers: map[string]int{"b": 1}, node: "a", want: 3},
+		{name: "invalid override", workerCount: 3, nodeWorkers: map[string]int{"a": 0}, node: "a", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wp := newTestPool(t, tt.workerCount, tt.nodeWorkers, 0)
+			b := newBlocker()
+
+			for i := 0; i < tt.want+2; i++ {
+				if err := wp.Submit(tt.node, PriorityNormal, b.job()); err != nil {
+					t.Fatalf("submit: %v", err)
+				}
+			}
+			b.wait(t, tt.want)
+
+			stats := nodeStats(t, wp, tt.node)
+			if stats.Workers != tt.want {
+				t.Fatalf("workers = %v, want %v", stats.Workers, tt.want)
+			}
+			if stats.Running != tt.want {
+				t.Fatalf("running = %v, want %v", stats.Running, tt.want)
+			}
+			if stats.Depth() != 2 {
+				t.Fatalf("depth = %v, want 2", stats.Depth())
+			}
+
+			close(b.release)
+			b.wait(t, 2)
+			if err := wp.Shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown: %v", err)
+			}
+			b.Lock()
+			defer b.Unlock()
+			if b.peak != tt.want {
+				t.Fatalf("peak of running jobs = %v, want %v", b.peak, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolNodeIsolation(t *testing.T) {
+
+	wp := newTestPool(t, 1, nil, 0)
+	b := newBlocker()
+	defer close(b.release)
+
+	if err := wp.Submit("slow", PriorityNormal, b.job()); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	b.wait(t, 1)
+
+	done := make(chan struct{})
+	if err := wp.Submit("fast", PriorityNormal, testJob(func() error {
+		close(done)
+		return nil
+	})); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatalf("job of the fast node waits for the slow node")
+	}
+}
+
+func TestPoolPriorityOrder(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		submitted []Priority
+		want      []Priority
+	}{
+		{
+			name:      "higher priorities first",
+			submitted: []Priority{PriorityBackground, PriorityNormal, PriorityInteractive},
+			want:      []Priority{PriorityInteractive, PriorityNormal, PriorityBackground},
+		},
+		{
+			name:      "same priority in submit order",
+			submitted: []Priority{PriorityNormal, PriorityNormal, PriorityNormal},
+			want:      []Priority{PriorityNormal, PriorityNormal, PriorityNormal},
+		},
+		{
+			name:      "interleaved priorities",
+			submitted: []Priority{PriorityNormal, PriorityBackground, PriorityInteractive, PriorityNormal, PriorityInteractive},
+			want:      []Priority{PriorityInteractive, PriorityInteractive, PriorityNormal, PriorityNormal, PriorityBackground},
+		},
+		{
+			name:      "unknown priority is normal",
+			submitted: []Priority{PriorityBackground, Priority(7), PriorityInteractive},
+			want:      []Priority{PriorityInteractive, Priority(7), PriorityBackground},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wp := newTestPool(t, 1, nil, 0)
+
+			// the only worker is held, so all jobs are queued before any of them runs
+			b := newBlocker()
+			if err := wp.Submit("a", PriorityNormal, b.job()); err != nil {
+				t.Fatalf("submit: %v", err)
+			}
+			b.wait(t, 1)
+
+			type run struct {
+				priority Priority
+				index    int
+			}
+			var (
+				order []run
+				mu    sync.Mutex
+			)
+			for i, priority := range tt.submitted {
+				i, priority := i, priority
+				err := wp.Submit("a", priority, testJob(func() error {
+					mu.Lock()
+					order = append(order, run{priority: priority, index: i})
+					mu.Unlock()
+					return nil
+				}))
+				if err != nil {
+					t.Fatalf("submit: %v", err)
+				}
+			}
+
+			close(b.release)
+			if err := wp.Shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown: %v", err)
+			}
+
+			if len(order) != len(tt.want) {
+				t.Fatalf("%v jobs have run, want %v", len(order), len(tt.want))
+			}
+			for i, r := range order {
+				if r.priority != tt.want[i] {
+					t.Fatalf("job %v has priority %v, want %v", i, r.priority, tt.want[i])
+				}
+				// jobs of the same priority run in the submit order
+				if i > 0 && order[i-1].priority == r.priority && order[i-1].index > r.index {
+					t.Fatalf("job %v of priority %v runs before job %v", order[i-1].index, r.priority, r.index)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolQueueDepth(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		queueDepth int
+		submitted  int
+		wantQueued int
+	}{
+		{name: "unbounded", queueDepth: 0, submitted: 10, wantQueued: 10},
+		{name: "below the limit", queueDepth: 5, submitted: 3, wantQueued: 3},
+		{name: "at the limit", queueDepth: 5, submitted: 5, wantQueued: 5},
+		{name: "over the limit", queueDepth: 3, submitted: 7, wantQueued: 3},
+		{name: "negative means unbounded", queueDepth: -1, submitted: 4, wantQueued: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wp := newTestPool(t, 1, nil, tt.queueDepth)
+			b := newBlocker()
+			defer close(b.release)
+
+			// the running job doesn't count toward the depth
+			if err := wp.Submit("a", PriorityNormal, b.job()); err != nil {
+				t.Fatalf("submit: %v", err)
+			}
+			b.wait(t, 1)
+
+			var rejected int
+			for i := 0; i < tt.submitted; i++ {
+				err := wp.Submit("a", PriorityInteractive, b.job())
+				if errors.Is(err, ErrQueueFull) {
+					rejected++
+					continue
+				}
+				if err != nil {
+					t.Fatalf("submit: %v", err)
+				}
+			}
+
+			stats := nodeStats(t, wp, "a")
+			if stats.Depth() != tt.wantQueued {
+				t.Fatalf("depth = %v, want %v", stats.Depth(), tt.wantQueued)
+			}
+			if rejected != tt.submitted-tt.wantQueued {
+				t.Fatalf("rejected = %v, want %v", rejected, tt.submitted-tt.wantQueued)
+			}
+			if stats.Rejected != int64(rejected) {
+				t.Fatalf("rejected stats = %v, want %v", stats.Rejected, rejected)
+			}
+
+			// other nodes have their own queues
+			if err := wp.Submit("b", PriorityNormal, b.job()); err != nil {
+				t.Fatalf("submit to another node: %v", err)
+			}
+		})
+	}
+}
+
+// failedJob records the error of the job which the pool has dropped
+type failedJob chan error
+
+func (j failedJob) Do() error {
+	return nil
+}
+
+func (j failedJob) Fail(err error) {
+	j <- err
+}
+
+func TestPoolShutdown(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		held     bool
+		timeout  time.Duration
+		queued   int
+		wantErr  error
+		wantFail int
+	}{
+		{name: "drained", queued: 3, timeout: testTimeout},
+		{name: "expired", held: true, queued: 3, timeout: 20 * time.Millisecond, wantErr: context.DeadlineExceeded, wantFail: 3},
+		{name: "expired without waiting jobs", held: true, timeout: 20 * time.Millisecond, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wp := newTestPool(t, 1, nil, 0)
+			b := newBlocker()
+			defer close(b.release)
+
+			if tt.held {
+				if err := wp.Submit("a", PriorityNormal, b.job()); err != nil {
+					t.Fatalf("submit: %v", err)
+				}
+				b.wait(t, 1)
+			}
+
+			failed := make(failedJob, tt.queued)
+			for i := 0; i < tt.queued; i++ {
+				if err := wp.Submit("a", PriorityNormal, failed); err != nil {
+					t.Fatalf("submit: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			if err := wp.Shutdown(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("shutdown error = %v, want %v", err, tt.wantErr)
+			}
+			if len(failed) != tt.wantFail {
+				t.Fatalf("%v jobs have failed, want %v", len(failed), tt.wantFail)
+			}
+			for i := 0; i < tt.wantFail; i++ {
+				if err := <-failed; !errors.Is(err, tt.wantErr) {
+					t.Fatalf("job error = %v, want %v", err, tt.wantErr)
+				}
+			}
+			if stats := nodeStats(t, wp, "a"); stats.Depth() != 0 {
+				t.Fatalf("depth after the shutdown = %v, want 0", stats.Depth())
+			}
+
+			if err := wp.Submit("a", PriorityNormal, failed); !errors.Is(err, ErrQueueClosed) {
+				t.Fatalf("submit after the shutdown = %v, want %v", err, ErrQueueClosed)
+			}
+		})
+	}
+}
+
+func TestPoolContextDone(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wp := NewWorkerPool(1, nil, 0)
+	wp.Start(ctx)
+
+	b := newBlocker()
+	defer close(b.release)
+	if err := wp.Submit("a", PriorityNormal, b.job()); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	b.wait(t, 1)
+
+	failed := make(failedJob, 1)
+	if err := wp.Submit("a", PriorityNormal, failed); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	cancel()
+
+	select {
+	case err := <-failed:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("job error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("waiting job hasn't failed when the pool context is done")
+	}
+}
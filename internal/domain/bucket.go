@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"regexp"
+	"time"
+)
+
+const (
+	// QuotaScopeBucket limits files stored in the bucket.
+	QuotaScopeBucket QuotaScope = "bucket"
+
+	maxKeySize = 1024
+)
+
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+type (
+	// Bucket is the namespace of files, keys of committed files are unique inside the bucket.
+	// Members of the bucket are its owner and principals of its tenant.
+	Bucket struct {
+		Name     string
+		Owner    string
+		Tenant   string
+		Replicas int // count of copies of every chunk on distinct nodes
+		Quota    QuotaLimits
+		// Versioning keeps previous versions of keys when they are overwritten or deleted.
+		Versioning bool
+		// MaxVersions is the count of noncurrent versions of the key which are kept, zero keeps all of them.
+		MaxVersions int
+		// Retention is the time committed files are kept before they can be deleted.
+		Retention time.Duration
+		// ExpireAfter limits the TTL of new files, zero keeps files until they are deleted.
+		ExpireAfter time.Duration
+		// NoncurrentExpireAfter is the time noncurrent versions are kept, zero keeps them until they are pruned.
+		NoncurrentExpireAfter time.Duration
+		// ChunkSize is the size of fixed chunks of new files, the size of the master when zero.
+		ChunkSize int64
+		// Compression is the codec of new files which don't select their own one, empty for none.
+		Compression string
+		// DefaultAccess and DefaultSharedWith are applied to new files of the bucket.
+		DefaultAccess     FileAccess
+		DefaultSharedWith []string
+		CreatedAt         time.Time
+	}
+)
+
+// ValidBucketName reports whether the name can be used in bucket/key paths.
+func ValidBucketName(name string) bool {
+	return bucketNamePattern.MatchString(name)
+}
+
+// ValidKey reports whether the key can address the file inside the bucket.
+func ValidKey(key string) bool {
+	return key != "" && len(key) <= maxKeySize && key[0] != '/'
+}
+
+// IsMember reports whether the principal may store files in the bucket.
+func (b *Bucket) IsMember(principal *Principal) bool {
+
+	if principal == nil || principal.Subject == "" {
+		return false
+	}
+
+	return b.Owner == principal.Subject || (b.Tenant != "" && b.Tenant == principal.Tenant)
+}
+
+// CanManage reports whether the principal may change settings of the bucket or delete it.
+func (b *Bucket) CanManage(principal *Principal) bool {
+	return principal != nil && principal.Subject != "" && (b.Owner == principal.Subject || principal.Admin)
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// BlobChunkNumber is the chunk number of the blob content on storage nodes, every blob keeps a single chunk.
+const BlobChunkNumber = 1
+
+type (
+	// Blob is the chunk content shared by chunks of uploads with the same content.
+	// Nodes keep the content under ID as the upload, the blob is removed when no chunk references it.
+	Blob struct {
+		ID         string
+		Key        string // identifies the content with its replication, encryption and compression, see BlobKey
+		Hash       string // hex encoded sha256 of the stored content
+		Size       int64  // bytes of the content before compression
+		StoredSize int64  // bytes of the content kept on nodes
+		Codec      string // compression codec of the stored content, empty when it isn't compressed
+		Node       string
+		Replicas   []string // other nodes which keep copies of the content
+		Refs       int64    // count of chunks which reference the blob
+		CreatedAt  time.Time
+	}
+
+	// DedupStats compares bytes of chunks with bytes which are stored on nodes for them,
+	// copies of replicas aren't counted.
+	DedupStats struct {
+		LogicalBytes    int64 // bytes of all chunks
+		StoredBytes     int64 // bytes of chunks which content isn't shared with other chunks
+		CompressedBytes int64 // bytes kept on nodes for StoredBytes after compression
+	}
+)
+
+// BlobKey identifies the content which can be shared, chunks share it only with the same replication,
+// encryption and compression codec.
+func BlobKey(hash string, replicas int, nodeEncrypted bool, codec string) string {
+
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	key := fmt.Sprintf("%s/%d", hash, replicas)
+	if nodeEncrypted {
+		key += "/node"
+	}
+	if codec != "" {
+		key += "/" + codec
+	}
+
+	return key
+}
+
+// Nodes returns all nodes which keep the content, the primary one goes first.
+func (b *Blob) Nodes() []string {
+	return append([]string{b.Node}, b.Replicas...)
+}
+
+// Ratio returns the share of logical bytes which aren't stored again thanks to deduplication.
+func (s *DedupStats) Ratio() float64 {
+
+	if s.LogicalBytes == 0 || s.StoredBytes >= s.LogicalBytes {
+		return 0
+	}
+
+	return 1 - float64(s.StoredBytes)/float64(s.LogicalBytes)
+}
+
+// CompressionRatio returns the share of stored bytes which compression saves on nodes.
+func (s *DedupStats) CompressionRatio() float64 {
+
+	if s.StoredBytes == 0 || s.CompressedBytes >= s.StoredBytes {
+		return 0
+	}
+
+	return 1 - float64(s.CompressedBytes)/float64(s.StoredBytes)
+}
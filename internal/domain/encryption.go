@@ -0,0 +1,20 @@
+package domain
+
+const (
+	// EncryptionNone stores chunks as they are received.
+	EncryptionNone EncryptionMode = "none"
+	// EncryptionMaster encrypts chunks on the master, nodes keep only the ciphertext.
+	EncryptionMaster EncryptionMode = "master"
+	// EncryptionNode asks nodes to encrypt chunks before writing them.
+	EncryptionNode EncryptionMode = "node"
+)
+
+type (
+	EncryptionMode string
+
+	// WrappedKey is the data key of the file encrypted by the key of the keyring.
+	WrappedKey struct {
+		KeyID      string // id of the keyring key which encrypts the data key
+		Ciphertext []byte
+	}
+)
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+)
+
+const (
+	ErrorCodeInternal         ErrorCode = "internal"
+	ErrorCodeInvalidRequest   ErrorCode = "invalid_request"
+	ErrorCodeUnauthorized     ErrorCode = "unauthorized"
+	ErrorCodeForbidden        ErrorCode = "forbidden"
+	ErrorCodeNotFound         ErrorCode = "not_found"
+	ErrorCodeConflict         ErrorCode = "conflict"
+	ErrorCodeQuotaExceeded    ErrorCode = "quota_exceeded"
+	ErrorCodeChecksumMismatch ErrorCode = "checksum_mismatch"
+	ErrorCodeInvalidChunk     ErrorCode = "invalid_chunk"
+	ErrorCodeInvalidRange     ErrorCode = "invalid_range"
+	ErrorCodeNodeUnavailable  ErrorCode = "node_unavailable"
+	ErrorCodeUnavailable      ErrorCode = "unavailable"
+)
+
+var (
+	// ErrInvalidRequest is wrapped by errors of requests which can't be decoded.
+	ErrInvalidRequest = NewError(ErrorCodeInvalidRequest, "invalid request")
+	// ErrInvalidChunk is wrapped by errors of chunks and frames which don't match their upload.
+	ErrInvalidChunk = NewError(ErrorCodeInvalidChunk, "invalid chunk")
+)
+
+type (
+	// ErrorCode identifies the kind of the error in API responses, codes don't change between versions.
+	ErrorCode string
+
+	// Error is the error of the known kind, services declare their errors with codes,
+	// so handlers report them with the same statuses and close codes.
+	Error struct {
+		Code    ErrorCode
+		Message string
+	}
+)
+
+// NewError creates the error of the code.
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCodeOf returns the code of the first error of the chain which has one, errors without codes are internal.
+func ErrorCodeOf(err error) ErrorCode {
+
+	var codeErr *Error
+	if errors.As(err, &codeErr) {
+		return codeErr.Code
+	}
+
+	return ErrorCodeInternal
+}
@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	// FileStatusPending marks the upload session which is still receiving chunks.
+	FileStatusPending FileStatus = "pending"
+	// FileStatusCommitted marks the upload which has received all chunks.
+	FileStatusCommitted FileStatus = "committed"
+
+	// FileAccessPrivate allows only the owner to read the file.
+	FileAccessPrivate FileAccess = "private"
+	// FileAccessShared allows the owner and the listed principals to read the file.
+	FileAccessShared FileAccess = "shared"
+	// FileAccessPublic allows every authenticated principal to read the file.
+	FileAccessPublic FileAccess = "public"
+
+	// ChunkingFixed splits the content into chunks of ChunkSize, the last chunk may be shorter.
+	ChunkingFixed ChunkingMode = "fixed"
+	// ChunkingCDC splits the content on boundaries defined by the content, chunks are at most ChunkSize.
+	// The content is uploaded by the single stream and TotalChunks is known once it is received.
+	ChunkingCDC ChunkingMode = "cdc"
+
+	// DefaultChunkSize is the size of fixed chunks when neither the master nor the bucket configures it.
+	DefaultChunkSize = 50 << 10
+	// MinChunkSize and MaxChunkSize limit sizes of fixed chunks, every chunk is kept in memory while it is streamed.
+	MinChunkSize = 4 << 10
+	MaxChunkSize = 16 << 20
+)
+
+type (
+	FileStatus string
+
+	FileAccess string
+
+	// ChunkingMode selects how the content of the file is split into chunks, the empty mode is fixed.
+	ChunkingMode string
+
+	File struct {
+		ID            string // unique id of the upload.
+		Bucket        string // empty for files outside of buckets
+		Filename      string // the key of the file inside the bucket
+		TotalFileSize int64  // in bytes
+		TotalChunks   int64
+		ChunkSize     int64 // in bytes
+		Chunking      ChunkingMode
+		Checksum      string // hex encoded sha256 of the file content provided by the client
+		Status        FileStatus
+		Latest        bool          // the committed file is the current version of its key
+		DeleteMarker  bool          // the version hides previous versions of its key as deleted
+		Owner         string        // subject of the principal which created the upload
+		Tenant        string        // tenant of the owner
+		Replicas      int           // count of copies of every chunk, one copy when zero
+		Retention     time.Duration // the committed file can't be deleted before the retention elapses
+		TTL           time.Duration // the file expires when the TTL elapses since the upload session is created
+		ExpiresAt     time.Time     // zero when the file doesn't expire
+		Access        FileAccess
+		SharedWith    []string // subjects allowed to read the shared file
+		Encryption    EncryptionMode
+		Compression   string            // codec which compresses chunks before they are sent to nodes, empty for none
+		DataKey       *WrappedKey       // wrapped key of chunks encrypted on the master
+		Metadata      map[string]string // opaque metadata provided by the client
+		CreatedAt     time.Time
+		CommittedAt   time.Time
+		SupersededAt  time.Time // when the version stopped being the latest one
+	}
+
+	// FileFilter selects files from the catalog, zero Limit means no limit.
+	// Non-empty Reader selects only files which the principal with this subject can read.
+	// Noncurrent versions and delete markers are selected only with Versions.
+	FileFilter struct {
+		Bucket   string
+		Prefix   string
+		Status   FileStatus
+		Reader   string
+		Versions bool
+		Offset   int64
+		Limit    int64
+	}
+
+	// ChunkRange is the inclusive range of chunk numbers.
+	ChunkRange struct {
+		First int64
+		Last  int64
+	}
+
+	// ChunkLocation describes storage nodes which keep the specific chunk of the upload.
+	// The chunk with Blob references the shared content which nodes keep under the blob id.
+	ChunkLocation struct {
+		UploadID     string
+		ChunkNumber  int64
+		Size         int64  // bytes of the chunk content
+		StoredSize   int64  // bytes kept on nodes, zero means Size
+		Codec        string // compression codec of the stored content, empty when it isn't compressed
+		Node         string
+		Replicas     []string // other nodes which keep copies of the chunk
+		Blob         string
+		Deduplicated bool // the content was stored before by another chunk
+	}
+)
+
+// CanRead reports whether the principal with the subject is allowed to download the file.
+func (f *File) CanRead(subject string) bool {
+
+	if subject == "" {
+		return false
+	}
+
+	switch {
+	case f.Owner == subject:
+		return true
+	case f.Access == FileAccessPublic:
+		return true
+	case f.Access == FileAccessShared:
+		for _, shared := range f.SharedWith {
+			if shared == subject {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// CanWrite reports whether the principal with the subject is allowed to change or delete the file.
+func (f *File) CanWrite(subject string) bool {
+	return subject != "" && f.Owner == subject
+}
+
+// Valid reports whether the access is the known one.
+func (a FileAccess) Valid() bool {
+	switch a {
+	case FileAccessPrivate, FileAccessShared, FileAccessPublic:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContentDefined reports whether chunks of the file have content defined boundaries.
+func (f *File) ContentDefined() bool {
+	return f.Chunking == ChunkingCDC
+}
+
+// ChunkOffset returns the offset of the chunk in the file of the fixed chunking.
+func (f *File) ChunkOffset(chunkNumber int64) int64 {
+	return (chunkNumber - 1) * f.ChunkSize
+}
+
+// RangeSize returns the count of content bytes in chunks from first to last of the fixed chunking.
+func (f *File) RangeSize(first, last int64) int64 {
+	if last < first {
+		return 0
+	}
+
+	return min(last*f.ChunkSize, f.TotalFileSize) - f.ChunkOffset(first)
+}
+
+// ValidChunkSize reports whether fixed chunks can have the size.
+func ValidChunkSize(size int64) bool {
+	return size >= MinChunkSize && size <= MaxChunkSize
+}
+
+// MissingChunks returns ranges of chunk numbers from 1 to TotalChunks which aren't in the sorted list of stored ones.
+func (f *File) MissingChunks(stored []int64) []ChunkRange {
+
+	var (
+		missing []ChunkRange
+		next    int64 = 1
+	)
+
+	for _, number := range stored {
+		if number > next {
+			missing = append(missing, ChunkRange{First: next, Last: number - 1})
+		}
+		next = number + 1
+	}
+
+	if next <= f.TotalChunks {
+		missing = append(missing, ChunkRange{First: next, Last: f.TotalChunks})
+	}
+
+	return missing
+}
+
+// Nodes returns all nodes which keep the chunk, the primary one goes first.
+func (l *ChunkLocation) Nodes() []string {
+	return append([]string{l.Node}, l.Replicas...)
+}
+
+// Stored returns the upload ID and the chunk number which nodes keep the content of the chunk under.
+func (l *ChunkLocation) Stored() (string, int64) {
+	if l.Blob != "" {
+		return l.Blob, BlobChunkNumber
+	}
+
+	return l.UploadID, l.ChunkNumber
+}
+
+// Retained reports whether the retention of the committed file hasn't elapsed at the time.
+func (f *File) Retained(at time.Time) bool {
+	return f.Status == FileStatusCommitted && f.Retention > 0 && at.Before(f.CommittedAt.Add(f.Retention))
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "testing"
+
+func TestFileAccess(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		file      File
+		subject   string
+		wantRead  bool
+		wantWrite bool
+	}{
+		{name: "owner of the private file", file: File{Owner: "alice", Access: FileAccessPrivate}, subject: "alice", wantRead: true, wantWrite: true},
+		{name: "other of the private file", file: File{Owner: "alice", Access: FileAccessPrivate}, subject: "bob"},
+		{name: "principal of the shared file", file: File{Owner: "alice", Access: FileAccessShared, SharedWith: []string{"carol", "bob"}}, subject: "bob", wantRead: true},
+		{name: "other of the shared file", file: File{Owner: "alice", Access: FileAccessShared, SharedWith: []string{"carol"}}, subject: "bob"},
+		{name: "principals of the private file", file: File{Owner: "alice", Access: FileAccessPrivate, SharedWith: []string{"bob"}}, subject: "bob"},
+		{name: "other of the public file", file: File{Owner: "alice", Access: FileAccessPublic}, subject: "bob", wantRead: true},
+		{name: "anonymous of the public file", file: File{Owner: "alice", Access: FileAccessPublic}},
+		{name: "anonymous of the file without owner", file: File{Access: FileAccessPrivate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.file.CanRead(tt.subject); got != tt.wantRead {
+				t.Fatalf("CanRead(%q) = %v, want %v", tt.subject, got, tt.wantRead)
+			}
+			if got := tt.file.CanWrite(tt.subject); got != tt.wantWrite {
+				t.Fatalf("CanWrite(%q) = %v, want %v", tt.subject, got, tt.wantWrite)
+			}
+		})
+	}
+}
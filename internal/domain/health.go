@@ -0,0 +1,35 @@
+package domain
+
+type (
+	// HealthCheck is the result of one readiness check, the check without Error has passed.
+	HealthCheck struct {
+		Name  string
+		Error string
+	}
+
+	// Health lists readiness checks of the service.
+	Health []HealthCheck
+)
+
+// Ready reports whether all checks have passed.
+func (h Health) Ready() bool {
+
+	for _, check := range h {
+		if check.Error != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check appends the result of the check, nil err means it has passed.
+func (h Health) Check(name string, err error) Health {
+
+	check := HealthCheck{Name: name}
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	return append(h, check)
+}
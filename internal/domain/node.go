@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+)
+
+type (
+	// NodeState describes the capacity of the storage node as the master sees it.
+	NodeState struct {
+		Address   string
+		Size      int64 // in bytes
+		Free      int64 // in bytes
+		Used      int64 // in bytes
+		Available bool
+		Ready     bool // the node has passed its readiness checks
+		Error     string
+		Queue     NodeQueue
+	}
+
+	// NodeQueue describes jobs which the master runs against the node, latencies are averages of completed jobs.
+	NodeQueue struct {
+		Workers     int
+		Queued      int // jobs waiting for workers
+		Running     int
+		Completed   int64
+		Failed      int64
+		WaitLatency time.Duration // from the submit to the start of the job
+		RunLatency  time.Duration
+	}
+)
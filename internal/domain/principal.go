@@ -0,0 +1,11 @@
+package domain
+
+type (
+	// Principal is the authenticated caller of the master API.
+	Principal struct {
+		Subject string // the API key name or the JWT subject
+		Tenant  string // the tenant of the principal, empty when it doesn't belong to any
+		Admin   bool   // allowed to manage quotas
+		Method  string // how the principal was authenticated
+	}
+)
@@ -0,0 +1,52 @@
+package domain
+
+const (
+	// QuotaScopeUser limits files owned by the principal.
+	QuotaScopeUser QuotaScope = "user"
+	// QuotaScopeTenant limits files owned by all principals of the tenant.
+	QuotaScopeTenant QuotaScope = "tenant"
+)
+
+type (
+	QuotaScope string
+
+	// QuotaLimits are limits of committed files, zero values mean no limit.
+	QuotaLimits struct {
+		MaxBytes int64
+		MaxFiles int64
+	}
+
+	// Quota is the usage of the user or the tenant with its limits.
+	// Default limits apply when Custom is false.
+	Quota struct {
+		Scope     QuotaScope
+		Name      string
+		Limits    QuotaLimits
+		Custom    bool
+		UsedBytes int64
+		UsedFiles int64
+	}
+)
+
+// Valid reports whether the scope is the known one.
+func (s QuotaScope) Valid() bool {
+	switch s {
+	case QuotaScopeUser, QuotaScopeTenant, QuotaScopeBucket:
+		return true
+	default:
+		return false
+	}
+}
+
+// Allows reports whether one more file of the size fits the limits.
+func (q *Quota) Allows(size int64) bool {
+
+	if q.Limits.MaxFiles > 0 && q.UsedFiles+1 > q.Limits.MaxFiles {
+		return false
+	}
+	if q.Limits.MaxBytes > 0 && q.UsedBytes+size > q.Limits.MaxBytes {
+		return false
+	}
+
+	return true
+}
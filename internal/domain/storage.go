@@ -8,5 +8,7 @@ type (
 		TotalFileSize int64 // in bytes
 		Filename      string
 		Data          []byte
+		NodeEncrypted bool // the node encrypts the data at rest with the data key of the upload
+		Replicas      int  // count of distinct nodes which keep copies of the chunk, one copy when zero
 	}
 )
@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+
+	"node-test/pkg/tracing"
+)
+
+const (
+	operationUpload   = "upload"
+	operationDownload = "download"
+	operationDelete   = "delete"
+	operationState    = "state"
+	operationReady    = "ready"
+)
+
+var (
+	nodeRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "master",
+		Subsystem: "node",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of requests to storage nodes by nodes and operations.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"node", "operation"})
+
+	nodeRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "master",
+		Subsystem: "node",
+		Name:      "request_errors_total",
+		Help:      "Failed requests to storage nodes by nodes and operations.",
+	}, []string{"node", "operation"})
+)
+
+// track starts the span of the request to the node, the returned function ends it
+// and records the latency and the failure of the request
+func track(ctx context.Context, node, operation string) (context.Context, func(err error)) {
+
+	started := time.Now()
+	ctx, span := tracing.Start(ctx, "node."+operation, attribute.String("node", node))
+
+	return ctx, func(err error) {
+		nodeRequestDuration.WithLabelValues(node, operation).Observe(time.Since(started).Seconds())
+		if err != nil {
+			nodeRequestErrors.WithLabelValues(node, operation).Inc()
+		}
+		tracing.End(span, err)
+	}
+}
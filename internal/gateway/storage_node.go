@@ -2,60 +2,97 @@ package gateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"sync"
 
-	"github.com/gorilla/websocket"
-
 	commonRest "node-test/internal/common/http"
 	"node-test/internal/common/pool"
 	"node-test/internal/domain"
 	"node-test/internal/master/config"
+	"node-test/internal/node/handler/dto"
+	httpLib "node-test/pkg/http"
+	"node-test/pkg/tracing"
 )
 
 const (
 	minStorageNodeCount = 6
 
 	nodeStatePath            = "/state"
+	nodeReadyPath            = "/readyz"
+	nodeUploadPath           = "/upload"
+	nodeDownloadPath         = "/download"
 	nodeStateValueHeaderName = "X-NODE-STATE"
 )
 
+var (
+	// ErrNodeUnavailable is wrapped by errors of requests which the node didn't answer or failed to serve.
+	ErrNodeUnavailable = domain.NewError(domain.ErrorCodeNodeUnavailable, "node unavailable")
+)
+
 type (
 	nodes []*nodeState
 
 	nodeState struct {
 		ip           string
-		currentState int64
+		currentState int64 // free bytes which the node has reported, less bytes of chunks sent since then
 		sync.RWMutex
 	}
 
 	storageNodeGateway struct {
-		nodes nodes
-		cfg   config.StorageConfig
-		pool  *pool.Pool
+		nodes  nodes
+		cfg    config.StorageConfig
+		pool   *pool.Pool
+		client *http.Client
 		sync.RWMutex
 	}
 
 	StorageNodeGateway interface {
-		SendAsync(data *domain.Chunk)
-		DownloadAsync(id string) chan *domain.Chunk
+		SendAsync(ctx context.Context, data *domain.Chunk, done SendCallback)
+		Download(ctx context.Context, location *domain.ChunkLocation) (*domain.Chunk, error)
+		DownloadAsync(ctx context.Context, location *domain.ChunkLocation, done DownloadCallback)
+		Delete(ctx context.Context, node, uploadID string) error
+		Nodes(ctx context.Context) []*domain.NodeState
 	}
 
+	// SendCallback is called when all nodes have stored copies of the chunk or one of them failed to do it.
+	SendCallback func(nodes []string, err error)
+
+	// DownloadCallback is called when the chunk is retrieved from the node or the request failed.
+	DownloadCallback func(chunk *domain.Chunk, err error)
+
+	// sendAsyncJob stores one copy of the chunk on the node, copies are sent by queues of their nodes
 	sendAsyncJob struct {
-		url  string
-		data *commonRest.Chunk
+		ctx         context.Context
+		client      *http.Client
+		node        string
+		data        *commonRest.Chunk
+		replication *replication
 	}
 
+	// replication calls the callback once all copies of the chunk are stored or one of them has failed
+	replication struct {
+		nodes   []string
+		pending int
+		err     error
+		done    SendCallback
+		sync.Mutex
+	}
+
+	// downloadAsyncJob retrieves the chunk from the node of the attempt,
+	// the failed attempt is repeated by the queue of the next node which keeps the chunk
 	downloadAsyncJob struct {
-		url   string
-		id    string
-		chann chan *domain.Chunk
+		ctx      context.Context
+		gateway  *storageNodeGateway
+		location *domain.ChunkLocation
+		attempt  int
+		done     DownloadCallback
 	}
 )
 
@@ -63,8 +100,14 @@ func NewStorageNodeGateway(cfg config.StorageConfig, pool *pool.Pool) (StorageNo
 
 	fsNodes := make([]*nodeState, 0, len(cfg.Nodes))
 
+	client, err := newNodeClient(cfg.TLS.External())
+	if err != nil {
+		return nil, fmt.Errorf("can't configure the node client %w", err)
+	}
+
 	gateway := &storageNodeGateway{
-		pool: pool,
+		pool:   pool,
+		client: client,
 	}
 
 	for _, ip := range cfg.Nodes {
@@ -92,10 +135,11 @@ func (g *storageNodeGateway) loadState(ip string) (int64, error) {
 	g.Lock()
 	defer g.Unlock()
 
-	stateResponse, err := http.Get(ip + nodeStatePath)
+	stateResponse, err := g.client.Get(ip + nodeStatePath)
 	if err != nil {
 		return 0, err
 	}
+	defer stateResponse.Body.Close()
 
 	stateStr := stateResponse.Header.Get(nodeStateValueHeaderName)
 
@@ -107,96 +151,386 @@ func (g *storageNodeGateway) loadState(ip string) (int64, error) {
 	return int64(state), nil
 }
 
-// balanceStates sorting nodes by their current state
+// newNodeClient creates the http client which presents the certificate of the master to nodes
+func newNodeClient(cfg httpLib.TLSConfig) (*http.Client, error) {
+
+	if !cfg.Enabled() && cfg.CAFile == "" {
+		return http.DefaultClient, nil
+	}
+
+	tlsConfig, err := cfg.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// balanceStates sorts nodes by their free space, nodes with more free space go first
 func (g *storageNodeGateway) balanceStates() {
 	g.Lock()
 	defer g.Unlock()
-	sort.Slice(g.nodes, func(i, j int) bool {
-		g.nodes[i].Lock()
-		defer g.nodes[i].Unlock()
-		ext := g.nodes[i].currentState < g.nodes[j].currentState
-		return ext
+	sort.SliceStable(g.nodes, func(i, j int) bool {
+		return g.nodes[i].free() > g.nodes[j].free()
 	})
+}
 
-	return
+// free returns free bytes of the node
+func (s *nodeState) free() int64 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.currentState
 }
 
-// increase increases current state of the specific node
-func (s *nodeState) increase(len int64) {
+// reserve decreases free space of the node by the size of the chunk sent to it
+func (s *nodeState) reserve(size int64) {
 	s.Lock()
 	defer s.Unlock()
-	s.currentState = s.currentState + len
-	return
+	s.currentState -= size
 }
 
-// getUnloaded retrieves the node with more available space
-func (g *storageNodeGateway) getUnloaded() *nodeState {
+// getUnloaded retrieves count of nodes with more available space, nodes must be balanced first
+func (g *storageNodeGateway) getUnloaded(count int) ([]*nodeState, error) {
 	g.RLock()
 	defer g.RUnlock()
-	first := g.nodes[0]
-	return first
+	if count > len(g.nodes) {
+		return nil, fmt.Errorf("%v replicas requested, %v nodes are configured", count, len(g.nodes))
+	}
+	return append([]*nodeState(nil), g.nodes[:count]...), nil
 }
 
-// SendAsync sends the async request to the node to store specific chunk
-func (g *storageNodeGateway) SendAsync(data *domain.Chunk) {
+// SendAsync sends the async request to distinct nodes to store copies of specific chunk,
+// ctx carries the trace of the chunk, copies are stored even if it's canceled
+func (g *storageNodeGateway) SendAsync(ctx context.Context, data *domain.Chunk, done SendCallback) {
+
+	replicas := data.Replicas
+	if replicas < 1 {
+		replicas = 1
+	}
 
 	//  balance the state
 	g.balanceStates()
-	// get unloaded node
-	node := g.getUnloaded()
-
-	defer func() {
-		node.increase(int64(len(data.Data)))
-	}()
-
-	g.pool.Submit(&sendAsyncJob{
-		url: node.ip + "/upload",
-		data: &commonRest.Chunk{
-			UploadID:      data.UploadID,
-			ChunkNumber:   data.ChunkNumber,
-			TotalChunks:   data.TotalChunks,
-			TotalFileSize: data.TotalFileSize,
-			Filename:      data.Filename,
-			Data:          data.Data,
-		},
-	})
+	// get unloaded nodes
+	states, err := g.getUnloaded(replicas)
+	if err != nil {
+		done(nil, err)
+		return
+	}
 
-}
+	nodes := make([]string, 0, len(states))
+	for _, node := range states {
+		nodes = append(nodes, node.ip)
+		node.reserve(int64(len(data.Data)))
+	}
 
-func (g *storageNodeGateway) DownloadAsync(id string) chan *domain.Chunk {
+	chunk := &commonRest.Chunk{
+		UploadID:      data.UploadID,
+		ChunkNumber:   data.ChunkNumber,
+		TotalChunks:   data.TotalChunks,
+		TotalFileSize: data.TotalFileSize,
+		Filename:      data.Filename,
+		Data:          data.Data,
+		Encrypt:       data.NodeEncrypted,
+	}
 
-	var downloadChunk = make(chan *domain.Chunk, 1)
-	for _, node := range g.cfg.Nodes {
-		g.pool.Submit(&downloadAsyncJob{
-			url:   node,
-			id:    id,
-			chann: downloadChunk,
+	// copies are stored concurrently, every node runs the copy by its own queue
+	copies := &replication{nodes: nodes, pending: len(nodes), done: done}
+	for _, node := range nodes {
+		err := g.pool.Submit(node, pool.PriorityNormal, &sendAsyncJob{
+			ctx:         context.WithoutCancel(ctx),
+			client:      g.client,
+			node:        node,
+			data:        chunk,
+			replication: copies,
 		})
+		if err != nil {
+			copies.complete(fmt.Errorf("%w: %w", ErrNodeUnavailable, err))
+		}
+	}
+}
+
+// Download retrieves the chunk from the first node which keeps its copy and responds
+func (g *storageNodeGateway) Download(ctx context.Context, location *domain.ChunkLocation) (*domain.Chunk, error) {
+
+	var err error
+	for _, node := range location.Nodes() {
+		var data []byte
+		if data, err = g.download(ctx, node, location); err == nil {
+			return &domain.Chunk{
+				UploadID:    location.UploadID,
+				ChunkNumber: location.ChunkNumber,
+				Data:        data,
+			}, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
 	}
 
-	return downloadChunk
+	return nil, err
+}
+
+func (g *storageNodeGateway) download(ctx context.Context, node string, location *domain.ChunkLocation) (data []byte, err error) {
+
+	ctx, finish := track(ctx, node, operationDownload)
+	defer func() { finish(err) }()
+
+	uploadID, chunkNumber := location.Stored()
+
+	query := url.Values{}
+	query.Set("upload_id", uploadID)
+	query.Set("chunk_number", strconv.FormatInt(chunkNumber, 10))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, node+nodeDownloadPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request %w", err)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to send http request %w", ErrNodeUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode)
+	}
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk data %w", err)
+	}
+
+	return data, nil
+}
+
+// DownloadAsync submits the job to the queue of the primary node to retrieve the chunk,
+// downloads go before uploads of the node since clients wait for them
+func (g *storageNodeGateway) DownloadAsync(ctx context.Context, location *domain.ChunkLocation, done DownloadCallback) {
+	job := &downloadAsyncJob{
+		ctx:      ctx,
+		gateway:  g,
+		location: location,
+		done:     done,
+	}
+	job.submit()
+}
+
+// Delete removes all chunks of the upload from the node
+func (g *storageNodeGateway) Delete(ctx context.Context, node, uploadID string) (err error) {
+
+	ctx, finish := track(ctx, node, operationDelete)
+	defer func() { finish(err) }()
+
+	query := url.Values{}
+	query.Set("upload_id", uploadID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, node+nodeUploadPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create http request %w", err)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to send http request %w", ErrNodeUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Nodes requests the current state of every configured node
+func (g *storageNodeGateway) Nodes(ctx context.Context) []*domain.NodeState {
+
+	states := make([]*domain.NodeState, len(g.cfg.Nodes))
+
+	var wg sync.WaitGroup
+	for i, node := range g.cfg.Nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+
+			state := &domain.NodeState{Address: node, Queue: g.queue(node)}
+			states[i] = state
+			if err := g.requestState(ctx, state); err != nil {
+				state.Error = err.Error()
+				return
+			}
+			state.Available = true
+
+			// the available node may still refuse new chunks, like when its space is exhausted
+			if err := g.requestReady(ctx, node); err != nil {
+				state.Error = err.Error()
+				return
+			}
+			state.Ready = true
+		}(i, node)
+	}
+	wg.Wait()
+
+	return states
+}
+
+// queue returns stats of jobs of the node, the node without jobs yet has the empty queue
+func (g *storageNodeGateway) queue(node string) domain.NodeQueue {
+
+	for _, stats := range g.pool.Stats() {
+		if stats.Node == node {
+			return domain.NodeQueue{
+				Workers:     stats.Workers,
+				Queued:      stats.Depth(),
+				Running:     stats.Running,
+				Completed:   stats.Completed,
+				Failed:      stats.Failed,
+				WaitLatency: stats.WaitLatency,
+				RunLatency:  stats.RunLatency,
+			}
+		}
+	}
+
+	return domain.NodeQueue{}
+}
+
+// statusError describes the failed response of the node, the node failing to serve the request is unavailable
+func statusError(status int) error {
+	if status >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: bad request status code %v", ErrNodeUnavailable, status)
+	}
+
+	return fmt.Errorf("bad request status code %v", status)
+}
+
+func (g *storageNodeGateway) requestState(ctx context.Context, state *domain.NodeState) (err error) {
+
+	ctx, finish := track(ctx, state.Address, operationState)
+	defer func() { finish(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, state.Address+nodeStatePath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create http request %w", err)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to send http request %w", ErrNodeUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode)
+	}
+
+	var body dto.StateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode node state %w", err)
+	}
+
+	state.Size = body.NodeSize
+	state.Free = body.NodeAvailable
+	state.Used = body.NodeUsed
+
+	return nil
+}
+
+// requestReady checks the readiness of the node, the node which isn't ready answers with 503
+func (g *storageNodeGateway) requestReady(ctx context.Context, node string) (err error) {
+
+	ctx, finish := track(ctx, node, operationReady)
+	defer func() { finish(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, node+nodeReadyPath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create http request %w", err)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to send http request %w", ErrNodeUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body commonRest.HealthResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+			for _, check := range body.Checks {
+				if check.Error != "" {
+					return fmt.Errorf("%w: %v %v", ErrNodeUnavailable, check.Name, check.Error)
+				}
+			}
+		}
+		return fmt.Errorf("%w: readiness status code %v", ErrNodeUnavailable, resp.StatusCode)
+	}
+
+	return nil
 }
 
 func (j *sendAsyncJob) Do() error {
 
+	err := j.send(j.node)
+	if err != nil {
+		err = fmt.Errorf("node %v %w", j.node, err)
+	}
+	j.replication.complete(err)
+
+	return err
+}
+
+// Fail completes the copy which the queue of the node has dropped
+func (j *sendAsyncJob) Fail(err error) {
+	j.replication.complete(fmt.Errorf("node %v %w", j.node, err))
+}
+
+// complete records the result of the copy, the callback gets the first error once all copies are done
+func (r *replication) complete(err error) {
+	r.Lock()
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	r.pending--
+	last := r.pending == 0
+	r.Unlock()
+
+	if last && r.done != nil {
+		r.done(r.nodes, r.err)
+	}
+}
+
+func (j *sendAsyncJob) send(node string) (err error) {
+
+	ctx, finish := track(j.ctx, node, operationUpload)
+	defer func() { finish(err) }()
+
 	body, err := json.Marshal(j.data)
 	if err != nil {
 		return fmt.Errorf("failed marshal data %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, j.url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, node+nodeUploadPath, bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("failed to create http request %w", err)
 	}
+	tracing.Inject(ctx, req.Header)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := j.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send http request %v", err)
+		return fmt.Errorf("%w: failed to send http request %w", ErrNodeUnavailable, err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad request status code %v", resp.StatusCode)
+		return statusError(resp.StatusCode)
 	}
 
 	return nil
@@ -204,33 +538,45 @@ func (j *sendAsyncJob) Do() error {
 
 func (j *downloadAsyncJob) Do() error {
 
-	u := url.URL{Scheme: "ws", Host: j.url, Path: "/api/v1/download"}
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	nodes := j.location.Nodes()
+	data, err := j.gateway.download(j.ctx, nodes[j.attempt], j.location)
 	if err != nil {
+		if j.attempt+1 < len(nodes) && j.ctx.Err() == nil {
+			j.attempt++
+			j.submit()
+			return err
+		}
+		j.done(nil, err)
 		return err
 	}
-	defer conn.Close()
 
-	err = conn.WriteMessage(websocket.TextMessage, []byte(j.id))
-	if err != nil {
-		return fmt.Errorf("failed to send request  %w", err)
-	}
+	j.done(&domain.Chunk{
+		UploadID:    j.location.UploadID,
+		ChunkNumber: j.location.ChunkNumber,
+		Data:        data,
+	}, nil)
 
+	return nil
+}
+
+// Fail reports the error of the attempt which the queue of the node has dropped
+func (j *downloadAsyncJob) Fail(err error) {
+	j.done(nil, err)
+}
+
+// submit queues the attempt to the queue of its node, the attempt skips nodes which queues are full
+func (j *downloadAsyncJob) submit() {
+
+	nodes := j.location.Nodes()
 	for {
-		var resp commonRest.Chunk
-		if err := conn.ReadJSON(&resp); err != nil {
-			log.Println(err)
-			break
+		err := j.gateway.pool.Submit(nodes[j.attempt], pool.PriorityInteractive, j)
+		if err == nil {
+			return
 		}
-		j.chann <- &domain.Chunk{
-			UploadID:      j.id,
-			ChunkNumber:   resp.ChunkNumber,
-			TotalChunks:   resp.TotalChunks,
-			TotalFileSize: resp.TotalFileSize,
-			Filename:      resp.Filename,
-			Data:          resp.Data,
+		if j.attempt+1 == len(nodes) {
+			j.done(nil, fmt.Errorf("%w: %w", ErrNodeUnavailable, err))
+			return
 		}
+		j.attempt++
 	}
-
-	return nil
 }
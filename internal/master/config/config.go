@@ -2,22 +2,237 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
+	"node-test/internal/common/chunker"
+	"node-test/internal/domain"
 	configLib "node-test/pkg/config"
+	"node-test/pkg/http"
+	"node-test/pkg/mongodb"
+	"node-test/pkg/tracing"
 )
 
+// exampleCredentials are API keys and JWT secrets of the example configs, the master refuses them
+var exampleCredentials = []string{
+	"change-me-admin-api-key",
+	"change-me-jwt-secret-of-32-bytes-or-more",
+	"local-development-key",
+	"local-development-jwt-secret-change-me",
+}
+
 type Config struct {
 	Server      ServerConfig  `validate:"required"`
 	FileStorage StorageConfig `validate:"required"`
+	Mongo       MongoConfig   `validate:"required"`
+	Auth        AuthConfig
+	Encryption  EncryptionConfig
+	Quotas      QuotasConfig
+	Lifecycle   LifecycleConfig
+	Tracing     TracingConfig
 }
 
 type StorageConfig struct {
-	Nodes       []string `validate:"required"`
-	WorkerCount int      `validate:"required"`
+	Nodes []string `validate:"required"`
+	// count of nodes which must pass their readiness checks for the master to be ready, one when zero
+	MinReadyNodes int `validate:"min=0"`
+	// count of concurrent requests to every node, NodeWorkers overrides it for specific nodes
+	WorkerCount int                 `validate:"required"`
+	NodeWorkers []NodeWorkersConfig `validate:"dive"`
+	// count of requests waiting in the queue of every node, requests past it fail, unbounded when zero
+	QueueDepth int `validate:"min=0"`
+	// chunks with the same content are stored once and shared by files
+	Deduplication bool
+	// size of fixed chunks in bytes, buckets and uploads can select another one, 50KB when zero
+	ChunkSize int64 `validate:"omitempty,min=4096,max=16777216"`
+	Chunking  ChunkingConfig
+	// chunks which uploads keep in memory until nodes store them
+	Flow FlowConfig
+	// codec of new files which neither their uploads nor buckets select: none, gzip, zstd or snappy
+	Compression string
+	// client certificate presented to nodes and the CA which signs certificates of nodes
+	TLS TLSConfig
 }
 
 type ServerConfig struct {
 	Port int `validate:"required,min=80"`
+	TLS  TLSConfig
+}
+
+// MongoConfig describes the catalog database of the master.
+type MongoConfig struct {
+	URI      string `validate:"required,url"`
+	User     string `validate:"required"`
+	Password string `validate:"required"`
+	DB       string `validate:"required"`
+}
+
+func (cfg MongoConfig) External() mongodb.Config {
+	return mongodb.Config{
+		URI:      cfg.URI,
+		User:     cfg.User,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}
+}
+
+// AuthConfig lists credentials accepted by the master API.
+// Requests are rejected when neither API keys nor the JWT secret are configured.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `validate:"dive"`
+	JWT     JWTConfig
+}
+
+// APIKeyConfig is the static key, the name identifies its owner.
+type APIKeyConfig struct {
+	Name   string `validate:"required"`
+	Key    string `validate:"required,min=16"`
+	Tenant string
+	Admin  bool
+}
+
+// Validate rejects the JWT issuer or audience without the secret and credentials
+// of the example config, since everyone knows them.
+func (cfg AuthConfig) Validate() error {
+
+	if cfg.JWT.Secret == "" && (cfg.JWT.Issuer != "" || cfg.JWT.Audience != "") {
+		return errors.New("auth jwt secret is empty")
+	}
+	if slices.Contains(exampleCredentials, cfg.JWT.Secret) {
+		return errors.New("auth jwt secret is the example one")
+	}
+	for _, key := range cfg.APIKeys {
+		if slices.Contains(exampleCredentials, key.Key) {
+			return fmt.Errorf("auth api key %v is the example one", key.Name)
+		}
+	}
+
+	return nil
+}
+
+// JWTConfig describes HMAC signed bearer tokens, empty Issuer and Audience aren't checked.
+type JWTConfig struct {
+	Secret   string `validate:"omitempty,min=32"`
+	Issuer   string
+	Audience string
+}
+
+// QuotasConfig describes default limits of users and tenants without custom quotas.
+type QuotasConfig struct {
+	User   QuotaLimitsConfig
+	Tenant QuotaLimitsConfig
+}
+
+// QuotaLimitsConfig limits committed files, zero values mean no limit.
+type QuotaLimitsConfig struct {
+	MaxBytes int64 `validate:"min=0"`
+	MaxFiles int64 `validate:"min=0"`
+}
+
+func (cfg QuotasConfig) Defaults() map[domain.QuotaScope]domain.QuotaLimits {
+	return map[domain.QuotaScope]domain.QuotaLimits{
+		domain.QuotaScopeUser:   {MaxBytes: cfg.User.MaxBytes, MaxFiles: cfg.User.MaxFiles},
+		domain.QuotaScopeTenant: {MaxBytes: cfg.Tenant.MaxBytes, MaxFiles: cfg.Tenant.MaxFiles},
+	}
+}
+
+// ChunkingConfig selects how the content of new files is split, sizes of content defined chunks
+// are in bytes and zero sizes mean defaults.
+type ChunkingConfig struct {
+	Mode string `validate:"omitempty,oneof=fixed cdc"`
+	Min  int    `validate:"min=0"`
+	Avg  int    `validate:"min=0"`
+	Max  int    `validate:"min=0,max=16777216"`
+}
+
+// Params returns sizes of content defined chunks, nil means fixed chunks.
+func (cfg ChunkingConfig) Params() (*chunker.Params, error) {
+
+	if domain.ChunkingMode(cfg.Mode) != domain.ChunkingCDC {
+		return nil, nil
+	}
+
+	params := chunker.Params{Min: cfg.Min, Avg: cfg.Avg, Max: cfg.Max}.WithDefaults()
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	// chunks are streamed as single frames, the frame can't exceed the max size of chunks
+	if params.Max > domain.MaxChunkSize {
+		return nil, fmt.Errorf("max chunk size %v exceeds %v", params.Max, domain.MaxChunkSize)
+	}
+
+	return &params, nil
+}
+
+// NodeWorkersConfig is the count of concurrent requests to the node.
+type NodeWorkersConfig struct {
+	Address string `validate:"required"`
+	Workers int    `validate:"required,min=1"`
+}
+
+// Workers returns counts of concurrent requests of nodes which override WorkerCount.
+func (cfg StorageConfig) Workers() map[string]int {
+
+	workers := make(map[string]int, len(cfg.NodeWorkers))
+	for _, node := range cfg.NodeWorkers {
+		workers[node.Address] = node.Workers
+	}
+
+	return workers
+}
+
+// FlowConfig bounds chunks in flight, Window is the count of chunks of every upload stream and Memory is
+// the budget in bytes shared by all streams. Streams stop reading their clients while limits are reached,
+// zero values mean defaults.
+type FlowConfig struct {
+	Window int   `validate:"min=0"`
+	Memory int64 `validate:"min=0"`
+}
+
+// LifecycleConfig describes the worker which removes expired files, zero values mean defaults.
+type LifecycleConfig struct {
+	Interval time.Duration `validate:"min=0"`
+	Batch    int64         `validate:"min=0"`
+}
+
+// EncryptionConfig selects where chunks are encrypted at rest, the key file keeps keys
+// which wrap data keys of files encrypted on the master.
+type EncryptionConfig struct {
+	Mode    string `validate:"omitempty,oneof=none master node"`
+	KeyFile string `validate:"required_if=Mode master"`
+}
+
+// TLSConfig describes the certificate of the service and the CA of its peers, empty CertFile disables TLS.
+type TLSConfig struct {
+	CertFile string `validate:"required_with=KeyFile"`
+	KeyFile  string `validate:"required_with=CertFile"`
+	CAFile   string
+}
+
+func (cfg TLSConfig) External() http.TLSConfig {
+	return http.TLSConfig{
+		CertFile: cfg.CertFile,
+		KeyFile:  cfg.KeyFile,
+		CAFile:   cfg.CAFile,
+	}
+}
+
+// TracingConfig describes the OTLP gRPC collector which receives spans, empty Endpoint disables the export.
+// SampleRatio is the share of traced requests without the sampled caller, 1 when zero.
+type TracingConfig struct {
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64 `validate:"min=0,max=1"`
+}
+
+func (cfg TracingConfig) External() tracing.Config {
+	return tracing.Config{
+		Endpoint:    cfg.Endpoint,
+		Insecure:    cfg.Insecure,
+		SampleRatio: cfg.SampleRatio,
+	}
 }
 
 func GetConfig(ctx context.Context) (*Config, error) {
@@ -36,5 +251,9 @@ func GetConfig(ctx context.Context) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Auth.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+
+	"node-test/internal/domain"
+)
+
+func TestChunkingConfigParams(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		cfg   ChunkingConfig
+		fixed bool
+		valid bool
+	}{
+		{name: "fixed chunks", cfg: ChunkingConfig{Mode: "fixed"}, fixed: true, valid: true},
+		{name: "cdc defaults", cfg: ChunkingConfig{Mode: "cdc"}, valid: true},
+		{name: "cdc max chunk size", cfg: ChunkingConfig{Mode: "cdc", Min: 1 << 20, Avg: 4 << 20, Max: domain.MaxChunkSize}, valid: true},
+		{name: "cdc max above max chunk size", cfg: ChunkingConfig{Mode: "cdc", Min: 1 << 20, Avg: 4 << 20, Max: domain.MaxChunkSize + 1}},
+		{name: "cdc sizes don't grow", cfg: ChunkingConfig{Mode: "cdc", Min: 4096, Avg: 1024, Max: 8192}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			params, err := tt.cfg.Params()
+			if (err == nil) != tt.valid {
+				t.Fatalf("params: %v, want valid %v", err, tt.valid)
+			}
+			if tt.valid && (params == nil) != tt.fixed {
+				t.Fatalf("params %+v, want fixed %v", params, tt.fixed)
+			}
+		})
+	}
+}
+
+func TestAuthConfigValidate(t *testing.T) {
+
+	const (
+		secret = "generated-jwt-secret-0123456789abcdef"
+		key    = "generated-api-key-0123456789"
+	)
+
+	tests := []struct {
+		name  string
+		cfg   AuthConfig
+		valid bool
+	}{
+		{name: "no credentials", valid: true},
+		{name: "api keys", cfg: AuthConfig{APIKeys: []APIKeyConfig{{Name: "admin", Key: key, Admin: true}}}, valid: true},
+		{name: "jwt", cfg: AuthConfig{JWT: JWTConfig{Secret: secret, Issuer: "master"}}, valid: true},
+		{name: "jwt issuer without secret", cfg: AuthConfig{JWT: JWTConfig{Issuer: "master"}}},
+		{name: "jwt audience without secret", cfg: AuthConfig{JWT: JWTConfig{Audience: "storage"}}},
+		{name: "example jwt secret", cfg: AuthConfig{JWT: JWTConfig{Secret: "change-me-jwt-secret-of-32-bytes-or-more"}}},
+		{name: "former default jwt secret", cfg: AuthConfig{JWT: JWTConfig{Secret: "local-development-jwt-secret-change-me"}}},
+		{name: "example api key", cfg: AuthConfig{APIKeys: []APIKeyConfig{{Name: "admin", Key: key}, {Name: "local", Key: "change-me-admin-api-key"}}}},
+		{name: "former default api key", cfg: AuthConfig{APIKeys: []APIKeyConfig{{Name: "local", Key: "local-development-key", Admin: true}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err == nil) != tt.valid {
+				t.Fatalf("validate: %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
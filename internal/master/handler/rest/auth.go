@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	commonErrors "node-test/internal/common/errors"
+	"node-test/internal/domain"
+	"node-test/internal/master/config"
+	"node-test/internal/master/service"
+)
+
+const (
+	apiKeyHeaderName = "X-API-Key"
+	// tokenQueryParam carries the credential of the websocket upgrade, browsers can't set its headers.
+	tokenQueryParam = "token"
+	bearerPrefix    = "Bearer "
+
+	authMethodAPIKey = "api_key"
+	authMethodJWT    = "jwt"
+)
+
+var (
+	// tokenMethods are HMAC algorithms accepted for tokens signed by the shared secret
+	tokenMethods = []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodHS384.Alg(), jwt.SigningMethodHS512.Alg()}
+)
+
+var (
+	errMissingCredentials = domain.NewError(domain.ErrorCodeUnauthorized, "missing credentials")
+	errInvalidCredentials = domain.NewError(domain.ErrorCodeUnauthorized, "invalid credentials")
+)
+
+type (
+	authenticator struct {
+		apiKeys []config.APIKeyConfig
+		jwt     config.JWTConfig
+	}
+
+	// tokenClaims are registered claims with the tenant and the admin flag of the subject
+	tokenClaims struct {
+		jwt.RegisteredClaims
+		Tenant string `json:"tenant,omitempty"`
+		Admin  bool   `json:"admin,omitempty"`
+	}
+)
+
+func newAuthenticator(cfg config.AuthConfig) *authenticator {
+	return &authenticator{
+		apiKeys: cfg.APIKeys,
+		jwt:     cfg.JWT,
+	}
+}
+
+// Middleware rejects requests without the valid API key or JWT bearer token.
+// The credential is taken from the X-API-Key or Authorization header,
+// the token query parameter is accepted only on the websocket upgrade.
+func (a *authenticator) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+
+		p, err := a.authenticate(c.Request())
+		if err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="master"`)
+			return commonErrors.Respond(c, err)
+		}
+
+		c.SetRequest(c.Request().WithContext(service.ContextWithPrincipal(c.Request().Context(), p)))
+
+		return next(c)
+	}
+}
+
+// requireAdmin rejects requests of principals which aren't admins, it must follow Middleware.
+func requireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+
+		p := service.PrincipalFromContext(c.Request().Context())
+		if p == nil || !p.Admin {
+			return commonErrors.Respond(c, service.ErrAccessDenied)
+		}
+
+		return next(c)
+	}
+}
+
+func (a *authenticator) authenticate(r *http.Request) (*domain.Principal, error) {
+
+	if key := r.Header.Get(apiKeyHeaderName); key != "" {
+		return a.apiKey(key)
+	}
+
+	credential := r.Header.Get(echo.HeaderAuthorization)
+	if credential != "" {
+		if !strings.HasPrefix(credential, bearerPrefix) {
+			return nil, fmt.Errorf("%w: unsupported authorization scheme", errInvalidCredentials)
+		}
+		credential = strings.TrimPrefix(credential, bearerPrefix)
+	} else if websocket.IsWebSocketUpgrade(r) {
+		credential = r.URL.Query().Get(tokenQueryParam)
+	}
+
+	if credential == "" {
+		return nil, errMissingCredentials
+	}
+
+	// API keys may be sent as bearer tokens as well, everything else must be the JWT
+	if p, err := a.apiKey(credential); err == nil {
+		return p, nil
+	}
+
+	return a.token(credential)
+}
+
+// apiKey finds the configured key, every key is compared to not leak the match by timing
+func (a *authenticator) apiKey(key string) (*domain.Principal, error) {
+
+	var matched *domain.Principal
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 && matched == nil {
+			matched = &domain.Principal{Subject: k.Name, Tenant: k.Tenant, Admin: k.Admin, Method: authMethodAPIKey}
+		}
+	}
+
+	if matched == nil {
+		return nil, errInvalidCredentials
+	}
+
+	return matched, nil
+}
+
+// token validates the signature, the expiration and the configured issuer and audience of the JWT,
+// tokens without the expiration are rejected
+func (a *authenticator) token(credential string) (*domain.Principal, error) {
+
+	if a.jwt.Secret == "" {
+		return nil, errInvalidCredentials
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(tokenMethods), jwt.WithExpirationRequired()}
+	if a.jwt.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.jwt.Issuer))
+	}
+	if a.jwt.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.jwt.Audience))
+	}
+
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(credential, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(a.jwt.Secret), nil
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidCredentials, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", errInvalidCredentials)
+	}
+
+	return &domain.Principal{
+		Subject: claims.Subject,
+		Tenant:  claims.Tenant,
+		Admin:   claims.Admin,
+		Method:  authMethodJWT,
+	}, nil
+}
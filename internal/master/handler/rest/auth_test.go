@@ -0,0 +1,332 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"node-test/internal/master/config"
+	"node-test/internal/master/service"
+)
+
+const (
+	testAPIKey    = "test-api-key-0123456789"
+	testJWTSecret = "test-jwt-secret-0123456789abcdef"
+	testIssuer    = "master"
+)
+
+func newTestAuthRouter(cfg config.AuthConfig) *echo.Echo {
+
+	e := echo.New()
+	g := e.Group("/api/v1/storage")
+	g.Use(newAuthenticator(cfg).Middleware)
+	g.GET("/files", func(c echo.Context) error {
+		p := service.PrincipalFromContext(c.Request().Context())
+		return c.String(http.StatusOK, p.Subject)
+	})
+
+	return e
+}
+
+func testAuthConfig() config.AuthConfig {
+	return config.AuthConfig{
+		APIKeys: []config.APIKeyConfig{{Name: "tester", Key: testAPIKey}},
+		JWT:     config.JWTConfig{Secret: testJWTSecret, Issuer: testIssuer},
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	return token
+}
+
+func validClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    testIssuer,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestAuthenticatorRejects(t *testing.T) {
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	noExpiration := validClaims()
+	noExpiration.ExpiresAt = nil
+
+	foreignIssuer := validClaims()
+	foreignIssuer.Issuer = "someone-else"
+
+	noSubject := validClaims()
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name   string
+		cfg    config.AuthConfig
+		target string
+		header http.Header
+	}{
+		{
+			name:   "no credentials",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+		},
+		{
+			name:   "unknown api key",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{apiKeyHeaderName: {"unknown-api-key-0123456789"}},
+		},
+		{
+			name:   "unsupported scheme",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {"Basic " + testAPIKey}},
+		},
+		{
+			name:   "malformed token",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {bearerPrefix + "not.a.token"}},
+		},
+		{
+			name:   "foreign signature",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte("another-secret-0123456789abcdef"), validClaims()),
+			}},
+		},
+		{
+			name:   "unsigned token",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims()),
+			}},
+		},
+		{
+			name:   "expired token",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), expired),
+			}},
+		},
+		{
+			name:   "token without expiration",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), noExpiration),
+			}},
+		},
+		{
+			name:   "foreign issuer",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), foreignIssuer),
+			}},
+		},
+		{
+			name:   "token without subject",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), noSubject),
+			}},
+		},
+		{
+			name:   "token when jwt is disabled",
+			cfg:    config.AuthConfig{APIKeys: testAuthConfig().APIKeys},
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), validClaims()),
+			}},
+		},
+		{
+			name:   "nothing configured",
+			cfg:    config.AuthConfig{},
+			target: "/api/v1/storage/files",
+			header: http.Header{apiKeyHeaderName: {testAPIKey}},
+		},
+		{
+			name:   "query token without websocket upgrade",
+			cfg:    testAuthConfig(),
+			target: "/api/v1/storage/files?" + tokenQueryParam + "=" + testAPIKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, values := range tt.header {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			rec := httptest.NewRecorder()
+
+			newTestAuthRouter(tt.cfg).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status %v, want %v", rec.Code, http.StatusUnauthorized)
+			}
+			if rec.Header().Get(echo.HeaderWWWAuthenticate) == "" {
+				t.Fatalf("missing %v header", echo.HeaderWWWAuthenticate)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorAccepts(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		target  string
+		header  http.Header
+		subject string
+	}{
+		{
+			name:    "api key header",
+			target:  "/api/v1/storage/files",
+			header:  http.Header{apiKeyHeaderName: {testAPIKey}},
+			subject: "tester",
+		},
+		{
+			name:    "api key bearer",
+			target:  "/api/v1/storage/files",
+			header:  http.Header{echo.HeaderAuthorization: {bearerPrefix + testAPIKey}},
+			subject: "tester",
+		},
+		{
+			name:   "jwt bearer",
+			target: "/api/v1/storage/files",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), validClaims()),
+			}},
+			subject: "alice",
+		},
+		{
+			name:   "query token on websocket upgrade",
+			target: "/api/v1/storage/files?" + tokenQueryParam + "=" + testAPIKey,
+			header: http.Header{
+				"Connection": {"Upgrade"},
+				"Upgrade":    {"websocket"},
+			},
+			subject: "tester",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, values := range tt.header {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			rec := httptest.NewRecorder()
+
+			newTestAuthRouter(testAuthConfig()).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %v, want %v: %v", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if rec.Body.String() != tt.subject {
+				t.Fatalf("subject %q, want %q", rec.Body.String(), tt.subject)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+
+	cfg := testAuthConfig()
+	cfg.APIKeys = append(cfg.APIKeys, config.APIKeyConfig{Name: "root", Key: "admin-api-key-0123456789", Admin: true})
+
+	e := echo.New()
+	g := e.Group("/api/v1/admin")
+	g.Use(newAuthenticator(cfg).Middleware, requireAdmin)
+	g.GET("/quotas/:scope/:name", func(c echo.Context) error {
+		p := service.PrincipalFromContext(c.Request().Context())
+		return c.String(http.StatusOK, p.Tenant)
+	})
+
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+		tenant string
+	}{
+		{
+			name:   "regular api key",
+			header: http.Header{apiKeyHeaderName: {testAPIKey}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "admin api key",
+			header: http.Header{apiKeyHeaderName: {"admin-api-key-0123456789"}},
+			status: http.StatusOK,
+		},
+		{
+			name: "regular jwt",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), &tokenClaims{
+					RegisteredClaims: validClaims(),
+					Tenant:           "acme",
+				}),
+			}},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "admin jwt",
+			header: http.Header{echo.HeaderAuthorization: {
+				bearerPrefix + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), &tokenClaims{
+					RegisteredClaims: validClaims(),
+					Tenant:           "acme",
+					Admin:            true,
+				}),
+			}},
+			status: http.StatusOK,
+			tenant: "acme",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/quotas/user/alice", nil)
+			for name, values := range tt.header {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %v, want %v: %v", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.tenant {
+				t.Fatalf("tenant %q, want %q", rec.Body.String(), tt.tenant)
+			}
+		})
+	}
+}
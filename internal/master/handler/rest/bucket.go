@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	commonErrors "node-test/internal/common/errors"
+	commonHttp "node-test/internal/common/http"
+	"node-test/internal/domain"
+	"node-test/internal/master/service"
+)
+
+type (
+	bucketHandler struct {
+		service service.BucketService
+	}
+)
+
+func newBucketHandler(bucketService service.BucketService) *bucketHandler {
+	return &bucketHandler{
+		service: bucketService,
+	}
+}
+
+// Create registers the bucket owned by the caller
+func (h *bucketHandler) Create(c echo.Context) error {
+
+	var request commonHttp.BucketRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	bucket, err := h.service.Create(c.Request().Context(), newBucket(&request))
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, newBucketInfo(bucket))
+}
+
+// Bucket returns settings of the bucket
+func (h *bucketHandler) Bucket(c echo.Context) error {
+
+	bucket, err := h.service.Bucket(c.Request().Context(), c.Param("bucket"))
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newBucketInfo(bucket))
+}
+
+// List returns buckets which the caller is the member of
+func (h *bucketHandler) List(c echo.Context) error {
+
+	buckets, err := h.service.List(c.Request().Context())
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	list := make([]*commonHttp.BucketInfo, 0, len(buckets))
+	for _, bucket := range buckets {
+		list = append(list, newBucketInfo(bucket))
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+// Update replaces settings of the bucket
+func (h *bucketHandler) Update(c echo.Context) error {
+
+	var request commonHttp.BucketRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+	// the body can't rename the bucket
+	request.Name = c.Param("bucket")
+
+	bucket, err := h.service.Update(c.Request().Context(), newBucket(&request))
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newBucketInfo(bucket))
+}
+
+// Delete removes the empty bucket
+func (h *bucketHandler) Delete(c echo.Context) error {
+
+	if err := h.service.Delete(c.Request().Context(), c.Param("bucket")); err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func newBucket(request *commonHttp.BucketRequest) *domain.Bucket {
+	return &domain.Bucket{
+		Name:                  request.Name,
+		Replicas:              request.Replicas,
+		Versioning:            request.Versioning,
+		MaxVersions:           request.MaxVersions,
+		Quota:                 domain.QuotaLimits{MaxBytes: request.MaxBytes, MaxFiles: request.MaxFiles},
+		Retention:             time.Duration(request.RetentionSeconds) * time.Second,
+		ExpireAfter:           time.Duration(request.ExpireAfterSeconds) * time.Second,
+		NoncurrentExpireAfter: time.Duration(request.NoncurrentExpireAfterSeconds) * time.Second,
+		ChunkSize:             request.ChunkSize,
+		Compression:           request.Compression,
+		DefaultAccess:         domain.FileAccess(request.DefaultAccess),
+		DefaultSharedWith:     request.DefaultSharedWith,
+	}
+}
+
+func newBucketInfo(bucket *domain.Bucket) *commonHttp.BucketInfo {
+	return &commonHttp.BucketInfo{
+		Name:                         bucket.Name,
+		Owner:                        bucket.Owner,
+		Tenant:                       bucket.Tenant,
+		Replicas:                     bucket.Replicas,
+		Versioning:                   bucket.Versioning,
+		MaxVersions:                  bucket.MaxVersions,
+		RetentionSeconds:             int64(bucket.Retention / time.Second),
+		ExpireAfterSeconds:           int64(bucket.ExpireAfter / time.Second),
+		NoncurrentExpireAfterSeconds: int64(bucket.NoncurrentExpireAfter / time.Second),
+		ChunkSize:                    bucket.ChunkSize,
+		Compression:                  bucket.Compression,
+		MaxBytes:                     bucket.Quota.MaxBytes,
+		MaxFiles:                     bucket.Quota.MaxFiles,
+		DefaultAccess:                string(bucket.DefaultAccess),
+		DefaultSharedWith:            bucket.DefaultSharedWith,
+		CreatedAt:                    bucket.CreatedAt,
+	}
+}
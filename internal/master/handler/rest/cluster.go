@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	commonHttp "node-test/internal/common/http"
+	"node-test/internal/master/service"
+)
+
+type (
+	clusterHandler struct {
+		service service.ClusterService
+	}
+)
+
+func newClusterHandler(clusterService service.ClusterService) *clusterHandler {
+	return &clusterHandler{
+		service: clusterService,
+	}
+}
+
+// Nodes returns the state of storage nodes
+func (h *clusterHandler) Nodes(c echo.Context) error {
+
+	states := h.service.Nodes(c.Request().Context())
+
+	list := make([]*commonHttp.NodeInfo, 0, len(states))
+	for _, state := range states {
+		list = append(list, &commonHttp.NodeInfo{
+			Address:   state.Address,
+			Size:      state.Size,
+			Free:      state.Free,
+			Used:      state.Used,
+			Available: state.Available,
+			Ready:     state.Ready,
+			Error:     state.Error,
+			Queue: commonHttp.NodeQueueInfo{
+				Workers:       state.Queue.Workers,
+				Queued:        state.Queue.Queued,
+				Running:       state.Queue.Running,
+				Completed:     state.Queue.Completed,
+				Failed:        state.Queue.Failed,
+				WaitLatencyMs: milliseconds(state.Queue.WaitLatency),
+				RunLatencyMs:  milliseconds(state.Queue.RunLatency),
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	commonHttp "node-test/internal/common/http"
+	"node-test/internal/domain"
+	"node-test/internal/master/service"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+)
+
+type (
+	healthHandler struct {
+		service service.ClusterService
+	}
+)
+
+func newHealthHandler(clusterService service.ClusterService) *healthHandler {
+	return &healthHandler{
+		service: clusterService,
+	}
+}
+
+// Live reports that the master serves requests, it doesn't depend on the catalog and nodes.
+func (h *healthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, &commonHttp.HealthResponse{Status: healthStatusOK})
+}
+
+// Ready reports whether the master can serve uploads and downloads, the failed check is answered with 503.
+func (h *healthHandler) Ready(c echo.Context) error {
+
+	health := h.service.Ready(c.Request().Context())
+	if !health.Ready() {
+		return c.JSON(http.StatusServiceUnavailable, newHealthResponse(healthStatusUnavailable, health))
+	}
+
+	return c.JSON(http.StatusOK, newHealthResponse(healthStatusOK, health))
+}
+
+func newHealthResponse(status string, health domain.Health) *commonHttp.HealthResponse {
+
+	checks := make([]commonHttp.HealthCheckInfo, 0, len(health))
+	for _, check := range health {
+		checks = append(checks, commonHttp.HealthCheckInfo{Name: check.Name, Error: check.Error})
+	}
+
+	return &commonHttp.HealthResponse{Status: status, Checks: checks}
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	commonHttp "node-test/internal/common/http"
+	"node-test/internal/domain"
+	"node-test/internal/master/service"
+)
+
+// staticCluster is the cluster service which reports the same health
+type staticCluster struct {
+	service.ClusterService
+	health domain.Health
+}
+
+func (s *staticCluster) Ready(context.Context) domain.Health {
+	return s.health
+}
+
+func TestHealthHandler(t *testing.T) {
+
+	failed := domain.Health{}.Check("catalog", nil).Check("nodes", errors.New("0 of 3 nodes are ready"))
+
+	tests := []struct {
+		name       string
+		path       string
+		health     domain.Health
+		wantStatus int
+		wantBody   commonHttp.HealthResponse
+	}{
+		{
+			name:       "live",
+			path:       "/healthz",
+			health:     failed,
+			wantStatus: http.StatusOK,
+			wantBody:   commonHttp.HealthResponse{Status: healthStatusOK},
+		},
+		{
+			name:       "ready",
+			path:       "/readyz",
+			health:     domain.Health{}.Check("catalog", nil).Check("nodes", nil),
+			wantStatus: http.StatusOK,
+			wantBody: commonHttp.HealthResponse{
+				Status: healthStatusOK,
+				Checks: []commonHttp.HealthCheckInfo{{Name: "catalog"}, {Name: "nodes"}},
+			},
+		},
+		{
+			name:       "not ready",
+			path:       "/readyz",
+			health:     failed,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody: commonHttp.HealthResponse{
+				Status: healthStatusUnavailable,
+				Checks: []commonHttp.HealthCheckInfo{{Name: "catalog"}, {Name: "nodes", Error: "0 of 3 nodes are ready"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealthHandler(&staticCluster{health: tt.health})
+			e := echo.New()
+			e.GET("/healthz", h.Live)
+			e.GET("/readyz", h.Ready)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			var body commonHttp.HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+			}
+			if body.Status != tt.wantBody.Status || !slices.Equal(body.Checks, tt.wantBody.Checks) {
+				t.Fatalf("body = %+v, want %+v", body, tt.wantBody)
+			}
+		})
+	}
+}
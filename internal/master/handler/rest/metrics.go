@@ -0,0 +1,14 @@
+package rest
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	wsSessions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "master",
+		Name:      "websocket_sessions",
+		Help:      "Open WebSocket sessions of uploads and downloads.",
+	}, []string{"direction"})
+)
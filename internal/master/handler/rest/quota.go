@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	commonErrors "node-test/internal/common/errors"
+	commonHttp "node-test/internal/common/http"
+	"node-test/internal/domain"
+	"node-test/internal/master/service"
+)
+
+type (
+	quotaHandler struct {
+		service service.QuotaService
+	}
+)
+
+func newQuotaHandler(quotaService service.QuotaService) *quotaHandler {
+	return &quotaHandler{
+		service: quotaService,
+	}
+}
+
+// Usage returns quotas of the caller and its tenant
+func (h *quotaHandler) Usage(c echo.Context) error {
+
+	quotas, err := h.service.Usage(c.Request().Context())
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	list := make([]*commonHttp.QuotaInfo, 0, len(quotas))
+	for _, quota := range quotas {
+		list = append(list, newQuotaInfo(quota))
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+// Quota returns limits and usage of the user or the tenant
+func (h *quotaHandler) Quota(c echo.Context) error {
+
+	var request commonHttp.QuotaRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	quota, err := h.service.Quota(c.Request().Context(), domain.QuotaScope(request.Scope), request.Name)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newQuotaInfo(quota))
+}
+
+// SetQuota replaces limits of the user or the tenant
+func (h *quotaHandler) SetQuota(c echo.Context) error {
+
+	var request commonHttp.QuotaRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	quota, err := h.service.SetQuota(
+		c.Request().Context(),
+		domain.QuotaScope(request.Scope),
+		request.Name,
+		domain.QuotaLimits{MaxBytes: request.MaxBytes, MaxFiles: request.MaxFiles},
+	)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newQuotaInfo(quota))
+}
+
+func newQuotaInfo(quota *domain.Quota) *commonHttp.QuotaInfo {
+	return &commonHttp.QuotaInfo{
+		Scope:     string(quota.Scope),
+		Name:      quota.Name,
+		MaxBytes:  quota.Limits.MaxBytes,
+		MaxFiles:  quota.Limits.MaxFiles,
+		Custom:    quota.Custom,
+		UsedBytes: quota.UsedBytes,
+		UsedFiles: quota.UsedFiles,
+	}
+}
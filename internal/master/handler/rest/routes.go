@@ -3,15 +3,23 @@ package rest
 import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	commonErrors "node-test/internal/common/errors"
+	"node-test/internal/master/config"
 	"node-test/internal/master/service"
+	"node-test/pkg/tracing"
 )
 
 type (
 	RouterDependencies struct {
 		Logger         zap.Logger
+		Auth           config.AuthConfig
 		StorageService service.UploadService
+		ClusterService service.ClusterService
+		QuotaService   service.QuotaService
+		BucketService  service.BucketService
 	}
 )
 
@@ -32,6 +40,7 @@ func MakeRoutes(
 ) *echo.Echo {
 
 	e := echo.New()
+	e.HTTPErrorHandler = commonErrors.HTTPErrorHandler
 
 	//e.Use(middleware.Secure())
 	//e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
@@ -41,15 +50,73 @@ func MakeRoutes(
 	//	AllowCredentials: true,
 	//}))
 
+	auth := newAuthenticator(dependencies.Auth)
+
+	// metrics are scraped and probes are checked without the api key
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	healthH := newHealthHandler(dependencies.ClusterService)
+	e.GET("/healthz", healthH.Live)
+	e.GET("/readyz", healthH.Ready)
+
 	router := e.Group("/api/v1")
+	router.Use(tracing.Middleware())
 	storage := router.Group("/storage")
 	{
 		storageH := newStorageHandler(dependencies.StorageService)
+		quotaH := newQuotaHandler(dependencies.QuotaService)
 		storage.Use(middleware.Recover())
 		storage.Use(middleware.Logger())
+		storage.Use(auth.Middleware)
+		storage.POST("/sessions", storageH.CreateSession)
+		storage.GET("/sessions/:id", storageH.Session)
+		storage.POST("/sessions/:id/commit", storageH.CommitSession)
+		storage.GET("/files", storageH.List)
+		storage.GET("/files/:id", storageH.File)
+		storage.GET("/objects/:bucket/*", storageH.Object)
+		storage.DELETE("/objects/:bucket/*", storageH.DeleteObject)
+		storage.GET("/versions/:bucket/*", storageH.Versions)
+		storage.DELETE("/files/:id", storageH.Delete)
+		storage.PUT("/files/:id/access", storageH.SetAccess)
+		storage.GET("/files/:id/dedup", storageH.FileDedup)
 		storage.GET("/ws/upload", storageH.WSUpload)
 		storage.GET("/ws/download", storageH.WSDownload)
+		storage.GET("/quota", quotaH.Usage)
+
+	}
+
+	buckets := router.Group("/buckets")
+	{
+		bucketH := newBucketHandler(dependencies.BucketService)
+		buckets.Use(middleware.Recover())
+		buckets.Use(middleware.Logger())
+		buckets.Use(auth.Middleware)
+		buckets.POST("", bucketH.Create)
+		buckets.GET("", bucketH.List)
+		buckets.GET("/:bucket", bucketH.Bucket)
+		buckets.PUT("/:bucket", bucketH.Update)
+		buckets.DELETE("/:bucket", bucketH.Delete)
+	}
+
+	cluster := router.Group("/cluster")
+	{
+		clusterH := newClusterHandler(dependencies.ClusterService)
+		cluster.Use(middleware.Recover())
+		cluster.Use(middleware.Logger())
+		cluster.Use(auth.Middleware)
+		cluster.GET("/nodes", clusterH.Nodes)
+	}
 
+	admin := router.Group("/admin")
+	{
+		quotaH := newQuotaHandler(dependencies.QuotaService)
+		storageH := newStorageHandler(dependencies.StorageService)
+		admin.Use(middleware.Recover())
+		admin.Use(middleware.Logger())
+		admin.Use(auth.Middleware)
+		admin.Use(requireAdmin)
+		admin.GET("/quotas/:scope/:name", quotaH.Quota)
+		admin.PUT("/quotas/:scope/:name", quotaH.SetQuota)
+		admin.GET("/dedup", storageH.Dedup)
 	}
 
 	return e
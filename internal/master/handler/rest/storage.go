@@ -1,21 +1,39 @@
 package rest
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 
+	commonErrors "node-test/internal/common/errors"
 	commonHttp "node-test/internal/common/http"
 	"node-test/internal/domain"
 	"node-test/internal/master/service"
 )
 
 const (
-	maxChunkSize = 50 * 1024
+	maxListLimit = 1000
+
+	// maxFrameSize limits binary frames of uploads, the master cuts them into chunks.
+	maxFrameSize = domain.MaxChunkSize
+
+	// initialCredit is the count of chunks which the client asking for credits may send before
+	// the stream grants more, frames are granted again once they are read.
+	initialCredit = 4
+
+	// ttlHeaderName carries the TTL of the new file in seconds when the metadata doesn't have it.
+	ttlHeaderName = "X-File-TTL"
+)
+
+var (
+	errSessionClosed = domain.NewError(domain.ErrorCodeConflict, "upload session is closed")
 )
 
 type (
@@ -23,6 +41,17 @@ type (
 		service service.UploadService
 		socket  websocket.Upgrader
 	}
+
+	// frameReader reads the content of the known size from binary frames of the websocket.
+	// With credits the client must not send more bytes than the reader has granted.
+	frameReader struct {
+		ws        *websocket.Conn
+		frame     []byte
+		size      int
+		remaining int64
+		credits   bool
+		credit    int64
+	}
 )
 
 func newStorageHandler(storageService service.UploadService) *storageHandler {
@@ -32,93 +61,634 @@ func newStorageHandler(storageService service.UploadService) *storageHandler {
 	}
 }
 
-func (h *storageHandler) WSUpload(c echo.Context) error {
+// CreateSession registers the upload session which chunks may be sent by several websocket streams
+func (h *storageHandler) CreateSession(c echo.Context) error {
 
-	ws, err := h.socket.Upgrade(c.Response(), c.Request(), nil)
+	var request commonHttp.ChunkMetadata
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+	if err := ttlFromHeader(c.Request(), &request); err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	file, err := h.service.CreateSession(c.Request().Context(), newFile(&request))
 	if err != nil {
-		return err
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, &commonHttp.UploadSession{
+		UploadID:    file.ID,
+		ChunkSize:   file.ChunkSize,
+		TotalChunks: file.TotalChunks,
+		Chunking:    string(file.Chunking),
+	})
+}
+
+// Session returns the state of the upload session with the chunks which are still missing
+func (h *storageHandler) Session(c echo.Context) error {
+
+	var request commonHttp.FileRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
 	}
-	defer ws.Close()
 
 	ctx := c.Request().Context()
 
-	uploadChan := h.service.UploadChunkedAsync(ctx)
-	defer close(uploadChan)
+	file, err := h.service.Session(ctx, request.UploadID)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	missing, err := h.service.MissingChunks(ctx, file.ID)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	response := &commonHttp.SessionState{
+		UploadID:      file.ID,
+		TotalFileSize: file.TotalFileSize,
+		ChunkSize:     file.ChunkSize,
+		TotalChunks:   file.TotalChunks,
+		Chunking:      string(file.Chunking),
+		Status:        string(file.Status),
+		Missing:       make([]commonHttp.ChunkRange, 0, len(missing)),
+	}
+	for _, r := range missing {
+		response.Missing = append(response.Missing, commonHttp.ChunkRange{First: r.First, Last: r.Last})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// CommitSession commits the upload session when all chunks are stored
+func (h *storageHandler) CommitSession(c echo.Context) error {
+
+	var request commonHttp.CommitRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	file, err := h.service.Commit(c.Request().Context(), request.UploadID, request.Checksum)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newFileInfo(file))
+}
+
+// File returns the information about the uploaded file
+func (h *storageHandler) File(c echo.Context) error {
+
+	var request commonHttp.FileRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	file, err := h.service.File(c.Request().Context(), request.UploadID)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newFileInfo(file))
+}
+
+// FileDedup returns deduplication stats of the file
+func (h *storageHandler) FileDedup(c echo.Context) error {
+
+	var request commonHttp.FileRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	stats, err := h.service.Dedup(c.Request().Context(), request.UploadID)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newDedupInfo(request.UploadID, stats))
+}
+
+// Dedup returns deduplication stats of all stored files
+func (h *storageHandler) Dedup(c echo.Context) error {
+
+	stats, err := h.service.Dedup(c.Request().Context(), "")
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newDedupInfo("", stats))
+}
+
+// SetAccess changes who can read the file, only the owner of the file can do it
+func (h *storageHandler) SetAccess(c echo.Context) error {
 
-	var msg []byte
-	_, msg, err = ws.ReadMessage()
+	var request commonHttp.AccessRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	file, err := h.service.SetAccess(
+		c.Request().Context(),
+		request.UploadID,
+		domain.FileAccess(request.Access),
+		request.SharedWith,
+	)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newFileInfo(file))
+}
+
+// Object returns the information about the committed file referenced by its key inside the bucket
+func (h *storageHandler) Object(c echo.Context) error {
+
+	var request commonHttp.ObjectRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	file, err := h.service.Resolve(c.Request().Context(), request.Bucket, request.Key)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newFileInfo(file))
+}
+
+// Versions returns versions of the key inside the bucket, the latest version goes first
+func (h *storageHandler) Versions(c echo.Context) error {
+
+	var request commonHttp.ObjectRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	versions, err := h.service.Versions(c.Request().Context(), request.Bucket, request.Key)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	list := make([]*commonHttp.FileInfo, 0, len(versions))
+	for _, version := range versions {
+		list = append(list, newFileInfo(version))
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+// DeleteObject deletes the key inside the bucket, the delete marker is returned when the bucket keeps versions
+func (h *storageHandler) DeleteObject(c echo.Context) error {
+
+	var request commonHttp.ObjectRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	marker, err := h.service.DeleteKey(c.Request().Context(), request.Bucket, request.Key)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	if marker == nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	return c.JSON(http.StatusOK, newFileInfo(marker))
+}
+
+// List returns the page of committed files which names start with the requested prefix
+func (h *storageHandler) List(c echo.Context) error {
+
+	var request commonHttp.ListRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	if request.Limit <= 0 || request.Limit > maxListLimit {
+		request.Limit = maxListLimit
+	}
+
+	files, err := h.service.List(c.Request().Context(), &domain.FileFilter{
+		Bucket: request.Bucket,
+		Prefix: request.Prefix,
+		Offset: request.Offset,
+		Limit:  request.Limit,
+	})
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	response := &commonHttp.FileList{
+		Files: make([]*commonHttp.FileInfo, 0, len(files)),
+	}
+	for _, file := range files {
+		response.Files = append(response.Files, newFileInfo(file))
+	}
+	if int64(len(files)) == request.Limit {
+		response.NextOffset = request.Offset + request.Limit
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// Delete removes the file and its chunks
+func (h *storageHandler) Delete(c echo.Context) error {
+
+	var request commonHttp.FileRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	if err := h.service.Delete(c.Request().Context(), request.UploadID); err != nil {
+		return commonErrors.Respond(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *storageHandler) WSUpload(c echo.Context) error {
+
+	// the shutdown waits for the stream and interrupts it at its deadline
+	ctx, done, err := h.service.OpenStream(c.Request().Context())
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+	defer done()
+
+	ws, err := h.upgrade(c)
 	if err != nil {
 		return err
 	}
+	defer ws.Close()
+
+	sessions := wsSessions.WithLabelValues("upload")
+	sessions.Inc()
+	defer sessions.Dec()
+
+	// the interrupted stream stops waiting for frames of the client
+	stop := context.AfterFunc(ctx, func() {
+		_ = ws.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
 	var metadata commonHttp.ChunkMetadata
-	err = json.Unmarshal(msg, &metadata)
+	if err := ws.ReadJSON(&metadata); err != nil {
+		return closeWithError(c, ws, fmt.Errorf("%w: %v", domain.ErrInvalidRequest, err))
+	}
+	if err := ttlFromHeader(c.Request(), &metadata); err != nil {
+		return closeWithError(c, ws, err)
+	}
+
+	file, err := h.openUpload(ctx, &metadata)
 	if err != nil {
-		return err
+		return closeWithError(c, ws, err)
+	}
+
+	if file.ContentDefined() {
+		return h.uploadContent(ctx, c, ws, file, &metadata)
 	}
 
 	var (
-		totalChunkNum       = metadata.TotalFileSize / maxChunkSize
-		chunkNum      int64 = 1
-		chunkSent     int64 = 0
-		uploadID            = uuid.New().String()
+		implicit   = metadata.UploadID == ""
+		firstChunk = metadata.FirstChunk
+		lastChunk  = metadata.LastChunk
 	)
 
-	if metadata.TotalFileSize%maxChunkSize > 0 {
-		totalChunkNum++
+	if firstChunk == 0 {
+		firstChunk = 1
+	}
+	if lastChunk == 0 {
+		lastChunk = file.TotalChunks
+	}
+	if firstChunk < 1 || lastChunk > file.TotalChunks || firstChunk > lastChunk+1 {
+		return closeWithError(c, ws, fmt.Errorf("%w: %v-%v of %v", service.ErrInvalidRange, firstChunk, lastChunk, file.TotalChunks))
+	}
+
+	// frames may have any size, the content of the range is cut into chunks of the chunk size
+	content := &frameReader{ws: ws, remaining: file.RangeSize(firstChunk, lastChunk), credits: metadata.Credits}
+	if err := content.grant(initialCredit * file.ChunkSize); err != nil {
+		return nil
 	}
 
+	uploadChan, result := h.service.UploadChunkedAsync(ctx, file)
+
+	chunkNum := firstChunk
+
 upload:
-	for {
+	for ; chunkNum <= lastChunk; chunkNum++ {
+		data := make([]byte, file.RangeSize(chunkNum, chunkNum))
+		if _, readErr := io.ReadFull(content, data); readErr != nil {
+			err = readErr
+			break
+		}
+
 		select {
 		case <-ctx.Done():
-			break
-		default:
-			_, chunk, err := ws.ReadMessage()
-			if err != nil {
-				break upload
-			}
-
-			chunkSent += int64(len(chunk))
-			chunkNum++
-
-			if chunkSent > metadata.TotalFileSize {
-				err = fmt.Errorf(
-					"wrong count of data received! expected %v actual %v",
-					metadata.TotalFileSize,
-					chunkSent)
-
-				break upload
-			}
-
-			uploadChan <- &domain.Chunk{
-				UploadID:      uploadID,
-				ChunkNumber:   chunkNum,
-				TotalChunks:   totalChunkNum,
-				TotalFileSize: metadata.TotalFileSize,
-				Filename:      metadata.Filename,
-				Data:          chunk,
-			}
+			err = context.Cause(ctx)
+			break upload
+		case uploadChan <- &domain.Chunk{
+			UploadID:      file.ID,
+			ChunkNumber:   chunkNum,
+			TotalChunks:   file.TotalChunks,
+			TotalFileSize: file.TotalFileSize,
+			Filename:      file.Filename,
+			Data:          data,
+		}:
 		}
 	}
 
+	close(uploadChan)
+	if uploadErr := <-result; err == nil {
+		err = uploadErr
+	}
+
+	if err == nil && implicit {
+		_, err = h.service.Commit(ctx, file.ID, "")
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
+		return closeWithError(c, ws, err)
+	}
+
+	err = ws.WriteJSON(&commonHttp.UploadResult{
+		UploadID:       file.ID,
+		ChunksReceived: chunkNum - firstChunk,
+	})
 	if err != nil {
-		return c.String(http.StatusInternalServerError, err.Error())
+		return nil
 	}
 
-	return c.NoContent(http.StatusOK)
+	return closeNormal(ws)
 }
 
 func (h *storageHandler) WSDownload(c echo.Context) error {
 
-	ws, err := h.socket.Upgrade(c.Response(), c.Request(), nil)
+	var request commonHttp.DownloadRequest
+	if err := c.Bind(&request); err != nil {
+		return commonErrors.RespondInvalidRequest(c, err)
+	}
+
+	ctx := c.Request().Context()
+
+	if request.UploadID == "" {
+		file, err := h.service.Resolve(ctx, request.Bucket, request.Key)
+		if err != nil {
+			return commonErrors.Respond(c, err)
+		}
+		request.UploadID = file.ID
+	}
+
+	stream, err := h.service.DownloadStream(ctx, request.UploadID, request.FirstChunk, request.LastChunk)
+	if err != nil {
+		return commonErrors.Respond(c, err)
+	}
+	defer stream.Close()
+
+	ws, err := h.upgrade(c)
 	if err != nil {
 		return err
 	}
 	defer ws.Close()
 
-	ctx := c.Request().Context()
+	sessions := wsSessions.WithLabelValues("download")
+	sessions.Inc()
+	defer sessions.Dec()
+
+	if err := ws.WriteJSON(newFileInfo(stream.File())); err != nil {
+		return nil
+	}
+
+	for {
+		chunk, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return closeWithError(c, ws, err)
+		}
+
+		if err := ws.WriteMessage(websocket.BinaryMessage, chunk.Data); err != nil {
+			return nil
+		}
+	}
+
+	return closeNormal(ws)
+}
 
-	downloadList, err := h.service.DownloadChunked(ctx)
+// uploadContent receives the whole content of the file which the master splits into content defined chunks,
+// frames of any size up to maxFrameSize are accepted
+func (h *storageHandler) uploadContent(ctx context.Context, c echo.Context, ws *websocket.Conn, file *domain.File, metadata *commonHttp.ChunkMetadata) error {
 
-	return c.NoContent(http.StatusOK)
+	if metadata.FirstChunk != 0 || metadata.LastChunk != 0 {
+		return closeWithError(c, ws, fmt.Errorf("%w: content defined chunks are uploaded with the whole content", service.ErrInvalidRange))
+	}
+
+	content := &frameReader{ws: ws, remaining: file.TotalFileSize, credits: metadata.Credits}
+	if err := content.grant(initialCredit * file.ChunkSize); err != nil {
+		return nil
+	}
+
+	err := h.service.UploadContent(ctx, file, content)
+	if err == nil && metadata.UploadID == "" {
+		_, err = h.service.Commit(ctx, file.ID, "")
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
+		return closeWithError(c, ws, err)
+	}
+
+	err = ws.WriteJSON(&commonHttp.UploadResult{
+		UploadID:       file.ID,
+		ChunksReceived: file.TotalChunks,
+	})
+	if err != nil {
+		return nil
+	}
+
+	return closeNormal(ws)
+}
+
+// upgrade switches the request to the websocket which rejects frames larger than maxFrameSize,
+// the connection is closed once the header announces the larger frame, so its payload isn't read
+func (h *storageHandler) upgrade(c echo.Context) (*websocket.Conn, error) {
+
+	ws, err := h.socket.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(maxFrameSize)
+
+	return ws, nil
+}
+
+// Read reads frames of the websocket until the announced size of the content is received
+func (r *frameReader) Read(p []byte) (int, error) {
+
+	for len(r.frame) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+
+		// the connection limits frames to maxFrameSize before their payload is read
+		_, data, err := r.ws.ReadMessage()
+		if errors.Is(err, websocket.ErrReadLimit) {
+			return 0, fmt.Errorf("%w: frame exceeds the limit %v", domain.ErrInvalidChunk, maxFrameSize)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if int64(len(data)) > r.remaining {
+			return 0, fmt.Errorf("%w: content exceeds its size by %v bytes", domain.ErrInvalidChunk, int64(len(data))-r.remaining)
+		}
+		if r.credits && int64(len(data)) > r.credit {
+			return 0, fmt.Errorf("%w: frame of %v bytes exceeds the granted credit %v", domain.ErrInvalidChunk, len(data), r.credit)
+		}
+
+		r.remaining -= int64(len(data))
+		r.credit -= int64(len(data))
+		r.frame, r.size = data, len(data)
+	}
+
+	n := copy(p, r.frame)
+	r.frame = r.frame[n:]
+
+	// the read frame is granted again, so the client sends while chunks are accepted
+	if len(r.frame) == 0 {
+		if err := r.grant(int64(r.size)); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// grant allows the client asking for credits to send n more bytes, nothing is granted past the content
+func (r *frameReader) grant(n int64) error {
+
+	n = min(n, r.remaining-r.credit)
+	if !r.credits || n <= 0 {
+		return nil
+	}
+
+	r.credit += n
+
+	return r.ws.WriteJSON(&commonHttp.UploadCredit{Bytes: n})
+}
+
+// openUpload creates the new session or loads the existing one referenced by the metadata
+func (h *storageHandler) openUpload(ctx context.Context, metadata *commonHttp.ChunkMetadata) (*domain.File, error) {
+
+	if metadata.UploadID == "" {
+		return h.service.CreateSession(ctx, newFile(metadata))
+	}
+
+	file, err := h.service.Session(ctx, metadata.UploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Status != domain.FileStatusPending {
+		return nil, fmt.Errorf("%w: upload %v is already %v", errSessionClosed, file.ID, file.Status)
+	}
+
+	return file, nil
+}
+
+// ttlFromHeader fills the TTL of the metadata from the header, the TTL of the metadata takes precedence
+func ttlFromHeader(r *http.Request, metadata *commonHttp.ChunkMetadata) error {
+
+	header := r.Header.Get(ttlHeaderName)
+	if header == "" || metadata.TTLSeconds != 0 {
+		return nil
+	}
+
+	seconds, err := strconv.ParseInt(header, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: header %v %q", service.ErrInvalidTTL, ttlHeaderName, header)
+	}
+	metadata.TTLSeconds = seconds
+
+	return nil
+}
+
+func newFile(metadata *commonHttp.ChunkMetadata) *domain.File {
+	return &domain.File{
+		Bucket:        metadata.Bucket,
+		Filename:      metadata.Filename,
+		TotalFileSize: metadata.TotalFileSize,
+		Checksum:      metadata.Checksum,
+		TTL:           time.Duration(metadata.TTLSeconds) * time.Second,
+		ChunkSize:     metadata.ChunkSize,
+		Compression:   metadata.Compression,
+		Metadata:      metadata.Metadata,
+	}
+}
+
+func newFileInfo(file *domain.File) *commonHttp.FileInfo {
+	return &commonHttp.FileInfo{
+		UploadID:      file.ID,
+		Bucket:        file.Bucket,
+		Filename:      file.Filename,
+		TotalFileSize: file.TotalFileSize,
+		TotalChunks:   file.TotalChunks,
+		ChunkSize:     file.ChunkSize,
+		Chunking:      string(file.Chunking),
+		Compression:   file.Compression,
+		Checksum:      file.Checksum,
+		Status:        string(file.Status),
+		Latest:        file.Latest,
+		DeleteMarker:  file.DeleteMarker,
+		Owner:         file.Owner,
+		Access:        string(file.Access),
+		SharedWith:    file.SharedWith,
+		Metadata:      file.Metadata,
+		CreatedAt:     file.CreatedAt,
+		CommittedAt:   file.CommittedAt,
+		ExpiresAt:     file.ExpiresAt,
+	}
+}
+
+// closeWithError reports the error to the websocket client with the close frame,
+// the response can't be written with echo after the connection is upgraded.
+func closeWithError(c echo.Context, ws *websocket.Conn, err error) error {
+
+	// streams which the client has dropped aren't failures of the master
+	code := domain.ErrorCodeOf(err)
+	if commonErrors.Hidden(code) && !errors.As(err, new(*websocket.CloseError)) {
+		c.Logger().Errorf("%v %v %v", c.Request().Method, c.Path(), err)
+	}
+
+	_ = ws.WriteMessage(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(commonErrors.CloseCode(code), commonErrors.CloseReason(err)),
+	)
+
+	return nil
+}
+
+func closeNormal(ws *websocket.Conn) error {
+	_ = ws.WriteMessage(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+	)
+
+	return nil
+}
+
+func newDedupInfo(uploadID string, stats *domain.DedupStats) *commonHttp.DedupInfo {
+	return &commonHttp.DedupInfo{
+		UploadID:         uploadID,
+		LogicalBytes:     stats.LogicalBytes,
+		StoredBytes:      stats.StoredBytes,
+		CompressedBytes:  stats.CompressedBytes,
+		DedupRatio:       stats.Ratio(),
+		CompressionRatio: stats.CompressionRatio(),
+	}
 }
@@ -0,0 +1,359 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	commonErrors "node-test/internal/common/errors"
+	commonHttp "node-test/internal/common/http"
+	"node-test/internal/domain"
+	"node-test/internal/master/service"
+)
+
+// newTestSocket connects the client to the server side of the websocket
+func newTestSocket(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- ws
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server = <-conns
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return server, client
+}
+
+func readCredit(t *testing.T, client *websocket.Conn) int64 {
+	t.Helper()
+
+	var credit commonHttp.UploadCredit
+	if err := client.ReadJSON(&credit); err != nil {
+		t.Fatalf("read credit: %v", err)
+	}
+
+	return credit.Bytes
+}
+
+func TestFrameReaderGrant(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		remaining int64
+		credit    int64
+		grant     int64
+		want      int64
+	}{
+		{name: "grant", remaining: 100, grant: 40, want: 40},
+		{name: "capped by the content", remaining: 100, grant: 400, want: 100},
+		{name: "capped by the granted credit", remaining: 100, credit: 80, grant: 40, want: 20},
+		{name: "whole content is granted", remaining: 100, credit: 100, grant: 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestSocket(t)
+			r := &frameReader{ws: server, remaining: tt.remaining, credits: true, credit: tt.credit}
+
+			if err := r.grant(tt.grant); err != nil {
+				t.Fatalf("grant: %v", err)
+			}
+			if r.credit != tt.credit+tt.want {
+				t.Fatalf("credit = %v, want %v", r.credit, tt.credit+tt.want)
+			}
+
+			// the marker shows that nothing else was written when nothing is granted
+			if err := server.WriteJSON(&commonHttp.UploadCredit{Bytes: -1}); err != nil {
+				t.Fatalf("write marker: %v", err)
+			}
+			if tt.want > 0 {
+				if got := readCredit(t, client); got != tt.want {
+					t.Fatalf("granted %v bytes, want %v", got, tt.want)
+				}
+			}
+			if got := readCredit(t, client); got != -1 {
+				t.Fatalf("granted %v bytes, want nothing", got)
+			}
+		})
+	}
+}
+
+func TestFrameReaderCredits(t *testing.T) {
+
+	server, client := newTestSocket(t)
+	r := &frameReader{ws: server, remaining: 30, credits: true}
+	if err := r.grant(20); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if got := readCredit(t, client); got != 20 {
+		t.Fatalf("initial credit = %v, want 20", got)
+	}
+
+	// every read frame is granted again up to the rest of the content
+	frames := []struct {
+		size  int
+		grant int64
+	}{
+		{size: 15, grant: 10},
+		{size: 10},
+		{size: 5},
+	}
+	for _, frame := range frames {
+		if err := client.WriteMessage(websocket.BinaryMessage, make([]byte, frame.size)); err != nil {
+			t.Fatalf("write frame: %v", err)
+		}
+		n, err := io.ReadFull(r, make([]byte, frame.size))
+		if err != nil {
+			t.Fatalf("read frame of %v bytes: %v", frame.size, err)
+		}
+		if n != frame.size {
+			t.Fatalf("read %v bytes, want %v", n, frame.size)
+		}
+		if frame.grant > 0 {
+			if got := readCredit(t, client); got != frame.grant {
+				t.Fatalf("granted %v bytes after the frame of %v, want %v", got, frame.size, frame.grant)
+			}
+		}
+	}
+
+	if r.credit != 0 {
+		t.Fatalf("credit = %v after the content, want 0", r.credit)
+	}
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read after the content = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestFrameReaderExceedsCredit(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		credits bool
+		wantErr error
+	}{
+		{name: "credits", credits: true, wantErr: domain.ErrInvalidChunk},
+		{name: "no credits", credits: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestSocket(t)
+			r := &frameReader{ws: server, remaining: 100, credits: tt.credits}
+			if err := r.grant(10); err != nil {
+				t.Fatalf("grant: %v", err)
+			}
+			if tt.credits {
+				readCredit(t, client)
+			}
+
+			if err := client.WriteMessage(websocket.BinaryMessage, make([]byte, 20)); err != nil {
+				t.Fatalf("write frame: %v", err)
+			}
+			_, err := r.Read(make([]byte, 20))
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("read error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadFrameLimit(t *testing.T) {
+
+	read := make(chan error, 1)
+	h := newStorageHandler(nil)
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		ws, err := h.upgrade(c)
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+
+		r := &frameReader{ws: ws, remaining: 1 << 40}
+		_, err = r.Read(make([]byte, 1))
+		read <- err
+		return nil
+	})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	// only the header of the masked binary frame is sent, it announces the payload past the limit
+	header := []byte{0x82, 0x80 | 127}
+	header = binary.BigEndian.AppendUint64(header, uint64(maxFrameSize)+1)
+	header = append(header, 0, 0, 0, 0)
+	if _, err := client.UnderlyingConn().Write(header); err != nil {
+		t.Fatalf("write frame header: %v", err)
+	}
+
+	select {
+	case err := <-read:
+		if !errors.Is(err, domain.ErrInvalidChunk) {
+			t.Fatalf("read error = %v, want %v", err, domain.ErrInvalidChunk)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("oversized frame is read until its payload arrives")
+	}
+
+	_, _, err = client.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("client read error = %v, want close %v", err, websocket.CloseMessageTooBig)
+	}
+}
+
+// chunkRecorder is the storage service which records chunks uploaded to fixed sessions of the chunk size
+type chunkRecorder struct {
+	service.UploadService
+	chunkSize int64
+	chunks    []*domain.Chunk
+	committed bool
+}
+
+func (r *chunkRecorder) OpenStream(ctx context.Context) (context.Context, func(), error) {
+	return ctx, func() {}, nil
+}
+
+func (r *chunkRecorder) CreateSession(_ context.Context, file *domain.File) (*domain.File, error) {
+	file.ID = "a1"
+	file.Status = domain.FileStatusPending
+	file.Chunking = domain.ChunkingFixed
+	file.ChunkSize = r.chunkSize
+	file.TotalChunks = (file.TotalFileSize + r.chunkSize - 1) / r.chunkSize
+
+	return file, nil
+}
+
+func (r *chunkRecorder) UploadChunkedAsync(_ context.Context, _ *domain.File) (chan *domain.Chunk, <-chan error) {
+
+	uploadChan, result := make(chan *domain.Chunk), make(chan error, 1)
+	go func() {
+		for chunk := range uploadChan {
+			r.chunks = append(r.chunks, chunk)
+		}
+		result <- nil
+	}()
+
+	return uploadChan, result
+}
+
+func (r *chunkRecorder) Commit(_ context.Context, id, _ string) (*domain.File, error) {
+	r.committed = true
+
+	return &domain.File{ID: id, Status: domain.FileStatusCommitted}, nil
+}
+
+func TestWSUploadFrames(t *testing.T) {
+
+	const chunkSize = 100
+	content := make([]byte, 2*chunkSize+50)
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	tests := []struct {
+		name string
+		// frames are sizes of frames in turn, the last size repeats until the content is sent
+		frames []int
+		// excess is the count of bytes sent after the content
+		excess   int
+		wantCode domain.ErrorCode
+	}{
+		{name: "frames of the chunk size", frames: []int{chunkSize}},
+		{name: "single bytes", frames: []int{1}},
+		{name: "frames smaller than chunks", frames: []int{7}},
+		{name: "frames across chunks", frames: []int{130}},
+		{name: "whole content", frames: []int{len(content)}},
+		{name: "mixed frames", frames: []int{1, 99, 150, 3}},
+		{name: "content over its size", frames: []int{200}, excess: 50, wantCode: domain.ErrorCodeInvalidChunk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &chunkRecorder{chunkSize: chunkSize}
+			h := newStorageHandler(recorder)
+			e := echo.New()
+			e.GET("/ws", h.WSUpload)
+			srv := httptest.NewServer(e)
+			defer srv.Close()
+
+			client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer client.Close()
+
+			if err := client.WriteJSON(&commonHttp.ChunkMetadata{Filename: "a.bin", TotalFileSize: int64(len(content))}); err != nil {
+				t.Fatalf("write metadata: %v", err)
+			}
+
+			// frames past the content may be written after the master has closed the stream
+			sent := append(bytes.Clone(content), make([]byte, tt.excess)...)
+			for i := 0; len(sent) > 0; i++ {
+				size := min(tt.frames[min(i, len(tt.frames)-1)], len(sent))
+				if err := client.WriteMessage(websocket.BinaryMessage, sent[:size]); err != nil {
+					break
+				}
+				sent = sent[size:]
+			}
+
+			if tt.wantCode != "" {
+				_, _, err := client.ReadMessage()
+				var closeErr *websocket.CloseError
+				if !errors.As(err, &closeErr) || commonErrors.ParseCloseReason(closeErr.Text).Code != tt.wantCode {
+					t.Fatalf("upload error = %v, want %v", err, tt.wantCode)
+				}
+				return
+			}
+
+			var result commonHttp.UploadResult
+			if err := client.ReadJSON(&result); err != nil {
+				t.Fatalf("read result: %v", err)
+			}
+			if result.ChunksReceived != 3 || len(recorder.chunks) != 3 || !recorder.committed {
+				t.Fatalf("%v chunks received, committed %v, want 3 committed chunks", result.ChunksReceived, recorder.committed)
+			}
+
+			for i, chunk := range recorder.chunks {
+				start := i * chunkSize
+				want := content[start:min(start+chunkSize, len(content))]
+				if chunk.ChunkNumber != int64(i+1) || !bytes.Equal(chunk.Data, want) {
+					t.Fatalf("chunk %v of %v bytes, want chunk %v of %v bytes", chunk.ChunkNumber, len(chunk.Data), i+1, len(want))
+				}
+			}
+		})
+	}
+}
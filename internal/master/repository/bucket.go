@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"node-test/internal/domain"
+)
+
+const (
+	bucketsCollectionName = "buckets"
+)
+
+type (
+	bucketRepository struct {
+		buckets *mongo.Collection
+	}
+
+	// BucketRepository keeps buckets and their settings, quotas of buckets are kept by QuotaRepository.
+	BucketRepository interface {
+		AddBucket(ctx context.Context, bucket *domain.Bucket) error
+		Bucket(ctx context.Context, name string) (*domain.Bucket, error)
+		Buckets(ctx context.Context, member *domain.Principal) ([]*domain.Bucket, error)
+		UpdateBucket(ctx context.Context, bucket *domain.Bucket) error
+		DeleteBucket(ctx context.Context, name string) error
+		ExpiringVersionBuckets(ctx context.Context) ([]*domain.Bucket, error)
+	}
+
+	bucketDocument struct {
+		Name                  string        `bson:"_id"`
+		Owner                 string        `bson:"owner"`
+		Tenant                string        `bson:"tenant,omitempty"`
+		Replicas              int           `bson:"replicas"`
+		Versioning            bool          `bson:"versioning,omitempty"`
+		MaxVersions           int           `bson:"max_versions,omitempty"`
+		Retention             time.Duration `bson:"retention,omitempty"`
+		ExpireAfter           time.Duration `bson:"expire_after,omitempty"`
+		NoncurrentExpireAfter time.Duration `bson:"noncurrent_expire_after,omitempty"`
+		ChunkSize             int64         `bson:"chunk_size,omitempty"`
+		Compression           string        `bson:"compression,omitempty"`
+		DefaultAccess         string        `bson:"default_access"`
+		DefaultSharedWith     []string      `bson:"default_shared_with,omitempty"`
+		CreatedAt             time.Time     `bson:"created_at"`
+	}
+)
+
+// NewBucketRepository creates a new BucketRepository instance.
+func NewBucketRepository(database *mongo.Database) BucketRepository {
+	return &bucketRepository{
+		buckets: database.Collection(bucketsCollectionName),
+	}
+}
+
+// AddBucket registers the new bucket, ErrDuplicate is returned when the name is taken.
+func (repo *bucketRepository) AddBucket(ctx context.Context, bucket *domain.Bucket) error {
+
+	if _, err := repo.buckets.InsertOne(ctx, newBucketDocument(bucket)); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert bucket: %w", err)
+	}
+
+	return nil
+}
+
+// Bucket retrieves the bucket by its name.
+func (repo *bucketRepository) Bucket(ctx context.Context, name string) (*domain.Bucket, error) {
+
+	var doc bucketDocument
+	if err := repo.buckets.FindOne(ctx, bson.D{{Key: "_id", Value: name}}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("find bucket: %w", err)
+	}
+
+	return doc.toDomain(), nil
+}
+
+// Buckets returns buckets which the principal is the member of ordered by name.
+func (repo *bucketRepository) Buckets(ctx context.Context, member *domain.Principal) ([]*domain.Bucket, error) {
+
+	members := bson.A{bson.D{{Key: "owner", Value: member.Subject}}}
+	if member.Tenant != "" {
+		members = append(members, bson.D{{Key: "tenant", Value: member.Tenant}})
+	}
+
+	cursor, err := repo.buckets.Find(ctx, bson.D{{Key: "$or", Value: members}}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find buckets: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bucketDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode buckets: %w", err)
+	}
+
+	list := make([]*domain.Bucket, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+// UpdateBucket replaces settings of the bucket, its owner and tenant aren't changed.
+func (repo *bucketRepository) UpdateBucket(ctx context.Context, bucket *domain.Bucket) error {
+
+	doc := newBucketDocument(bucket)
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "replicas", Value: doc.Replicas},
+		{Key: "versioning", Value: doc.Versioning},
+		{Key: "max_versions", Value: doc.MaxVersions},
+		{Key: "retention", Value: doc.Retention},
+		{Key: "expire_after", Value: doc.ExpireAfter},
+		{Key: "noncurrent_expire_after", Value: doc.NoncurrentExpireAfter},
+		{Key: "chunk_size", Value: doc.ChunkSize},
+		{Key: "compression", Value: doc.Compression},
+		{Key: "default_access", Value: doc.DefaultAccess},
+		{Key: "default_shared_with", Value: doc.DefaultSharedWith},
+	}}}
+
+	res, err := repo.buckets.UpdateByID(ctx, bucket.Name, update)
+	if err != nil {
+		return fmt.Errorf("update bucket: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteBucket removes the bucket, its files must be deleted before.
+func (repo *bucketRepository) DeleteBucket(ctx context.Context, name string) error {
+
+	res, err := repo.buckets.DeleteOne(ctx, bson.D{{Key: "_id", Value: name}})
+	if err != nil {
+		return fmt.Errorf("delete bucket: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ExpiringVersionBuckets returns buckets which noncurrent versions expire ordered by name.
+func (repo *bucketRepository) ExpiringVersionBuckets(ctx context.Context) ([]*domain.Bucket, error) {
+
+	query := bson.D{{Key: "noncurrent_expire_after", Value: bson.D{{Key: "$gt", Value: 0}}}}
+
+	cursor, err := repo.buckets.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find buckets: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bucketDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode buckets: %w", err)
+	}
+
+	list := make([]*domain.Bucket, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+func newBucketDocument(bucket *domain.Bucket) *bucketDocument {
+	return &bucketDocument{
+		Name:                  bucket.Name,
+		Owner:                 bucket.Owner,
+		Tenant:                bucket.Tenant,
+		Replicas:              bucket.Replicas,
+		Versioning:            bucket.Versioning,
+		MaxVersions:           bucket.MaxVersions,
+		Retention:             bucket.Retention,
+		ExpireAfter:           bucket.ExpireAfter,
+		NoncurrentExpireAfter: bucket.NoncurrentExpireAfter,
+		ChunkSize:             bucket.ChunkSize,
+		Compression:           bucket.Compression,
+		DefaultAccess:         string(bucket.DefaultAccess),
+		DefaultSharedWith:     bucket.DefaultSharedWith,
+		CreatedAt:             bucket.CreatedAt,
+	}
+}
+
+func (doc *bucketDocument) toDomain() *domain.Bucket {
+	return &domain.Bucket{
+		Name:                  doc.Name,
+		Owner:                 doc.Owner,
+		Tenant:                doc.Tenant,
+		Replicas:              doc.Replicas,
+		Versioning:            doc.Versioning,
+		MaxVersions:           doc.MaxVersions,
+		Retention:             doc.Retention,
+		ExpireAfter:           doc.ExpireAfter,
+		NoncurrentExpireAfter: doc.NoncurrentExpireAfter,
+		ChunkSize:             doc.ChunkSize,
+		Compression:           doc.Compression,
+		DefaultAccess:         domain.FileAccess(doc.DefaultAccess),
+		DefaultSharedWith:     doc.DefaultSharedWith,
+		CreatedAt:             doc.CreatedAt,
+	}
+}
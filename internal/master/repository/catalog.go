@@ -0,0 +1,1054 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"node-test/internal/domain"
+)
+
+const (
+	filesCollectionName  = "files"
+	chunksCollectionName = "chunks"
+	blobsCollectionName  = "blobs"
+
+	// retiredBlobKeyPrefix replaces keys of retired blobs, keys of blobs are unique
+	retiredBlobKeyPrefix = "retired/"
+)
+
+var (
+	// ErrNotFound is returned when the requested catalog entry doesn't exist.
+	ErrNotFound = errors.New("catalog entry not found")
+	// ErrDuplicate is returned when the entry with the same unique key already exists.
+	ErrDuplicate = errors.New("catalog entry already exists")
+)
+
+type (
+	catalogRepository struct {
+		files  *mongo.Collection
+		chunks *mongo.Collection
+		blobs  *mongo.Collection
+	}
+
+	// CatalogRepository keeps the uploaded files and the placement of their chunks.
+	CatalogRepository interface {
+		AddFile(ctx context.Context, file *domain.File) error
+		File(ctx context.Context, id string) (*domain.File, error)
+		FileByKey(ctx context.Context, bucket, key string) (*domain.File, error)
+		Versions(ctx context.Context, bucket, key string) ([]*domain.File, error)
+		SupersedeFile(ctx context.Context, bucket, key, id string, at time.Time) error
+		PromoteFile(ctx context.Context, id string) error
+		PromoteVersion(ctx context.Context, bucket, key string) error
+		Files(ctx context.Context, filter *domain.FileFilter) ([]*domain.File, error)
+		DeleteFile(ctx context.Context, id string) error
+		CommitFile(ctx context.Context, id, checksum string, at time.Time) error
+		CommitVersion(ctx context.Context, id, checksum string, at time.Time) error
+		SetAccess(ctx context.Context, id string, access domain.FileAccess, sharedWith []string) error
+		SetTotalChunks(ctx context.Context, id string, total int64) error
+		StaleDataKeys(ctx context.Context, primaryKeyID string, limit int64) ([]*domain.File, error)
+		ReplaceDataKey(ctx context.Context, id string, old, key *domain.WrappedKey) error
+		ExpiredFiles(ctx context.Context, at time.Time, offset, limit int64) ([]*domain.File, error)
+		ExpiredVersions(ctx context.Context, bucket string, before time.Time, offset, limit int64) ([]*domain.File, error)
+		AddChunk(ctx context.Context, location *domain.ChunkLocation) (*domain.ChunkLocation, error)
+		CountChunks(ctx context.Context, uploadID string) (int64, error)
+		Chunks(ctx context.Context, uploadID string, first, last int64) ([]*domain.ChunkLocation, error)
+		AllChunks(ctx context.Context, uploadID string) ([]*domain.ChunkLocation, error)
+		AddBlob(ctx context.Context, blob *domain.Blob) error
+		AcquireBlob(ctx context.Context, key string) (*domain.Blob, error)
+		ReleaseBlob(ctx context.Context, id string) (*domain.Blob, error)
+		RetireBlob(ctx context.Context, id string) error
+		DeleteBlob(ctx context.Context, id string) error
+		UnreferencedBlobs(ctx context.Context, limit int64) ([]*domain.Blob, error)
+		DedupStats(ctx context.Context, uploadID string) (*domain.DedupStats, error)
+		Ping(ctx context.Context) error
+	}
+
+	fileDocument struct {
+		ID            string            `bson:"_id"`
+		Bucket        string            `bson:"bucket,omitempty"`
+		Filename      string            `bson:"filename"`
+		TotalFileSize int64             `bson:"total_file_size"`
+		TotalChunks   int64             `bson:"total_chunks"`
+		ChunkSize     int64             `bson:"chunk_size"`
+		Chunking      string            `bson:"chunking,omitempty"`
+		Checksum      string            `bson:"checksum,omitempty"`
+		Status        string            `bson:"status"`
+		Latest        bool              `bson:"latest"`
+		DeleteMarker  bool              `bson:"delete_marker,omitempty"`
+		Owner         string            `bson:"owner"`
+		Tenant        string            `bson:"tenant,omitempty"`
+		Replicas      int               `bson:"replicas,omitempty"`
+		Retention     time.Duration     `bson:"retention,omitempty"`
+		TTL           time.Duration     `bson:"ttl,omitempty"`
+		ExpiresAt     time.Time         `bson:"expires_at,omitempty"`
+		Access        string            `bson:"access"`
+		SharedWith    []string          `bson:"shared_with,omitempty"`
+		Encryption    string            `bson:"encryption,omitempty"`
+		Compression   string            `bson:"compression,omitempty"`
+		DataKey       *keyDocument      `bson:"data_key,omitempty"`
+		Metadata      map[string]string `bson:"metadata,omitempty"`
+		CreatedAt     time.Time         `bson:"created_at"`
+		CommittedAt   time.Time         `bson:"committed_at,omitempty"`
+		SupersededAt  time.Time         `bson:"superseded_at,omitempty"`
+	}
+
+	keyDocument struct {
+		KeyID      string `bson:"key_id"`
+		Ciphertext []byte `bson:"ciphertext"`
+	}
+
+	chunkDocument struct {
+		UploadID     string   `bson:"upload_id"`
+		ChunkNumber  int64    `bson:"chunk_number"`
+		Size         int64    `bson:"size"`
+		StoredSize   int64    `bson:"stored_size,omitempty"`
+		Codec        string   `bson:"codec,omitempty"`
+		Node         string   `bson:"node"`
+		Replicas     []string `bson:"replicas,omitempty"`
+		Blob         string   `bson:"blob,omitempty"`
+		Deduplicated bool     `bson:"deduplicated,omitempty"`
+	}
+
+	blobDocument struct {
+		ID         string    `bson:"_id"`
+		Key        string    `bson:"key"`
+		Hash       string    `bson:"hash"`
+		Size       int64     `bson:"size"`
+		StoredSize int64     `bson:"stored_size,omitempty"`
+		Codec      string    `bson:"codec,omitempty"`
+		Node       string    `bson:"node"`
+		Replicas   []string  `bson:"replicas,omitempty"`
+		Refs       int64     `bson:"refs"`
+		Retired    bool      `bson:"retired,omitempty"`
+		CreatedAt  time.Time `bson:"created_at"`
+	}
+
+	// sizeTotal sums sizes of chunks or blobs, Stored counts their stored sizes
+	sizeTotal struct {
+		Bytes  int64 `bson:"bytes"`
+		Stored int64 `bson:"stored"`
+	}
+)
+
+// NewCatalogRepository creates a new CatalogRepository instance.
+func NewCatalogRepository(ctx context.Context, database *mongo.Database) (CatalogRepository, error) {
+
+	repo := &catalogRepository{
+		files:  database.Collection(filesCollectionName),
+		chunks: database.Collection(chunksCollectionName),
+		blobs:  database.Collection(blobsCollectionName),
+	}
+
+	_, err := repo.chunks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "upload_id", Value: 1}, {Key: "chunk_number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create chunks index: %w", err)
+	}
+
+	_, err = repo.files.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner", Value: 1}, {Key: "filename", Value: 1}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create files index: %w", err)
+	}
+
+	// every key of the bucket has the single latest version, pending uploads of the same key may race
+	_, err = repo.files.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "bucket", Value: 1}, {Key: "filename", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{
+			{Key: "bucket", Value: bson.D{{Key: "$exists", Value: true}}},
+			{Key: "status", Value: string(domain.FileStatusCommitted)},
+			{Key: "latest", Value: true},
+		}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create bucket keys index: %w", err)
+	}
+
+	_, err = repo.files.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create expiration index: %w", err)
+	}
+
+	// the content is looked up by its key, concurrent uploads of the same content race for the single blob
+	_, err = repo.blobs.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "refs", Value: 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create blobs index: %w", err)
+	}
+
+	return repo, nil
+}
+
+// AddFile registers the new file in the catalog.
+// ErrDuplicate is returned when the latest version is added to the key which already has it.
+func (repo *catalogRepository) AddFile(ctx context.Context, file *domain.File) error {
+
+	_, err := repo.files.InsertOne(ctx, newFileDocument(file))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert file: %w", err)
+	}
+
+	return nil
+}
+
+// File retrieves the file by its upload ID.
+func (repo *catalogRepository) File(ctx context.Context, id string) (*domain.File, error) {
+
+	var doc fileDocument
+	err := repo.files.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("find file: %w", err)
+	}
+
+	return doc.toDomain(), nil
+}
+
+// FileByKey retrieves the latest version of the key inside the bucket, it may be the delete marker.
+func (repo *catalogRepository) FileByKey(ctx context.Context, bucket, key string) (*domain.File, error) {
+
+	query := bson.D{
+		{Key: "bucket", Value: bucket},
+		{Key: "filename", Value: key},
+		{Key: "status", Value: string(domain.FileStatusCommitted)},
+		{Key: "latest", Value: true},
+	}
+
+	var doc fileDocument
+	if err := repo.files.FindOne(ctx, query).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("find file by key: %w", err)
+	}
+
+	return doc.toDomain(), nil
+}
+
+// Versions returns committed versions of the key inside the bucket, the newest version goes first.
+func (repo *catalogRepository) Versions(ctx context.Context, bucket, key string) ([]*domain.File, error) {
+
+	query := bson.D{
+		{Key: "bucket", Value: bucket},
+		{Key: "filename", Value: key},
+		{Key: "status", Value: string(domain.FileStatusCommitted)},
+	}
+
+	cursor, err := repo.files.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "committed_at", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find versions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []fileDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode versions: %w", err)
+	}
+
+	list := make([]*domain.File, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+// SupersedeFile makes the latest version of the key noncurrent at the time unless it is the file of the id,
+// so the committed version of the id can be promoted.
+func (repo *catalogRepository) SupersedeFile(ctx context.Context, bucket, key, id string, at time.Time) error {
+
+	query := bson.D{
+		{Key: "bucket", Value: bucket},
+		{Key: "filename", Value: key},
+		{Key: "latest", Value: true},
+		{Key: "_id", Value: bson.D{{Key: "$ne", Value: id}}},
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "latest", Value: false},
+		{Key: "superseded_at", Value: at},
+	}}}
+
+	if _, err := repo.files.UpdateMany(ctx, query, update); err != nil {
+		return fmt.Errorf("supersede file: %w", err)
+	}
+
+	return nil
+}
+
+// PromoteFile makes the committed file the latest version of its key, it returns ErrDuplicate
+// when the key already has the latest version and ErrNotFound when the file isn't committed.
+func (repo *catalogRepository) PromoteFile(ctx context.Context, id string) error {
+
+	query := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: string(domain.FileStatusCommitted)},
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "latest", Value: true}}},
+		{Key: "$unset", Value: bson.D{{Key: "superseded_at", Value: ""}}},
+	}
+
+	res, err := repo.files.UpdateOne(ctx, query, update)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("promote file: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PromoteVersion makes the newest committed version of the key the latest one when the key has no latest version.
+func (repo *catalogRepository) PromoteVersion(ctx context.Context, bucket, key string) error {
+
+	versions, err := repo.Versions(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 || versions[0].Latest {
+		return nil
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "latest", Value: true}}},
+		{Key: "$unset", Value: bson.D{{Key: "superseded_at", Value: ""}}},
+	}
+
+	_, err = repo.files.UpdateByID(ctx, versions[0].ID, update)
+	if err != nil {
+		// the concurrent commit has made its version the latest one
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return fmt.Errorf("promote version: %w", err)
+	}
+
+	return nil
+}
+
+// Files returns files matched by the filter ordered by name.
+func (repo *catalogRepository) Files(ctx context.Context, filter *domain.FileFilter) ([]*domain.File, error) {
+
+	query := bson.D{}
+	if filter.Bucket != "" {
+		query = append(query, bson.E{Key: "bucket", Value: filter.Bucket})
+	}
+	if filter.Status != "" {
+		query = append(query, bson.E{Key: "status", Value: string(filter.Status)})
+	}
+	if !filter.Versions {
+		// files committed before versioning have no latest flag
+		query = append(query,
+			bson.E{Key: "latest", Value: bson.D{{Key: "$ne", Value: false}}},
+			bson.E{Key: "delete_marker", Value: bson.D{{Key: "$ne", Value: true}}},
+		)
+	}
+	if filter.Reader != "" {
+		query = append(query, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "owner", Value: filter.Reader}},
+			bson.D{{Key: "access", Value: string(domain.FileAccessPublic)}},
+			bson.D{{Key: "access", Value: string(domain.FileAccessShared)}, {Key: "shared_with", Value: filter.Reader}},
+		}})
+	}
+	if filter.Prefix != "" {
+		query = append(query, bson.E{Key: "filename", Value: bson.D{
+			{Key: "$regex", Value: "^" + regexp.QuoteMeta(filter.Prefix)},
+		}})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "filename", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(filter.Offset)
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := repo.files.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find files: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []fileDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode files: %w", err)
+	}
+
+	list := make([]*domain.File, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+// DeleteFile removes the file and the placement of its chunks from the catalog.
+func (repo *catalogRepository) DeleteFile(ctx context.Context, id string) error {
+
+	if _, err := repo.chunks.DeleteMany(ctx, bson.D{{Key: "upload_id", Value: id}}); err != nil {
+		return fmt.Errorf("delete chunks: %w", err)
+	}
+
+	res, err := repo.files.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return fmt.Errorf("delete file: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CommitFile marks the pending file as the committed latest version and records the checksum of the content
+// when it is provided. ErrNotFound is returned when there is no pending file with the id,
+// ErrDuplicate when the key of the file has the latest version in the bucket.
+func (repo *catalogRepository) CommitFile(ctx context.Context, id, checksum string, at time.Time) error {
+
+	update := bson.D{
+		{Key: "status", Value: string(domain.FileStatusCommitted)},
+		{Key: "latest", Value: true},
+		{Key: "committed_at", Value: at},
+	}
+	if checksum != "" {
+		update = append(update, bson.E{Key: "checksum", Value: checksum})
+	}
+
+	query := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: string(domain.FileStatusPending)},
+	}
+
+	res, err := repo.files.UpdateOne(ctx, query, bson.D{{Key: "$set", Value: update}})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("commit file: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CommitVersion marks the pending file as the committed noncurrent version superseded at the time
+// and records the checksum of the content, PromoteFile makes it the latest one.
+// It returns ErrNotFound when the file isn't pending.
+func (repo *catalogRepository) CommitVersion(ctx context.Context, id, checksum string, at time.Time) error {
+
+	update := bson.D{
+		{Key: "status", Value: string(domain.FileStatusCommitted)},
+		{Key: "latest", Value: false},
+		{Key: "committed_at", Value: at},
+		{Key: "superseded_at", Value: at},
+	}
+	if checksum != "" {
+		update = append(update, bson.E{Key: "checksum", Value: checksum})
+	}
+
+	query := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: string(domain.FileStatusPending)},
+	}
+
+	res, err := repo.files.UpdateOne(ctx, query, bson.D{{Key: "$set", Value: update}})
+	if err != nil {
+		return fmt.Errorf("commit version: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetAccess replaces the access of the file and the list of principals it is shared with.
+func (repo *catalogRepository) SetAccess(ctx context.Context, id string, access domain.FileAccess, sharedWith []string) error {
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "access", Value: string(access)},
+			{Key: "shared_with", Value: sharedWith},
+		}},
+	}
+
+	res, err := repo.files.UpdateByID(ctx, id, update)
+	if err != nil {
+		return fmt.Errorf("set file access: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetTotalChunks records the count of chunks of the pending file once its content is split.
+func (repo *catalogRepository) SetTotalChunks(ctx context.Context, id string, total int64) error {
+
+	query := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: string(domain.FileStatusPending)},
+	}
+
+	res, err := repo.files.UpdateOne(ctx, query, bson.D{{Key: "$set", Value: bson.D{{Key: "total_chunks", Value: total}}}})
+	if err != nil {
+		return fmt.Errorf("set total chunks: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// StaleDataKeys returns files which data keys are wrapped by other keys than the primary one.
+func (repo *catalogRepository) StaleDataKeys(ctx context.Context, primaryKeyID string, limit int64) ([]*domain.File, error) {
+
+	query := bson.D{
+		{Key: "data_key.key_id", Value: bson.D{{Key: "$exists", Value: true}, {Key: "$ne", Value: primaryKeyID}}},
+	}
+
+	cursor, err := repo.files.Find(ctx, query, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("find stale data keys: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []fileDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode files: %w", err)
+	}
+
+	list := make([]*domain.File, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+// ReplaceDataKey replaces the wrapped data key of the file if it is still wrapped by the old key.
+func (repo *catalogRepository) ReplaceDataKey(ctx context.Context, id string, old, key *domain.WrappedKey) error {
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "data_key.key_id", Value: old.KeyID},
+	}
+
+	res, err := repo.files.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "data_key", Value: newKeyDocument(key)},
+	}}})
+	if err != nil {
+		return fmt.Errorf("replace data key: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ExpiredFiles returns files which TTL has elapsed at the time, the earliest expired file goes first.
+func (repo *catalogRepository) ExpiredFiles(ctx context.Context, at time.Time, offset, limit int64) ([]*domain.File, error) {
+
+	query := bson.D{
+		{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: at}}},
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "expires_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	return repo.findFiles(ctx, query, opts)
+}
+
+// ExpiredVersions returns noncurrent versions of keys inside the bucket which were superseded before the time,
+// the earliest superseded version goes first.
+func (repo *catalogRepository) ExpiredVersions(
+	ctx context.Context,
+	bucket string,
+	before time.Time,
+	offset, limit int64,
+) ([]*domain.File, error) {
+
+	query := bson.D{
+		{Key: "bucket", Value: bucket},
+		{Key: "status", Value: string(domain.FileStatusCommitted)},
+		{Key: "latest", Value: false},
+		{Key: "superseded_at", Value: bson.D{{Key: "$lt", Value: before}}},
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "superseded_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	return repo.findFiles(ctx, query, opts)
+}
+
+func (repo *catalogRepository) findFiles(ctx context.Context, query bson.D, opts *options.FindOptions) ([]*domain.File, error) {
+
+	cursor, err := repo.files.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find files: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []fileDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode files: %w", err)
+	}
+
+	list := make([]*domain.File, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+// AddChunk stores the placement of the chunk, the repeated upload of the same chunk replaces the previous one.
+// The replaced placement is returned, nil means the chunk is stored for the first time.
+func (repo *catalogRepository) AddChunk(ctx context.Context, location *domain.ChunkLocation) (*domain.ChunkLocation, error) {
+
+	doc := newChunkDocument(location)
+
+	var previous chunkDocument
+	err := repo.chunks.FindOneAndReplace(
+		ctx,
+		bson.D{{Key: "upload_id", Value: doc.UploadID}, {Key: "chunk_number", Value: doc.ChunkNumber}},
+		doc,
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.Before),
+	).Decode(&previous)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("upsert chunk: %w", err)
+	}
+
+	return previous.toDomain(), nil
+}
+
+// CountChunks returns the count of stored chunks of the upload.
+func (repo *catalogRepository) CountChunks(ctx context.Context, uploadID string) (int64, error) {
+
+	count, err := repo.chunks.CountDocuments(ctx, bson.D{{Key: "upload_id", Value: uploadID}})
+	if err != nil {
+		return 0, fmt.Errorf("count chunks: %w", err)
+	}
+
+	return count, nil
+}
+
+// Chunks returns the placement of chunks from first to last inclusive ordered by chunk number.
+func (repo *catalogRepository) Chunks(ctx context.Context, uploadID string, first, last int64) ([]*domain.ChunkLocation, error) {
+
+	filter := bson.D{
+		{Key: "upload_id", Value: uploadID},
+		{Key: "chunk_number", Value: bson.D{{Key: "$gte", Value: first}, {Key: "$lte", Value: last}}},
+	}
+
+	return repo.findChunks(ctx, filter)
+}
+
+// AllChunks returns the placement of all stored chunks of the upload ordered by chunk number,
+// including chunks of sessions which don't know their count yet.
+func (repo *catalogRepository) AllChunks(ctx context.Context, uploadID string) ([]*domain.ChunkLocation, error) {
+
+	return repo.findChunks(ctx, bson.D{{Key: "upload_id", Value: uploadID}})
+}
+
+// findChunks returns chunks which match the filter ordered by chunk number
+func (repo *catalogRepository) findChunks(ctx context.Context, filter bson.D) ([]*domain.ChunkLocation, error) {
+
+	cursor, err := repo.chunks.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "chunk_number", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find chunks: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []chunkDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode chunks: %w", err)
+	}
+
+	list := make([]*domain.ChunkLocation, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+// AddBlob registers the content stored on nodes with the single reference,
+// ErrDuplicate is returned when the content with the same key is already registered.
+func (repo *catalogRepository) AddBlob(ctx context.Context, blob *domain.Blob) error {
+
+	blob.Refs = 1
+	if _, err := repo.blobs.InsertOne(ctx, newBlobDocument(blob)); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert blob: %w", err)
+	}
+
+	return nil
+}
+
+// AcquireBlob adds the reference to the content with the key and returns it, ErrNotFound is returned when
+// the content isn't stored. The unreferenced blob can be acquired until it is deleted.
+func (repo *catalogRepository) AcquireBlob(ctx context.Context, key string) (*domain.Blob, error) {
+
+	var doc blobDocument
+	err := repo.blobs.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "key", Value: key}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "refs", Value: 1}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("acquire blob: %w", err)
+	}
+
+	return doc.toDomain(), nil
+}
+
+// ReleaseBlob removes the reference to the blob and returns the blob with the remaining count of references.
+func (repo *catalogRepository) ReleaseBlob(ctx context.Context, id string) (*domain.Blob, error) {
+
+	var doc blobDocument
+	err := repo.blobs.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "refs", Value: -1}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("release blob: %w", err)
+	}
+
+	return doc.toDomain(), nil
+}
+
+// RetireBlob detaches the blob without references from its key, so the content can't be acquired anymore
+// while it is removed from nodes, and the same content is stored as the new blob. The retired blob stays
+// unreferenced until it is deleted. ErrNotFound is returned when the blob doesn't exist or is referenced again.
+func (repo *catalogRepository) RetireBlob(ctx context.Context, id string) error {
+
+	query := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "refs", Value: bson.D{{Key: "$lte", Value: 0}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "key", Value: retiredBlobKeyPrefix + id},
+		{Key: "retired", Value: true},
+	}}}
+
+	res, err := repo.blobs.UpdateOne(ctx, query, update)
+	if err != nil {
+		return fmt.Errorf("retire blob: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteBlob removes the blob without references, ErrNotFound is returned when it doesn't exist or is referenced again.
+func (repo *catalogRepository) DeleteBlob(ctx context.Context, id string) error {
+
+	query := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "refs", Value: bson.D{{Key: "$lte", Value: 0}}},
+	}
+
+	res, err := repo.blobs.DeleteOne(ctx, query)
+	if err != nil {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UnreferencedBlobs returns blobs which no chunk references.
+func (repo *catalogRepository) UnreferencedBlobs(ctx context.Context, limit int64) ([]*domain.Blob, error) {
+
+	query := bson.D{{Key: "refs", Value: bson.D{{Key: "$lte", Value: 0}}}}
+
+	cursor, err := repo.blobs.Find(ctx, query, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("find unreferenced blobs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []blobDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode blobs: %w", err)
+	}
+
+	list := make([]*domain.Blob, 0, len(docs))
+	for i := range docs {
+		list = append(list, docs[i].toDomain())
+	}
+
+	return list, nil
+}
+
+// DedupStats sums bytes of chunks of the upload and bytes which the upload has stored on nodes first.
+// The empty upload ID sums bytes of all chunks and bytes of all blobs and chunks which don't share their content.
+func (repo *catalogRepository) DedupStats(ctx context.Context, uploadID string) (*domain.DedupStats, error) {
+
+	var (
+		chunks = bson.D{}
+		stored = bson.D{{Key: "deduplicated", Value: bson.D{{Key: "$ne", Value: true}}}}
+	)
+	if uploadID != "" {
+		chunks = append(chunks, bson.E{Key: "upload_id", Value: uploadID})
+		stored = append(stored, bson.E{Key: "upload_id", Value: uploadID})
+	} else {
+		stored = append(stored, bson.E{Key: "blob", Value: bson.D{{Key: "$exists", Value: false}}})
+	}
+
+	all, err := sumSize(ctx, repo.chunks, chunks)
+	if err != nil {
+		return nil, fmt.Errorf("sum chunk bytes: %w", err)
+	}
+	own, err := sumSize(ctx, repo.chunks, stored)
+	if err != nil {
+		return nil, fmt.Errorf("sum stored chunk bytes: %w", err)
+	}
+
+	stats := &domain.DedupStats{
+		LogicalBytes:    all.Bytes,
+		StoredBytes:     own.Bytes,
+		CompressedBytes: own.Stored,
+	}
+
+	if uploadID == "" {
+		blobs, err := sumSize(ctx, repo.blobs, bson.D{})
+		if err != nil {
+			return nil, fmt.Errorf("sum blob bytes: %w", err)
+		}
+		stats.StoredBytes += blobs.Bytes
+		stats.CompressedBytes += blobs.Stored
+	}
+
+	return stats, nil
+}
+
+// sumSize sums sizes and stored sizes of documents of the collection matched by the query,
+// documents without the stored size are stored as is
+func sumSize(ctx context.Context, collection *mongo.Collection, query bson.D) (*sizeTotal, error) {
+
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: query}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "bytes", Value: bson.D{{Key: "$sum", Value: "$size"}}},
+			{Key: "stored", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$stored_size", "$size"}}}}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []sizeTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	if len(totals) == 0 {
+		return &sizeTotal{}, nil
+	}
+
+	return &totals[0], nil
+}
+
+func newFileDocument(file *domain.File) *fileDocument {
+	return &fileDocument{
+		ID:            file.ID,
+		Bucket:        file.Bucket,
+		Filename:      file.Filename,
+		TotalFileSize: file.TotalFileSize,
+		TotalChunks:   file.TotalChunks,
+		ChunkSize:     file.ChunkSize,
+		Chunking:      string(file.Chunking),
+		Checksum:      file.Checksum,
+		Status:        string(file.Status),
+		Latest:        file.Latest,
+		DeleteMarker:  file.DeleteMarker,
+		Owner:         file.Owner,
+		Tenant:        file.Tenant,
+		Replicas:      file.Replicas,
+		Retention:     file.Retention,
+		TTL:           file.TTL,
+		ExpiresAt:     file.ExpiresAt,
+		Access:        string(file.Access),
+		SharedWith:    file.SharedWith,
+		Encryption:    string(file.Encryption),
+		Compression:   file.Compression,
+		DataKey:       newKeyDocument(file.DataKey),
+		Metadata:      file.Metadata,
+		CreatedAt:     file.CreatedAt,
+		CommittedAt:   file.CommittedAt,
+		SupersededAt:  file.SupersededAt,
+	}
+}
+
+func (doc *fileDocument) toDomain() *domain.File {
+	return &domain.File{
+		ID:            doc.ID,
+		Bucket:        doc.Bucket,
+		Filename:      doc.Filename,
+		TotalFileSize: doc.TotalFileSize,
+		TotalChunks:   doc.TotalChunks,
+		ChunkSize:     doc.ChunkSize,
+		Chunking:      domain.ChunkingMode(doc.Chunking),
+		Checksum:      doc.Checksum,
+		Status:        domain.FileStatus(doc.Status),
+		Latest:        doc.Latest,
+		DeleteMarker:  doc.DeleteMarker,
+		Owner:         doc.Owner,
+		Tenant:        doc.Tenant,
+		Replicas:      doc.Replicas,
+		Retention:     doc.Retention,
+		TTL:           doc.TTL,
+		ExpiresAt:     doc.ExpiresAt,
+		Access:        domain.FileAccess(doc.Access),
+		SharedWith:    doc.SharedWith,
+		Encryption:    domain.EncryptionMode(doc.Encryption),
+		Compression:   doc.Compression,
+		DataKey:       doc.DataKey.toDomain(),
+		Metadata:      doc.Metadata,
+		CreatedAt:     doc.CreatedAt,
+		CommittedAt:   doc.CommittedAt,
+		SupersededAt:  doc.SupersededAt,
+	}
+}
+
+func newChunkDocument(location *domain.ChunkLocation) *chunkDocument {
+	return &chunkDocument{
+		UploadID:     location.UploadID,
+		ChunkNumber:  location.ChunkNumber,
+		Size:         location.Size,
+		StoredSize:   location.StoredSize,
+		Codec:        location.Codec,
+		Node:         location.Node,
+		Replicas:     location.Replicas,
+		Blob:         location.Blob,
+		Deduplicated: location.Deduplicated,
+	}
+}
+
+func (doc *chunkDocument) toDomain() *domain.ChunkLocation {
+	return &domain.ChunkLocation{
+		UploadID:     doc.UploadID,
+		ChunkNumber:  doc.ChunkNumber,
+		Size:         doc.Size,
+		StoredSize:   doc.StoredSize,
+		Codec:        doc.Codec,
+		Node:         doc.Node,
+		Replicas:     doc.Replicas,
+		Blob:         doc.Blob,
+		Deduplicated: doc.Deduplicated,
+	}
+}
+
+func newBlobDocument(blob *domain.Blob) *blobDocument {
+	return &blobDocument{
+		ID:         blob.ID,
+		Key:        blob.Key,
+		Hash:       blob.Hash,
+		Size:       blob.Size,
+		StoredSize: blob.StoredSize,
+		Codec:      blob.Codec,
+		Node:       blob.Node,
+		Replicas:   blob.Replicas,
+		Refs:       blob.Refs,
+		CreatedAt:  blob.CreatedAt,
+	}
+}
+
+func (doc *blobDocument) toDomain() *domain.Blob {
+	return &domain.Blob{
+		ID:         doc.ID,
+		Key:        doc.Key,
+		Hash:       doc.Hash,
+		Size:       doc.Size,
+		StoredSize: doc.StoredSize,
+		Codec:      doc.Codec,
+		Node:       doc.Node,
+		Replicas:   doc.Replicas,
+		Refs:       doc.Refs,
+		CreatedAt:  doc.CreatedAt,
+	}
+}
+
+func newKeyDocument(key *domain.WrappedKey) *keyDocument {
+	if key == nil {
+		return nil
+	}
+
+	return &keyDocument{
+		KeyID:      key.KeyID,
+		Ciphertext: key.Ciphertext,
+	}
+}
+
+func (doc *keyDocument) toDomain() *domain.WrappedKey {
+	if doc == nil {
+		return nil
+	}
+
+	return &domain.WrappedKey{
+		KeyID:      doc.KeyID,
+		Ciphertext: doc.Ciphertext,
+	}
+}
+
+// Ping checks the connection to the catalog database.
+func (repo *catalogRepository) Ping(ctx context.Context) error {
+	return repo.files.Database().Client().Ping(ctx, readpref.Primary())
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"node-test/internal/domain"
+)
+
+const (
+	quotasCollectionName = "quotas"
+)
+
+var (
+	// ErrLimitReached is returned when the reserved usage doesn't fit the limits.
+	ErrLimitReached = errors.New("quota limit reached")
+)
+
+type (
+	quotaRepository struct {
+		quotas *mongo.Collection
+	}
+
+	// QuotaRepository keeps limits and usage counters of users and tenants.
+	QuotaRepository interface {
+		Quota(ctx context.Context, scope domain.QuotaScope, name string) (*domain.Quota, error)
+		SetLimits(ctx context.Context, scope domain.QuotaScope, name string, limits domain.QuotaLimits) error
+		AddUsage(ctx context.Context, scope domain.QuotaScope, name string, bytes, files int64) error
+		ReserveUsage(ctx context.Context, scope domain.QuotaScope, name string, bytes, files int64, limits domain.QuotaLimits) error
+	}
+
+	quotaDocument struct {
+		ID        string          `bson:"_id"`
+		Scope     string          `bson:"scope"`
+		Name      string          `bson:"name"`
+		Limits    *limitsDocument `bson:"limits,omitempty"`
+		UsedBytes int64           `bson:"used_bytes"`
+		UsedFiles int64           `bson:"used_files"`
+	}
+
+	limitsDocument struct {
+		MaxBytes int64 `bson:"max_bytes"`
+		MaxFiles int64 `bson:"max_files"`
+	}
+)
+
+// NewQuotaRepository creates a new QuotaRepository instance.
+func NewQuotaRepository(database *mongo.Database) QuotaRepository {
+	return &quotaRepository{
+		quotas: database.Collection(quotasCollectionName),
+	}
+}
+
+// Quota returns limits and usage of the user or the tenant.
+// ErrNotFound is returned when neither limits nor usage are recorded.
+func (repo *quotaRepository) Quota(ctx context.Context, scope domain.QuotaScope, name string) (*domain.Quota, error) {
+
+	var doc quotaDocument
+	err := repo.quotas.FindOne(ctx, bson.D{{Key: "_id", Value: quotaID(scope, name)}}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("find quota: %w", err)
+	}
+
+	return doc.toDomain(), nil
+}
+
+// SetLimits replaces limits of the user or the tenant, the usage is kept.
+func (repo *quotaRepository) SetLimits(ctx context.Context, scope domain.QuotaScope, name string, limits domain.QuotaLimits) error {
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "limits", Value: &limitsDocument{MaxBytes: limits.MaxBytes, MaxFiles: limits.MaxFiles}},
+		}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "scope", Value: string(scope)},
+			{Key: "name", Value: name},
+		}},
+	}
+
+	_, err := repo.quotas.UpdateByID(ctx, quotaID(scope, name), update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("set quota limits: %w", err)
+	}
+
+	return nil
+}
+
+// AddUsage adds bytes and files to usage counters, negative values are subtracted.
+func (repo *quotaRepository) AddUsage(ctx context.Context, scope domain.QuotaScope, name string, bytes, files int64) error {
+
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: "used_bytes", Value: bytes},
+			{Key: "used_files", Value: files},
+		}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "scope", Value: string(scope)},
+			{Key: "name", Value: name},
+		}},
+	}
+
+	_, err := repo.quotas.UpdateByID(ctx, quotaID(scope, name), update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("add quota usage: %w", err)
+	}
+
+	return nil
+}
+
+// ReserveUsage adds bytes and files to usage counters only when the usage stays within limits,
+// the counters are checked and increased by the single update. Zero limits aren't checked.
+func (repo *quotaRepository) ReserveUsage(
+	ctx context.Context,
+	scope domain.QuotaScope,
+	name string,
+	bytes, files int64,
+	limits domain.QuotaLimits,
+) error {
+
+	if (limits.MaxBytes > 0 && bytes > limits.MaxBytes) || (limits.MaxFiles > 0 && files > limits.MaxFiles) {
+		return ErrLimitReached
+	}
+
+	conditions := bson.A{bson.D{{Key: "_id", Value: quotaID(scope, name)}}}
+	if limits.MaxBytes > 0 {
+		conditions = append(conditions, usageWithin("used_bytes", limits.MaxBytes-bytes))
+	}
+	if limits.MaxFiles > 0 {
+		conditions = append(conditions, usageWithin("used_files", limits.MaxFiles-files))
+	}
+
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: "used_bytes", Value: bytes},
+			{Key: "used_files", Value: files},
+		}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "scope", Value: string(scope)},
+			{Key: "name", Value: name},
+		}},
+	}
+
+	// the quota which doesn't match the filter is over its limits, the upsert fails on its existing id then
+	_, err := repo.quotas.UpdateOne(ctx, bson.D{{Key: "$and", Value: conditions}}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrLimitReached
+		}
+		return fmt.Errorf("reserve quota usage: %w", err)
+	}
+
+	return nil
+}
+
+// usageWithin matches the counter up to max, the counter which isn't recorded yet is zero
+func usageWithin(counter string, max int64) bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: counter, Value: bson.D{{Key: "$lte", Value: max}}}},
+		bson.D{{Key: counter, Value: bson.D{{Key: "$exists", Value: false}}}},
+	}}}
+}
+
+func quotaID(scope domain.QuotaScope, name string) string {
+	return string(scope) + ":" + name
+}
+
+func (doc *quotaDocument) toDomain() *domain.Quota {
+
+	quota := &domain.Quota{
+		Scope:     domain.QuotaScope(doc.Scope),
+		Name:      doc.Name,
+		UsedBytes: doc.UsedBytes,
+		UsedFiles: doc.UsedFiles,
+	}
+	if doc.Limits != nil {
+		quota.Limits = domain.QuotaLimits{MaxBytes: doc.Limits.MaxBytes, MaxFiles: doc.Limits.MaxFiles}
+		quota.Custom = true
+	}
+
+	return quota
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"node-test/internal/domain"
+)
+
+func TestFileAccess(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		access     domain.FileAccess
+		sharedWith []string
+		caller     string
+		// wantRead is the error of reads, nil when the caller reads and lists the file
+		wantRead  error
+		wantWrite error
+	}{
+		{name: "owner", access: domain.FileAccessPrivate, caller: "alice"},
+		{name: "other of the private file", access: domain.FileAccessPrivate, caller: "bob", wantRead: ErrFileNotFound, wantWrite: ErrFileNotFound},
+		{name: "principal of the shared file", access: domain.FileAccessShared, sharedWith: []string{"bob"}, caller: "bob", wantWrite: ErrAccessDenied},
+		{name: "other of the shared file", access: domain.FileAccessShared, sharedWith: []string{"bob"}, caller: "carol", wantRead: ErrFileNotFound, wantWrite: ErrFileNotFound},
+		{name: "other of the public file", access: domain.FileAccessPublic, caller: "carol", wantWrite: ErrAccessDenied},
+		{name: "anonymous caller", access: domain.FileAccessPublic, wantRead: ErrFileNotFound, wantWrite: ErrFileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			owner := principalContext("alice")
+			file := env.commit(owner, t, &domain.File{Filename: "a.txt"}, []byte("content"))
+			if _, err := env.storage.SetAccess(owner, file.ID, tt.access, tt.sharedWith); err != nil {
+				t.Fatalf("set access: %v", err)
+			}
+
+			ctx := principalContext(tt.caller)
+
+			if _, err := env.storage.File(ctx, file.ID); !errors.Is(err, tt.wantRead) {
+				t.Fatalf("get file error = %v, want %v", err, tt.wantRead)
+			}
+			if _, err := env.storage.DownloadStream(ctx, file.ID, 0, 0); !errors.Is(err, tt.wantRead) {
+				t.Fatalf("download error = %v, want %v", err, tt.wantRead)
+			}
+
+			files, err := env.storage.List(ctx, &domain.FileFilter{})
+			if tt.caller == "" {
+				if !errors.Is(err, ErrAccessDenied) {
+					t.Fatalf("list error = %v, want %v", err, ErrAccessDenied)
+				}
+			} else if err != nil || (len(files) == 1) != (tt.wantRead == nil) {
+				t.Fatalf("listed %v files: %v, want the file %v", len(files), err, tt.wantRead == nil)
+			}
+
+			if _, err := env.storage.SetAccess(ctx, file.ID, domain.FileAccessPublic, nil); !errors.Is(err, tt.wantWrite) {
+				t.Fatalf("set access error = %v, want %v", err, tt.wantWrite)
+			}
+			if err := env.storage.Delete(ctx, file.ID); !errors.Is(err, tt.wantWrite) {
+				t.Fatalf("delete error = %v, want %v", err, tt.wantWrite)
+			}
+		})
+	}
+}
+
+func TestSetAccess(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		access         domain.FileAccess
+		sharedWith     []string
+		wantErr        error
+		wantSharedWith []string
+	}{
+		{name: "shared", access: domain.FileAccessShared, sharedWith: []string{"bob", "", "alice", "carol", "bob"}, wantSharedWith: []string{"bob", "carol"}},
+		{name: "private drops principals", access: domain.FileAccessPrivate, sharedWith: []string{"bob"}},
+		{name: "public drops principals", access: domain.FileAccessPublic, sharedWith: []string{"bob"}},
+		{name: "unknown access", access: "everyone", wantErr: ErrInvalidAccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			ctx := principalContext("alice")
+			file := env.commit(ctx, t, &domain.File{Filename: "a.txt"}, []byte("content"))
+
+			_, err := env.storage.SetAccess(ctx, file.ID, tt.access, tt.sharedWith)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("set access error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			stored, err := env.catalog.File(ctx, file.ID)
+			if err != nil {
+				t.Fatalf("get file: %v", err)
+			}
+			if stored.Access != tt.access || !slices.Equal(stored.SharedWith, tt.wantSharedWith) {
+				t.Fatalf("stored access %v %v, want %v %v", stored.Access, stored.SharedWith, tt.access, tt.wantSharedWith)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"node-test/internal/common/compression"
+	"node-test/internal/domain"
+	"node-test/internal/master/repository"
+)
+
+const maxReplicas = 5
+
+var (
+	ErrBucketNotFound = domain.NewError(domain.ErrorCodeNotFound, "bucket not found")
+	ErrBucketExists   = domain.NewError(domain.ErrorCodeConflict, "bucket already exists")
+	ErrBucketNotEmpty = domain.NewError(domain.ErrorCodeConflict, "bucket is not empty")
+	ErrInvalidBucket  = domain.NewError(domain.ErrorCodeInvalidRequest, "invalid bucket")
+)
+
+type (
+	bucketService struct {
+		logger  *zap.SugaredLogger
+		buckets repository.BucketRepository
+		catalog repository.CatalogRepository
+		quotas  repository.QuotaRepository
+		// nodes is the count of configured storage nodes, chunks can't have more copies
+		nodes int
+	}
+
+	// BucketService manages namespaces of files and their settings.
+	// Settings are applied to files uploaded after the change.
+	BucketService interface {
+		Create(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error)
+		Bucket(ctx context.Context, name string) (*domain.Bucket, error)
+		List(ctx context.Context) ([]*domain.Bucket, error)
+		Update(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error)
+		Delete(ctx context.Context, name string) error
+	}
+)
+
+// NewBucketService creates the service which keeps quotas of buckets in the quota repository.
+// Replicas of buckets are limited by the count of configured storage nodes.
+func NewBucketService(
+	logger *zap.SugaredLogger,
+	buckets repository.BucketRepository,
+	catalog repository.CatalogRepository,
+	quotas repository.QuotaRepository,
+	nodes int,
+) BucketService {
+	return &bucketService{
+		logger:  logger,
+		buckets: buckets,
+		catalog: catalog,
+		quotas:  quotas,
+		nodes:   nodes,
+	}
+}
+
+// Create registers the bucket owned by the caller and shared with its tenant.
+func (s *bucketService) Create(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error) {
+
+	principal := PrincipalFromContext(ctx)
+	if principal == nil || principal.Subject == "" {
+		return nil, ErrAccessDenied
+	}
+
+	if !domain.ValidBucketName(bucket.Name) {
+		return nil, fmt.Errorf("%w: name %q", ErrInvalidBucket, bucket.Name)
+	}
+	if err := validateBucket(bucket, s.maxReplicas()); err != nil {
+		return nil, err
+	}
+
+	bucket.Owner = principal.Subject
+	bucket.Tenant = principal.Tenant
+	bucket.CreatedAt = time.Now().UTC()
+
+	if err := s.buckets.AddBucket(ctx, bucket); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrBucketExists
+		}
+		return nil, fmt.Errorf("add bucket %w", err)
+	}
+
+	if err := s.quotas.SetLimits(ctx, domain.QuotaScopeBucket, bucket.Name, bucket.Quota); err != nil {
+		return nil, fmt.Errorf("set bucket quota %w", err)
+	}
+
+	return bucket, nil
+}
+
+// Bucket returns the bucket which the caller is the member of.
+// Other buckets are reported as not found to not disclose their existence.
+func (s *bucketService) Bucket(ctx context.Context, name string) (*domain.Bucket, error) {
+
+	bucket, err := s.bucket(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.withQuota(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	return bucket, nil
+}
+
+// List returns buckets which the caller is the member of.
+func (s *bucketService) List(ctx context.Context) ([]*domain.Bucket, error) {
+
+	principal := PrincipalFromContext(ctx)
+	if principal == nil || principal.Subject == "" {
+		return nil, ErrAccessDenied
+	}
+
+	buckets, err := s.buckets.Buckets(ctx, principal)
+	if err != nil {
+		return nil, fmt.Errorf("list buckets %w", err)
+	}
+
+	for _, bucket := range buckets {
+		if err := s.withQuota(ctx, bucket); err != nil {
+			return nil, err
+		}
+	}
+
+	return buckets, nil
+}
+
+// Update replaces settings of the bucket, only its owner or admins can do it.
+func (s *bucketService) Update(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error) {
+
+	current, err := s.bucket(ctx, bucket.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !current.CanManage(PrincipalFromContext(ctx)) {
+		return nil, ErrAccessDenied
+	}
+
+	if err := validateBucket(bucket, s.maxReplicas()); err != nil {
+		return nil, err
+	}
+
+	bucket.Owner = current.Owner
+	bucket.Tenant = current.Tenant
+	bucket.CreatedAt = current.CreatedAt
+
+	if err := s.buckets.UpdateBucket(ctx, bucket); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrBucketNotFound
+		}
+		return nil, fmt.Errorf("update bucket %w", err)
+	}
+
+	if err := s.quotas.SetLimits(ctx, domain.QuotaScopeBucket, bucket.Name, bucket.Quota); err != nil {
+		return nil, fmt.Errorf("set bucket quota %w", err)
+	}
+
+	return bucket, nil
+}
+
+// Delete removes the bucket without files, versions and delete markers are counted as files.
+// Pending uploads aren't counted, they can't be committed after the bucket is deleted.
+func (s *bucketService) Delete(ctx context.Context, name string) error {
+
+	bucket, err := s.bucket(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !bucket.CanManage(PrincipalFromContext(ctx)) {
+		return ErrAccessDenied
+	}
+
+	files, err := s.catalog.Files(ctx, &domain.FileFilter{
+		Bucket:   name,
+		Status:   domain.FileStatusCommitted,
+		Versions: true,
+		Limit:    1,
+	})
+	if err != nil {
+		return fmt.Errorf("list bucket files %w", err)
+	}
+	if len(files) > 0 {
+		return ErrBucketNotEmpty
+	}
+
+	if err := s.buckets.DeleteBucket(ctx, name); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrBucketNotFound
+		}
+		return fmt.Errorf("delete bucket %w", err)
+	}
+
+	return nil
+}
+
+// bucket returns the bucket which the caller is the member of or the admin
+func (s *bucketService) bucket(ctx context.Context, name string) (*domain.Bucket, error) {
+
+	bucket, err := s.buckets.Bucket(ctx, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrBucketNotFound
+		}
+		return nil, fmt.Errorf("get bucket %w", err)
+	}
+
+	if principal := PrincipalFromContext(ctx); !bucket.IsMember(principal) && !admin(ctx) {
+		return nil, ErrBucketNotFound
+	}
+
+	return bucket, nil
+}
+
+// withQuota fills limits of the bucket quota
+func (s *bucketService) withQuota(ctx context.Context, bucket *domain.Bucket) error {
+
+	quota, err := s.quotas.Quota(ctx, domain.QuotaScopeBucket, bucket.Name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("get bucket quota %w", err)
+	}
+
+	bucket.Quota = quota.Limits
+
+	return nil
+}
+
+// maxReplicas returns the count of copies which chunks of buckets can have
+func (s *bucketService) maxReplicas() int {
+	return min(maxReplicas, s.nodes)
+}
+
+// validateBucket checks settings of the bucket, the empty default access means private files
+func validateBucket(bucket *domain.Bucket, replicas int) error {
+
+	if bucket.DefaultAccess == "" {
+		bucket.DefaultAccess = domain.FileAccessPrivate
+	}
+
+	switch {
+	case bucket.Replicas < 0 || bucket.Replicas > replicas:
+		return fmt.Errorf("%w: replicas must be 0-%v", ErrInvalidBucket, replicas)
+	case bucket.MaxVersions < 0:
+		return fmt.Errorf("%w: negative max versions", ErrInvalidBucket)
+	case bucket.Retention < 0:
+		return fmt.Errorf("%w: negative retention", ErrInvalidBucket)
+	case bucket.ExpireAfter < 0 || bucket.NoncurrentExpireAfter < 0:
+		return fmt.Errorf("%w: negative expiration", ErrInvalidBucket)
+	case bucket.Quota.MaxBytes < 0 || bucket.Quota.MaxFiles < 0:
+		return fmt.Errorf("%w: negative quota", ErrInvalidBucket)
+	case !bucket.DefaultAccess.Valid():
+		return fmt.Errorf("%w: default access %q", ErrInvalidBucket, bucket.DefaultAccess)
+	case bucket.ChunkSize != 0 && !domain.ValidChunkSize(bucket.ChunkSize):
+		return fmt.Errorf("%w: chunk size must be %v-%v", ErrInvalidBucket, domain.MinChunkSize, domain.MaxChunkSize)
+	case !compression.Valid(bucket.Compression):
+		return fmt.Errorf("%w: compression %q", ErrInvalidBucket, bucket.Compression)
+	}
+
+	if bucket.DefaultAccess == domain.FileAccessShared {
+		bucket.DefaultSharedWith = sharedSubjects(bucket.DefaultSharedWith, "")
+	} else {
+		bucket.DefaultSharedWith = nil
+	}
+
+	return nil
+}
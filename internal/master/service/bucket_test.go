@@ -0,0 +1,48 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"node-test/internal/domain"
+)
+
+func TestBucketReplicas(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		nodes    int
+		replicas int
+		wantErr  error
+	}{
+		{name: "default", nodes: 3},
+		{name: "every node", nodes: 3, replicas: 3},
+		{name: "more than nodes", nodes: 3, replicas: 4, wantErr: ErrInvalidBucket},
+		{name: "single node", nodes: 1, replicas: 2, wantErr: ErrInvalidBucket},
+		{name: "more than the limit", nodes: 10, replicas: maxReplicas + 1, wantErr: ErrInvalidBucket},
+		{name: "negative", nodes: 3, replicas: -1, wantErr: ErrInvalidBucket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			buckets := NewBucketService(zap.NewNop().Sugar(), env.buckets, env.catalog, env.quotas, tt.nodes)
+			ctx := principalContext("alice")
+
+			_, err := buckets.Create(ctx, &domain.Bucket{Name: "created", Replicas: tt.replicas})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("create error = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err := buckets.Create(ctx, &domain.Bucket{Name: "updated"}); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			_, err = buckets.Update(ctx, &domain.Bucket{Name: "updated", Replicas: tt.replicas})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("update error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
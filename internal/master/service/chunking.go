@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"node-test/internal/common/chunker"
+	"node-test/internal/domain"
+)
+
+// UploadContent splits the whole content of the file with content defined chunking and stores its chunks,
+// the count of chunks is recorded once the content is read. The content must have the size of the file.
+// The failed upload is repeated by sending the whole content again, equal chunks replace the stored ones.
+func (s *uploadService) UploadContent(ctx context.Context, file *domain.File, content io.Reader) error {
+
+	if !file.ContentDefined() {
+		return fmt.Errorf("%w: file %v has fixed chunks", ErrInvalidRange, file.ID)
+	}
+
+	var params chunker.Params
+	if s.chunking != nil {
+		params = *s.chunking
+	}
+	// chunks never exceed the chunk size which was announced for the file
+	params.Max = int(file.ChunkSize)
+
+	split, err := chunker.New(content, params)
+	if err != nil {
+		return fmt.Errorf("create chunker %w", err)
+	}
+
+	var (
+		uploadChan, result = s.UploadChunkedAsync(ctx, file)
+		total, size        int64
+	)
+
+upload:
+	for {
+		data, readErr := split.Next()
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			err = fmt.Errorf("read content %w", readErr)
+			break
+		}
+
+		total++
+		size += int64(len(data))
+
+		select {
+		case <-ctx.Done():
+			err = context.Cause(ctx)
+			break upload
+		case uploadChan <- &domain.Chunk{
+			UploadID:      file.ID,
+			ChunkNumber:   total,
+			TotalFileSize: file.TotalFileSize,
+			Filename:      file.Filename,
+			Data:          data,
+		}:
+		}
+	}
+
+	close(uploadChan)
+	if uploadErr := <-result; err == nil {
+		err = uploadErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if size != file.TotalFileSize {
+		return fmt.Errorf("%w: content has %v bytes of %v", ErrIncompleteUpload, size, file.TotalFileSize)
+	}
+
+	if err := s.catalog.SetTotalChunks(ctx, file.ID, total); err != nil {
+		return fmt.Errorf("set total chunks %w", err)
+	}
+	file.TotalChunks = total
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"node-test/internal/domain"
+	"node-test/internal/gateway"
+	"node-test/internal/master/repository"
+)
+
+const (
+	// names of readiness checks
+	checkCatalog = "catalog"
+	checkNodes   = "nodes"
+)
+
+type (
+	clusterService struct {
+		logger            *zap.SugaredLogger
+		storageGateway    gateway.StorageNodeGateway
+		catalogRepository repository.CatalogRepository
+		minReadyNodes     int
+	}
+
+	// ClusterService represents an interface for the storage cluster state
+	ClusterService interface {
+		Nodes(ctx context.Context) []*domain.NodeState
+		Ready(ctx context.Context) domain.Health
+	}
+)
+
+// NewClusterService creates the cluster service, the master is ready while minReadyNodes nodes are ready,
+// one node is required when it is zero.
+func NewClusterService(
+	logger *zap.SugaredLogger,
+	storageGateway gateway.StorageNodeGateway,
+	catalogRepository repository.CatalogRepository,
+	minReadyNodes int,
+) ClusterService {
+	if minReadyNodes < 1 {
+		minReadyNodes = 1
+	}
+
+	return &clusterService{
+		logger:            logger,
+		storageGateway:    storageGateway,
+		catalogRepository: catalogRepository,
+		minReadyNodes:     minReadyNodes,
+	}
+}
+
+// Nodes returns the current state of storage nodes
+func (s *clusterService) Nodes(ctx context.Context) []*domain.NodeState {
+	return s.storageGateway.Nodes(ctx)
+}
+
+// Ready checks the connection to the catalog and that enough storage nodes are ready.
+func (s *clusterService) Ready(ctx context.Context) domain.Health {
+
+	var health domain.Health
+
+	health = health.Check(checkCatalog, s.catalogRepository.Ping(ctx))
+
+	states := s.storageGateway.Nodes(ctx)
+
+	var ready int
+	for _, state := range states {
+		if state.Ready {
+			ready++
+		}
+	}
+
+	var err error
+	if ready < s.minReadyNodes {
+		err = fmt.Errorf("%v of %v nodes are ready, %v are required", ready, len(states), s.minReadyNodes)
+	}
+
+	return health.Check(checkNodes, err)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"node-test/internal/domain"
+)
+
+func TestClusterReady(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		failPing      bool
+		nodes         []bool // readiness of nodes
+		minReadyNodes int
+		// wantFailed are names of failed checks
+		wantFailed []string
+	}{
+		{name: "ready", nodes: []bool{true, false}},
+		{name: "required nodes", nodes: []bool{true, true, false}, minReadyNodes: 2},
+		{name: "not enough nodes", nodes: []bool{true, false, false}, minReadyNodes: 2, wantFailed: []string{checkNodes}},
+		{name: "no nodes", nodes: []bool{}, wantFailed: []string{checkNodes}},
+		{name: "catalog failure", failPing: true, nodes: []bool{true}, wantFailed: []string{checkCatalog}},
+		{name: "all checks fail", failPing: true, nodes: []bool{false}, wantFailed: []string{checkCatalog, checkNodes}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			env.catalog.failPing = tt.failPing
+			env.gateway.nodes = []*domain.NodeState{}
+			for _, ready := range tt.nodes {
+				env.gateway.nodes = append(env.gateway.nodes, &domain.NodeState{Address: testNode, Available: ready, Ready: ready})
+			}
+
+			health := NewClusterService(env.logger, env.gateway, env.catalog, tt.minReadyNodes).Ready(context.Background())
+
+			var failed []string
+			for _, check := range health {
+				if check.Error != "" {
+					failed = append(failed, check.Name)
+				}
+			}
+			if len(health) != 2 || !slices.Equal(failed, tt.wantFailed) {
+				t.Fatalf("health = %+v, want failed checks %v", health, tt.wantFailed)
+			}
+			if health.Ready() != (len(tt.wantFailed) == 0) {
+				t.Fatalf("ready = %v with failed checks %v", health.Ready(), failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tel-io/tel/v2"
+
+	"node-test/internal/domain"
+	"node-test/internal/master/repository"
+)
+
+// Dedup returns deduplication stats of the file which the caller can read.
+// The empty id returns stats of all stored chunks, only admins can request them.
+func (s *uploadService) Dedup(ctx context.Context, id string) (*domain.DedupStats, error) {
+
+	if id == "" {
+		if !admin(ctx) {
+			return nil, ErrAccessDenied
+		}
+	} else if _, err := s.File(ctx, id); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.catalog.DedupStats(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get dedup stats %w", err)
+	}
+
+	return stats, nil
+}
+
+// acquireBlob references the stored content of the chunk at the location, otherwise it returns the new blob to store the content
+func (s *uploadService) acquireBlob(ctx context.Context, chunk *domain.Chunk, location *domain.ChunkLocation) (shared, fresh *domain.Blob, err error) {
+
+	sum := sha256.Sum256(chunk.Data)
+	hash := hex.EncodeToString(sum[:])
+	key := domain.BlobKey(hash, chunk.Replicas, chunk.NodeEncrypted, location.Codec)
+
+	shared, err = s.catalog.AcquireBlob(ctx, key)
+	if err == nil {
+		return shared, nil, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, fmt.Errorf("acquire blob %w", err)
+	}
+
+	return nil, &domain.Blob{
+		ID:         uuid.New().String(),
+		Key:        key,
+		Hash:       hash,
+		Size:       location.Size,
+		StoredSize: int64(len(chunk.Data)),
+		Codec:      location.Codec,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// registerBlob registers the content stored on nodes and references it by the location.
+// When the same content is registered concurrently, the registered blob is referenced and the stored copy is removed.
+func (s *uploadService) registerBlob(ctx context.Context, blob *domain.Blob, location *domain.ChunkLocation) error {
+
+	blob.Node, blob.Replicas = location.Node, location.Replicas
+
+	err := s.catalog.AddBlob(ctx, blob)
+	if err == nil {
+		location.Blob = blob.ID
+		return nil
+	}
+
+	if !errors.Is(err, repository.ErrDuplicate) {
+		s.discardContent(ctx, blob)
+		return fmt.Errorf("add blob %w", err)
+	}
+
+	shared, err := s.catalog.AcquireBlob(ctx, blob.Key)
+	s.discardContent(ctx, blob)
+	if err != nil {
+		return fmt.Errorf("acquire blob %w", err)
+	}
+
+	location.Blob, location.Node, location.Replicas = shared.ID, shared.Node, shared.Replicas
+	location.StoredSize, location.Deduplicated = shared.StoredSize, true
+
+	return nil
+}
+
+// addChunk stores the location of the chunk, the content referenced by the replaced location is released
+func (s *uploadService) addChunk(ctx context.Context, location *domain.ChunkLocation) error {
+
+	previous, err := s.catalog.AddChunk(ctx, location)
+	if err != nil {
+		if location.Blob != "" {
+			s.releaseBlob(ctx, location.Blob)
+		}
+		return err
+	}
+
+	if previous != nil && previous.Blob != "" {
+		s.releaseBlob(ctx, previous.Blob)
+	}
+
+	return nil
+}
+
+// releaseBlob removes the reference to the blob and collects the blob which isn't referenced anymore.
+// Failures are logged, the unreferenced blob is collected later by the lifecycle of files.
+func (s *fileRemover) releaseBlob(ctx context.Context, id string) {
+
+	blob, err := s.catalog.ReleaseBlob(ctx, id)
+	if err != nil {
+		s.logger.Error("release blob", tel.String("blob", id), tel.Error(err))
+		return
+	}
+
+	if blob.Refs > 0 {
+		return
+	}
+
+	if err := s.collectBlob(ctx, blob); err != nil {
+		s.logger.Error("collect blob", tel.String("blob", id), tel.Error(err))
+	}
+}
+
+// collectBlob removes the content of the unreferenced blob from nodes and then the blob from the catalog.
+// The blob is retired first, so it isn't referenced again while its content is removed. The blob which
+// failed to be removed from some node stays in the catalog, the lifecycle of files collects it again.
+// The blob which is referenced again is kept.
+func (s *fileRemover) collectBlob(ctx context.Context, blob *domain.Blob) error {
+
+	if err := s.catalog.RetireBlob(ctx, blob.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("retire blob %w", err)
+	}
+
+	for _, node := range blob.Nodes() {
+		if err := s.storageGateway.Delete(ctx, node, blob.ID); err != nil {
+			return fmt.Errorf("delete blob content from node %v %w", node, err)
+		}
+	}
+
+	if err := s.catalog.DeleteBlob(ctx, blob.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("delete blob %w", err)
+	}
+
+	return nil
+}
+
+// discardContent removes the content of the blob which isn't registered from nodes, failures are logged
+func (s *fileRemover) discardContent(ctx context.Context, blob *domain.Blob) {
+	for _, node := range blob.Nodes() {
+		if err := s.storageGateway.Delete(ctx, node, blob.ID); err != nil {
+			s.logger.Error("discard blob content", tel.String("blob", blob.ID), tel.String("node", node), tel.Error(err))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"node-test/internal/domain"
+)
+
+// assertBlobs checks that every blob is referenced the count of times and its content is kept on nodes
+func assertBlobs(t *testing.T, env *testEnv, refs int64, count int) {
+	t.Helper()
+
+	blobs := env.catalog.blobRefs()
+	if len(blobs) != count {
+		t.Fatalf("%v blobs are registered, want %v", len(blobs), count)
+	}
+	for id, got := range blobs {
+		if got != refs {
+			t.Fatalf("blob %v has %v references, want %v", id, got, refs)
+		}
+		if !env.gateway.stored(id) {
+			t.Fatalf("content of the blob %v is removed from nodes", id)
+		}
+	}
+}
+
+func TestDedupSharedBlob(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		copies int
+		// deleted are indexes of copies in the delete order
+		deleted []int
+	}{
+		{name: "two copies", copies: 2, deleted: []int{0, 1}},
+		{name: "last copy deleted first", copies: 2, deleted: []int{1, 0}},
+		{name: "three copies", copies: 3, deleted: []int{1, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{Dedup: true})
+			ctx := principalContext("alice")
+			content := testContent(2 * domain.MinChunkSize)
+
+			files := make([]*domain.File, tt.copies)
+			for i := range files {
+				files[i] = env.commit(ctx, t, &domain.File{Filename: "copy", ChunkSize: domain.MinChunkSize}, content)
+				// chunks of files are kept under IDs of blobs only
+				if env.gateway.stored(files[i].ID) {
+					t.Fatalf("chunks of the file %v are stored under its ID", i)
+				}
+			}
+			assertBlobs(t, env, int64(tt.copies), 2)
+
+			for i, index := range tt.deleted {
+				if err := env.storage.Delete(ctx, files[index].ID); err != nil {
+					t.Fatalf("delete copy %v: %v", index, err)
+				}
+				if left := tt.copies - i - 1; left > 0 {
+					assertBlobs(t, env, int64(left), 2)
+				}
+			}
+
+			if blobs := env.catalog.blobRefs(); len(blobs) > 0 {
+				t.Fatalf("blobs %v are kept after the last reference is released", blobs)
+			}
+			if stored := len(env.gateway.chunks); stored > 0 {
+				t.Fatalf("content of %v blobs is kept on nodes", stored)
+			}
+		})
+	}
+}
+
+func TestDedupRepeatedChunks(t *testing.T) {
+
+	env := newTestEnv(t, StorageServiceOptions{Dedup: true})
+	ctx := principalContext("alice")
+
+	// both chunks of the file have the same content
+	chunk := testContent(domain.MinChunkSize)
+	file := env.commit(ctx, t, &domain.File{Filename: "twice", ChunkSize: domain.MinChunkSize}, append(slices.Clone(chunk), chunk...))
+	assertBlobs(t, env, 2, 1)
+
+	stats, err := env.storage.Dedup(ctx, file.ID)
+	if err != nil {
+		t.Fatalf("dedup stats: %v", err)
+	}
+	if stats.LogicalBytes != 2*domain.MinChunkSize || stats.StoredBytes != domain.MinChunkSize {
+		t.Fatalf("stats = %+v, want %v logical and %v stored bytes", stats, 2*domain.MinChunkSize, domain.MinChunkSize)
+	}
+}
+
+func TestRegisterBlobConcurrently(t *testing.T) {
+
+	env := newTestEnv(t, StorageServiceOptions{Dedup: true})
+	ctx := context.Background()
+
+	registered := &domain.Blob{ID: "registered", Key: "hash/1"}
+	if err := env.storage.registerBlob(ctx, registered, &domain.ChunkLocation{Node: testNode}); err != nil {
+		t.Fatalf("register blob: %v", err)
+	}
+
+	// the same content was stored by another upload before it was registered
+	env.gateway.chunks["stored"] = map[int64][]byte{domain.BlobChunkNumber: []byte("content")}
+	location := &domain.ChunkLocation{Node: testNode}
+	if err := env.storage.registerBlob(ctx, &domain.Blob{ID: "stored", Key: "hash/1"}, location); err != nil {
+		t.Fatalf("register blob concurrently: %v", err)
+	}
+
+	if location.Blob != registered.ID || !location.Deduplicated {
+		t.Fatalf("location references blob %q deduplicated %v, want %v", location.Blob, location.Deduplicated, registered.ID)
+	}
+	if refs := env.catalog.blobRefs(); refs[registered.ID] != 2 || len(refs) != 1 {
+		t.Fatalf("blob references = %v, want 2 of %v", refs, registered.ID)
+	}
+	if env.gateway.stored("stored") {
+		t.Fatalf("content of the duplicate blob is kept on nodes")
+	}
+}
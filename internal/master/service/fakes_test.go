@@ -0,0 +1,889 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"node-test/internal/domain"
+	"node-test/internal/gateway"
+	"node-test/internal/master/repository"
+)
+
+const testNode = "node-1"
+
+var errTestFailure = errors.New("injected failure")
+
+type (
+	// memCatalog keeps the catalog in memory with the same rules as the mongo catalog:
+	// every key of the bucket has the single committed latest version and blobs are unique by their keys
+	memCatalog struct {
+		files  map[string]*domain.File
+		chunks map[string]map[int64]*domain.ChunkLocation
+		blobs  map[string]*domain.Blob
+		// failCommit fails commits of versions, so their keys must keep the latest version
+		failCommit bool
+		// failDelete fails the removal of files from the catalog
+		failDelete bool
+		// failPromote fails promotions of versions to the latest one
+		failPromote bool
+		// failPing fails pings of the catalog
+		failPing bool
+		sync.Mutex
+	}
+
+	// memBuckets keeps buckets in memory
+	memBuckets struct {
+		buckets map[string]*domain.Bucket
+		sync.Mutex
+	}
+
+	// memQuotas keeps quotas in memory
+	memQuotas struct {
+		quotas map[string]*domain.Quota
+		sync.Mutex
+	}
+
+	// memGateway keeps chunks of nodes in memory by upload IDs
+	memGateway struct {
+		chunks map[string]map[int64][]byte
+		// failDelete fails removals of chunks from nodes
+		failDelete bool
+		// downloadDelay delays async downloads of chunks by their numbers, so they complete out of order
+		downloadDelay func(number int64) time.Duration
+		// downloads counts async downloads of chunks
+		downloads atomic.Int64
+		// nodes are states of nodes, the single ready node when it is nil
+		nodes []*domain.NodeState
+		sync.Mutex
+	}
+
+	// testEnv is the storage service with in-memory repositories and nodes
+	testEnv struct {
+		storage *uploadService
+		catalog *memCatalog
+		buckets *memBuckets
+		quotas  *memQuotas
+		gateway *memGateway
+		logger  *zap.SugaredLogger
+	}
+)
+
+var (
+	_ repository.CatalogRepository = (*memCatalog)(nil)
+	_ repository.BucketRepository  = (*memBuckets)(nil)
+	_ repository.QuotaRepository   = (*memQuotas)(nil)
+	_ gateway.StorageNodeGateway   = (*memGateway)(nil)
+)
+
+func newTestEnv(t *testing.T, opts StorageServiceOptions) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		catalog: &memCatalog{
+			files:  make(map[string]*domain.File),
+			chunks: make(map[string]map[int64]*domain.ChunkLocation),
+			blobs:  make(map[string]*domain.Blob),
+		},
+		buckets: &memBuckets{buckets: make(map[string]*domain.Bucket)},
+		quotas:  &memQuotas{quotas: make(map[string]*domain.Quota)},
+		gateway: &memGateway{chunks: make(map[string]map[int64][]byte)},
+		logger:  zap.NewNop().Sugar(),
+	}
+
+	quotas := NewQuotaService(env.logger, env.quotas, nil)
+	env.storage = NewStorageService(env.logger, env.gateway, env.catalog, env.buckets, quotas, opts).(*uploadService)
+
+	return env
+}
+
+// principalContext returns the context of requests of the user
+func principalContext(user string) context.Context {
+	return ContextWithPrincipal(context.Background(), &domain.Principal{Subject: user})
+}
+
+// addBucket adds the bucket owned by the user
+func (env *testEnv) addBucket(t *testing.T, bucket *domain.Bucket) {
+	t.Helper()
+
+	if bucket.Owner == "" {
+		bucket.Owner = "alice"
+	}
+	if err := env.buckets.AddBucket(context.Background(), bucket); err != nil {
+		t.Fatalf("add bucket: %v", err)
+	}
+}
+
+// upload stores the content as the new session of the caller and returns the session,
+// chunks are cut by the chunk size of fixed sessions and by the chunker of content defined ones
+func (env *testEnv) upload(ctx context.Context, t *testing.T, file *domain.File, content []byte) *domain.File {
+	t.Helper()
+
+	file.TotalFileSize = int64(len(content))
+	session, err := env.storage.CreateSession(ctx, file)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	if session.ContentDefined() {
+		if err := env.storage.UploadContent(ctx, session, strings.NewReader(string(content))); err != nil {
+			t.Fatalf("upload content: %v", err)
+		}
+		return session
+	}
+
+	uploadChan, result := env.storage.UploadChunkedAsync(ctx, session)
+	for number := int64(1); number <= session.TotalChunks; number++ {
+		start := (number - 1) * session.ChunkSize
+		end := min(start+session.ChunkSize, int64(len(content)))
+		uploadChan <- &domain.Chunk{
+			UploadID:      session.ID,
+			ChunkNumber:   number,
+			TotalChunks:   session.TotalChunks,
+			TotalFileSize: session.TotalFileSize,
+			Filename:      session.Filename,
+			Data:          append([]byte(nil), content[start:end]...),
+		}
+	}
+	close(uploadChan)
+	if err := <-result; err != nil {
+		t.Fatalf("upload chunks: %v", err)
+	}
+
+	return session
+}
+
+// commit uploads and commits the content
+func (env *testEnv) commit(ctx context.Context, t *testing.T, file *domain.File, content []byte) *domain.File {
+	t.Helper()
+
+	session := env.upload(ctx, t, file, content)
+	committed, err := env.storage.Commit(ctx, session.ID, "")
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	return committed
+}
+
+func (m *memCatalog) AddFile(_ context.Context, file *domain.File) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.latestConflict(file, file.Status, file.Latest) {
+		return repository.ErrDuplicate
+	}
+	stored := *file
+	m.files[file.ID] = &stored
+
+	return nil
+}
+
+func (m *memCatalog) File(_ context.Context, id string) (*domain.File, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	file, ok := m.files[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	found := *file
+
+	return &found, nil
+}
+
+func (m *memCatalog) FileByKey(_ context.Context, bucket, key string) (*domain.File, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	latest := m.latest(bucket, key)
+	if latest == nil {
+		return nil, repository.ErrNotFound
+	}
+	found := *latest
+
+	return &found, nil
+}
+
+func (m *memCatalog) Versions(_ context.Context, bucket, key string) ([]*domain.File, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var versions []*domain.File
+	for _, file := range m.files {
+		if file.Bucket == bucket && file.Filename == key && file.Status == domain.FileStatusCommitted {
+			version := *file
+			versions = append(versions, &version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].CommittedAt.Equal(versions[j].CommittedAt) {
+			return versions[i].CommittedAt.After(versions[j].CommittedAt)
+		}
+		return versions[i].ID > versions[j].ID
+	})
+
+	return versions, nil
+}
+
+func (m *memCatalog) SupersedeFile(_ context.Context, bucket, key, id string, at time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, file := range m.files {
+		if file.Bucket == bucket && file.Filename == key && file.Latest && file.ID != id {
+			file.Latest, file.SupersededAt = false, at
+		}
+	}
+
+	return nil
+}
+
+func (m *memCatalog) PromoteFile(_ context.Context, id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.failPromote {
+		return errTestFailure
+	}
+	file, ok := m.files[id]
+	if !ok || file.Status != domain.FileStatusCommitted {
+		return repository.ErrNotFound
+	}
+	if m.latestConflict(file, domain.FileStatusCommitted, true) {
+		return repository.ErrDuplicate
+	}
+	file.Latest, file.SupersededAt = true, time.Time{}
+
+	return nil
+}
+
+func (m *memCatalog) PromoteVersion(ctx context.Context, bucket, key string) error {
+
+	versions, err := m.Versions(ctx, bucket, key)
+	if err != nil || len(versions) == 0 || versions[0].Latest {
+		return err
+	}
+
+	if err := m.PromoteFile(ctx, versions[0].ID); err != nil && !errors.Is(err, repository.ErrDuplicate) {
+		return err
+	}
+
+	return nil
+}
+
+func (m *memCatalog) Files(_ context.Context, filter *domain.FileFilter) ([]*domain.File, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var files []*domain.File
+	for _, file := range m.files {
+		if filter.Bucket != "" && file.Bucket != filter.Bucket {
+			continue
+		}
+		if filter.Status != "" && file.Status != filter.Status {
+			continue
+		}
+		if !filter.Versions && (file.DeleteMarker || file.Status == domain.FileStatusCommitted && !file.Latest) {
+			continue
+		}
+		if !strings.HasPrefix(file.Filename, filter.Prefix) {
+			continue
+		}
+		if filter.Reader != "" && !file.CanRead(filter.Reader) {
+			continue
+		}
+		found := *file
+		files = append(files, &found)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+
+	return page(files, filter.Offset, filter.Limit), nil
+}
+
+func (m *memCatalog) DeleteFile(_ context.Context, id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.failDelete {
+		return errTestFailure
+	}
+	if _, ok := m.files[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.files, id)
+	delete(m.chunks, id)
+
+	return nil
+}
+
+func (m *memCatalog) CommitFile(_ context.Context, id, checksum string, at time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	file, ok := m.files[id]
+	if !ok || file.Status != domain.FileStatusPending {
+		return repository.ErrNotFound
+	}
+	if m.latestConflict(file, domain.FileStatusCommitted, true) {
+		return repository.ErrDuplicate
+	}
+	file.Status, file.Latest, file.CommittedAt = domain.FileStatusCommitted, true, at
+	if checksum != "" {
+		file.Checksum = checksum
+	}
+
+	return nil
+}
+
+func (m *memCatalog) CommitVersion(_ context.Context, id, checksum string, at time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.failCommit {
+		return errTestFailure
+	}
+	file, ok := m.files[id]
+	if !ok || file.Status != domain.FileStatusPending {
+		return repository.ErrNotFound
+	}
+	file.Status, file.Latest, file.CommittedAt, file.SupersededAt = domain.FileStatusCommitted, false, at, at
+	if checksum != "" {
+		file.Checksum = checksum
+	}
+
+	return nil
+}
+
+func (m *memCatalog) SetAccess(_ context.Context, id string, access domain.FileAccess, sharedWith []string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	file, ok := m.files[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	file.Access, file.SharedWith = access, sharedWith
+
+	return nil
+}
+
+func (m *memCatalog) SetTotalChunks(_ context.Context, id string, total int64) error {
+	m.Lock()
+	defer m.Unlock()
+
+	file, ok := m.files[id]
+	if !ok || file.Status != domain.FileStatusPending {
+		return repository.ErrNotFound
+	}
+	file.TotalChunks = total
+
+	return nil
+}
+
+func (m *memCatalog) StaleDataKeys(_ context.Context, primaryKeyID string, limit int64) ([]*domain.File, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var files []*domain.File
+	for _, file := range m.files {
+		if file.DataKey != nil && file.DataKey.KeyID != primaryKeyID {
+			found := *file
+			files = append(files, &found)
+		}
+	}
+
+	return page(files, 0, limit), nil
+}
+
+func (m *memCatalog) ReplaceDataKey(_ context.Context, id string, old, key *domain.WrappedKey) error {
+	m.Lock()
+	defer m.Unlock()
+
+	file, ok := m.files[id]
+	if !ok || file.DataKey == nil || file.DataKey.KeyID != old.KeyID {
+		return repository.ErrNotFound
+	}
+	file.DataKey = key
+
+	return nil
+}
+
+func (m *memCatalog) ExpiredFiles(_ context.Context, at time.Time, offset, limit int64) ([]*domain.File, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var files []*domain.File
+	for _, file := range m.files {
+		if !file.ExpiresAt.IsZero() && !file.ExpiresAt.After(at) {
+			found := *file
+			files = append(files, &found)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+
+	return page(files, offset, limit), nil
+}
+
+func (m *memCatalog) ExpiredVersions(_ context.Context, bucket string, before time.Time, offset, limit int64) ([]*domain.File, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var files []*domain.File
+	for _, file := range m.files {
+		if file.Bucket == bucket && file.Status == domain.FileStatusCommitted && !file.Latest &&
+			!file.SupersededAt.IsZero() && file.SupersededAt.Before(before) {
+			found := *file
+			files = append(files, &found)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].SupersededAt.Before(files[j].SupersededAt) })
+
+	return page(files, offset, limit), nil
+}
+
+func (m *memCatalog) AddChunk(_ context.Context, location *domain.ChunkLocation) (*domain.ChunkLocation, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.chunks[location.UploadID] == nil {
+		m.chunks[location.UploadID] = make(map[int64]*domain.ChunkLocation)
+	}
+	previous := m.chunks[location.UploadID][location.ChunkNumber]
+	stored := *location
+	m.chunks[location.UploadID][location.ChunkNumber] = &stored
+
+	return previous, nil
+}
+
+func (m *memCatalog) CountChunks(_ context.Context, uploadID string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	return int64(len(m.chunks[uploadID])), nil
+}
+
+func (m *memCatalog) Chunks(_ context.Context, uploadID string, first, last int64) ([]*domain.ChunkLocation, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var locations []*domain.ChunkLocation
+	for number, location := range m.chunks[uploadID] {
+		if number >= first && number <= last {
+			found := *location
+			locations = append(locations, &found)
+		}
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].ChunkNumber < locations[j].ChunkNumber })
+
+	return locations, nil
+}
+
+func (m *memCatalog) AllChunks(ctx context.Context, uploadID string) ([]*domain.ChunkLocation, error) {
+	return m.Chunks(ctx, uploadID, math.MinInt64, math.MaxInt64)
+}
+
+func (m *memCatalog) AddBlob(_ context.Context, blob *domain.Blob) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.blobByKey(blob.Key) != nil {
+		return repository.ErrDuplicate
+	}
+	blob.Refs = 1
+	stored := *blob
+	m.blobs[blob.ID] = &stored
+
+	return nil
+}
+
+func (m *memCatalog) AcquireBlob(_ context.Context, key string) (*domain.Blob, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	blob := m.blobByKey(key)
+	if blob == nil {
+		return nil, repository.ErrNotFound
+	}
+	blob.Refs++
+	found := *blob
+
+	return &found, nil
+}
+
+func (m *memCatalog) ReleaseBlob(_ context.Context, id string) (*domain.Blob, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	blob, ok := m.blobs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	blob.Refs--
+	found := *blob
+
+	return &found, nil
+}
+
+func (m *memCatalog) RetireBlob(_ context.Context, id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	blob, ok := m.blobs[id]
+	if !ok || blob.Refs > 0 {
+		return repository.ErrNotFound
+	}
+	blob.Key = "retired/" + id
+
+	return nil
+}
+
+func (m *memCatalog) DeleteBlob(_ context.Context, id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	blob, ok := m.blobs[id]
+	if !ok || blob.Refs > 0 {
+		return repository.ErrNotFound
+	}
+	delete(m.blobs, id)
+
+	return nil
+}
+
+func (m *memCatalog) UnreferencedBlobs(_ context.Context, limit int64) ([]*domain.Blob, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var blobs []*domain.Blob
+	for _, blob := range m.blobs {
+		if blob.Refs <= 0 && int64(len(blobs)) < limit {
+			found := *blob
+			blobs = append(blobs, &found)
+		}
+	}
+
+	return blobs, nil
+}
+
+func (m *memCatalog) DedupStats(_ context.Context, uploadID string) (*domain.DedupStats, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var stats domain.DedupStats
+	for _, location := range m.chunks[uploadID] {
+		stats.LogicalBytes += location.Size
+		if !location.Deduplicated {
+			stats.StoredBytes += location.Size
+			stats.CompressedBytes += location.StoredSize
+		}
+	}
+
+	return &stats, nil
+}
+
+func (m *memCatalog) Ping(context.Context) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.failPing {
+		return errTestFailure
+	}
+
+	return nil
+}
+
+// latest returns the committed latest version of the key
+func (m *memCatalog) latest(bucket, key string) *domain.File {
+
+	if bucket == "" {
+		return nil
+	}
+	for _, file := range m.files {
+		if file.Bucket == bucket && file.Filename == key && file.Status == domain.FileStatusCommitted && file.Latest {
+			return file
+		}
+	}
+
+	return nil
+}
+
+// latestConflict reports whether the file with the status and the latest flag breaks the unique latest version of its key
+func (m *memCatalog) latestConflict(file *domain.File, status domain.FileStatus, latest bool) bool {
+
+	if file.Bucket == "" || status != domain.FileStatusCommitted || !latest {
+		return false
+	}
+	current := m.latest(file.Bucket, file.Filename)
+
+	return current != nil && current.ID != file.ID
+}
+
+// blobRefs returns counts of references of registered blobs by their IDs
+func (m *memCatalog) blobRefs() map[string]int64 {
+	m.Lock()
+	defer m.Unlock()
+
+	refs := make(map[string]int64, len(m.blobs))
+	for id, blob := range m.blobs {
+		refs[id] = blob.Refs
+	}
+
+	return refs
+}
+
+func (m *memCatalog) blobByKey(key string) *domain.Blob {
+	for _, blob := range m.blobs {
+		if blob.Key == key {
+			return blob
+		}
+	}
+
+	return nil
+}
+
+// versionIDs returns IDs of versions of the key, the latest version goes first
+func (m *memCatalog) versionIDs(t *testing.T, bucket, key string) (latest string, noncurrent []string) {
+	t.Helper()
+
+	versions, err := m.Versions(context.Background(), bucket, key)
+	if err != nil {
+		t.Fatalf("versions: %v", err)
+	}
+	for _, version := range versions {
+		if version.Latest {
+			if latest != "" {
+				t.Fatalf("key %v/%v has latest versions %v and %v", bucket, key, latest, version.ID)
+			}
+			latest = version.ID
+			continue
+		}
+		noncurrent = append(noncurrent, version.ID)
+	}
+
+	return latest, noncurrent
+}
+
+func page[T any](list []T, offset, limit int64) []T {
+
+	if offset >= int64(len(list)) {
+		return nil
+	}
+	list = list[offset:]
+	if limit > 0 && int64(len(list)) > limit {
+		list = list[:limit]
+	}
+
+	return list
+}
+
+func (m *memBuckets) AddBucket(_ context.Context, bucket *domain.Bucket) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.buckets[bucket.Name]; ok {
+		return repository.ErrDuplicate
+	}
+	stored := *bucket
+	m.buckets[bucket.Name] = &stored
+
+	return nil
+}
+
+func (m *memBuckets) Bucket(_ context.Context, name string) (*domain.Bucket, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	bucket, ok := m.buckets[name]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	found := *bucket
+
+	return &found, nil
+}
+
+func (m *memBuckets) Buckets(_ context.Context, member *domain.Principal) ([]*domain.Bucket, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var buckets []*domain.Bucket
+	for _, bucket := range m.buckets {
+		if bucket.IsMember(member) {
+			found := *bucket
+			buckets = append(buckets, &found)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+
+	return buckets, nil
+}
+
+func (m *memBuckets) UpdateBucket(_ context.Context, bucket *domain.Bucket) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.buckets[bucket.Name]; !ok {
+		return repository.ErrNotFound
+	}
+	stored := *bucket
+	m.buckets[bucket.Name] = &stored
+
+	return nil
+}
+
+func (m *memBuckets) DeleteBucket(_ context.Context, name string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.buckets[name]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.buckets, name)
+
+	return nil
+}
+
+func (m *memBuckets) ExpiringVersionBuckets(context.Context) ([]*domain.Bucket, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var buckets []*domain.Bucket
+	for _, bucket := range m.buckets {
+		if bucket.NoncurrentExpireAfter > 0 {
+			found := *bucket
+			buckets = append(buckets, &found)
+		}
+	}
+
+	return buckets, nil
+}
+
+func (m *memQuotas) Quota(_ context.Context, scope domain.QuotaScope, name string) (*domain.Quota, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	quota, ok := m.quotas[string(scope)+"/"+name]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	found := *quota
+
+	return &found, nil
+}
+
+func (m *memQuotas) SetLimits(_ context.Context, scope domain.QuotaScope, name string, limits domain.QuotaLimits) error {
+	m.Lock()
+	defer m.Unlock()
+
+	quota := m.quota(scope, name)
+	quota.Limits, quota.Custom = limits, true
+
+	return nil
+}
+
+func (m *memQuotas) AddUsage(_ context.Context, scope domain.QuotaScope, name string, bytes, files int64) error {
+	m.Lock()
+	defer m.Unlock()
+
+	quota := m.quota(scope, name)
+	quota.UsedBytes += bytes
+	quota.UsedFiles += files
+
+	return nil
+}
+
+func (m *memQuotas) ReserveUsage(_ context.Context, scope domain.QuotaScope, name string, bytes, files int64, limits domain.QuotaLimits) error {
+	m.Lock()
+	defer m.Unlock()
+
+	quota := m.quota(scope, name)
+	if limits.MaxBytes > 0 && quota.UsedBytes+bytes > limits.MaxBytes || limits.MaxFiles > 0 && quota.UsedFiles+files > limits.MaxFiles {
+		return repository.ErrLimitReached
+	}
+	quota.UsedBytes += bytes
+	quota.UsedFiles += files
+
+	return nil
+}
+
+func (m *memQuotas) quota(scope domain.QuotaScope, name string) *domain.Quota {
+
+	key := string(scope) + "/" + name
+	if m.quotas[key] == nil {
+		m.quotas[key] = &domain.Quota{Scope: scope, Name: name}
+	}
+
+	return m.quotas[key]
+}
+
+func (g *memGateway) SendAsync(_ context.Context, data *domain.Chunk, done gateway.SendCallback) {
+	g.Lock()
+	if g.chunks[data.UploadID] == nil {
+		g.chunks[data.UploadID] = make(map[int64][]byte)
+	}
+	g.chunks[data.UploadID][data.ChunkNumber] = append([]byte(nil), data.Data...)
+	g.Unlock()
+
+	go done([]string{testNode}, nil)
+}
+
+func (g *memGateway) Download(_ context.Context, location *domain.ChunkLocation) (*domain.Chunk, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	uploadID, chunkNumber := location.Stored()
+	data, ok := g.chunks[uploadID][chunkNumber]
+	if !ok {
+		return nil, fmt.Errorf("%w: chunk %v/%v", gateway.ErrNodeUnavailable, uploadID, chunkNumber)
+	}
+
+	return &domain.Chunk{UploadID: location.UploadID, ChunkNumber: location.ChunkNumber, Data: data}, nil
+}
+
+func (g *memGateway) DownloadAsync(ctx context.Context, location *domain.ChunkLocation, done gateway.DownloadCallback) {
+	g.downloads.Add(1)
+	go func() {
+		if g.downloadDelay != nil {
+			time.Sleep(g.downloadDelay(location.ChunkNumber))
+		}
+		done(g.Download(ctx, location))
+	}()
+}
+
+func (g *memGateway) Delete(_ context.Context, _, uploadID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.failDelete {
+		return errTestFailure
+	}
+	delete(g.chunks, uploadID)
+
+	return nil
+}
+
+func (g *memGateway) Nodes(context.Context) []*domain.NodeState {
+	if g.nodes != nil {
+		return g.nodes
+	}
+
+	return []*domain.NodeState{{Address: testNode, Available: true, Ready: true}}
+}
+
+// stored reports whether nodes keep chunks of the upload or the blob
+func (g *memGateway) stored(id string) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	return len(g.chunks[id]) > 0
+}
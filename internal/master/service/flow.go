@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	defaultUploadWindow = 8
+	defaultUploadMemory = 256 << 20 // 256MB
+)
+
+type (
+	// flowControl bounds chunks which uploads keep in memory until nodes store them.
+	// Every stream has the window of chunks in flight and all streams share the memory budget,
+	// the stream stops reading its client while either of them is exhausted.
+	flowControl struct {
+		window int
+		budget int64
+		memory *semaphore.Weighted
+	}
+)
+
+func newFlowControl(window int, budget int64) *flowControl {
+	if window <= 0 {
+		window = defaultUploadWindow
+	}
+	if budget <= 0 {
+		budget = defaultUploadMemory
+	}
+
+	return &flowControl{
+		window: window,
+		budget: budget,
+		memory: semaphore.NewWeighted(budget),
+	}
+}
+
+// newWindow returns the window of chunks in flight of the new stream
+func (f *flowControl) newWindow() chan struct{} {
+	return make(chan struct{}, f.window)
+}
+
+// acquire waits until the chunk of the size fits the window and the memory budget,
+// the returned weight must be released when nodes have stored the chunk
+func (f *flowControl) acquire(ctx context.Context, window chan struct{}, size int64) (int64, error) {
+
+	select {
+	case window <- struct{}{}:
+	case <-ctx.Done():
+		return 0, context.Cause(ctx)
+	}
+
+	// the chunk larger than the whole budget waits until the budget is free
+	weight := min(size, f.budget)
+	if err := f.memory.Acquire(ctx, weight); err != nil {
+		<-window
+		return 0, context.Cause(ctx)
+	}
+
+	return weight, nil
+}
+
+// release returns the chunk to the window and its weight to the memory budget
+func (f *flowControl) release(window chan struct{}, weight int64) {
+	f.memory.Release(weight)
+	<-window
+}
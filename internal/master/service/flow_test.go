@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockedWait is how long acquire is expected to keep waiting
+const blockedWait = 50 * time.Millisecond
+
+func TestNewFlowControlDefaults(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		window     int
+		budget     int64
+		wantWindow int
+		wantBudget int64
+	}{
+		{name: "defaults", wantWindow: defaultUploadWindow, wantBudget: defaultUploadMemory},
+		{name: "negative", window: -1, budget: -1, wantWindow: defaultUploadWindow, wantBudget: defaultUploadMemory},
+		{name: "configured", window: 2, budget: 1024, wantWindow: 2, wantBudget: 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFlowControl(tt.window, tt.budget)
+			if f.window != tt.wantWindow {
+				t.Fatalf("window = %v, want %v", f.window, tt.wantWindow)
+			}
+			if f.budget != tt.wantBudget {
+				t.Fatalf("budget = %v, want %v", f.budget, tt.wantBudget)
+			}
+			if cap(f.newWindow()) != tt.wantWindow {
+				t.Fatalf("new window capacity = %v, want %v", cap(f.newWindow()), tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestFlowControlAcquire(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		window  int
+		budget  int64
+		held    []int64
+		size    int64
+		blocked bool
+		weight  int64
+	}{
+		{name: "fits", window: 2, budget: 100, size: 40, weight: 40},
+		{name: "fits beside held chunks", window: 3, budget: 100, held: []int64{30, 30}, size: 40, weight: 40},
+		{name: "window is full", window: 2, budget: 100, held: []int64{10, 10}, size: 10, blocked: true},
+		{name: "memory is exhausted", window: 4, budget: 100, held: []int64{60}, size: 50, blocked: true},
+		{name: "chunk larger than the budget", window: 2, budget: 100, size: 500, weight: 100},
+		{name: "larger chunk waits for the whole budget", window: 2, budget: 100, held: []int64{1}, size: 500, blocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFlowControl(tt.window, tt.budget)
+			window := f.newWindow()
+			for _, size := range tt.held {
+				if _, err := f.acquire(context.Background(), window, size); err != nil {
+					t.Fatalf("acquire held chunk: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), blockedWait)
+			defer cancel()
+
+			weight, err := f.acquire(ctx, window, tt.size)
+			if tt.blocked {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("acquire error = %v, want %v", err, context.DeadlineExceeded)
+				}
+				if len(window) != len(tt.held) {
+					t.Fatalf("window holds %v chunks, want %v", len(window), len(tt.held))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			if weight != tt.weight {
+				t.Fatalf("weight = %v, want %v", weight, tt.weight)
+			}
+		})
+	}
+}
+
+func TestFlowControlRelease(t *testing.T) {
+
+	f := newFlowControl(1, 100)
+	window := f.newWindow()
+
+	weight, err := f.acquire(context.Background(), window, 80)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := f.acquire(context.Background(), window, 80)
+		acquired <- err
+	}()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquire returned %v before the chunk is released", err)
+	case <-time.After(blockedWait):
+	}
+
+	f.release(window, weight)
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("acquire after release: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("acquire is blocked after the chunk is released")
+	}
+}
+
+func TestFlowControlSharedBudget(t *testing.T) {
+
+	f := newFlowControl(4, 100)
+	first, second := f.newWindow(), f.newWindow()
+
+	if _, err := f.acquire(context.Background(), first, 70); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blockedWait)
+	defer cancel()
+
+	// the window of the second stream is empty, but the first one holds the memory
+	if _, err := f.acquire(ctx, second, 70); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(second) != 0 {
+		t.Fatalf("window holds %v chunks after the failed acquire, want 0", len(second))
+	}
+}
+
+func TestFlowControlCause(t *testing.T) {
+
+	cause := errors.New("stream is closed")
+
+	f := newFlowControl(1, 100)
+	window := f.newWindow()
+	if _, err := f.acquire(context.Background(), window, 10); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cause)
+
+	if _, err := f.acquire(ctx, window, 10); !errors.Is(err, cause) {
+		t.Fatalf("acquire error = %v, want %v", err, cause)
+	}
+}
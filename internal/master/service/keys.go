@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"node-test/internal/common/encryption"
+	"node-test/internal/master/repository"
+)
+
+const (
+	rotationBatchSize = 100
+)
+
+type (
+	keyService struct {
+		logger  *zap.SugaredLogger
+		catalog repository.CatalogRepository
+		keyring *encryption.Keyring
+	}
+
+	// KeyService maintains data keys of files encrypted on the master
+	KeyService interface {
+		Rotate(ctx context.Context) (int64, error)
+	}
+)
+
+func NewKeyService(
+	logger *zap.SugaredLogger,
+	catalog repository.CatalogRepository,
+	keyring *encryption.Keyring,
+) KeyService {
+	return &keyService{
+		logger:  logger,
+		catalog: catalog,
+		keyring: keyring,
+	}
+}
+
+// Rotate re-wraps data keys by the primary key of the keyring and returns the count of re-wrapped keys.
+// Chunks stay as they are, since the data keys themselves don't change.
+func (s *keyService) Rotate(ctx context.Context) (int64, error) {
+
+	var rotated int64
+	for {
+		files, err := s.catalog.StaleDataKeys(ctx, s.keyring.Primary(), rotationBatchSize)
+		if err != nil {
+			return rotated, fmt.Errorf("get stale data keys %w", err)
+		}
+		if len(files) == 0 {
+			return rotated, nil
+		}
+
+		for _, file := range files {
+			key, err := s.keyring.Rewrap(file.DataKey)
+			if err != nil {
+				return rotated, fmt.Errorf("rewrap data key of file %v %w", file.ID, err)
+			}
+
+			err = s.catalog.ReplaceDataKey(ctx, file.ID, file.DataKey, key)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return rotated, fmt.Errorf("replace data key of file %v %w", file.ID, err)
+			}
+			if err == nil {
+				rotated++
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tel-io/tel/v2"
+	"go.uber.org/zap"
+
+	"node-test/internal/domain"
+	"node-test/internal/gateway"
+	"node-test/internal/master/repository"
+)
+
+const (
+	defaultLifecycleInterval  = time.Minute
+	defaultLifecycleBatchSize = 100
+)
+
+type (
+	lifecycleService struct {
+		fileRemover
+		buckets  repository.BucketRepository
+		interval time.Duration
+		batch    int64
+	}
+
+	// LifecycleService removes files which TTL has elapsed and noncurrent versions which expire by rules of their buckets
+	LifecycleService interface {
+		Run(ctx context.Context)
+		Expire(ctx context.Context) (int64, error)
+	}
+)
+
+// NewLifecycleService creates the service which looks for expired files every interval by batches of the size,
+// zero values mean the default interval and batch size.
+func NewLifecycleService(
+	logger *zap.SugaredLogger,
+	storageGateway gateway.StorageNodeGateway,
+	catalog repository.CatalogRepository,
+	buckets repository.BucketRepository,
+	quotas QuotaService,
+	interval time.Duration,
+	batch int64,
+) LifecycleService {
+	if interval <= 0 {
+		interval = defaultLifecycleInterval
+	}
+	if batch <= 0 {
+		batch = defaultLifecycleBatchSize
+	}
+
+	return &lifecycleService{
+		fileRemover: fileRemover{
+			logger:         logger,
+			storageGateway: storageGateway,
+			catalog:        catalog,
+			quotas:         quotas,
+		},
+		buckets:  buckets,
+		interval: interval,
+		batch:    batch,
+	}
+}
+
+// Run expires files every interval until the context is done.
+func (s *lifecycleService) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := s.Expire(ctx)
+		if err != nil {
+			s.logger.Error("expire files", tel.Error(err))
+		}
+		if removed > 0 {
+			s.logger.Info("expired files removed", tel.Int64("count", removed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Expire removes expired files and expired noncurrent versions and returns the count of removed files,
+// blobs which aren't referenced anymore are collected as well.
+// Retained files are kept until their retention elapses, files which fail to be removed are retried by the next call.
+func (s *lifecycleService) Expire(ctx context.Context) (int64, error) {
+
+	now := time.Now().UTC()
+
+	removed, err := s.expireFiles(ctx, now)
+	if err != nil {
+		return removed, err
+	}
+
+	buckets, err := s.buckets.ExpiringVersionBuckets(ctx)
+	if err != nil {
+		return removed, fmt.Errorf("get buckets with expiring versions %w", err)
+	}
+
+	for _, bucket := range buckets {
+		count, err := s.expireVersions(ctx, bucket, now)
+		removed += count
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, s.collectBlobs(ctx)
+}
+
+// collectBlobs removes the batch of unreferenced blobs which weren't collected when their last chunk was removed
+func (s *lifecycleService) collectBlobs(ctx context.Context) error {
+
+	blobs, err := s.catalog.UnreferencedBlobs(ctx, s.batch)
+	if err != nil {
+		return fmt.Errorf("get unreferenced blobs %w", err)
+	}
+
+	for _, blob := range blobs {
+		if err := s.collectBlob(ctx, blob); err != nil {
+			s.logger.Error("collect blob", tel.String("blob", blob.ID), tel.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// expireFiles removes files which TTL has elapsed at the time
+func (s *lifecycleService) expireFiles(ctx context.Context, now time.Time) (int64, error) {
+
+	var removed, kept int64
+	for {
+		files, err := s.catalog.ExpiredFiles(ctx, now, kept, s.batch)
+		if err != nil {
+			return removed, fmt.Errorf("get expired files %w", err)
+		}
+		if len(files) == 0 {
+			return removed, nil
+		}
+
+		for _, file := range files {
+			if file.Retained(now) {
+				kept++
+				continue
+			}
+			if err := s.expire(ctx, file, now); err != nil {
+				s.logger.Error("remove expired file", tel.String("upload_id", file.ID), tel.Error(err))
+				kept++
+				continue
+			}
+			removed++
+		}
+	}
+}
+
+// expireVersions removes noncurrent versions of the bucket which were superseded before the expiration of the bucket
+func (s *lifecycleService) expireVersions(ctx context.Context, bucket *domain.Bucket, now time.Time) (int64, error) {
+
+	var removed, kept int64
+	for {
+		versions, err := s.catalog.ExpiredVersions(ctx, bucket.Name, now.Add(-bucket.NoncurrentExpireAfter), kept, s.batch)
+		if err != nil {
+			return removed, fmt.Errorf("get expired versions of bucket %v %w", bucket.Name, err)
+		}
+		if len(versions) == 0 {
+			return removed, nil
+		}
+
+		for _, version := range versions {
+			if version.Retained(now) {
+				kept++
+				continue
+			}
+			if err := s.purge(ctx, version); err != nil {
+				s.logger.Error("remove expired version", tel.String("upload_id", version.ID), tel.Error(err))
+				kept++
+				continue
+			}
+			removed++
+		}
+	}
+}
+
+// expire removes the expired file. When the latest version of the key expires in the bucket which keeps versions,
+// the key gets the delete marker, so previous versions don't become the latest one. The file is purged first,
+// so the failed purge is retried by the next call without adding another marker.
+func (s *lifecycleService) expire(ctx context.Context, file *domain.File, now time.Time) error {
+
+	if file.Bucket == "" || !file.Latest || file.Status != domain.FileStatusCommitted {
+		return s.remove(ctx, file)
+	}
+
+	bucket, err := s.buckets.Bucket(ctx, file.Bucket)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("get bucket %w", err)
+	}
+	if bucket == nil || !bucket.Versioning {
+		return s.remove(ctx, file)
+	}
+
+	versions, err := s.catalog.Versions(ctx, file.Bucket, file.Filename)
+	if err != nil {
+		return fmt.Errorf("get versions %w", err)
+	}
+	if len(versions) < 2 {
+		return s.remove(ctx, file)
+	}
+
+	if err := s.purge(ctx, file); err != nil {
+		return err
+	}
+	// the newer version has been committed since the file was found
+	if versions[0].ID != file.ID {
+		return nil
+	}
+
+	// the key without the latest version reads as deleted until the marker is added
+	marker := &domain.File{
+		ID:           uuid.New().String(),
+		Bucket:       file.Bucket,
+		Filename:     file.Filename,
+		Status:       domain.FileStatusCommitted,
+		Latest:       true,
+		DeleteMarker: true,
+		Owner:        file.Owner,
+		Tenant:       file.Tenant,
+		Access:       file.Access,
+		SharedWith:   file.SharedWith,
+		CreatedAt:    now,
+		CommittedAt:  now,
+	}
+	// the file is removed already, the failure is logged, so the file is counted and not retried
+	if err := s.addDeleteMarker(ctx, marker); err != nil {
+		s.logger.Error("add delete marker of expired file", tel.String("upload_id", file.ID), tel.Error(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"node-test/internal/domain"
+)
+
+func newTestLifecycle(env *testEnv) LifecycleService {
+	return NewLifecycleService(env.logger, env.gateway, env.catalog, env.buckets, NewQuotaService(env.logger, env.quotas, nil), 0, 0)
+}
+
+// deleteMarkers returns delete markers of the key
+func deleteMarkers(t *testing.T, env *testEnv, bucket, key string) []*domain.File {
+	t.Helper()
+
+	versions, err := env.catalog.Versions(context.Background(), bucket, key)
+	if err != nil {
+		t.Fatalf("versions: %v", err)
+	}
+
+	var markers []*domain.File
+	for _, version := range versions {
+		if version.DeleteMarker {
+			markers = append(markers, version)
+		}
+	}
+
+	return markers
+}
+
+func TestLifecycleExpire(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		bucket     *domain.Bucket
+		previous   bool
+		wantMarker bool
+	}{
+		{name: "file without the bucket"},
+		{name: "bucket without versioning", bucket: &domain.Bucket{Name: "docs"}},
+		{name: "single version", bucket: &domain.Bucket{Name: "docs", Versioning: true}},
+		{name: "latest version", bucket: &domain.Bucket{Name: "docs", Versioning: true}, previous: true, wantMarker: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			ctx := principalContext("alice")
+
+			var bucketName string
+			if tt.bucket != nil {
+				env.addBucket(t, tt.bucket)
+				bucketName = tt.bucket.Name
+			}
+
+			var previous *domain.File
+			if tt.previous {
+				previous = env.commit(ctx, t, &domain.File{Bucket: bucketName, Filename: "a.txt"}, []byte("previous"))
+			}
+			expired := env.commit(ctx, t, &domain.File{Bucket: bucketName, Filename: "a.txt", TTL: time.Nanosecond}, []byte("expired"))
+
+			removed, err := newTestLifecycle(env).Expire(context.Background())
+			if err != nil {
+				t.Fatalf("expire: %v", err)
+			}
+			if removed != 1 {
+				t.Fatalf("removed %v files, want 1", removed)
+			}
+			if env.gateway.stored(expired.ID) {
+				t.Fatalf("chunks of the expired file are kept on nodes")
+			}
+			if _, err := env.catalog.File(context.Background(), expired.ID); err == nil {
+				t.Fatalf("expired file is kept in the catalog")
+			}
+
+			if bucketName == "" {
+				return
+			}
+			latest, noncurrent := env.catalog.versionIDs(t, bucketName, "a.txt")
+			markers := deleteMarkers(t, env, bucketName, "a.txt")
+			if !tt.wantMarker {
+				if latest != "" || len(markers) > 0 {
+					t.Fatalf("key has the latest version %q and %v markers, want none", latest, len(markers))
+				}
+				return
+			}
+			if len(markers) != 1 || latest != markers[0].ID {
+				t.Fatalf("latest version = %v, want the single marker of %v", latest, len(markers))
+			}
+			if len(noncurrent) != 1 || noncurrent[0] != previous.ID {
+				t.Fatalf("noncurrent versions = %v, want %v", noncurrent, previous.ID)
+			}
+		})
+	}
+}
+
+func TestLifecycleExpireFailure(t *testing.T) {
+
+	tests := []struct {
+		name string
+		fail func(env *testEnv, failed bool)
+		// wantRemoved means the file is removed while the failure lasts
+		wantRemoved bool
+	}{
+		{name: "purge fails", fail: func(env *testEnv, failed bool) { env.gateway.failDelete = failed }},
+		{name: "marker fails", fail: func(env *testEnv, failed bool) { env.catalog.failPromote = failed }, wantRemoved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			env.addBucket(t, &domain.Bucket{Name: "docs", Versioning: true})
+			ctx := principalContext("alice")
+			lifecycle := newTestLifecycle(env)
+
+			previous := env.commit(ctx, t, &domain.File{Bucket: "docs", Filename: "a.txt"}, []byte("previous"))
+			expired := env.commit(ctx, t, &domain.File{Bucket: "docs", Filename: "a.txt", TTL: time.Nanosecond}, []byte("expired"))
+
+			tt.fail(env, true)
+			var removed int64
+			for attempt := 1; attempt <= 3; attempt++ {
+				count, _ := lifecycle.Expire(context.Background())
+				removed += count
+
+				// failed attempts neither add markers nor make the previous version the latest one
+				if markers := deleteMarkers(t, env, "docs", "a.txt"); len(markers) > 1 {
+					t.Fatalf("attempt %v left %v delete markers", attempt, len(markers))
+				}
+				latest, _ := env.catalog.versionIDs(t, "docs", "a.txt")
+				if latest == previous.ID {
+					t.Fatalf("attempt %v made the previous version the latest one", attempt)
+				}
+			}
+			if tt.wantRemoved != (removed == 1) {
+				t.Fatalf("removed %v files while the failure lasts", removed)
+			}
+
+			tt.fail(env, false)
+			count, err := lifecycle.Expire(context.Background())
+			if err != nil {
+				t.Fatalf("expire: %v", err)
+			}
+			if removed += count; removed != 1 {
+				t.Fatalf("removed %v files, want 1", removed)
+			}
+			if env.gateway.stored(expired.ID) {
+				t.Fatalf("chunks of the expired file are kept on nodes")
+			}
+			if _, err := env.storage.Resolve(ctx, "docs", "a.txt"); err == nil {
+				t.Fatalf("expired key is resolved")
+			}
+			if markers := deleteMarkers(t, env, "docs", "a.txt"); len(markers) > 1 {
+				t.Fatalf("key has %v delete markers", len(markers))
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	directionUpload   = "upload"
+	directionDownload = "download"
+)
+
+var (
+	transferredChunks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "master",
+		Name:      "chunks_total",
+		Help:      "Chunks uploaded by clients and downloaded by them.",
+	}, []string{"direction"})
+
+	transferredBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "master",
+		Name:      "bytes_total",
+		Help:      "Bytes of file content uploaded by clients and downloaded by them, before compression and encryption.",
+	}, []string{"direction"})
+)
+
+// transferred counts the chunk which is uploaded or downloaded in the direction
+func transferred(direction string, size int64) {
+	transferredChunks.WithLabelValues(direction).Inc()
+	transferredBytes.WithLabelValues(direction).Add(float64(size))
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+
+	"node-test/internal/domain"
+)
+
+type (
+	principalContextKey struct{}
+)
+
+// ContextWithPrincipal returns the context of the request made by the authenticated principal.
+func ContextWithPrincipal(ctx context.Context, principal *domain.Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of the request or nil when the request isn't authenticated.
+func PrincipalFromContext(ctx context.Context) *domain.Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*domain.Principal)
+	return principal
+}
+
+// subject returns the subject of the authenticated principal, it is empty for anonymous requests
+func subject(ctx context.Context) string {
+	if principal := PrincipalFromContext(ctx); principal != nil {
+		return principal.Subject
+	}
+
+	return ""
+}
+
+// tenant returns the tenant of the authenticated principal
+func tenant(ctx context.Context) string {
+	if principal := PrincipalFromContext(ctx); principal != nil {
+		return principal.Tenant
+	}
+
+	return ""
+}
+
+// admin reports whether the authenticated principal is the admin
+func admin(ctx context.Context) bool {
+	principal := PrincipalFromContext(ctx)
+	return principal != nil && principal.Admin
+}
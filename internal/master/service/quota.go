@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tel-io/tel/v2"
+	"go.uber.org/zap"
+
+	"node-test/internal/domain"
+	"node-test/internal/master/repository"
+)
+
+var (
+	ErrQuotaExceeded = domain.NewError(domain.ErrorCodeQuotaExceeded, "storage quota exceeded")
+	ErrInvalidQuota  = domain.NewError(domain.ErrorCodeInvalidRequest, "invalid quota")
+)
+
+type (
+	quotaService struct {
+		logger   *zap.SugaredLogger
+		quotas   repository.QuotaRepository
+		defaults map[domain.QuotaScope]domain.QuotaLimits
+	}
+
+	quotaScope struct {
+		scope domain.QuotaScope
+		name  string
+	}
+
+	// QuotaService limits bytes and files which users, tenants and buckets keep in the storage.
+	// Only committed files are counted, the usage of the file is reserved when it is committed.
+	QuotaService interface {
+		Quota(ctx context.Context, scope domain.QuotaScope, name string) (*domain.Quota, error)
+		SetQuota(ctx context.Context, scope domain.QuotaScope, name string, limits domain.QuotaLimits) (*domain.Quota, error)
+		Usage(ctx context.Context) ([]*domain.Quota, error)
+		Check(ctx context.Context, file *domain.File) error
+		Reserve(ctx context.Context, file *domain.File) error
+		Track(ctx context.Context, file *domain.File, files int64) error
+	}
+)
+
+// NewQuotaService creates the service which applies default limits to users and tenants without custom ones.
+func NewQuotaService(
+	logger *zap.SugaredLogger,
+	quotas repository.QuotaRepository,
+	defaults map[domain.QuotaScope]domain.QuotaLimits,
+) QuotaService {
+	return &quotaService{
+		logger:   logger,
+		quotas:   quotas,
+		defaults: defaults,
+	}
+}
+
+// Quota returns limits and usage of the user, the tenant or the bucket, only admins can read quotas of others.
+func (s *quotaService) Quota(ctx context.Context, scope domain.QuotaScope, name string) (*domain.Quota, error) {
+
+	if !admin(ctx) {
+		return nil, ErrAccessDenied
+	}
+
+	if !scope.Valid() || name == "" {
+		return nil, fmt.Errorf("%w: scope %q name %q", ErrInvalidQuota, scope, name)
+	}
+
+	return s.quota(ctx, scope, name)
+}
+
+// SetQuota replaces limits of the user, the tenant or the bucket, zero limits mean no limit.
+// Files which are already stored aren't affected when the usage exceeds the new limits.
+func (s *quotaService) SetQuota(ctx context.Context, scope domain.QuotaScope, name string, limits domain.QuotaLimits) (*domain.Quota, error) {
+
+	if !admin(ctx) {
+		return nil, ErrAccessDenied
+	}
+
+	if !scope.Valid() || name == "" || limits.MaxBytes < 0 || limits.MaxFiles < 0 {
+		return nil, fmt.Errorf("%w: scope %q name %q", ErrInvalidQuota, scope, name)
+	}
+
+	if err := s.quotas.SetLimits(ctx, scope, name, limits); err != nil {
+		return nil, fmt.Errorf("set quota %w", err)
+	}
+
+	return s.quota(ctx, scope, name)
+}
+
+// Usage returns quotas of the caller and its tenant.
+func (s *quotaService) Usage(ctx context.Context) ([]*domain.Quota, error) {
+
+	principal := PrincipalFromContext(ctx)
+	if principal == nil || principal.Subject == "" {
+		return nil, ErrAccessDenied
+	}
+
+	user, err := s.quota(ctx, domain.QuotaScopeUser, principal.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := []*domain.Quota{user}
+	if principal.Tenant != "" {
+		tenant, err := s.quota(ctx, domain.QuotaScopeTenant, principal.Tenant)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, tenant)
+	}
+
+	return usage, nil
+}
+
+// Check reports ErrQuotaExceeded when one more file doesn't fit the quota of its owner, tenant or bucket.
+// Pending uploads aren't counted, so the check only rejects sessions early and Reserve enforces the quota.
+func (s *quotaService) Check(ctx context.Context, file *domain.File) error {
+
+	for _, scope := range fileScopes(file) {
+		quota, err := s.quota(ctx, scope.scope, scope.name)
+		if err != nil {
+			return err
+		}
+		if !quota.Allows(file.TotalFileSize) {
+			return fmt.Errorf("%w: %v %v", ErrQuotaExceeded, scope.scope, scope.name)
+		}
+	}
+
+	return nil
+}
+
+// Reserve adds the file to usage of its owner, tenant and bucket when it fits all their quotas,
+// otherwise nothing is added and ErrQuotaExceeded is reported. Track with the negative count releases the usage.
+func (s *quotaService) Reserve(ctx context.Context, file *domain.File) error {
+
+	scopes := fileScopes(file)
+	for i, scope := range scopes {
+		quota, err := s.quota(ctx, scope.scope, scope.name)
+		if err == nil {
+			err = s.quotas.ReserveUsage(ctx, scope.scope, scope.name, file.TotalFileSize, 1, quota.Limits)
+		}
+		if err == nil {
+			continue
+		}
+
+		// usage reserved in previous scopes is released
+		for _, reserved := range scopes[:i] {
+			if err := s.quotas.AddUsage(ctx, reserved.scope, reserved.name, -file.TotalFileSize, -1); err != nil {
+				s.logger.Error("release quota usage", tel.String("upload_id", file.ID), tel.Error(err))
+			}
+		}
+
+		if errors.Is(err, repository.ErrLimitReached) {
+			return fmt.Errorf("%w: %v %v", ErrQuotaExceeded, scope.scope, scope.name)
+		}
+		return fmt.Errorf("reserve %v usage %w", scope.scope, err)
+	}
+
+	return nil
+}
+
+// Track adds the file to usage of its owner, tenant and bucket, the negative count removes it.
+func (s *quotaService) Track(ctx context.Context, file *domain.File, files int64) error {
+
+	for _, scope := range fileScopes(file) {
+		if err := s.quotas.AddUsage(ctx, scope.scope, scope.name, files*file.TotalFileSize, files); err != nil {
+			return fmt.Errorf("track %v usage %w", scope.scope, err)
+		}
+	}
+
+	return nil
+}
+
+// fileScopes returns quotas which the file is counted in
+func fileScopes(file *domain.File) []quotaScope {
+
+	scopes := []quotaScope{{scope: domain.QuotaScopeUser, name: file.Owner}}
+	if file.Tenant != "" {
+		scopes = append(scopes, quotaScope{scope: domain.QuotaScopeTenant, name: file.Tenant})
+	}
+	if file.Bucket != "" {
+		scopes = append(scopes, quotaScope{scope: domain.QuotaScopeBucket, name: file.Bucket})
+	}
+
+	return scopes
+}
+
+// quota loads the recorded quota and applies default limits when there are no custom ones
+func (s *quotaService) quota(ctx context.Context, scope domain.QuotaScope, name string) (*domain.Quota, error) {
+
+	quota, err := s.quotas.Quota(ctx, scope, name)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("get quota %w", err)
+		}
+		quota = &domain.Quota{Scope: scope, Name: name}
+	}
+
+	if !quota.Custom {
+		quota.Limits = s.defaults[scope]
+	}
+
+	return quota, nil
+}
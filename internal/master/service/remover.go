@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tel-io/tel/v2"
+	"go.uber.org/zap"
+
+	"node-test/internal/domain"
+	"node-test/internal/gateway"
+	"node-test/internal/master/repository"
+)
+
+type (
+	// fileRemover removes files from storage nodes and the catalog for uploads and the lifecycle of files
+	fileRemover struct {
+		logger         *zap.SugaredLogger
+		storageGateway gateway.StorageNodeGateway
+		catalog        repository.CatalogRepository
+		quotas         QuotaService
+	}
+)
+
+// remove purges the file, the previous version becomes the latest one when the latest version of the key is removed
+func (s *fileRemover) remove(ctx context.Context, file *domain.File) error {
+
+	if err := s.purge(ctx, file); err != nil {
+		return err
+	}
+
+	if file.Bucket != "" && file.Latest {
+		if err := s.catalog.PromoteVersion(ctx, file.Bucket, file.Filename); err != nil {
+			return fmt.Errorf("promote previous version %w", err)
+		}
+	}
+
+	return nil
+}
+
+// purge removes chunks of the file from storage nodes and the file from the catalog.
+// The shared content is released after the file is removed, so the failure leaves the content stored rather than lost.
+func (s *fileRemover) purge(ctx context.Context, file *domain.File) error {
+
+	// the pending session of content defined chunks doesn't know the count of its stored chunks
+	locations, err := s.catalog.AllChunks(ctx, file.ID)
+	if err != nil {
+		return fmt.Errorf("get chunk locations %w", err)
+	}
+
+	var (
+		nodes = make(map[string]struct{})
+		blobs []string
+	)
+	for _, location := range locations {
+		if location.Blob != "" {
+			blobs = append(blobs, location.Blob)
+			continue
+		}
+		for _, node := range location.Nodes() {
+			nodes[node] = struct{}{}
+		}
+	}
+
+	for node := range nodes {
+		if err := s.storageGateway.Delete(ctx, node, file.ID); err != nil {
+			return fmt.Errorf("delete chunks from node %v %w", node, err)
+		}
+	}
+
+	if err := s.catalog.DeleteFile(ctx, file.ID); err != nil {
+		return fmt.Errorf("delete file %w", err)
+	}
+
+	for _, blob := range blobs {
+		s.releaseBlob(ctx, blob)
+	}
+
+	if file.Status == domain.FileStatusCommitted && !file.DeleteMarker {
+		if err := s.quotas.Track(ctx, file, -1); err != nil {
+			s.logger.Error("release quota usage", tel.String("upload_id", file.ID), tel.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// addDeleteMarker makes the delete marker the latest version of its key, the current latest version becomes noncurrent.
+// The marker is stored as the noncurrent version first, so the key keeps its latest version when the marker can't be added.
+func (s *fileRemover) addDeleteMarker(ctx context.Context, marker *domain.File) error {
+
+	marker.Latest, marker.SupersededAt = false, marker.CommittedAt
+	if err := s.catalog.AddFile(ctx, marker); err != nil {
+		return fmt.Errorf("add delete marker %w", err)
+	}
+
+	if err := s.promote(ctx, marker, marker.CommittedAt); err != nil {
+		return fmt.Errorf("promote delete marker %w", err)
+	}
+
+	return nil
+}
+
+// promote makes the committed version the latest one of its key, the latest version becomes noncurrent at the time.
+// Other versions are superseded once the version is committed, so the failed commit doesn't leave the key
+// without the latest version, and the version isn't superseded by the retry of its own commit.
+func (s *fileRemover) promote(ctx context.Context, version *domain.File, at time.Time) error {
+
+	for attempt := 1; ; attempt++ {
+		if err := s.catalog.SupersedeFile(ctx, version.Bucket, version.Filename, version.ID, at); err != nil {
+			return fmt.Errorf("supersede latest version %w", err)
+		}
+
+		// the concurrent version may become the latest one between both updates
+		err := s.catalog.PromoteFile(ctx, version.ID)
+		if err == nil {
+			version.Latest, version.SupersededAt = true, time.Time{}
+			return nil
+		}
+		if !errors.Is(err, repository.ErrDuplicate) || attempt == maxVersionAttempts {
+			return err
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"node-test/internal/common/chunker"
+	"node-test/internal/domain"
+	"node-test/internal/master/repository"
+)
+
+// testContent returns the random content of the size which is the same for every call
+func testContent(size int) []byte {
+
+	content := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(content)
+
+	return content
+}
+
+func TestDeletePendingSession(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		chunking *chunker.Params
+	}{
+		{name: "fixed chunks"},
+		{name: "content defined chunks", chunking: &chunker.Params{Min: 64, Avg: 256, Max: 1024}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{Chunking: tt.chunking})
+			ctx := principalContext("alice")
+			content := testContent(8 << 10)
+
+			var session *domain.File
+			if tt.chunking == nil {
+				session = env.upload(ctx, t, &domain.File{Filename: "a.bin"}, content)
+			} else {
+				// the content ends before the announced size, so the count of chunks stays unknown
+				var err error
+				session, err = env.storage.CreateSession(ctx, &domain.File{Filename: "a.bin", TotalFileSize: int64(len(content)) * 2})
+				if err != nil {
+					t.Fatalf("create session: %v", err)
+				}
+				err = env.storage.UploadContent(ctx, session, bytes.NewReader(content))
+				if !errors.Is(err, ErrIncompleteUpload) {
+					t.Fatalf("upload content = %v, want %v", err, ErrIncompleteUpload)
+				}
+			}
+
+			stored, err := env.catalog.CountChunks(ctx, session.ID)
+			if err != nil || stored == 0 {
+				t.Fatalf("session has %v stored chunks: %v", stored, err)
+			}
+
+			if err := env.storage.Delete(ctx, session.ID); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+
+			if _, err := env.catalog.File(context.Background(), session.ID); !errors.Is(err, repository.ErrNotFound) {
+				t.Fatalf("get deleted session = %v, want %v", err, repository.ErrNotFound)
+			}
+			if env.gateway.stored(session.ID) {
+				t.Fatalf("chunks of the deleted session are kept on nodes")
+			}
+		})
+	}
+}
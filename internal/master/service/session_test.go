@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"node-test/internal/common/chunker"
+	"node-test/internal/domain"
+)
+
+func TestCreateSessionChunkSize(t *testing.T) {
+
+	const size = 1 << 20
+
+	tests := []struct {
+		name        string
+		opts        StorageServiceOptions
+		bucketChunk int64
+		requested   int64
+		wantSize    int64
+		wantChunks  int64
+		wantErr     error
+	}{
+		{name: "default", wantSize: domain.DefaultChunkSize, wantChunks: size/domain.DefaultChunkSize + 1},
+		{name: "master", opts: StorageServiceOptions{ChunkSize: 64 << 10}, wantSize: 64 << 10, wantChunks: 16},
+		{name: "bucket", opts: StorageServiceOptions{ChunkSize: 64 << 10}, bucketChunk: 256 << 10, wantSize: 256 << 10, wantChunks: 4},
+		{name: "upload", bucketChunk: 256 << 10, requested: 128 << 10, wantSize: 128 << 10, wantChunks: 8},
+		{name: "below the minimum", requested: 1, wantSize: domain.MinChunkSize, wantChunks: size / domain.MinChunkSize},
+		{name: "above the maximum", requested: domain.MaxChunkSize + 1, wantSize: domain.MaxChunkSize, wantChunks: 1},
+		{name: "negative", requested: -1, wantErr: ErrInvalidChunkSize},
+		{
+			name:       "content defined chunks",
+			opts:       StorageServiceOptions{Chunking: &chunker.Params{Min: 1 << 10, Avg: 4 << 10, Max: 16 << 10}},
+			requested:  128 << 10,
+			wantSize:   16 << 10,
+			wantChunks: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, tt.opts)
+			env.addBucket(t, &domain.Bucket{Name: "docs", ChunkSize: tt.bucketChunk})
+			ctx := principalContext("alice")
+
+			session, err := env.storage.CreateSession(ctx, &domain.File{Bucket: "docs", Filename: "a.bin", TotalFileSize: size, ChunkSize: tt.requested})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("create session error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if session.ChunkSize != tt.wantSize || session.TotalChunks != tt.wantChunks {
+				t.Fatalf("session has %v chunks of %v bytes, want %v chunks of %v bytes",
+					session.TotalChunks, session.ChunkSize, tt.wantChunks, tt.wantSize)
+			}
+		})
+	}
+}
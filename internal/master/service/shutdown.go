@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"node-test/internal/domain"
+)
+
+// interruptTimeout limits the wait for interrupted streams which record chunks already sent to nodes
+const interruptTimeout = 5 * time.Second
+
+var ErrShuttingDown = domain.NewError(domain.ErrorCodeUnavailable, "master is shutting down")
+
+type (
+	// drain tracks upload streams so the shutdown lets them finish. Once the shutdown starts new sessions
+	// and streams are rejected, streams left at its deadline are interrupted and their sessions can be resumed.
+	drain struct {
+		mu        sync.Mutex
+		closed    bool
+		streams   sync.WaitGroup
+		stop      context.Context
+		interrupt context.CancelCauseFunc
+	}
+)
+
+func newDrain() *drain {
+	stop, interrupt := context.WithCancelCause(context.Background())
+
+	return &drain{
+		stop:      stop,
+		interrupt: interrupt,
+	}
+}
+
+// begin registers the stream, its context is canceled when the shutdown interrupts streams.
+// The returned func must be called once the stream ends.
+func (d *drain) begin(ctx context.Context) (context.Context, func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, nil, ErrShuttingDown
+	}
+	d.streams.Add(1)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(d.stop, func() {
+		cancel(context.Cause(d.stop))
+	})
+
+	return ctx, func() {
+		stop()
+		cancel(nil)
+		d.streams.Done()
+	}, nil
+}
+
+// closing reports whether the shutdown has started
+func (d *drain) closing() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.closed
+}
+
+// shutdown rejects new streams and waits for registered ones, they are interrupted when ctx is done
+// and the shutdown waits until they record their state
+func (d *drain) shutdown(ctx context.Context) error {
+
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.streams.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.interrupt(ErrShuttingDown)
+	select {
+	case <-done:
+	case <-time.After(interruptTimeout):
+	}
+
+	return ctx.Err()
+}
+
+// OpenStream registers the upload stream, the returned context is canceled when the shutdown interrupts
+// the stream and the returned func must be called once the stream ends. New streams are rejected by the shutdown.
+func (s *uploadService) OpenStream(ctx context.Context) (context.Context, func(), error) {
+	return s.drain.begin(ctx)
+}
+
+// Shutdown rejects new sessions and streams and waits until open streams end. Streams left when ctx is done
+// are interrupted, chunks which nodes have stored are recorded so their sessions can be resumed.
+func (s *uploadService) Shutdown(ctx context.Context) error {
+	return s.drain.shutdown(ctx)
+}
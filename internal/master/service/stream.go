@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"node-test/internal/common/compression"
+	"node-test/internal/common/encryption"
+	"node-test/internal/domain"
+)
+
+const (
+	// downloadWindow is the count of chunks requested from nodes ahead of the emitted one,
+	// so the memory used by the download doesn't depend on the file size.
+	downloadWindow = 8
+	// locationBatchSize is the count of chunk locations loaded from the catalog at once.
+	locationBatchSize = 256
+)
+
+var (
+	errStreamClosed = errors.New("chunk stream is closed")
+)
+
+type (
+	// ChunkStream emits chunks of the file in order, Next returns io.EOF after the last chunk.
+	ChunkStream interface {
+		File() *domain.File
+		Next() (*domain.Chunk, error)
+		Close() error
+	}
+
+	chunkStream struct {
+		ctx     context.Context
+		cancel  context.CancelFunc
+		service *uploadService
+		file    *domain.File
+		cipher  *encryption.ChunkCipher // nil when chunks aren't encrypted on the master
+
+		next      int64                   // the first chunk which location isn't loaded yet
+		last      int64                   // the last chunk of the stream
+		locations []*domain.ChunkLocation // loaded locations which aren't requested yet
+		pending   []chan *downloadResult  // requested chunks in order of their numbers
+		err       error
+	}
+
+	downloadResult struct {
+		chunk *domain.Chunk
+		err   error
+	}
+)
+
+func newChunkStream(
+	ctx context.Context,
+	service *uploadService,
+	file *domain.File,
+	chunkCipher *encryption.ChunkCipher,
+	first, last int64,
+) *chunkStream {
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &chunkStream{
+		ctx:     ctx,
+		cancel:  cancel,
+		service: service,
+		file:    file,
+		cipher:  chunkCipher,
+		next:    first,
+		last:    last,
+		pending: make([]chan *downloadResult, 0, downloadWindow),
+	}
+}
+
+// File returns the file which chunks are streamed
+func (s *chunkStream) File() *domain.File {
+	return s.file
+}
+
+// Next waits for the next chunk in order while the following ones are fetched from nodes
+func (s *chunkStream) Next() (*domain.Chunk, error) {
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if err := s.requestAhead(); err != nil {
+		return nil, s.fail(err)
+	}
+
+	if len(s.pending) == 0 {
+		s.err = io.EOF
+		return nil, s.err
+	}
+
+	var result *downloadResult
+	select {
+	case <-s.ctx.Done():
+		return nil, s.fail(s.ctx.Err())
+	case result = <-s.pending[0]:
+	}
+
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+
+	if result.err != nil {
+		return nil, s.fail(result.err)
+	}
+
+	return result.chunk, nil
+}
+
+// Close cancels the chunks requested ahead
+func (s *chunkStream) Close() error {
+	s.cancel()
+	if s.err == nil || s.err == io.EOF {
+		s.err = errStreamClosed
+	}
+
+	return nil
+}
+
+// requestAhead submits download jobs until the window is full or every chunk is requested
+func (s *chunkStream) requestAhead() error {
+
+	for len(s.pending) < downloadWindow {
+		if len(s.locations) == 0 {
+			if s.next > s.last {
+				return nil
+			}
+			if err := s.loadLocations(); err != nil {
+				return err
+			}
+		}
+
+		location := s.locations[0]
+		s.locations[0] = nil
+		s.locations = s.locations[1:]
+
+		result := make(chan *downloadResult, 1)
+		s.service.storageGateway.DownloadAsync(s.ctx, location, func(chunk *domain.Chunk, err error) {
+			if err != nil {
+				result <- &downloadResult{
+					err: fmt.Errorf("download chunk %v from nodes %v %w", location.ChunkNumber, location.Nodes(), err),
+				}
+				return
+			}
+
+			if s.cipher != nil {
+				data, err := s.cipher.Open(chunk.UploadID, chunk.ChunkNumber, chunk.Data)
+				if err != nil {
+					result <- &downloadResult{err: fmt.Errorf("decrypt chunk %v %w", location.ChunkNumber, err)}
+					return
+				}
+				chunk.Data = data
+			}
+
+			data, err := compression.Decompress(location.Codec, chunk.Data, location.Size)
+			if err != nil {
+				result <- &downloadResult{err: fmt.Errorf("decompress chunk %v %w", location.ChunkNumber, err)}
+				return
+			}
+			chunk.Data = data
+			transferred(directionDownload, int64(len(chunk.Data)))
+
+			chunk.TotalChunks = s.file.TotalChunks
+			chunk.TotalFileSize = s.file.TotalFileSize
+			chunk.Filename = s.file.Filename
+			result <- &downloadResult{chunk: chunk}
+		})
+
+		s.pending = append(s.pending, result)
+	}
+
+	return nil
+}
+
+// loadLocations loads the next batch of chunk locations from the catalog
+func (s *chunkStream) loadLocations() error {
+
+	to := s.next + locationBatchSize - 1
+	if to > s.last {
+		to = s.last
+	}
+
+	locations, err := s.service.catalog.Chunks(s.ctx, s.file.ID, s.next, to)
+	if err != nil {
+		return fmt.Errorf("get chunk locations %w", err)
+	}
+
+	if int64(len(locations)) != to-s.next+1 {
+		return fmt.Errorf("%w: expected %v actual %v", ErrIncompleteUpload, to-s.next+1, len(locations))
+	}
+
+	s.locations = locations
+	s.next = to + 1
+
+	return nil
+}
+
+func (s *chunkStream) fail(err error) error {
+	s.cancel()
+	s.err = err
+
+	return err
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"node-test/internal/domain"
+	"node-test/internal/gateway"
+)
+
+func TestDownloadStream(t *testing.T) {
+
+	const totalChunks = 5*downloadWindow + 3
+	content := testContent(totalChunks*domain.MinChunkSize - 100)
+
+	tests := []struct {
+		name        string
+		first, last int64
+		wantErr     error
+	}{
+		{name: "whole file"},
+		{name: "range", first: 3, last: 2*downloadWindow + 1},
+		{name: "last chunk", first: totalChunks, last: totalChunks},
+		{name: "range over the end", first: 2, last: totalChunks + 1, wantErr: ErrInvalidRange},
+		{name: "reversed range", first: 5, last: 4, wantErr: ErrInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{ChunkSize: domain.MinChunkSize})
+			ctx := principalContext("alice")
+			file := env.commit(ctx, t, &domain.File{Filename: "a.bin"}, content)
+			// later chunks of every window arrive first
+			env.gateway.downloadDelay = func(number int64) time.Duration {
+				return time.Duration(downloadWindow-number%downloadWindow) * time.Millisecond
+			}
+
+			stream, err := env.storage.DownloadStream(ctx, file.ID, tt.first, tt.last)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("download stream error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer stream.Close()
+
+			first, last := max(tt.first, 1), tt.last
+			if last == 0 {
+				last = totalChunks
+			}
+
+			var got []byte
+			for number := first; ; number++ {
+				chunk, err := stream.Next()
+				if errors.Is(err, io.EOF) {
+					if number != last+1 {
+						t.Fatalf("stream ended before chunk %v, want %v chunks", number, last-first+1)
+					}
+					break
+				}
+				if err != nil {
+					t.Fatalf("next chunk: %v", err)
+				}
+				if chunk.ChunkNumber != number {
+					t.Fatalf("chunk %v emitted, want %v", chunk.ChunkNumber, number)
+				}
+				// chunks are requested ahead only within the window
+				if requested := env.gateway.downloads.Load() - (number - first + 1); requested > downloadWindow {
+					t.Fatalf("%v chunks requested ahead of chunk %v, want at most %v", requested, number, downloadWindow)
+				}
+				got = append(got, chunk.Data...)
+			}
+
+			want := content[file.ChunkOffset(first) : file.ChunkOffset(first)+file.RangeSize(first, last)]
+			if !bytes.Equal(got, want) {
+				t.Fatalf("streamed %v bytes differ from %v bytes of chunks %v-%v", len(got), len(want), first, last)
+			}
+		})
+	}
+}
+
+func TestDownloadStreamFailure(t *testing.T) {
+
+	tests := []struct {
+		name string
+		// fail breaks the stream of the file after its first chunk is emitted
+		fail    func(env *testEnv, stream ChunkStream, file *domain.File)
+		wantErr error
+	}{
+		{
+			name: "chunk lost by nodes",
+			fail: func(env *testEnv, _ ChunkStream, file *domain.File) {
+				env.gateway.Lock()
+				delete(env.gateway.chunks[file.ID], 2*downloadWindow)
+				env.gateway.Unlock()
+			},
+			wantErr: gateway.ErrNodeUnavailable,
+		},
+		{
+			name: "closed stream",
+			fail: func(_ *testEnv, stream ChunkStream, _ *domain.File) {
+				_ = stream.Close()
+			},
+			wantErr: errStreamClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{ChunkSize: domain.MinChunkSize})
+			ctx := principalContext("alice")
+			file := env.commit(ctx, t, &domain.File{Filename: "a.bin"}, testContent(3*downloadWindow*domain.MinChunkSize))
+
+			stream, err := env.storage.DownloadStream(ctx, file.ID, 0, 0)
+			if err != nil {
+				t.Fatalf("download stream: %v", err)
+			}
+			defer stream.Close()
+
+			if _, err := stream.Next(); err != nil {
+				t.Fatalf("first chunk: %v", err)
+			}
+			tt.fail(env, stream, file)
+
+			for {
+				_, err = stream.Next()
+				if err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("next chunk error = %v, want %v", err, tt.wantErr)
+			}
+			// the failed stream keeps failing with the same error
+			if _, again := stream.Next(); again != err {
+				t.Fatalf("next chunk after the failure = %v, want %v", again, err)
+			}
+		})
+	}
+}
@@ -2,82 +2,733 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/tel-io/tel/v2"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
+	"node-test/internal/common/chunker"
+	"node-test/internal/common/compression"
+	"node-test/internal/common/encryption"
 	"node-test/internal/domain"
 	"node-test/internal/gateway"
+	"node-test/internal/master/repository"
+	"node-test/pkg/tracing"
+)
+
+const (
+	maxMetadataEntries   = 32
+	maxMetadataKeySize   = 128
+	maxMetadataValueSize = 1024
+)
+
+var (
+	ErrFileNotFound     = domain.NewError(domain.ErrorCodeNotFound, "file not found")
+	ErrFileNotCommitted = domain.NewError(domain.ErrorCodeConflict, "file upload is not committed")
+	ErrIncompleteUpload = domain.NewError(domain.ErrorCodeConflict, "not all chunks of the file are uploaded")
+	ErrInvalidRange     = domain.NewError(domain.ErrorCodeInvalidRange, "invalid chunk range")
+	ErrChecksumMismatch = domain.NewError(domain.ErrorCodeChecksumMismatch, "checksum doesn't match the recorded one")
+	ErrAccessDenied     = domain.NewError(domain.ErrorCodeForbidden, "access denied")
+	ErrInvalidAccess    = domain.NewError(domain.ErrorCodeInvalidRequest, "invalid file access")
+	ErrInvalidMetadata  = domain.NewError(domain.ErrorCodeInvalidRequest, "invalid file metadata")
+	ErrKeyExists        = domain.NewError(domain.ErrorCodeConflict, "key already exists in the bucket")
+	ErrRetained         = domain.NewError(domain.ErrorCodeConflict, "file is retained")
+	ErrInvalidTTL       = domain.NewError(domain.ErrorCodeInvalidRequest, "invalid file ttl")
+	ErrInvalidCodec     = domain.NewError(domain.ErrorCodeInvalidRequest, "invalid compression codec")
+	ErrInvalidChunkSize = domain.NewError(domain.ErrorCodeInvalidChunk, "invalid chunk size")
 )
 
 type (
 	uploadService struct {
-		logger         *zap.SugaredLogger
-		storageGateway gateway.StorageNodeGateway
+		fileRemover
+		buckets     repository.BucketRepository
+		encryption  domain.EncryptionMode
+		keyring     *encryption.Keyring
+		dedup       bool
+		chunkSize   int64
+		chunking    *chunker.Params
+		compression string
+		flow        *flowControl
+		drain       *drain
+	}
+
+	// StorageServiceOptions describes how the storage service stores new files, zero values select defaults
+	StorageServiceOptions struct {
+		// EncryptionMode encrypts new files, the keyring is required in the master mode
+		// and to download files encrypted on the master
+		EncryptionMode domain.EncryptionMode
+		Keyring        *encryption.Keyring
+		// Dedup stores chunks with the same content once
+		Dedup bool
+		// ChunkSize is the size of fixed chunks of new files
+		ChunkSize int64
+		// Chunking splits new files into content defined chunks, nil params mean fixed chunks
+		Chunking *chunker.Params
+		// Compression is the codec of chunks unless their uploads or buckets select another one
+		Compression string
+		// UploadWindow is the number of chunks in flight of every stream
+		UploadWindow int
+		// UploadMemory is the budget of chunks in memory shared by all streams
+		UploadMemory int64
 	}
 
 	// UploadService represents an interface for uploader service
 	UploadService interface {
-		UploadChunkedAsync(ctx context.Context) chan *domain.Chunk
-		DownloadChunked(ctx context.Context, id string) ([]*domain.Chunk, error)
+		CreateSession(ctx context.Context, file *domain.File) (*domain.File, error)
+		File(ctx context.Context, id string) (*domain.File, error)
+		Resolve(ctx context.Context, bucket, key string) (*domain.File, error)
+		Versions(ctx context.Context, bucket, key string) ([]*domain.File, error)
+		DeleteKey(ctx context.Context, bucket, key string) (*domain.File, error)
+		Session(ctx context.Context, id string) (*domain.File, error)
+		SetAccess(ctx context.Context, id string, access domain.FileAccess, sharedWith []string) (*domain.File, error)
+		List(ctx context.Context, filter *domain.FileFilter) ([]*domain.File, error)
+		Delete(ctx context.Context, id string) error
+		UploadChunkedAsync(ctx context.Context, file *domain.File) (chan *domain.Chunk, <-chan error)
+		OpenStream(ctx context.Context) (context.Context, func(), error)
+		Shutdown(ctx context.Context) error
+		UploadContent(ctx context.Context, file *domain.File, content io.Reader) error
+		MissingChunks(ctx context.Context, id string) ([]domain.ChunkRange, error)
+		Commit(ctx context.Context, id, checksum string) (*domain.File, error)
+		Dedup(ctx context.Context, id string) (*domain.DedupStats, error)
+		DownloadStream(ctx context.Context, id string, first, last int64) (ChunkStream, error)
 	}
 )
 
+// NewStorageService creates the service which uploads new files and downloads stored ones.
+// New uploads are limited by quotas of their owners and get settings of their buckets,
+// the rest of the settings is described by the options.
 func NewStorageService(
 	logger *zap.SugaredLogger,
 	storageGateway gateway.StorageNodeGateway,
+	catalog repository.CatalogRepository,
+	buckets repository.BucketRepository,
+	quotas QuotaService,
+	opts StorageServiceOptions,
 ) UploadService {
+	chunkSize := opts.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = domain.DefaultChunkSize
+	}
+
 	return &uploadService{
-		logger:         logger,
-		storageGateway: storageGateway,
+		fileRemover: fileRemover{
+			logger:         logger,
+			storageGateway: storageGateway,
+			catalog:        catalog,
+			quotas:         quotas,
+		},
+		buckets:     buckets,
+		encryption:  opts.EncryptionMode,
+		keyring:     opts.Keyring,
+		dedup:       opts.Dedup,
+		chunkSize:   chunkSize,
+		chunking:    opts.Chunking,
+		compression: opts.Compression,
+		flow:        newFlowControl(opts.UploadWindow, opts.UploadMemory),
+		drain:       newDrain(),
 	}
 }
 
-// UploadChunkedAsync register jobs for worker pool to upload file chunk async
-func (s *uploadService) UploadChunkedAsync(ctx context.Context) chan *domain.Chunk {
+// CreateSession registers the pending file which chunks can be uploaded by several streams.
+// The file must describe the name, the size and optionally the checksum of the content.
+// The caller becomes the owner of the private file, the file must fit quotas of the caller and its tenant.
+// The file of the bucket is addressed by its name as the key and gets replication, retention and access of the bucket.
+// The file expires when its TTL or the expiration of the bucket, whichever is shorter, elapses.
+// Chunks are compressed by the codec of the upload, otherwise by the codec of the bucket or the default one.
+// The chunk size requested by the upload, otherwise the one of the bucket or the master, is limited
+// to sizes which the master accepts, the session reports the negotiated size.
+func (s *uploadService) CreateSession(ctx context.Context, file *domain.File) (*domain.File, error) {
+
+	owner := subject(ctx)
+	if owner == "" {
+		return nil, ErrAccessDenied
+	}
+	if s.drain.closing() {
+		return nil, ErrShuttingDown
+	}
+
+	if err := validateMetadata(file.Metadata); err != nil {
+		return nil, err
+	}
+	if file.TTL < 0 {
+		return nil, fmt.Errorf("%w: negative ttl", ErrInvalidTTL)
+	}
+	if !compression.Valid(file.Compression) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCodec, file.Compression)
+	}
+	if file.ChunkSize < 0 {
+		return nil, fmt.Errorf("%w: negative size", ErrInvalidChunkSize)
+	}
+
+	file.Owner = owner
+	file.Tenant = tenant(ctx)
+	file.Access = domain.FileAccessPrivate
 
-	var uploadChan = make(chan *domain.Chunk, 1)
+	if file.Bucket != "" {
+		if err := s.applyBucket(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.quotas.Check(ctx, file); err != nil {
+		return nil, err
+	}
+
+	if file.Compression == "" {
+		file.Compression = s.compression
+	}
+	if file.Compression == compression.None {
+		file.Compression = ""
+	}
+
+	if s.chunking != nil {
+		// the count of content defined chunks is known once the content is split
+		file.Chunking = domain.ChunkingCDC
+		file.ChunkSize = int64(s.chunking.Max)
+		file.TotalChunks = 0
+	} else {
+		if file.ChunkSize == 0 {
+			file.ChunkSize = s.chunkSize
+		}
+		file.Chunking = domain.ChunkingFixed
+		file.ChunkSize = min(max(file.ChunkSize, domain.MinChunkSize), domain.MaxChunkSize)
+		file.TotalChunks = file.TotalFileSize / file.ChunkSize
+		if file.TotalFileSize%file.ChunkSize > 0 {
+			file.TotalChunks++
+		}
+	}
+
+	file.ID = uuid.New().String()
+	file.Status = domain.FileStatusPending
+
+	switch s.encryption {
+	case domain.EncryptionMaster:
+		_, dataKey, err := s.keyring.NewDataKey()
+		if err != nil {
+			return nil, fmt.Errorf("create data key %w", err)
+		}
+		file.Encryption = domain.EncryptionMaster
+		file.DataKey = dataKey
+	case domain.EncryptionNode:
+		file.Encryption = domain.EncryptionNode
+	}
+	file.CreatedAt = time.Now().UTC()
+	if file.TTL > 0 {
+		file.ExpiresAt = file.CreatedAt.Add(file.TTL)
+	}
+
+	if err := s.catalog.AddFile(ctx, file); err != nil {
+		return nil, fmt.Errorf("register file %w", err)
+	}
+
+	return file, nil
+}
+
+// applyBucket copies settings of the bucket to the new file, the caller must be the member of the bucket
+func (s *uploadService) applyBucket(ctx context.Context, file *domain.File) error {
+
+	if !domain.ValidKey(file.Filename) {
+		return fmt.Errorf("%w: key %q", ErrInvalidBucket, file.Filename)
+	}
+
+	bucket, err := s.memberBucket(ctx, file.Bucket)
+	if err != nil {
+		return err
+	}
+
+	// the key is checked again on commit, it fails early here when the key is already taken
+	if !bucket.Versioning {
+		latest, err := s.catalog.FileByKey(ctx, file.Bucket, file.Filename)
+		if err == nil && !latest.DeleteMarker {
+			return fmt.Errorf("%w: %v/%v", ErrKeyExists, file.Bucket, file.Filename)
+		}
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("get file by key %w", err)
+		}
+	}
+
+	file.Replicas = bucket.Replicas
+	file.Retention = bucket.Retention
+	if bucket.ExpireAfter > 0 && (file.TTL == 0 || file.TTL > bucket.ExpireAfter) {
+		file.TTL = bucket.ExpireAfter
+	}
+	if file.ChunkSize == 0 {
+		file.ChunkSize = bucket.ChunkSize
+	}
+	if file.Compression == "" {
+		file.Compression = bucket.Compression
+	}
+	file.Access = bucket.DefaultAccess
+	file.SharedWith = sharedSubjects(bucket.DefaultSharedWith, file.Owner)
+
+	return nil
+}
+
+// validateMetadata limits the opaque metadata of the file, it is stored with every catalog record
+func validateMetadata(metadata map[string]string) error {
+
+	if len(metadata) > maxMetadataEntries {
+		return fmt.Errorf("%w: more than %v entries", ErrInvalidMetadata, maxMetadataEntries)
+	}
+
+	for key, value := range metadata {
+		if key == "" || len(key) > maxMetadataKeySize {
+			return fmt.Errorf("%w: key %q must have 1-%v bytes", ErrInvalidMetadata, key, maxMetadataKeySize)
+		}
+		if len(value) > maxMetadataValueSize {
+			return fmt.Errorf("%w: value of %q exceeds %v bytes", ErrInvalidMetadata, key, maxMetadataValueSize)
+		}
+	}
+
+	return nil
+}
+
+// File retrieves the file from the catalog.
+// Files which the caller isn't allowed to read are reported as not found to not disclose their existence.
+func (s *uploadService) File(ctx context.Context, id string) (*domain.File, error) {
+
+	file, err := s.catalog.File(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("get file %w", err)
+	}
+
+	if !file.CanRead(subject(ctx)) {
+		return nil, ErrFileNotFound
+	}
+
+	return file, nil
+}
+
+// Resolve retrieves the latest version of the key inside the bucket, deleted keys aren't found.
+func (s *uploadService) Resolve(ctx context.Context, bucket, key string) (*domain.File, error) {
+
+	file, err := s.catalog.FileByKey(ctx, bucket, key)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("get file by key %w", err)
+	}
+
+	if file.DeleteMarker || !file.CanRead(subject(ctx)) {
+		return nil, ErrFileNotFound
+	}
+
+	return file, nil
+}
+
+// Session retrieves the file which the caller is allowed to change
+func (s *uploadService) Session(ctx context.Context, id string) (*domain.File, error) {
+
+	file, err := s.File(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !file.CanWrite(subject(ctx)) {
+		return nil, ErrAccessDenied
+	}
+
+	return file, nil
+}
+
+// SetAccess changes who can read the file, only the owner can do it.
+// The list of principals is kept only for the shared access.
+func (s *uploadService) SetAccess(
+	ctx context.Context,
+	id string,
+	access domain.FileAccess,
+	sharedWith []string,
+) (*domain.File, error) {
+
+	if !access.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAccess, access)
+	}
+
+	file, err := s.Session(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []string
+	if access == domain.FileAccessShared {
+		subjects = sharedSubjects(sharedWith, file.Owner)
+	}
+
+	if err := s.catalog.SetAccess(ctx, file.ID, access, subjects); err != nil {
+		return nil, fmt.Errorf("set file access %w", err)
+	}
+
+	file.Access = access
+	file.SharedWith = subjects
+
+	return file, nil
+}
+
+// sharedSubjects drops empty and repeated subjects and the owner from the list
+func sharedSubjects(sharedWith []string, owner string) []string {
+
+	var subjects []string
+	seen := make(map[string]struct{}, len(sharedWith))
+	for _, shared := range sharedWith {
+		if _, ok := seen[shared]; ok || shared == "" || shared == owner {
+			continue
+		}
+		seen[shared] = struct{}{}
+		subjects = append(subjects, shared)
+	}
+
+	return subjects
+}
+
+// List returns committed files matched by the filter which the caller is allowed to read
+func (s *uploadService) List(ctx context.Context, filter *domain.FileFilter) ([]*domain.File, error) {
+
+	filter.Reader = subject(ctx)
+	if filter.Reader == "" {
+		return nil, ErrAccessDenied
+	}
+
+	filter.Status = domain.FileStatusCommitted
+
+	files, err := s.catalog.Files(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list files %w", err)
+	}
+
+	return files, nil
+}
+
+// Delete removes chunks of the file from storage nodes and the file from the catalog, only the owner can do it.
+// Files can't be deleted until their retention elapses. When the latest version of the key is deleted,
+// the previous version becomes the latest one.
+func (s *uploadService) Delete(ctx context.Context, id string) error {
+
+	file, err := s.Session(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if file.Retained(time.Now()) {
+		return fmt.Errorf("%w: until %v", ErrRetained, file.CommittedAt.Add(file.Retention).Format(time.RFC3339))
+	}
+
+	return s.remove(ctx, file)
+}
+
+// UploadChunkedAsync register jobs for worker pool to upload file chunk async.
+// The returned error channel receives the result once the chunk channel is closed
+// and all submitted chunks are stored. Chunks are encrypted according to the encryption of the file.
+// With deduplication the content which is already stored isn't sent to nodes again,
+// except for files encrypted on the master which content differs for every file.
+// Chunks sent to nodes are bounded by the window of the stream and the memory budget of all streams,
+// the chunk channel isn't drained while they are full.
+func (s *uploadService) UploadChunkedAsync(ctx context.Context, file *domain.File) (chan *domain.Chunk, <-chan error) {
+
+	var (
+		uploadChan = make(chan *domain.Chunk, 1)
+		resultChan = make(chan error, 1)
+		window     = s.flow.newWindow()
+		wg         sync.WaitGroup
+		failOnce   sync.Once
+		failure    error
+	)
+
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failure = err
+		})
+	}
+
+	chunkCipher, cipherErr := s.chunkCipher(file)
+	if cipherErr != nil {
+		fail(cipherErr)
+	}
 
 	go func() {
 	upload:
 		for {
 			select {
 			case <-ctx.Done():
+				fail(context.Cause(ctx))
 				break upload
 			case chunk := <-uploadChan:
 				if chunk == nil {
 					break upload
 				}
-				s.storageGateway.SendAsync(chunk)
+				if cipherErr != nil {
+					// chunks are drained, they can't be stored without the encryption
+					continue
+				}
+
+				location := &domain.ChunkLocation{
+					UploadID:    chunk.UploadID,
+					ChunkNumber: chunk.ChunkNumber,
+					Size:        int64(len(chunk.Data)),
+				}
+
+				// chunks which don't shrink are stored as is, the codec of every chunk is recorded
+				data, codec, err := compression.Compress(file.Compression, chunk.Data)
+				if err != nil {
+					fail(fmt.Errorf("compress chunk %v %w", chunk.ChunkNumber, err))
+					continue
+				}
+				chunk.Data, location.Codec = data, codec
+
+				if chunkCipher != nil {
+					data, err := chunkCipher.Seal(chunk.UploadID, chunk.ChunkNumber, chunk.Data)
+					if err != nil {
+						fail(fmt.Errorf("encrypt chunk %v %w", chunk.ChunkNumber, err))
+						continue
+					}
+					chunk.Data = data
+				}
+				chunk.NodeEncrypted = file.Encryption == domain.EncryptionNode
+				chunk.Replicas = file.Replicas
+				location.StoredSize = int64(len(chunk.Data))
+
+				var blob *domain.Blob
+				if s.dedup && file.Encryption != domain.EncryptionMaster {
+					shared, fresh, err := s.acquireBlob(ctx, chunk, location)
+					if err != nil {
+						fail(fmt.Errorf("look up chunk %v content %w", chunk.ChunkNumber, err))
+						continue
+					}
+					if shared != nil {
+						location.Blob, location.Node, location.Replicas = shared.ID, shared.Node, shared.Replicas
+						location.StoredSize, location.Deduplicated = shared.StoredSize, true
+						if err := s.addChunk(ctx, location); err != nil {
+							fail(fmt.Errorf("register chunk %v %w", location.ChunkNumber, err))
+							continue
+						}
+						transferred(directionUpload, location.Size)
+						continue
+					}
+
+					// nodes keep the new content under the blob id, so it outlives the upload while it is referenced
+					blob = fresh
+					chunk.UploadID, chunk.ChunkNumber = blob.ID, domain.BlobChunkNumber
+				}
+
+				// the span of the chunk covers the wait for the flow window, queues of nodes and their requests
+				chunkCtx, span := tracing.Start(ctx, "upload.chunk",
+					attribute.String("upload_id", location.UploadID),
+					attribute.Int64("chunk_number", location.ChunkNumber),
+					attribute.Int64("size", location.StoredSize),
+				)
+
+				// the stream pauses while its window or the memory budget of all streams is full
+				weight, err := s.flow.acquire(chunkCtx, window, int64(len(chunk.Data)))
+				if err != nil {
+					tracing.End(span, err)
+					fail(err)
+					continue
+				}
+
+				wg.Add(1)
+				s.storageGateway.SendAsync(chunkCtx, chunk, func(nodes []string, err error) {
+					defer wg.Done()
+					s.flow.release(window, weight)
+					err = s.storedChunk(ctx, location, blob, nodes, err)
+					tracing.End(span, err)
+					if err != nil {
+						fail(err)
+						return
+					}
+					transferred(directionUpload, location.Size)
+				})
 			}
 		}
+
+		wg.Wait()
+		if errors.Is(failure, ErrShuttingDown) {
+			s.logger.Info("upload interrupted by the shutdown, it can be resumed", tel.String("upload_id", file.ID))
+		}
+		resultChan <- failure
 	}()
 
-	return uploadChan
+	return uploadChan, resultChan
 }
 
-func (s *uploadService) DownloadChunked(ctx context.Context, id string) ([]*domain.Chunk, error) {
+// storedChunk records the location of the chunk which copies nodes have stored
+func (s *uploadService) storedChunk(ctx context.Context, location *domain.ChunkLocation, blob *domain.Blob, nodes []string, err error) error {
 
-	var list []*domain.Chunk
+	if err != nil {
+		return fmt.Errorf("store chunk %v %w", location.ChunkNumber, err)
+	}
 
-	downloadChann := s.storageGateway.DownloadAsync(id)
+	location.Node, location.Replicas = nodes[0], nodes[1:]
+	if blob != nil {
+		if err := s.registerBlob(ctx, blob, location); err != nil {
+			return fmt.Errorf("register chunk %v content %w", location.ChunkNumber, err)
+		}
+	}
+	if err := s.addChunk(ctx, location); err != nil {
+		return fmt.Errorf("register chunk %v %w", location.ChunkNumber, err)
+	}
 
-download:
-	for {
-		select {
-		case <-ctx.Done():
-			break download
-		case msg := <-downloadChann:
-			if msg == nil {
-				break download
-			}
+	return nil
+}
 
-			if list == nil {
-				list = make([]*domain.Chunk, 0, msg.TotalChunks)
-			}
-			list[msg.ChunkNumber-1] = msg
+// chunkCipher unwraps the data key of the file encrypted on the master, nil means the master doesn't encrypt it
+func (s *uploadService) chunkCipher(file *domain.File) (*encryption.ChunkCipher, error) {
+
+	if file.Encryption != domain.EncryptionMaster {
+		return nil, nil
+	}
+
+	if s.keyring == nil {
+		return nil, errors.New("keyring isn't configured")
+	}
+
+	dataKey, err := s.keyring.Unwrap(file.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key %w", err)
+	}
+
+	return encryption.NewChunkCipher(dataKey)
+}
+
+// MissingChunks returns ranges of chunks which aren't stored yet, so the interrupted upload can be resumed
+func (s *uploadService) MissingChunks(ctx context.Context, id string) ([]domain.ChunkRange, error) {
+
+	file, err := s.Session(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	locations, err := s.catalog.Chunks(ctx, file.ID, 1, file.TotalChunks)
+	if err != nil {
+		return nil, fmt.Errorf("get chunk locations %w", err)
+	}
+
+	stored := make([]int64, 0, len(locations))
+	for _, location := range locations {
+		stored = append(stored, location.ChunkNumber)
+	}
+
+	return file.MissingChunks(stored), nil
+}
+
+// Commit marks the file as committed when all its chunks are stored.
+// The checksum is recorded when the session was created without it.
+// The file of the bucket isn't committed when another file with the same key is committed first,
+// unless the bucket keeps versions, then the file becomes the latest version of the key.
+func (s *uploadService) Commit(ctx context.Context, id, checksum string) (*domain.File, error) {
+
+	file, err := s.Session(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if checksum != "" && file.Checksum != "" && checksum != file.Checksum {
+		return nil, ErrChecksumMismatch
+	}
+
+	if file.Status == domain.FileStatusCommitted {
+		return file, nil
+	}
+
+	if file.ContentDefined() && file.TotalChunks == 0 && file.TotalFileSize > 0 {
+		return nil, fmt.Errorf("%w: the content isn't split yet", ErrIncompleteUpload)
+	}
+
+	stored, err := s.catalog.CountChunks(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("count stored chunks %w", err)
+	}
+
+	if stored != file.TotalChunks {
+		return nil, fmt.Errorf("%w: expected %v actual %v", ErrIncompleteUpload, file.TotalChunks, stored)
+	}
+
+	// the usage is reserved first, so concurrent commits can't exceed the quota together
+	if err := s.quotas.Reserve(ctx, file); err != nil {
+		return nil, err
+	}
+
+	var (
+		now    = time.Now().UTC()
+		bucket *domain.Bucket
+	)
+	if file.Bucket != "" {
+		bucket, err = s.commitVersion(ctx, file, checksum, now)
+	} else {
+		err = s.catalog.CommitFile(ctx, id, checksum, now)
+		file.Latest = err == nil
+	}
+	if err != nil {
+		// the usage isn't kept when the file isn't committed, the counter is off by the file when the release fails
+		if err := s.quotas.Track(ctx, file, -1); err != nil {
+			s.logger.Error("release quota usage", tel.String("upload_id", file.ID), tel.Error(err))
+		}
+
+		// the concurrent commit has won, the file is already counted
+		if errors.Is(err, repository.ErrNotFound) {
+			return s.Session(ctx, id)
+		}
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("%w: %v/%v", ErrKeyExists, file.Bucket, file.Filename)
 		}
+		return nil, fmt.Errorf("commit file %w", err)
 	}
 
-	return list, nil
+	file.Status = domain.FileStatusCommitted
+	file.CommittedAt = now
+	if checksum != "" {
+		file.Checksum = checksum
+	}
+
+	if bucket != nil {
+		s.pruneVersions(ctx, bucket, file.Filename)
+	}
+
+	return file, nil
+}
+
+// DownloadStream opens the stream of chunks from first to last inclusive.
+// Zero values of first and last mean the first and the last chunk of the file.
+// The stream must be closed to release chunks requested ahead.
+func (s *uploadService) DownloadStream(ctx context.Context, id string, first, last int64) (ChunkStream, error) {
+
+	file, err := s.File(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Status != domain.FileStatusCommitted {
+		return nil, ErrFileNotCommitted
+	}
+	if file.DeleteMarker {
+		return nil, ErrFileNotFound
+	}
+
+	if first == 0 {
+		first = 1
+	}
+	if last == 0 {
+		last = file.TotalChunks
+	}
+	if first < 1 || last > file.TotalChunks || first > last {
+		if file.TotalChunks != 0 {
+			return nil, fmt.Errorf("%w: %v-%v of %v", ErrInvalidRange, first, last, file.TotalChunks)
+		}
+		first, last = 1, 0
+	}
+
+	chunkCipher, err := s.chunkCipher(file)
+	if err != nil {
+		return nil, err
+	}
 
+	return newChunkStream(ctx, s, file, chunkCipher, first, last), nil
 }
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tel-io/tel/v2"
+
+	"node-test/internal/domain"
+	"node-test/internal/master/repository"
+)
+
+// maxVersionAttempts limits attempts to make the version the latest one when concurrent versions of the key are committed
+const maxVersionAttempts = 3
+
+// Versions returns versions of the key inside the bucket which the caller can read, the latest version goes first.
+// Delete markers are included, so the caller can see when the key was deleted.
+func (s *uploadService) Versions(ctx context.Context, bucket, key string) ([]*domain.File, error) {
+
+	versions, err := s.catalog.Versions(ctx, bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("get versions %w", err)
+	}
+
+	readable := make([]*domain.File, 0, len(versions))
+	for _, version := range versions {
+		if version.CanRead(subject(ctx)) {
+			readable = append(readable, version)
+		}
+	}
+
+	if len(readable) == 0 {
+		return nil, ErrFileNotFound
+	}
+
+	return readable, nil
+}
+
+// DeleteKey deletes the key inside the bucket, members of the bucket can do it.
+// The bucket which keeps versions gets the delete marker as the latest version of the key and keeps previous ones,
+// otherwise the file is removed when the caller is its owner.
+func (s *uploadService) DeleteKey(ctx context.Context, bucketName, key string) (*domain.File, error) {
+
+	bucket, err := s.memberBucket(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := s.Resolve(ctx, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bucket.Versioning {
+		return nil, s.Delete(ctx, latest.ID)
+	}
+
+	now := time.Now().UTC()
+	marker := &domain.File{
+		ID:           uuid.New().String(),
+		Bucket:       bucketName,
+		Filename:     key,
+		Status:       domain.FileStatusCommitted,
+		Latest:       true,
+		DeleteMarker: true,
+		Owner:        subject(ctx),
+		Tenant:       tenant(ctx),
+		Access:       latest.Access,
+		SharedWith:   latest.SharedWith,
+		CreatedAt:    now,
+		CommittedAt:  now,
+	}
+
+	if err := s.addDeleteMarker(ctx, marker); err != nil {
+		return nil, err
+	}
+
+	s.pruneVersions(ctx, bucket, key)
+
+	return marker, nil
+}
+
+// commitVersion commits the file of the bucket as the latest version of its key.
+// The latest version is kept in the bucket without versioning, unless it is the delete marker.
+// The version which loses the race to concurrent versions stays committed as the noncurrent one.
+func (s *uploadService) commitVersion(ctx context.Context, file *domain.File, checksum string, at time.Time) (*domain.Bucket, error) {
+
+	bucket, err := s.buckets.Bucket(ctx, file.Bucket)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrBucketNotFound
+		}
+		return nil, fmt.Errorf("get bucket %w", err)
+	}
+
+	supersede := bucket.Versioning
+	if !supersede {
+		latest, err := s.catalog.FileByKey(ctx, file.Bucket, file.Filename)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("get file by key %w", err)
+		}
+		supersede = err == nil && latest.DeleteMarker
+	}
+
+	if !supersede {
+		if err := s.catalog.CommitFile(ctx, file.ID, checksum, at); err != nil {
+			return nil, err
+		}
+		file.Latest = true
+		return bucket, nil
+	}
+
+	if err := s.catalog.CommitVersion(ctx, file.ID, checksum, at); err != nil {
+		return nil, err
+	}
+	file.SupersededAt = at
+
+	if err := s.promote(ctx, file, at); err != nil {
+		s.logger.Error("promote committed version", tel.String("upload_id", file.ID), tel.Error(err))
+	}
+
+	return bucket, nil
+}
+
+// pruneVersions removes noncurrent versions of the key which exceed the limit of the bucket, the oldest go first.
+// Retained versions are kept, failures are logged since the new version is already committed.
+func (s *uploadService) pruneVersions(ctx context.Context, bucket *domain.Bucket, key string) {
+
+	if !bucket.Versioning || bucket.MaxVersions == 0 {
+		return
+	}
+
+	versions, err := s.catalog.Versions(ctx, bucket.Name, key)
+	if err != nil {
+		s.logger.Error("get versions to prune", tel.String("bucket", bucket.Name), tel.Error(err))
+		return
+	}
+
+	var (
+		now        = time.Now()
+		noncurrent int
+	)
+	for _, version := range versions {
+		if version.Latest {
+			continue
+		}
+		if noncurrent++; noncurrent <= bucket.MaxVersions || version.Retained(now) {
+			continue
+		}
+
+		if err := s.purge(ctx, version); err != nil {
+			s.logger.Error("prune version", tel.String("upload_id", version.ID), tel.Error(err))
+		}
+	}
+}
+
+// memberBucket returns the bucket which the caller is the member of
+func (s *uploadService) memberBucket(ctx context.Context, name string) (*domain.Bucket, error) {
+
+	bucket, err := s.buckets.Bucket(ctx, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrBucketNotFound
+		}
+		return nil, fmt.Errorf("get bucket %w", err)
+	}
+
+	if !bucket.IsMember(PrincipalFromContext(ctx)) {
+		return nil, ErrBucketNotFound
+	}
+
+	return bucket, nil
+}
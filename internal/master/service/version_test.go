@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"node-test/internal/domain"
+	"node-test/internal/master/repository"
+)
+
+func TestCommitVersion(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		versioning bool
+		// deleted adds the delete marker after the first version
+		deleted        bool
+		wantErr        error
+		wantLatest     int
+		wantVersions   int
+		wantSuperseded bool
+	}{
+		{name: "versioning", versioning: true, wantLatest: 1, wantVersions: 2, wantSuperseded: true},
+		{name: "without versioning", wantErr: ErrKeyExists, wantLatest: 0, wantVersions: 1},
+		{name: "over the delete marker", versioning: true, deleted: true, wantLatest: 1, wantVersions: 3, wantSuperseded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			env.addBucket(t, &domain.Bucket{Name: "docs", Versioning: tt.versioning})
+			ctx := principalContext("alice")
+
+			// both sessions are open before the key has the version, as concurrent uploads do
+			first := env.upload(ctx, t, &domain.File{Bucket: "docs", Filename: "a.txt"}, []byte("first"))
+			second := env.upload(ctx, t, &domain.File{Bucket: "docs", Filename: "a.txt"}, []byte("second"))
+			if _, err := env.storage.Commit(ctx, first.ID, ""); err != nil {
+				t.Fatalf("commit first version: %v", err)
+			}
+			if tt.deleted {
+				if _, err := env.storage.DeleteKey(ctx, "docs", "a.txt"); err != nil {
+					t.Fatalf("delete key: %v", err)
+				}
+			}
+
+			committed, err := env.storage.Commit(ctx, second.ID, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("commit error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !committed.Latest {
+				t.Fatalf("committed version isn't the latest one")
+			}
+
+			versions := []string{first.ID, second.ID}
+			latest, noncurrent := env.catalog.versionIDs(t, "docs", "a.txt")
+			if latest != versions[tt.wantLatest] {
+				t.Fatalf("latest version = %v, want %v", latest, versions[tt.wantLatest])
+			}
+			if got := len(noncurrent) + 1; got != tt.wantVersions {
+				t.Fatalf("%v versions, want %v", got, tt.wantVersions)
+			}
+			if tt.wantSuperseded && !slices.Contains(noncurrent, first.ID) {
+				t.Fatalf("first version %v isn't noncurrent: %v", first.ID, noncurrent)
+			}
+		})
+	}
+}
+
+func TestCommitVersionKeepsLatest(t *testing.T) {
+
+	tests := []struct {
+		name string
+		// commit commits the pending or committed version of the key
+		commit  func(env *testEnv, latest, pending *domain.File) error
+		wantErr error
+	}{
+		{
+			name: "failed commit",
+			commit: func(env *testEnv, _, pending *domain.File) error {
+				env.catalog.failCommit = true
+				_, err := env.storage.commitVersion(principalContext("alice"), pending, "", time.Now().UTC())
+				return err
+			},
+			wantErr: errTestFailure,
+		},
+		{
+			name: "repeated commit of the latest version",
+			commit: func(env *testEnv, latest, _ *domain.File) error {
+				_, err := env.storage.commitVersion(principalContext("alice"), latest, "", time.Now().UTC())
+				return err
+			},
+			wantErr: repository.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			env.addBucket(t, &domain.Bucket{Name: "docs", Versioning: true})
+			ctx := principalContext("alice")
+
+			latest := env.commit(ctx, t, &domain.File{Bucket: "docs", Filename: "a.txt"}, []byte("latest"))
+			pending := env.upload(ctx, t, &domain.File{Bucket: "docs", Filename: "a.txt"}, []byte("pending"))
+
+			if err := tt.commit(env, latest, pending); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("commit error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got, _ := env.catalog.versionIDs(t, "docs", "a.txt"); got != latest.ID {
+				t.Fatalf("latest version = %q, want %v", got, latest.ID)
+			}
+		})
+	}
+}
+
+func TestDeleteKey(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		versioning bool
+		wantMarker bool
+	}{
+		{name: "versioning", versioning: true, wantMarker: true},
+		{name: "without versioning"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, StorageServiceOptions{})
+			env.addBucket(t, &domain.Bucket{Name: "docs", Versioning: tt.versioning})
+			ctx := principalContext("alice")
+
+			file := env.commit(ctx, t, &domain.File{Bucket: "docs", Filename: "a.txt"}, []byte("content"))
+
+			marker, err := env.storage.DeleteKey(ctx, "docs", "a.txt")
+			if err != nil {
+				t.Fatalf("delete key: %v", err)
+			}
+			if _, err := env.storage.Resolve(ctx, "docs", "a.txt"); !errors.Is(err, ErrFileNotFound) {
+				t.Fatalf("resolve deleted key = %v, want %v", err, ErrFileNotFound)
+			}
+
+			latest, noncurrent := env.catalog.versionIDs(t, "docs", "a.txt")
+			if !tt.wantMarker {
+				if marker != nil || latest != "" || len(noncurrent) > 0 {
+					t.Fatalf("key keeps versions %q %v after the delete", latest, noncurrent)
+				}
+				if env.gateway.stored(file.ID) {
+					t.Fatalf("chunks of the deleted file are kept on nodes")
+				}
+				return
+			}
+
+			if marker == nil || !marker.DeleteMarker || !marker.Latest {
+				t.Fatalf("delete marker = %+v, want the latest marker", marker)
+			}
+			if latest != marker.ID {
+				t.Fatalf("latest version = %v, want the marker %v", latest, marker.ID)
+			}
+			if !slices.Equal(noncurrent, []string{file.ID}) {
+				t.Fatalf("noncurrent versions = %v, want %v", noncurrent, []string{file.ID})
+			}
+			if !env.gateway.stored(file.ID) {
+				t.Fatalf("chunks of the noncurrent version are removed")
+			}
+		})
+	}
+}
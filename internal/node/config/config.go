@@ -4,12 +4,22 @@ import (
 	"context"
 
 	configLib "node-test/pkg/config"
+	"node-test/pkg/http"
 	"node-test/pkg/mongodb"
+	"node-test/pkg/tracing"
 )
 
 type Config struct {
 	Server ServerConfig `validate:"required"`
 	Mongo  MongoConfig  `validate:"required"`
+	// Encryption is required to store chunks which the master asks to encrypt
+	Encryption EncryptionConfig
+	Tracing    TracingConfig
+}
+
+// EncryptionConfig describes the key file which keeps keys wrapping data keys of uploads.
+type EncryptionConfig struct {
+	KeyFile string
 }
 
 type MongoConfig struct {
@@ -32,6 +42,41 @@ type ServerConfig struct {
 	Port int `validate:"required,min=80"`
 	// free size for node in bytes
 	Size int64 `validate:"required"`
+	// the node isn't ready to receive chunks when it has MinFree bytes or less
+	MinFree int64 `validate:"min=0"`
+	// chunks are written and read only by clients with the certificate signed by TLS.CAFile when it is set
+	TLS TLSConfig
+}
+
+// TLSConfig describes the certificate of the service and the CA of its peers, empty CertFile disables TLS.
+type TLSConfig struct {
+	CertFile string `validate:"required_with=KeyFile"`
+	KeyFile  string `validate:"required_with=CertFile"`
+	CAFile   string
+}
+
+func (cfg TLSConfig) External() http.TLSConfig {
+	return http.TLSConfig{
+		CertFile: cfg.CertFile,
+		KeyFile:  cfg.KeyFile,
+		CAFile:   cfg.CAFile,
+	}
+}
+
+// TracingConfig describes the OTLP gRPC collector which receives spans, empty Endpoint disables the export.
+// SampleRatio is the share of traced requests without the sampled caller, 1 when zero.
+type TracingConfig struct {
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64 `validate:"min=0,max=1"`
+}
+
+func (cfg TracingConfig) External() tracing.Config {
+	return tracing.Config{
+		Endpoint:    cfg.Endpoint,
+		Insecure:    cfg.Insecure,
+		SampleRatio: cfg.SampleRatio,
+	}
 }
 
 func GetConfig(ctx context.Context) (*Config, error) {
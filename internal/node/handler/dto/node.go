@@ -6,4 +6,8 @@ type (
 		NodeAvailable int64 `json:"node_available"`
 		NodeUsed      int64 `json:"node_used"`
 	}
+
+	DeleteResponse struct {
+		Deleted int64 `json:"deleted"`
+	}
 )
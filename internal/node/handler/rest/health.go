@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	http2 "node-test/internal/common/http"
+	"node-test/internal/domain"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+)
+
+// Live reports that the node serves requests, it doesn't depend on mongo.
+func (h *nodeHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, &http2.HealthResponse{Status: healthStatusOK})
+}
+
+// Ready reports whether the node can store chunks, the failed check is answered with 503.
+func (h *nodeHandler) Ready(c echo.Context) error {
+
+	health := h.nodeService.Ready(c.Request().Context())
+	if !health.Ready() {
+		return c.JSON(http.StatusServiceUnavailable, healthResponse(healthStatusUnavailable, health))
+	}
+
+	return c.JSON(http.StatusOK, healthResponse(healthStatusOK, health))
+}
+
+func healthResponse(status string, health domain.Health) *http2.HealthResponse {
+
+	checks := make([]http2.HealthCheckInfo, 0, len(health))
+	for _, check := range health {
+		checks = append(checks, http2.HealthCheckInfo{Name: check.Name, Error: check.Error})
+	}
+
+	return &http2.HealthResponse{Status: status, Checks: checks}
+}
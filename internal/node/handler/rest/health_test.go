@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	http2 "node-test/internal/common/http"
+	"node-test/internal/domain"
+	"node-test/internal/node/service"
+)
+
+// staticNode is the node service which reports the same health
+type staticNode struct {
+	service.NodeService
+	health domain.Health
+}
+
+func (s *staticNode) Ready(context.Context) domain.Health {
+	return s.health
+}
+
+func TestNodeHealth(t *testing.T) {
+
+	full := domain.Health{}.Check("mongo", nil).Check("free_space", errors.New("0 bytes are free"))
+
+	tests := []struct {
+		name       string
+		path       string
+		health     domain.Health
+		wantStatus int
+		wantBody   http2.HealthResponse
+	}{
+		{
+			name:       "live",
+			path:       "/healthz",
+			health:     full,
+			wantStatus: http.StatusOK,
+			wantBody:   http2.HealthResponse{Status: healthStatusOK},
+		},
+		{
+			name:       "ready",
+			path:       "/readyz",
+			health:     domain.Health{}.Check("mongo", nil).Check("free_space", nil),
+			wantStatus: http.StatusOK,
+			wantBody: http2.HealthResponse{
+				Status: healthStatusOK,
+				Checks: []http2.HealthCheckInfo{{Name: "mongo"}, {Name: "free_space"}},
+			},
+		},
+		{
+			name:       "not ready",
+			path:       "/readyz",
+			health:     full,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody: http2.HealthResponse{
+				Status: healthStatusUnavailable,
+				Checks: []http2.HealthCheckInfo{{Name: "mongo"}, {Name: "free_space", Error: "0 bytes are free"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newNodeHandler(&staticNode{health: tt.health})
+			e := echo.New()
+			e.GET("/healthz", h.Live)
+			e.GET("/readyz", h.Ready)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			var body http2.HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+			}
+			if body.Status != tt.wantBody.Status || !slices.Equal(body.Checks, tt.wantBody.Checks) {
+				t.Fatalf("body = %+v, want %+v", body, tt.wantBody)
+			}
+		})
+	}
+}
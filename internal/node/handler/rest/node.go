@@ -10,6 +10,7 @@ import (
 	"node-test/internal/common/errors"
 	http2 "node-test/internal/common/http"
 	"node-test/internal/domain"
+	"node-test/internal/node/handler/dto"
 	"node-test/internal/node/service"
 )
 
@@ -33,34 +34,66 @@ func (h *nodeHandler) State(c echo.Context) error {
 
 	nodeState, err := h.nodeService.State(context.Background())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.NewInternalError(err))
+		return errors.Respond(c, err)
 	}
 
 	c.Response().Header().Set(stateResponseHeaderName, fmt.Sprintf("%v", nodeState.Free))
-	return c.NoContent(http.StatusOK)
+	return c.JSON(http.StatusOK, &dto.StateResponse{
+		NodeSize:      nodeState.Size,
+		NodeAvailable: nodeState.Free,
+		NodeUsed:      nodeState.Used,
+	})
 }
 
 func (h *nodeHandler) Upload(c echo.Context) error {
 
 	var request http2.Chunk
 	if err := c.Bind(&request); err != nil {
-		fmt.Println(err)
-		return c.JSON(http.StatusBadRequest, errors.NewInternalError(err))
+		return errors.RespondInvalidRequest(c, err)
 	}
 
-	fmt.Println("received request", request.UploadID, request.ChunkNumber)
-
-	if err := h.nodeService.Upload(&domain.Chunk{
+	if err := h.nodeService.Upload(c.Request().Context(), &domain.Chunk{
 		UploadID:      request.UploadID,
 		ChunkNumber:   request.ChunkNumber,
 		TotalChunks:   request.TotalChunks,
 		TotalFileSize: request.TotalFileSize,
 		Filename:      request.Filename,
 		Data:          request.Data,
+		NodeEncrypted: request.Encrypt,
 	}); err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.NewInternalError(err))
+		return errors.Respond(c, err)
 	}
 
 	return c.NoContent(http.StatusOK)
 
 }
+
+func (h *nodeHandler) Download(c echo.Context) error {
+
+	var request http2.ChunkRequest
+	if err := c.Bind(&request); err != nil {
+		return errors.RespondInvalidRequest(c, err)
+	}
+
+	chunk, err := h.nodeService.Download(c.Request().Context(), request.UploadID, request.ChunkNumber)
+	if err != nil {
+		return errors.Respond(c, err)
+	}
+
+	return c.Blob(http.StatusOK, echo.MIMEOctetStream, chunk.Data)
+}
+
+func (h *nodeHandler) Delete(c echo.Context) error {
+
+	var request http2.DeleteRequest
+	if err := c.Bind(&request); err != nil {
+		return errors.RespondInvalidRequest(c, err)
+	}
+
+	deleted, err := h.nodeService.Delete(c.Request().Context(), request.UploadID)
+	if err != nil {
+		return errors.Respond(c, err)
+	}
+
+	return c.JSON(http.StatusOK, &dto.DeleteResponse{Deleted: deleted})
+}
@@ -3,15 +3,20 @@ package rest
 import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"node-test/internal/common/errors"
 	"node-test/internal/node/service"
+	"node-test/pkg/tracing"
 )
 
 type (
 	RouterDependencies struct {
 		Logger      zap.Logger
 		NodeService service.NodeService
+		// RequireClientCert allows chunk writes and reads only with the verified client certificate
+		RequireClientCert bool
 	}
 )
 
@@ -32,6 +37,7 @@ func MakeRoutes(
 ) *echo.Echo {
 
 	e := echo.New()
+	e.HTTPErrorHandler = errors.HTTPErrorHandler
 
 	e.Use(middleware.Secure())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
@@ -44,9 +50,23 @@ func MakeRoutes(
 	nodeH := newNodeHandler(dependencies.NodeService)
 
 	router := e.Group("/api/v1")
+	router.Use(tracing.Middleware())
 
 	router.GET("/state", nodeH.State)
-	router.POST("/upload", nodeH.Upload)
+	// the master checks readiness by addresses of nodes, which include the api prefix
+	router.GET("/readyz", nodeH.Ready)
+
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	e.GET("/healthz", nodeH.Live)
+	e.GET("/readyz", nodeH.Ready)
+
+	chunks := router.Group("")
+	if dependencies.RequireClientCert {
+		chunks.Use(requireClientCert)
+	}
+	chunks.POST("/upload", nodeH.Upload)
+	chunks.GET("/download", nodeH.Download)
+	chunks.DELETE("/upload", nodeH.Delete)
 
 	return e
 }
@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"node-test/internal/common/errors"
+	"node-test/internal/domain"
+)
+
+var (
+	errUntrustedClient = domain.NewError(domain.ErrorCodeForbidden, "client certificate signed by the trusted CA is required")
+)
+
+// requireClientCert rejects requests which didn't present the verified client certificate,
+// the certificate is verified against the configured CA during the TLS handshake.
+func requireClientCert(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+
+		state := c.Request().TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			return errors.Respond(c, errUntrustedClient)
+		}
+
+		return next(c)
+	}
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+
+	"node-test/pkg/tracing"
+)
+
+const (
+	operationWrite  = "write"
+	operationRead   = "read"
+	operationDelete = "delete"
+	operationState  = "state"
+)
+
+var (
+	gridfsDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "node",
+		Subsystem: "gridfs",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of GridFS operations.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"operation"})
+
+	gridfsErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "node",
+		Subsystem: "gridfs",
+		Name:      "operation_errors_total",
+		Help:      "Failed GridFS operations, missing chunks aren't counted.",
+	}, []string{"operation"})
+)
+
+// observe records the latency of the GridFS operation and counts its failure
+func observe(operation string, started time.Time, err error) {
+	gridfsDuration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if err != nil && err != ErrChunkNotFound {
+		gridfsErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// track starts the span of the GridFS operation on chunks of the upload, the returned function ends it
+// and records the latency and the failure of the operation
+func track(ctx context.Context, operation, uploadID string) func(err error) {
+
+	started := time.Now()
+	_, span := tracing.Start(ctx, "gridfs."+operation, attribute.String("upload_id", uploadID))
+
+	return func(err error) {
+		observe(operation, started, err)
+		if err == ErrChunkNotFound {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
@@ -1,25 +1,79 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"node-test/internal/domain"
 )
 
+const (
+	keysCollectionName = "upload_keys"
+)
+
+var (
+	// ErrChunkNotFound is returned when the node doesn't keep the requested chunk.
+	ErrChunkNotFound = domain.NewError(domain.ErrorCodeNotFound, "chunk not found")
+	// ErrKeyNotFound is returned when the upload has no data key.
+	ErrKeyNotFound = errors.New("data key not found")
+	// ErrClosed is returned when chunks are added after the repository is closed.
+	ErrClosed = domain.NewError(domain.ErrorCodeUnavailable, "repository is closed")
+)
+
 type (
 	nodeRepository struct {
-		fs *gridfs.Bucket
+		fs      *gridfs.Bucket
+		keys    *mongo.Collection
+		mu      sync.Mutex
+		closed  bool
+		streams sync.WaitGroup // open upload streams of GridFS
 	}
 
 	NodeRepository interface {
 		State(ctx context.Context) (int64, error)
-		Add(file *domain.Chunk) error
+		Ping(ctx context.Context) error
+		Add(ctx context.Context, file *domain.Chunk) error
+		Get(ctx context.Context, uploadID string, chunkNumber int64) (*domain.Chunk, error)
+		DeleteUpload(ctx context.Context, uploadID string) (int64, error)
+		DataKey(ctx context.Context, uploadID string) (*domain.WrappedKey, error)
+		AddDataKey(ctx context.Context, uploadID string, key *domain.WrappedKey) (*domain.WrappedKey, error)
+		StaleDataKeys(ctx context.Context, primaryKeyID string, limit int64) (map[string]*domain.WrappedKey, error)
+		ReplaceDataKey(ctx context.Context, uploadID string, old, key *domain.WrappedKey) error
+		Close(ctx context.Context) error
+	}
+
+	keyDocument struct {
+		UploadID   string `bson:"_id"`
+		KeyID      string `bson:"key_id"`
+		Ciphertext []byte `bson:"ciphertext"`
+	}
+
+	// usedTotal is the sum of lengths of GridFS files
+	usedTotal struct {
+		Bytes int64 `bson:"bytes"`
+	}
+
+	chunkFile struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Metadata struct {
+			UploadID      string `bson:"UploadID"`
+			ChunkNumber   int64  `bson:"ChunkNumber"`
+			TotalChunks   int64  `bson:"TotalChunks"`
+			TotalFileSize int64  `bson:"TotalFileSize"`
+			Filename      string `bson:"Filename"`
+			NodeEncrypted bool   `bson:"NodeEncrypted"`
+		} `bson:"metadata"`
 	}
 )
 
@@ -32,20 +86,42 @@ func NewNodeRepository(database *mongo.Database) (NodeRepository, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &nodeRepository{fs: fs}, nil
+	return &nodeRepository{
+		fs:   fs,
+		keys: database.Collection(keysCollectionName),
+	}, nil
 }
 
-// State returns the total size of all uploaded files in bytes.
-func (repo *nodeRepository) State(ctx context.Context) (int64, error) {
+// State returns the total size of all uploaded files in bytes, summed from lengths of GridFS files.
+func (repo *nodeRepository) State(ctx context.Context) (_ int64, err error) {
+
+	defer func(started time.Time) { observe(operationState, started, err) }(time.Now())
 
-	state, err := repo.fs.GetChunksCollection().CountDocuments(ctx, bson.D{})
+	cursor, err := repo.fs.GetFilesCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "bytes", Value: bson.D{{Key: "$sum", Value: "$length"}}},
+		}}},
+	})
 	if err != nil {
 		return 0, err
 	}
+	defer cursor.Close(ctx)
+
+	var totals []usedTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return 0, err
+	}
+	if len(totals) == 0 {
+		return 0, nil
+	}
 
-	state = state * 255 * 1024
+	return totals[0].Bytes, nil
+}
 
-	return state, nil
+// Ping checks the connection to the database.
+func (repo *nodeRepository) Ping(ctx context.Context) error {
+	return repo.keys.Database().Client().Ping(ctx, readpref.Primary())
 }
 
 // RetrieveFileByUploadID retrieves a file chunk from GridFS by its upload ID.
@@ -70,7 +146,16 @@ func (repo *nodeRepository) RetrieveFileByUploadID(ctx context.Context, uploadID
 	return nil, fmt.Errorf("file with upload ID %s not found", uploadID)
 }
 
-func (repo *nodeRepository) Add(file *domain.Chunk) error {
+// Add writes the chunk to GridFS, the partially written chunk is aborted so its pieces don't remain.
+func (repo *nodeRepository) Add(ctx context.Context, file *domain.Chunk) (err error) {
+
+	if err := repo.openStream(); err != nil {
+		return err
+	}
+	defer repo.streams.Done()
+
+	finish := track(ctx, operationWrite, file.UploadID)
+	defer func() { finish(err) }()
 
 	fsFileName := fmt.Sprintf("%s_%v", file.Filename, file.ChunkNumber)
 	opts := &options.UploadOptions{}
@@ -80,18 +165,209 @@ func (repo *nodeRepository) Add(file *domain.Chunk) error {
 		"TotalChunks":   file.TotalChunks,
 		"TotalFileSize": file.TotalFileSize,
 		"Filename":      file.Filename,
+		"NodeEncrypted": file.NodeEncrypted,
 	})
 	uploadStream, err := repo.fs.OpenUploadStream(fsFileName, opts)
 	if err != nil {
 		return fmt.Errorf("failed to open upload stream: %w", err)
 	}
-	defer uploadStream.Close()
 	// Write chunk data to GridFS.
 	_, err = uploadStream.Write(file.Data)
 	if err != nil {
+		_ = uploadStream.Abort()
 		return fmt.Errorf("failed to write data to upload stream: %w", err)
 	}
+	if err := uploadStream.Close(); err != nil {
+		_ = uploadStream.Abort()
+		return fmt.Errorf("failed to close upload stream: %w", err)
+	}
+
+	return nil
+}
+
+// openStream registers the upload stream unless the repository is closed
+func (repo *nodeRepository) openStream() error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.closed {
+		return ErrClosed
+	}
+	repo.streams.Add(1)
+
+	return nil
+}
+
+// Close rejects new chunks and waits until open upload streams finish or ctx is done.
+func (repo *nodeRepository) Close(ctx context.Context) error {
+
+	repo.mu.Lock()
+	repo.closed = true
+	repo.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		repo.streams.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Get retrieves the chunk with its data by the upload ID and the chunk number.
+func (repo *nodeRepository) Get(ctx context.Context, uploadID string, chunkNumber int64) (_ *domain.Chunk, err error) {
+
+	finish := track(ctx, operationRead, uploadID)
+	defer func() { finish(err) }()
+
+	var file chunkFile
+	err = repo.fs.GetFilesCollection().FindOne(ctx, bson.D{
+		{Key: "metadata.UploadID", Value: uploadID},
+		{Key: "metadata.ChunkNumber", Value: chunkNumber},
+	}).Decode(&file)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrChunkNotFound
+		}
+		return nil, fmt.Errorf("failed to find chunk in GridFS: %w", err)
+	}
+
+	var data bytes.Buffer
+	if _, err := repo.fs.DownloadToStream(file.ID, &data); err != nil {
+		return nil, fmt.Errorf("failed to read chunk data: %w", err)
+	}
+
+	return &domain.Chunk{
+		UploadID:      file.Metadata.UploadID,
+		ChunkNumber:   file.Metadata.ChunkNumber,
+		TotalChunks:   file.Metadata.TotalChunks,
+		TotalFileSize: file.Metadata.TotalFileSize,
+		Filename:      file.Metadata.Filename,
+		Data:          data.Bytes(),
+		NodeEncrypted: file.Metadata.NodeEncrypted,
+	}, nil
+}
+
+// DeleteUpload removes all chunks of the upload and returns the count of removed chunks.
+func (repo *nodeRepository) DeleteUpload(ctx context.Context, uploadID string) (_ int64, err error) {
+
+	finish := track(ctx, operationDelete, uploadID)
+	defer func() { finish(err) }()
+
+	cursor, err := repo.fs.FindContext(ctx, bson.D{{Key: "metadata.UploadID", Value: uploadID}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find chunks in GridFS: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var files []chunkFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return 0, fmt.Errorf("failed to decode chunks: %w", err)
+	}
+
+	for _, file := range files {
+		if err := repo.fs.DeleteContext(ctx, file.ID); err != nil {
+			return 0, fmt.Errorf("failed to delete chunk %v: %w", file.Metadata.ChunkNumber, err)
+		}
+	}
+
+	if _, err := repo.keys.DeleteOne(ctx, bson.D{{Key: "_id", Value: uploadID}}); err != nil {
+		return 0, fmt.Errorf("failed to delete data key: %w", err)
+	}
+
+	return int64(len(files)), nil
+}
+
+// DataKey retrieves the wrapped data key of the upload.
+func (repo *nodeRepository) DataKey(ctx context.Context, uploadID string) (*domain.WrappedKey, error) {
+
+	var doc keyDocument
+	err := repo.keys.FindOne(ctx, bson.D{{Key: "_id", Value: uploadID}}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to find data key: %w", err)
+	}
+
+	return doc.toDomain(), nil
+}
+
+// AddDataKey stores the data key of the upload unless it already has one and returns the stored key,
+// so concurrent chunks of the upload agree on the same key.
+func (repo *nodeRepository) AddDataKey(ctx context.Context, uploadID string, key *domain.WrappedKey) (*domain.WrappedKey, error) {
+
+	_, err := repo.keys.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: uploadID}},
+		bson.D{{Key: "$setOnInsert", Value: bson.D{
+			{Key: "key_id", Value: key.KeyID},
+			{Key: "ciphertext", Value: key.Ciphertext},
+		}}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("failed to add data key: %w", err)
+	}
+
+	return repo.DataKey(ctx, uploadID)
+}
+
+// StaleDataKeys returns data keys of uploads wrapped by other keys than the primary one.
+func (repo *nodeRepository) StaleDataKeys(ctx context.Context, primaryKeyID string, limit int64) (map[string]*domain.WrappedKey, error) {
+
+	cursor, err := repo.keys.Find(
+		ctx,
+		bson.D{{Key: "key_id", Value: bson.D{{Key: "$ne", Value: primaryKeyID}}}},
+		options.Find().SetLimit(limit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stale data keys: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []keyDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode data keys: %w", err)
+	}
+
+	keys := make(map[string]*domain.WrappedKey, len(docs))
+	for i := range docs {
+		keys[docs[i].UploadID] = docs[i].toDomain()
+	}
+
+	return keys, nil
+}
+
+// ReplaceDataKey replaces the data key of the upload if it is still wrapped by the old key.
+func (repo *nodeRepository) ReplaceDataKey(ctx context.Context, uploadID string, old, key *domain.WrappedKey) error {
+
+	res, err := repo.keys.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: uploadID}, {Key: "key_id", Value: old.KeyID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "key_id", Value: key.KeyID},
+			{Key: "ciphertext", Value: key.Ciphertext},
+		}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to replace data key: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrKeyNotFound
+	}
 
 	return nil
+}
 
+func (doc *keyDocument) toDomain() *domain.WrappedKey {
+	return &domain.WrappedKey{
+		KeyID:      doc.KeyID,
+		Ciphertext: doc.Ciphertext,
+	}
 }
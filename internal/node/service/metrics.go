@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// stateTimeout limits the request of the state while metrics are scraped
+	stateTimeout = 5 * time.Second
+)
+
+var (
+	sizeDesc = prometheus.NewDesc("node_size_bytes", "Configured capacity of the node.", nil, nil)
+	usedDesc = prometheus.NewDesc("node_used_bytes", "Bytes of chunks stored by the node.", nil, nil)
+	freeDesc = prometheus.NewDesc("node_free_bytes", "Bytes the node can still store.", nil, nil)
+)
+
+type (
+	// stateCollector exports the state of the node when metrics are scraped
+	stateCollector struct {
+		service NodeService
+	}
+)
+
+// NewStateCollector creates the prometheus collector of the capacity of the node.
+func NewStateCollector(service NodeService) prometheus.Collector {
+	return &stateCollector{service: service}
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sizeDesc
+	ch <- usedDesc
+	ch <- freeDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	state, err := c.service.State(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(usedDesc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(sizeDesc, prometheus.GaugeValue, float64(state.Size))
+	ch <- prometheus.MustNewConstMetric(usedDesc, prometheus.GaugeValue, float64(state.Used))
+	ch <- prometheus.MustNewConstMetric(freeDesc, prometheus.GaugeValue, float64(state.Free))
+}
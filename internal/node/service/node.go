@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	validatorEngine "github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
 
+	"node-test/internal/common/encryption"
 	commonDomain "node-test/internal/domain"
 	"node-test/internal/node/config"
 	"node-test/internal/node/repository"
@@ -19,23 +21,44 @@ type (
 		nodeRepository repository.NodeRepository
 		logger         *zap.SugaredLogger
 		validator      *validatorEngine.Validate
+		keyring        *encryption.Keyring
 	}
 
 	NodeService interface {
 		State(ctx context.Context) (*domain.State, error)
-		Upload(chunk *commonDomain.Chunk) error
+		Ready(ctx context.Context) commonDomain.Health
+		Upload(ctx context.Context, chunk *commonDomain.Chunk) error
+		Download(ctx context.Context, uploadID string, chunkNumber int64) (*commonDomain.Chunk, error)
+		Delete(ctx context.Context, uploadID string) (int64, error)
+		RotateKeys(ctx context.Context) (int64, error)
 	}
 )
 
+const (
+	rotationBatchSize = 100
+
+	// names of readiness checks
+	checkMongo     = "mongo"
+	checkFreeSpace = "free_space"
+)
+
+var (
+	// ErrEncryptionDisabled is returned when the chunk must be encrypted but the keyring isn't configured.
+	ErrEncryptionDisabled = errors.New("encryption at rest isn't configured on the node")
+)
+
+// NewNodeService creates the node service, the keyring is required to store encrypted chunks.
 func NewNodeService(
 	cfg *config.Config,
 	logger *zap.SugaredLogger,
-	nodeRepository repository.NodeRepository) NodeService {
+	nodeRepository repository.NodeRepository,
+	keyring *encryption.Keyring) NodeService {
 	return &nodeService{
 		cfg:            cfg,
 		nodeRepository: nodeRepository,
 		logger:         logger,
 		validator:      validatorEngine.New(),
+		keyring:        keyring,
 	}
 }
 
@@ -53,15 +76,135 @@ func (s *nodeService) State(ctx context.Context) (*domain.State, error) {
 	}, nil
 }
 
-func (s *nodeService) Upload(chunk *commonDomain.Chunk) error {
+// Ready checks the connection to mongo and that the node has free space for new chunks.
+func (s *nodeService) Ready(ctx context.Context) commonDomain.Health {
+
+	var health commonDomain.Health
+
+	health = health.Check(checkMongo, s.nodeRepository.Ping(ctx))
+
+	state, err := s.State(ctx)
+	if err == nil && state.Free <= s.cfg.Server.MinFree {
+		err = fmt.Errorf("%v bytes are free, more than %v are required", state.Free, s.cfg.Server.MinFree)
+	}
+
+	return health.Check(checkFreeSpace, err)
+}
+
+func (s *nodeService) Upload(ctx context.Context, chunk *commonDomain.Chunk) error {
 
 	if err := s.validator.Struct(chunk); err != nil {
-		return fmt.Errorf("chunk validation %w", err)
+		return fmt.Errorf("%w: %v", commonDomain.ErrInvalidChunk, err)
 	}
 
-	if err := s.nodeRepository.Add(chunk); err != nil {
+	if chunk.NodeEncrypted {
+		chunkCipher, err := s.chunkCipher(ctx, chunk.UploadID, true)
+		if err != nil {
+			return err
+		}
+
+		chunk.Data, err = chunkCipher.Seal(chunk.UploadID, chunk.ChunkNumber, chunk.Data)
+		if err != nil {
+			return fmt.Errorf("encrypt chunk %w", err)
+		}
+	}
+
+	if err := s.nodeRepository.Add(ctx, chunk); err != nil {
 		return fmt.Errorf("add file to fs %w", err)
 	}
 
 	return nil
 }
+
+func (s *nodeService) Download(ctx context.Context, uploadID string, chunkNumber int64) (*commonDomain.Chunk, error) {
+
+	chunk, err := s.nodeRepository.Get(ctx, uploadID, chunkNumber)
+	if err != nil {
+		return nil, fmt.Errorf("get file from fs %w", err)
+	}
+
+	if chunk.NodeEncrypted {
+		chunkCipher, err := s.chunkCipher(ctx, uploadID, false)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk.Data, err = chunkCipher.Open(chunk.UploadID, chunk.ChunkNumber, chunk.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt chunk %w", err)
+		}
+	}
+
+	return chunk, nil
+}
+
+func (s *nodeService) Delete(ctx context.Context, uploadID string) (int64, error) {
+
+	deleted, err := s.nodeRepository.DeleteUpload(ctx, uploadID)
+	if err != nil {
+		return 0, fmt.Errorf("delete file from fs %w", err)
+	}
+
+	return deleted, nil
+}
+
+// RotateKeys re-wraps data keys of uploads by the primary key of the keyring without rewriting chunks
+func (s *nodeService) RotateKeys(ctx context.Context) (int64, error) {
+
+	if s.keyring == nil {
+		return 0, ErrEncryptionDisabled
+	}
+
+	var rotated int64
+	for {
+		keys, err := s.nodeRepository.StaleDataKeys(ctx, s.keyring.Primary(), rotationBatchSize)
+		if err != nil {
+			return rotated, fmt.Errorf("get stale data keys %w", err)
+		}
+		if len(keys) == 0 {
+			return rotated, nil
+		}
+
+		for uploadID, old := range keys {
+			key, err := s.keyring.Rewrap(old)
+			if err != nil {
+				return rotated, fmt.Errorf("rewrap data key of upload %v %w", uploadID, err)
+			}
+
+			err = s.nodeRepository.ReplaceDataKey(ctx, uploadID, old, key)
+			if err != nil && !errors.Is(err, repository.ErrKeyNotFound) {
+				return rotated, fmt.Errorf("replace data key of upload %v %w", uploadID, err)
+			}
+			if err == nil {
+				rotated++
+			}
+		}
+	}
+}
+
+// chunkCipher unwraps the data key of the upload, the key is generated for the new upload when create is set
+func (s *nodeService) chunkCipher(ctx context.Context, uploadID string, create bool) (*encryption.ChunkCipher, error) {
+
+	if s.keyring == nil {
+		return nil, ErrEncryptionDisabled
+	}
+
+	key, err := s.nodeRepository.DataKey(ctx, uploadID)
+	if errors.Is(err, repository.ErrKeyNotFound) && create {
+		_, key, err = s.keyring.NewDataKey()
+		if err != nil {
+			return nil, fmt.Errorf("create data key %w", err)
+		}
+		key, err = s.nodeRepository.AddDataKey(ctx, uploadID, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get data key %w", err)
+	}
+
+	dataKey, err := s.keyring.Unwrap(key)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key %w", err)
+	}
+
+	return encryption.NewChunkCipher(dataKey)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	commonHttp "node-test/internal/common/http"
+)
+
+// CreateBucket creates the bucket owned by the caller and shared with its tenant.
+func (c *Client) CreateBucket(ctx context.Context, bucket *Bucket) (*Bucket, error) {
+
+	var info commonHttp.BucketInfo
+	if err := c.doJSON(ctx, "create bucket", http.MethodPost, c.restURL(bucketsPath, nil), newBucketRequest(bucket), &info); err != nil {
+		return nil, err
+	}
+
+	return newBucket(&info), nil
+}
+
+// Bucket returns settings of the bucket which the caller is the member of.
+func (c *Client) Bucket(ctx context.Context, name string) (*Bucket, error) {
+
+	var info commonHttp.BucketInfo
+	if err := c.doJSON(ctx, "bucket", http.MethodGet, c.restURL(bucketPath(name), nil), nil, &info); err != nil {
+		return nil, err
+	}
+
+	return newBucket(&info), nil
+}
+
+// Buckets returns buckets which the caller is the member of.
+func (c *Client) Buckets(ctx context.Context) ([]*Bucket, error) {
+
+	var infos []*commonHttp.BucketInfo
+	if err := c.doJSON(ctx, "buckets", http.MethodGet, c.restURL(bucketsPath, nil), nil, &infos); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]*Bucket, 0, len(infos))
+	for _, info := range infos {
+		buckets = append(buckets, newBucket(info))
+	}
+
+	return buckets, nil
+}
+
+// UpdateBucket replaces settings of the bucket, the caller must be its owner or the admin.
+func (c *Client) UpdateBucket(ctx context.Context, bucket *Bucket) (*Bucket, error) {
+
+	var info commonHttp.BucketInfo
+	err := c.doJSON(ctx, "update bucket", http.MethodPut, c.restURL(bucketPath(bucket.Name), nil), newBucketRequest(bucket), &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBucket(&info), nil
+}
+
+// DeleteBucket removes the bucket, its files must be deleted before.
+func (c *Client) DeleteBucket(ctx context.Context, name string) error {
+	return c.doJSON(ctx, "delete bucket", http.MethodDelete, c.restURL(bucketPath(name), nil), nil, nil)
+}
+
+func bucketPath(name string) string {
+	return bucketsPath + "/" + url.PathEscape(name)
+}
+
+func newBucketRequest(bucket *Bucket) *commonHttp.BucketRequest {
+	return &commonHttp.BucketRequest{
+		Name:                         bucket.Name,
+		Replicas:                     bucket.Replicas,
+		Versioning:                   bucket.Versioning,
+		MaxVersions:                  bucket.MaxVersions,
+		RetentionSeconds:             int64(bucket.Retention / time.Second),
+		ExpireAfterSeconds:           int64(bucket.ExpireAfter / time.Second),
+		NoncurrentExpireAfterSeconds: int64(bucket.NoncurrentExpireAfter / time.Second),
+		ChunkSize:                    bucket.ChunkSize,
+		Compression:                  bucket.Compression,
+		MaxBytes:                     bucket.MaxBytes,
+		MaxFiles:                     bucket.MaxFiles,
+		DefaultAccess:                bucket.DefaultAccess,
+		DefaultSharedWith:            bucket.DefaultSharedWith,
+	}
+}
+
+func newBucket(info *commonHttp.BucketInfo) *Bucket {
+	return &Bucket{
+		Name:                  info.Name,
+		Owner:                 info.Owner,
+		Tenant:                info.Tenant,
+		Replicas:              info.Replicas,
+		Versioning:            info.Versioning,
+		MaxVersions:           info.MaxVersions,
+		Retention:             time.Duration(info.RetentionSeconds) * time.Second,
+		ExpireAfter:           time.Duration(info.ExpireAfterSeconds) * time.Second,
+		NoncurrentExpireAfter: time.Duration(info.NoncurrentExpireAfterSeconds) * time.Second,
+		ChunkSize:             info.ChunkSize,
+		Compression:           info.Compression,
+		MaxBytes:              info.MaxBytes,
+		MaxFiles:              info.MaxFiles,
+		DefaultAccess:         info.DefaultAccess,
+		DefaultSharedWith:     info.DefaultSharedWith,
+		CreatedAt:             info.CreatedAt,
+	}
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	commonErrors "node-test/internal/common/errors"
+	"node-test/pkg/tracing"
+)
+
+const (
+	apiPath     = "/api/v1"
+	storagePath = apiPath + "/storage"
+	clusterPath = apiPath + "/cluster"
+	bucketsPath = apiPath + "/buckets"
+
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 200 * time.Millisecond
+	maxRetryBackoff      = 5 * time.Second
+)
+
+type (
+	// Client talks to the master of the storage cluster through its REST and websocket API.
+	Client struct {
+		host       string
+		secure     bool
+		token      string
+		httpClient *http.Client
+		dialer     *websocket.Dialer
+		retry      RetryPolicy
+		encryption *Encryption
+	}
+
+	// RetryPolicy describes how temporary failures are repeated.
+	RetryPolicy struct {
+		Attempts int           // total count of attempts, 1 disables retries
+		Backoff  time.Duration // delay before the second attempt, doubled for every next one
+	}
+
+	Option func(c *Client)
+)
+
+// WithToken authenticates requests with the bearer token.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient replaces the http client used for REST requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithDialer replaces the websocket dialer used for uploads and downloads.
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
+
+// WithRetry replaces the default retry policy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithTLS switches the client to https and wss schemes.
+func WithTLS() Option {
+	return func(c *Client) {
+		c.secure = true
+	}
+}
+
+// WithEncryption encrypts new files before they are sent to the master and decrypts
+// downloaded files, the master stores only non-secret parameters of the encryption.
+func WithEncryption(encryption *Encryption) Option {
+	return func(c *Client) {
+		c.encryption = encryption
+	}
+}
+
+// New creates the client of the master listening on host, e.g. "127.0.0.1:8080".
+func New(host string, opts ...Option) *Client {
+
+	c := &Client{
+		host:       host,
+		httpClient: http.DefaultClient,
+		dialer:     websocket.DefaultDialer,
+		retry: RetryPolicy{
+			Attempts: defaultRetryAttempts,
+			Backoff:  defaultRetryBackoff,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *Client) restURL(path string, query url.Values) string {
+	scheme := "http"
+	if c.secure {
+		scheme = "https"
+	}
+
+	u := url.URL{Scheme: scheme, Host: c.host, Path: path, RawQuery: query.Encode()}
+
+	return u.String()
+}
+
+// header returns headers of the request, the master continues the trace of ctx
+func (c *Client) header(ctx context.Context) http.Header {
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	tracing.Inject(ctx, header)
+
+	return header
+}
+
+// doJSON sends the request with the optional json body and decodes the json response into out,
+// temporary failures are retried.
+func (c *Client) doJSON(ctx context.Context, op, method, u string, in, out interface{}) error {
+
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("%s: encode request: %w", op, err)
+		}
+	}
+
+	return c.withRetry(ctx, func() error {
+
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header = c.header(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			return responseError(op, resp)
+		}
+
+		if out == nil || resp.StatusCode == http.StatusNoContent {
+			return nil
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%s: decode response: %w", op, err)
+		}
+
+		return nil
+	})
+}
+
+// dial opens the websocket connection which is closed when ctx is done.
+// The returned function must be called to release the connection.
+func (c *Client) dial(ctx context.Context, op, path string, query url.Values) (*websocket.Conn, func(), error) {
+
+	scheme := "ws"
+	if c.secure {
+		scheme = "wss"
+	}
+	u := url.URL{Scheme: scheme, Host: c.host, Path: storagePath + path, RawQuery: query.Encode()}
+
+	conn, resp, err := c.dialer.DialContext(ctx, u.String(), c.header(ctx))
+	if err != nil {
+		if resp != nil {
+			return nil, nil, responseError(op, resp)
+		}
+		return nil, nil, fmt.Errorf("%s: dial: %w", op, err)
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			_ = conn.Close()
+		})
+	}
+	stop := context.AfterFunc(ctx, release)
+
+	return conn, func() {
+		stop()
+		release()
+	}, nil
+}
+
+// withRetry repeats fn while it fails with temporary errors and attempts are left
+func (c *Client) withRetry(ctx context.Context, fn func() error) error {
+
+	var (
+		backoff  = c.retry.Backoff
+		attempts = c.retry.Attempts
+		err      error
+	)
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= attempts || !temporary(err) || ctx.Err() != nil {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+// temporary reports whether the failed request may be repeated
+func temporary(err error) bool {
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func responseError(op string, resp *http.Response) error {
+
+	var errResp commonErrors.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Message == "" {
+		errResp.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return &Error{Op: op, Code: errResp.Code, StatusCode: resp.StatusCode, Message: errResp.Message}
+}
+
+// closeError converts the abnormal close frame of the master into the error
+func closeError(op string, err error) error {
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Code != websocket.CloseNormalClosure {
+		reason := commonErrors.ParseCloseReason(closeErr.Text)
+		return &Error{Op: op, Code: reason.Code, CloseCode: closeErr.Code, Message: reason.Message}
+	}
+
+	return fmt.Errorf("%s: %w", op, err)
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	commonErrors "node-test/internal/common/errors"
+	commonHttp "node-test/internal/common/http"
+)
+
+// testResponse is the response of the test master, the empty code responds with the status and no body
+type testResponse struct {
+	status int
+	code   ErrorCode
+}
+
+// newTestClient returns the client of the master which serves responses in turn and then the file info
+func newTestClient(t *testing.T, attempts int, responses ...testResponse) (*Client, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if n <= len(responses) {
+			resp := responses[n-1]
+			if resp.code == "" {
+				w.WriteHeader(resp.status)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(resp.status)
+			_ = json.NewEncoder(w).Encode(commonErrors.ErrorResponse{Code: resp.code, Message: string(resp.code)})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(commonHttp.FileInfo{UploadID: "a1", Filename: "a.txt"})
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(strings.TrimPrefix(srv.URL, "http://"),
+		WithToken("token"),
+		WithRetry(RetryPolicy{Attempts: attempts, Backoff: time.Millisecond}),
+	)
+
+	return c, &requests
+}
+
+func TestRetry(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		attempts     int
+		responses    []testResponse
+		wantRequests int32
+		wantErr      error
+		wantStatus   int
+	}{
+		{name: "success", attempts: 3, wantRequests: 1},
+		{
+			name:         "temporary failures",
+			attempts:     3,
+			responses:    []testResponse{{http.StatusBadGateway, CodeNodeUnavailable}, {http.StatusServiceUnavailable, ""}},
+			wantRequests: 3,
+		},
+		{
+			name:         "attempts are over",
+			attempts:     2,
+			responses:    []testResponse{{http.StatusInternalServerError, CodeInternal}, {http.StatusBadGateway, CodeNodeUnavailable}},
+			wantRequests: 2,
+			wantErr:      ErrNodeUnavailable,
+			wantStatus:   http.StatusBadGateway,
+		},
+		{
+			name:         "retries are disabled",
+			attempts:     1,
+			responses:    []testResponse{{http.StatusTooManyRequests, ""}},
+			wantRequests: 1,
+			wantStatus:   http.StatusTooManyRequests,
+		},
+		{
+			name:         "permanent failure",
+			attempts:     3,
+			responses:    []testResponse{{http.StatusNotFound, CodeNotFound}},
+			wantRequests: 1,
+			wantErr:      ErrNotFound,
+			wantStatus:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newTestClient(t, tt.attempts, tt.responses...)
+
+			file, err := c.Stat(context.Background(), "a1")
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Fatalf("%v requests, want %v", got, tt.wantRequests)
+			}
+			if tt.wantStatus == 0 {
+				if err != nil || file.ID != "a1" {
+					t.Fatalf("stat = %+v, %v, want the file", file, err)
+				}
+				return
+			}
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus || apiErr.Op != "stat" {
+				t.Fatalf("stat error = %v, want the status %v", err, tt.wantStatus)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("stat error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+
+	c, requests := newTestClient(t, 5, testResponse{http.StatusServiceUnavailable, ""})
+	c.retry.Backoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Stat(ctx, "a1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stat error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("%v requests, want 1", got)
+	}
+}
+
+func TestResponseError(t *testing.T) {
+
+	c, _ := newTestClient(t, 1)
+	c.token = "wrong"
+
+	// the response without the body is described by its status
+	_, err := c.Stat(context.Background(), "a1")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Message != http.StatusText(http.StatusUnauthorized) || !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("stat error = %v, want %v", err, ErrUnauthorized)
+	}
+}
+
+func TestKeyPath(t *testing.T) {
+
+	tests := []struct {
+		bucket string
+		key    string
+		want   string
+	}{
+		{bucket: "docs", key: "a.txt", want: "docs/a.txt"},
+		{bucket: "docs", key: "2024/05/a.txt", want: "docs/2024/05/a.txt"},
+		{bucket: "docs", key: "a b?#.txt", want: "docs/a%20b%3F%23.txt"},
+		{bucket: "my docs", key: "dir%2Fa", want: "my%20docs/dir%252Fa"},
+	}
+
+	for _, tt := range tests {
+		if got := keyPath(tt.bucket, tt.key); got != tt.want {
+			t.Fatalf("keyPath(%q, %q) = %v, want %v", tt.bucket, tt.key, got, tt.want)
+		}
+	}
+}
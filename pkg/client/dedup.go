@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	commonHttp "node-test/internal/common/http"
+)
+
+// FileDedup returns deduplication stats of the file which the caller can read.
+func (c *Client) FileDedup(ctx context.Context, id string) (*DedupStats, error) {
+
+	var info commonHttp.DedupInfo
+	err := c.doJSON(ctx, "file dedup", http.MethodGet, c.restURL(storagePath+"/files/"+url.PathEscape(id)+"/dedup", nil), nil, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDedupStats(&info), nil
+}
+
+// Dedup returns deduplication stats of all stored files, the caller must be the admin.
+func (c *Client) Dedup(ctx context.Context) (*DedupStats, error) {
+
+	var info commonHttp.DedupInfo
+	if err := c.doJSON(ctx, "dedup", http.MethodGet, c.restURL(apiPath+"/admin/dedup", nil), nil, &info); err != nil {
+		return nil, err
+	}
+
+	return newDedupStats(&info), nil
+}
+
+func newDedupStats(info *commonHttp.DedupInfo) *DedupStats {
+	return &DedupStats{
+		UploadID:         info.UploadID,
+		LogicalBytes:     info.LogicalBytes,
+		StoredBytes:      info.StoredBytes,
+		CompressedBytes:  info.CompressedBytes,
+		Ratio:            info.DedupRatio,
+		CompressionRatio: info.CompressionRatio,
+	}
+}
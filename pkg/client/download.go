@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+
+	commonHttp "node-test/internal/common/http"
+)
+
+type (
+	downloadReader struct {
+		*io.PipeReader
+		cancel context.CancelFunc
+	}
+
+	progressWriter struct {
+		w    io.Writer
+		opts *DownloadOptions
+	}
+
+	// chunkWriter receives chunks of the file in order
+	chunkWriter func(chunkNum int64, data []byte) error
+
+	// rangeStream receives chunks of the range through the single websocket connection
+	rangeStream struct {
+		conn    *websocket.Conn
+		release func()
+	}
+)
+
+// Download returns the reader of the file content. Chunks are received in order through
+// the single connection which is reopened from the next chunk on temporary failures.
+// The content encrypted by the client is decrypted, the reader fails when it isn't authentic.
+// The reader must be closed.
+func (c *Client) Download(ctx context.Context, id string) (io.ReadCloser, error) {
+
+	file, err := c.Stat(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fc, err := c.fileCipher(file.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+
+	go func() {
+		_ = pw.CloseWithError(c.receiveContent(ctx, file, fc, pw, nil))
+	}()
+
+	return &downloadReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+func (r *downloadReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// DownloadAt downloads the file through opts.Parallel connections and writes chunks at their offsets of w.
+// The content encrypted by the client and files with content defined chunks are received in order
+// through the single connection.
+func (c *Client) DownloadAt(ctx context.Context, id string, w io.WriterAt, opts DownloadOptions) (*File, error) {
+
+	file, err := c.Stat(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fc, err := c.fileCipher(file.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	// offsets of content defined chunks aren't known, so they are received in order as well
+	if fc != nil || file.Chunking == ChunkingCDC {
+		if err := c.receiveContent(ctx, file, fc, io.NewOffsetWriter(w, 0), &opts); err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	ranges := splitRanges(ChunkRange{First: 1, Last: file.TotalChunks}, parallel)
+	err = runConcurrent(ctx, ranges, parallel, func(ctx context.Context, rng ChunkRange) error {
+		return c.receiveRange(ctx, file, rng, func(chunkNum int64, data []byte) error {
+			if _, err := w.WriteAt(data, (chunkNum-1)*file.ChunkSize); err != nil {
+				return err
+			}
+			opts.progress(len(data))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Verify downloads the file and compares its content with the recorded checksum.
+// The content encrypted by the client is authenticated as well when the client has the key.
+func (c *Client) Verify(ctx context.Context, id string, opts DownloadOptions) (*File, error) {
+
+	file, err := c.Stat(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Checksum == "" {
+		return nil, fmt.Errorf("verify: file %s has no recorded checksum", id)
+	}
+
+	var decrypt *decryptWriter
+	if file.Encrypted() && c.encryption != nil {
+		fc, err := c.fileCipher(file.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("verify: %w", err)
+		}
+		decrypt = fc.decryptWriter(io.Discard)
+	}
+
+	hash := sha256.New()
+	all := ChunkRange{First: 1, Last: file.TotalChunks}
+	err = c.receiveRange(ctx, file, all, func(_ int64, data []byte) error {
+		hash.Write(data)
+		if decrypt != nil {
+			if _, err := decrypt.Write(data); err != nil {
+				return err
+			}
+		}
+		opts.progress(len(data))
+		return nil
+	})
+	if err == nil && decrypt != nil {
+		err = decrypt.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != file.Checksum {
+		return file, fmt.Errorf("%w: expected %s actual %s", ErrChecksumMismatch, file.Checksum, actual)
+	}
+
+	return file, nil
+}
+
+// receiveContent writes the whole content of the file to w in order, decrypting it with the optional cipher.
+// Progress is reported with the count of written bytes when opts are given.
+func (c *Client) receiveContent(ctx context.Context, file *File, fc *fileCipher, w io.Writer, opts *DownloadOptions) error {
+
+	if opts != nil {
+		w = &progressWriter{w: w, opts: opts}
+	}
+
+	var decrypt *decryptWriter
+	if fc != nil {
+		decrypt = fc.decryptWriter(w)
+		w = decrypt
+	}
+
+	all := ChunkRange{First: 1, Last: file.TotalChunks}
+	err := c.receiveRange(ctx, file, all, func(_ int64, data []byte) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if decrypt != nil {
+		return decrypt.Close()
+	}
+
+	return nil
+}
+
+// receiveRange passes chunks of the range to write in order, the stream is reopened
+// from the next chunk on temporary failures
+func (c *Client) receiveRange(ctx context.Context, file *File, rng ChunkRange, write chunkWriter) error {
+
+	next := rng.First
+
+	return c.withRetry(ctx, func() error {
+
+		if next > rng.Last {
+			return nil
+		}
+
+		stream, err := c.openRange(ctx, file.ID, ChunkRange{First: next, Last: rng.Last})
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		for next <= rng.Last {
+			data, err := stream.Next()
+			if err != nil {
+				return err
+			}
+			if err := write(next, data); err != nil {
+				return err
+			}
+			next++
+		}
+
+		return nil
+	})
+}
+
+func (c *Client) openRange(ctx context.Context, id string, rng ChunkRange) (*rangeStream, error) {
+
+	query := url.Values{}
+	query.Set("id", id)
+	query.Set("from", strconv.FormatInt(rng.First, 10))
+	query.Set("to", strconv.FormatInt(rng.Last, 10))
+
+	conn, release, err := c.dial(ctx, "download", "/ws/download", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var header commonHttp.FileInfo
+	if err := conn.ReadJSON(&header); err != nil {
+		release()
+		return nil, closeError("download", err)
+	}
+
+	return &rangeStream{conn: conn, release: release}, nil
+}
+
+// Next returns data of the next chunk
+func (s *rangeStream) Next() ([]byte, error) {
+
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		return nil, closeError("download", err)
+	}
+
+	return data, nil
+}
+
+func (s *rangeStream) Close() {
+	s.release()
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.opts.progress(n)
+
+	return n, err
+}
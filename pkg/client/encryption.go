@@ -0,0 +1,375 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Metadata keys of files encrypted on the client, values are not secret.
+const (
+	MetadataEncryption = "enc"
+	MetadataKDF        = "enc.kdf"
+	MetadataKDFParams  = "enc.kdf.params"
+	MetadataSalt       = "enc.salt"
+	MetadataSegment    = "enc.segment"
+	MetadataSize       = "enc.size"
+
+	// encryptionScheme is AES-256-GCM over fixed segments of the content, the nonce of the segment
+	// is its number with the flag of the last segment, so segments can't be reordered or truncated.
+	encryptionScheme = "aes256gcm-stream"
+
+	kdfScrypt = "scrypt"
+	kdfHKDF   = "hkdf-sha256"
+
+	encryptionSegmentSize = 64 * 1024
+	encryptionKeySize     = 32
+	encryptionSaltSize    = 16
+	minEncryptionKeySize  = 32
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	// limits of scrypt parameters read from the file metadata
+	maxScryptN = 1 << 20
+	maxScryptR = 32
+	maxScryptP = 16
+)
+
+var hkdfInfo = []byte("master-client file key")
+
+type (
+	// Encryption derives keys of files from the passphrase or the key,
+	// every file gets its own key from the random salt stored in its metadata.
+	Encryption struct {
+		secret []byte
+		kdf    string
+	}
+
+	// fileCipher encrypts segments of the single file
+	fileCipher struct {
+		aead      cipher.AEAD
+		plainSize int64
+		segments  int64
+	}
+
+	encryptReader struct {
+		src     io.Reader
+		cipher  *fileCipher
+		segment int64
+		buf     []byte
+		out     []byte
+	}
+
+	encryptReaderAt struct {
+		src    io.ReaderAt
+		cipher *fileCipher
+	}
+
+	// decryptWriter decrypts the content written in order and passes the plain content to dst
+	decryptWriter struct {
+		dst     io.Writer
+		cipher  *fileCipher
+		segment int64
+		buf     []byte
+		out     []byte
+	}
+)
+
+// PassphraseEncryption derives file keys from the passphrase with scrypt.
+func PassphraseEncryption(passphrase string) (*Encryption, error) {
+
+	if passphrase == "" {
+		return nil, errors.New("client: empty passphrase")
+	}
+
+	return &Encryption{secret: []byte(passphrase), kdf: kdfScrypt}, nil
+}
+
+// KeyEncryption derives file keys from the random key of at least 32 bytes with HKDF.
+func KeyEncryption(key []byte) (*Encryption, error) {
+
+	if len(key) < minEncryptionKeySize {
+		return nil, fmt.Errorf("client: the key must be at least %d bytes", minEncryptionKeySize)
+	}
+
+	return &Encryption{secret: key, kdf: kdfHKDF}, nil
+}
+
+// newFile creates the cipher of the new file of plainSize bytes and its metadata
+func (e *Encryption) newFile(plainSize int64) (*fileCipher, map[string]string, error) {
+
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, fmt.Errorf("generate salt: %w", err)
+	}
+
+	metadata := map[string]string{
+		MetadataEncryption: encryptionScheme,
+		MetadataKDF:        e.kdf,
+		MetadataSalt:       base64.StdEncoding.EncodeToString(salt),
+		MetadataSegment:    strconv.Itoa(encryptionSegmentSize),
+		MetadataSize:       strconv.FormatInt(plainSize, 10),
+	}
+	if e.kdf == kdfScrypt {
+		metadata[MetadataKDFParams] = fmt.Sprintf("%d,%d,%d", scryptN, scryptR, scryptP)
+	}
+
+	fc, err := e.file(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return fc, metadata, nil
+}
+
+// file creates the cipher of the existing file from its metadata
+func (e *Encryption) file(metadata map[string]string) (*fileCipher, error) {
+
+	if metadata[MetadataEncryption] != encryptionScheme {
+		return nil, fmt.Errorf("client: unsupported encryption %q", metadata[MetadataEncryption])
+	}
+	if metadata[MetadataKDF] != e.kdf {
+		return nil, fmt.Errorf("client: the file key is derived by %q, the client has the %q secret", metadata[MetadataKDF], e.kdf)
+	}
+	if metadata[MetadataSegment] != strconv.Itoa(encryptionSegmentSize) {
+		return nil, fmt.Errorf("client: unsupported segment size %q", metadata[MetadataSegment])
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(metadata[MetadataSalt])
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("client: invalid encryption salt")
+	}
+
+	plainSize, err := strconv.ParseInt(metadata[MetadataSize], 10, 64)
+	if err != nil || plainSize < 0 {
+		return nil, errors.New("client: invalid encrypted content size")
+	}
+
+	key := make([]byte, encryptionKeySize)
+	switch e.kdf {
+	case kdfScrypt:
+		var n, r, p int
+		if _, err := fmt.Sscanf(metadata[MetadataKDFParams], "%d,%d,%d", &n, &r, &p); err != nil ||
+			n > maxScryptN || r > maxScryptR || p > maxScryptP {
+			return nil, errors.New("client: invalid scrypt parameters")
+		}
+		if key, err = scrypt.Key(e.secret, salt, n, r, p, encryptionKeySize); err != nil {
+			return nil, fmt.Errorf("client: derive key: %w", err)
+		}
+	case kdfHKDF:
+		if _, err := io.ReadFull(hkdf.New(sha256.New, e.secret, salt, hkdfInfo), key); err != nil {
+			return nil, fmt.Errorf("client: derive key: %w", err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// the empty content is the single empty segment, so it is authenticated as well
+	segments := (plainSize + encryptionSegmentSize - 1) / encryptionSegmentSize
+	if segments == 0 {
+		segments = 1
+	}
+
+	return &fileCipher{aead: aead, plainSize: plainSize, segments: segments}, nil
+}
+
+// encrypted reports whether the file is encrypted on the client
+func encrypted(metadata map[string]string) bool {
+	return metadata[MetadataEncryption] != ""
+}
+
+// fileCipher returns the cipher of the file encrypted on the client, nil when the file is plain
+func (c *Client) fileCipher(metadata map[string]string) (*fileCipher, error) {
+
+	if !encrypted(metadata) {
+		return nil, nil
+	}
+	if c.encryption == nil {
+		return nil, ErrEncrypted
+	}
+
+	return c.encryption.file(metadata)
+}
+
+// size returns the size of the encrypted content
+func (c *fileCipher) size() int64 {
+	return c.plainSize + c.segments*int64(c.aead.Overhead())
+}
+
+func (c *fileCipher) nonce(segment int64) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], uint64(segment))
+	if segment == c.segments-1 {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+// plainSegment returns the size of the plain segment
+func (c *fileCipher) plainSegment(segment int64) int64 {
+	if segment == c.segments-1 {
+		return c.plainSize - segment*encryptionSegmentSize
+	}
+
+	return encryptionSegmentSize
+}
+
+func (c *fileCipher) seal(dst []byte, segment int64, plain []byte) []byte {
+	return c.aead.Seal(dst, c.nonce(segment), plain, nil)
+}
+
+func (c *fileCipher) open(dst []byte, segment int64, sealed []byte) ([]byte, error) {
+	plain, err := c.aead.Open(dst, c.nonce(segment), sealed, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plain, nil
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+
+	for len(r.out) == 0 {
+		if r.segment == r.cipher.segments {
+			return 0, io.EOF
+		}
+
+		size := r.cipher.plainSegment(r.segment)
+		if _, err := io.ReadFull(r.src, r.buf[:size]); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+
+		r.out = r.cipher.seal(r.out[:0], r.segment, r.buf[:size])
+		r.segment++
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+// ReadAt encrypts segments which overlap the requested range, segments are encrypted
+// with the same nonces every time, so the repeated upload of the range sends the same bytes
+func (r *encryptReaderAt) ReadAt(p []byte, off int64) (int, error) {
+
+	var (
+		sealedSize = int64(encryptionSegmentSize + r.cipher.aead.Overhead())
+		total      = r.cipher.size()
+		plain      = make([]byte, encryptionSegmentSize)
+		sealed     = make([]byte, 0, sealedSize)
+		n          int
+	)
+
+	for n < len(p) && off < total {
+		segment := off / sealedSize
+		size := r.cipher.plainSegment(segment)
+
+		if read, err := r.src.ReadAt(plain[:size], segment*encryptionSegmentSize); int64(read) < size {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		sealed = r.cipher.seal(sealed[:0], segment, plain[:size])
+
+		copied := copy(p[n:], sealed[off-segment*sealedSize:])
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Write decrypts every complete segment, the segment is written to dst only when it is authenticated
+func (w *decryptWriter) Write(p []byte) (int, error) {
+
+	written := len(p)
+	overhead := int64(w.cipher.aead.Overhead())
+
+	for len(p) > 0 {
+		if w.segment == w.cipher.segments {
+			return 0, fmt.Errorf("%w: content is longer than expected", ErrDecrypt)
+		}
+
+		size := w.cipher.plainSegment(w.segment) + overhead
+		n := copy(w.buf[len(w.buf):size], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+
+		if int64(len(w.buf)) < size {
+			break
+		}
+
+		plain, err := w.cipher.open(w.out[:0], w.segment, w.buf)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.dst.Write(plain); err != nil {
+			return 0, err
+		}
+
+		w.buf = w.buf[:0]
+		w.segment++
+	}
+
+	return written, nil
+}
+
+// Close checks that the content isn't truncated
+func (w *decryptWriter) Close() error {
+	if w.segment != w.cipher.segments {
+		return fmt.Errorf("%w: content is truncated", ErrDecrypt)
+	}
+
+	return nil
+}
+
+func (c *fileCipher) encryptReader(src io.Reader) io.Reader {
+	return &encryptReader{
+		src:    src,
+		cipher: c,
+		buf:    make([]byte, encryptionSegmentSize),
+		out:    make([]byte, 0, encryptionSegmentSize+c.aead.Overhead()),
+	}
+}
+
+func (c *fileCipher) encryptReaderAt(src io.ReaderAt) io.ReaderAt {
+	return &encryptReaderAt{src: src, cipher: c}
+}
+
+func (c *fileCipher) decryptWriter(dst io.Writer) *decryptWriter {
+	return &decryptWriter{
+		dst:    dst,
+		cipher: c,
+		buf:    make([]byte, 0, encryptionSegmentSize+c.aead.Overhead()),
+		out:    make([]byte, 0, encryptionSegmentSize),
+	}
+}
@@ -0,0 +1,268 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"math/rand"
+	"testing"
+)
+
+// testContent returns the random content of the size which is the same for every call
+func testContent(size int) []byte {
+
+	content := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(content)
+
+	return content
+}
+
+func testKeyEncryption(t *testing.T, b byte) *Encryption {
+	t.Helper()
+
+	e, err := KeyEncryption(bytes.Repeat([]byte{b}, minEncryptionKeySize))
+	if err != nil {
+		t.Fatalf("key encryption: %v", err)
+	}
+
+	return e
+}
+
+// encryptContent encrypts the content as the new file and returns the ciphertext and the file metadata
+func encryptContent(t *testing.T, e *Encryption, content []byte) ([]byte, map[string]string) {
+	t.Helper()
+
+	fc, metadata, err := e.newFile(int64(len(content)))
+	if err != nil {
+		t.Fatalf("new file: %v", err)
+	}
+
+	sealed, err := io.ReadAll(fc.encryptReader(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if int64(len(sealed)) != fc.size() {
+		t.Fatalf("encrypted %v bytes, want %v", len(sealed), fc.size())
+	}
+
+	return sealed, metadata
+}
+
+// decryptContent decrypts the ciphertext written by parts of the size
+func decryptContent(e *Encryption, metadata map[string]string, sealed []byte, part int) ([]byte, error) {
+
+	fc, err := e.file(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var plain bytes.Buffer
+	w := fc.decryptWriter(&plain)
+	for len(sealed) > 0 {
+		n := min(part, len(sealed))
+		if _, err := w.Write(sealed[:n]); err != nil {
+			return nil, err
+		}
+		sealed = sealed[n:]
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return plain.Bytes(), nil
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+
+	passphrase, err := PassphraseEncryption("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("passphrase encryption: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		encryption *Encryption
+		size       int
+		part       int
+	}{
+		{name: "empty", encryption: testKeyEncryption(t, 1), size: 0, part: 1},
+		{name: "single segment", encryption: testKeyEncryption(t, 1), size: 1000, part: 100},
+		{name: "whole segment", encryption: testKeyEncryption(t, 1), size: encryptionSegmentSize, part: 4096},
+		{name: "segments and the tail", encryption: testKeyEncryption(t, 1), size: 3*encryptionSegmentSize + 17, part: 10000},
+		{name: "single write", encryption: testKeyEncryption(t, 1), size: 2*encryptionSegmentSize + 1, part: 1 << 20},
+		{name: "passphrase", encryption: passphrase, size: encryptionSegmentSize + 1, part: 777},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testContent(tt.size)
+			sealed, metadata := encryptContent(t, tt.encryption, content)
+			if tt.size > 0 && bytes.Contains(sealed, content) {
+				t.Fatalf("ciphertext contains the content")
+			}
+
+			plain, err := decryptContent(tt.encryption, metadata, sealed, tt.part)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(plain, content) {
+				t.Fatalf("decrypted %v bytes differ from %v encrypted bytes", len(plain), len(content))
+			}
+		})
+	}
+}
+
+func TestEncryptReaderAt(t *testing.T) {
+
+	e := testKeyEncryption(t, 1)
+	content := testContent(3*encryptionSegmentSize + 100)
+
+	fc, metadata, err := e.newFile(int64(len(content)))
+	if err != nil {
+		t.Fatalf("new file: %v", err)
+	}
+	// segments are sealed with the same nonces, so ranges match the stream of the same file
+	sealed, err := io.ReadAll(fc.encryptReader(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	sealedSegment := int64(encryptionSegmentSize + fc.aead.Overhead())
+
+	tests := []struct {
+		name    string
+		off     int64
+		size    int
+		wantN   int
+		wantEOF bool
+	}{
+		{name: "inside the segment", off: 10, size: 100, wantN: 100},
+		{name: "across the segment boundary", off: sealedSegment - 50, size: 100, wantN: 100},
+		{name: "across several segments", off: 5, size: int(2*sealedSegment) + 10, wantN: int(2*sealedSegment) + 10},
+		{name: "from the boundary", off: 2 * sealedSegment, size: 10, wantN: 10},
+		{name: "tail", off: int64(len(sealed)) - 20, size: 20, wantN: 20},
+		{name: "past the end", off: int64(len(sealed)) - 20, size: 50, wantN: 20, wantEOF: true},
+		{name: "at the end", off: int64(len(sealed)), size: 10, wantEOF: true},
+	}
+
+	r := fc.encryptReaderAt(bytes.NewReader(content))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := make([]byte, tt.size)
+			n, err := r.ReadAt(p, tt.off)
+			if n != tt.wantN {
+				t.Fatalf("read %v bytes, want %v", n, tt.wantN)
+			}
+			if tt.wantEOF != errors.Is(err, io.EOF) || !tt.wantEOF && err != nil {
+				t.Fatalf("read error = %v, want EOF %v", err, tt.wantEOF)
+			}
+			if !bytes.Equal(p[:n], sealed[tt.off:tt.off+int64(n)]) {
+				t.Fatalf("range at %v differs from the encrypted stream", tt.off)
+			}
+		})
+	}
+
+	// chunks of another size than segments are read by ranges and decrypted as the whole content
+	var chunks []byte
+	for off := int64(0); off < int64(len(sealed)); off += 50000 {
+		p := make([]byte, min(50000, int64(len(sealed))-off))
+		if _, err := r.ReadAt(p, off); err != nil {
+			t.Fatalf("read chunk at %v: %v", off, err)
+		}
+		chunks = append(chunks, p...)
+	}
+	plain, err := decryptContent(e, metadata, chunks, 50000)
+	if err != nil {
+		t.Fatalf("decrypt chunks: %v", err)
+	}
+	if !bytes.Equal(plain, content) {
+		t.Fatalf("decrypted chunks differ from the content")
+	}
+}
+
+func TestDecryptFailure(t *testing.T) {
+
+	e := testKeyEncryption(t, 1)
+	content := testContent(2*encryptionSegmentSize + 10)
+	sealed, metadata := encryptContent(t, e, content)
+
+	tampered := bytes.Clone(sealed)
+	tampered[encryptionSegmentSize+100] ^= 1
+
+	// the segment which is moved to another place is authenticated by its number
+	fc, err := e.file(metadata)
+	if err != nil {
+		t.Fatalf("file: %v", err)
+	}
+	sealedSegment := encryptionSegmentSize + fc.aead.Overhead()
+	swapped := bytes.Clone(sealed)
+	copy(swapped, sealed[sealedSegment:2*sealedSegment])
+	copy(swapped[sealedSegment:], sealed[:sealedSegment])
+
+	tests := []struct {
+		name       string
+		encryption *Encryption
+		sealed     []byte
+	}{
+		{name: "wrong key", encryption: testKeyEncryption(t, 2), sealed: sealed},
+		{name: "tampered ciphertext", encryption: e, sealed: tampered},
+		{name: "swapped segments", encryption: e, sealed: swapped},
+		{name: "truncated content", encryption: e, sealed: sealed[:len(sealed)-1]},
+		{name: "truncated at the segment boundary", encryption: e, sealed: sealed[:2*sealedSegment]},
+		{name: "longer content", encryption: e, sealed: append(bytes.Clone(sealed), 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptContent(tt.encryption, metadata, tt.sealed, 4096); !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("decrypt error = %v, want %v", err, ErrDecrypt)
+			}
+		})
+	}
+}
+
+func TestEncryptionFile(t *testing.T) {
+
+	e := testKeyEncryption(t, 1)
+	_, metadata, err := e.newFile(10)
+	if err != nil {
+		t.Fatalf("new file: %v", err)
+	}
+	passphrase, err := PassphraseEncryption("passphrase")
+	if err != nil {
+		t.Fatalf("passphrase encryption: %v", err)
+	}
+
+	// with returns the metadata with replaced pairs of keys and values
+	with := func(pairs ...string) map[string]string {
+		changed := maps.Clone(metadata)
+		for i := 0; i < len(pairs); i += 2 {
+			changed[pairs[i]] = pairs[i+1]
+		}
+		return changed
+	}
+
+	tests := []struct {
+		name       string
+		encryption *Encryption
+		metadata   map[string]string
+		wantErr    bool
+	}{
+		{name: "valid", encryption: e, metadata: metadata},
+		{name: "another secret kind", encryption: passphrase, metadata: metadata, wantErr: true},
+		{name: "unsupported scheme", encryption: e, metadata: with(MetadataEncryption, "rot13"), wantErr: true},
+		{name: "unsupported segment", encryption: e, metadata: with(MetadataSegment, "1024"), wantErr: true},
+		{name: "invalid salt", encryption: e, metadata: with(MetadataSalt, "!"), wantErr: true},
+		{name: "negative size", encryption: e, metadata: with(MetadataSize, "-1"), wantErr: true},
+		{name: "scrypt parameters over limits", encryption: passphrase, metadata: with(MetadataKDF, kdfScrypt, MetadataKDFParams, "1073741824,8,1"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.encryption.file(tt.metadata); (err != nil) != tt.wantErr {
+				t.Fatalf("file error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"node-test/internal/domain"
+)
+
+// Error codes which the master reports with failed requests and close frames, see Error.Code.
+const (
+	CodeInternal         = domain.ErrorCodeInternal
+	CodeInvalidRequest   = domain.ErrorCodeInvalidRequest
+	CodeUnauthorized     = domain.ErrorCodeUnauthorized
+	CodeForbidden        = domain.ErrorCodeForbidden
+	CodeNotFound         = domain.ErrorCodeNotFound
+	CodeConflict         = domain.ErrorCodeConflict
+	CodeQuotaExceeded    = domain.ErrorCodeQuotaExceeded
+	CodeChecksumMismatch = domain.ErrorCodeChecksumMismatch
+	CodeInvalidChunk     = domain.ErrorCodeInvalidChunk
+	CodeInvalidRange     = domain.ErrorCodeInvalidRange
+	CodeNodeUnavailable  = domain.ErrorCodeNodeUnavailable
+	CodeUnavailable      = domain.ErrorCodeUnavailable
+)
+
+var (
+	// ErrNotFound is matched by errors.Is when the file, the bucket or the session doesn't exist.
+	ErrNotFound = errors.New("client: not found")
+	// ErrConflict is matched by errors.Is when the request conflicts with the state of the file,
+	// e.g. the commit of the incomplete upload or the key which is already taken in the bucket.
+	ErrConflict = errors.New("client: conflict")
+	// ErrUnauthorized is matched by errors.Is when the master rejects the credentials.
+	ErrUnauthorized = errors.New("client: unauthorized")
+	// ErrQuotaExceeded is matched by errors.Is when the file doesn't fit the quota of the user, its tenant or the bucket.
+	ErrQuotaExceeded = errors.New("client: quota exceeded")
+	// ErrChecksumMismatch is returned when the downloaded content doesn't match the recorded checksum,
+	// it is matched as well when the master rejects the commit of the content with another checksum.
+	ErrChecksumMismatch = errors.New("client: checksum mismatch")
+	// ErrInvalidChunk is matched by errors.Is when the master rejects chunks or frames of the upload.
+	ErrInvalidChunk = errors.New("client: invalid chunk")
+	// ErrNodeUnavailable is matched by errors.Is when storage nodes failed to serve the request of the master,
+	// the request may succeed when it is repeated.
+	ErrNodeUnavailable = errors.New("client: storage node unavailable")
+	// ErrEncrypted is returned when the file is encrypted on the client and the client has no key.
+	ErrEncrypted = errors.New("client: file is encrypted, the key is required")
+	// ErrDecrypt is returned when the content can't be decrypted with the key or it was modified.
+	ErrDecrypt = errors.New("client: can't decrypt the content")
+)
+
+type (
+	// ErrorCode identifies the kind of the error reported by the master.
+	ErrorCode = domain.ErrorCode
+
+	// Error is the error reported by the master with the http status or the websocket close frame.
+	Error struct {
+		Op         string
+		Code       ErrorCode // empty when the response has no code
+		StatusCode int       // http status, zero when the error came with the close frame
+		CloseCode  int       // websocket close code, zero for http errors
+		Message    string
+	}
+
+	// UploadError is returned when the upload fails after the session has been created,
+	// the session can be resumed with ResumeUpload.
+	UploadError struct {
+		UploadID string
+		Err      error
+	}
+)
+
+func (e *Error) Error() string {
+	if e.CloseCode != 0 {
+		return fmt.Sprintf("%s: connection closed with code %d: %s", e.Op, e.CloseCode, e.Message)
+	}
+
+	return fmt.Sprintf("%s: status %d: %s", e.Op, e.StatusCode, e.Message)
+}
+
+// Is matches errors by their codes, statuses are matched when the response has no code.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == CodeNotFound || e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.Code == CodeConflict || e.Code == CodeChecksumMismatch || e.StatusCode == http.StatusConflict
+	case ErrUnauthorized:
+		return e.Code == CodeUnauthorized || e.Code == CodeForbidden ||
+			e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrQuotaExceeded:
+		return e.Code == CodeQuotaExceeded || e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrChecksumMismatch:
+		return e.Code == CodeChecksumMismatch
+	case ErrInvalidChunk:
+		return e.Code == CodeInvalidChunk
+	case ErrNodeUnavailable:
+		return e.Code == CodeNodeUnavailable
+	default:
+		return false
+	}
+}
+
+// Temporary reports whether the request may succeed when it is repeated.
+func (e *Error) Temporary() bool {
+	switch e.Code {
+	case CodeInternal, CodeNodeUnavailable, CodeUnavailable:
+		return true
+	case "":
+	default:
+		return false
+	}
+
+	if e.CloseCode != 0 {
+		return true
+	}
+
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload %s: %v", e.UploadID, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
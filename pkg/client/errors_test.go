@@ -0,0 +1,133 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	commonErrors "node-test/internal/common/errors"
+	"node-test/internal/domain"
+)
+
+func TestErrorIs(t *testing.T) {
+
+	targets := []error{ErrNotFound, ErrConflict, ErrUnauthorized, ErrQuotaExceeded, ErrChecksumMismatch, ErrInvalidChunk, ErrNodeUnavailable}
+
+	tests := []struct {
+		name string
+		err  *Error
+		want []error
+	}{
+		{name: "not found code", err: &Error{Code: CodeNotFound, StatusCode: http.StatusNotFound}, want: []error{ErrNotFound}},
+		{name: "not found status", err: &Error{StatusCode: http.StatusNotFound}, want: []error{ErrNotFound}},
+		{name: "conflict", err: &Error{Code: CodeConflict, StatusCode: http.StatusConflict}, want: []error{ErrConflict}},
+		{name: "checksum mismatch", err: &Error{Code: CodeChecksumMismatch, StatusCode: http.StatusConflict}, want: []error{ErrConflict, ErrChecksumMismatch}},
+		{name: "unauthorized", err: &Error{Code: CodeUnauthorized, StatusCode: http.StatusUnauthorized}, want: []error{ErrUnauthorized}},
+		{name: "forbidden status", err: &Error{StatusCode: http.StatusForbidden}, want: []error{ErrUnauthorized}},
+		{name: "quota exceeded", err: &Error{Code: CodeQuotaExceeded, StatusCode: http.StatusRequestEntityTooLarge}, want: []error{ErrQuotaExceeded}},
+		{name: "invalid chunk frame", err: &Error{Code: CodeInvalidChunk, CloseCode: websocket.CloseUnsupportedData}, want: []error{ErrInvalidChunk}},
+		{name: "node unavailable", err: &Error{Code: CodeNodeUnavailable, StatusCode: http.StatusBadGateway}, want: []error{ErrNodeUnavailable}},
+		{name: "invalid request", err: &Error{Code: CodeInvalidRequest, StatusCode: http.StatusBadRequest}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// errors are matched through the wrapping of the upload
+			err := &UploadError{UploadID: "a1", Err: fmt.Errorf("commit: %w", tt.err)}
+			for _, target := range targets {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == target
+				}
+				if got := errors.Is(err, target); got != want {
+					t.Fatalf("errors.Is(%v, %v) = %v, want %v", err, target, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorTemporary(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  *Error
+		want bool
+	}{
+		{name: "internal", err: &Error{Code: CodeInternal, StatusCode: http.StatusInternalServerError}, want: true},
+		{name: "node unavailable", err: &Error{Code: CodeNodeUnavailable, StatusCode: http.StatusBadGateway}, want: true},
+		{name: "unavailable", err: &Error{Code: CodeUnavailable, StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "not found", err: &Error{Code: CodeNotFound, StatusCode: http.StatusNotFound}},
+		{name: "code wins over the status", err: &Error{Code: CodeInvalidRequest, StatusCode: http.StatusInternalServerError}},
+		{name: "server status without code", err: &Error{StatusCode: http.StatusBadGateway}, want: true},
+		{name: "too many requests", err: &Error{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "client status without code", err: &Error{StatusCode: http.StatusBadRequest}},
+		{name: "close frame without code", err: &Error{CloseCode: websocket.CloseAbnormalClosure}, want: true},
+		{name: "close frame with code", err: &Error{Code: CodeQuotaExceeded, CloseCode: websocket.ClosePolicyViolation}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Temporary(); got != tt.want {
+				t.Fatalf("temporary = %v, want %v", got, tt.want)
+			}
+			if got := temporary(fmt.Errorf("op: %w", tt.err)); got != tt.want {
+				t.Fatalf("temporary of the wrapped error = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseError(t *testing.T) {
+
+	reason := commonErrors.CloseReason(domain.NewError(CodeQuotaExceeded, "quota exceeded"))
+
+	tests := []struct {
+		name string
+		err  error
+		// wantClose is zero when the error isn't reported by the master and is kept as is
+		wantClose int
+		wantCode  ErrorCode
+		wantIs    error
+	}{
+		{
+			name:      "close frame with the reason",
+			err:       &websocket.CloseError{Code: websocket.ClosePolicyViolation, Text: reason},
+			wantClose: websocket.ClosePolicyViolation,
+			wantCode:  CodeQuotaExceeded,
+			wantIs:    ErrQuotaExceeded,
+		},
+		{
+			name:      "close frame without the reason",
+			err:       &websocket.CloseError{Code: websocket.CloseInternalServerErr, Text: "failure"},
+			wantClose: websocket.CloseInternalServerErr,
+			wantCode:  CodeInternal,
+		},
+		{name: "normal closure", err: &websocket.CloseError{Code: websocket.CloseNormalClosure}},
+		{name: "connection failure", err: io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := closeError("upload", tt.err)
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				if tt.wantClose != 0 || !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want the close code %v", err, tt.wantClose)
+				}
+				return
+			}
+			if apiErr.CloseCode != tt.wantClose || apiErr.Code != tt.wantCode {
+				t.Fatalf("error = %+v, want close code %v and code %q", apiErr, tt.wantClose, tt.wantCode)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Fatalf("error = %v, want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
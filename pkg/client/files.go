@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	commonHttp "node-test/internal/common/http"
+)
+
+// Stat returns the information about the file.
+func (c *Client) Stat(ctx context.Context, id string) (*File, error) {
+
+	var info commonHttp.FileInfo
+	err := c.doJSON(ctx, "stat", http.MethodGet, c.restURL(storagePath+"/files/"+url.PathEscape(id), nil), nil, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(&info), nil
+}
+
+// Resolve returns the information about the committed file with the key inside the bucket.
+func (c *Client) Resolve(ctx context.Context, bucket, key string) (*File, error) {
+
+	var info commonHttp.FileInfo
+	err := c.doJSON(ctx, "resolve", http.MethodGet, c.restURL(objectPath(bucket, key), nil), nil, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(&info), nil
+}
+
+// Versions returns versions of the key inside the bucket, the latest version goes first.
+// Every version is the file with its own id, delete markers have no content.
+func (c *Client) Versions(ctx context.Context, bucket, key string) ([]*File, error) {
+
+	var infos []*commonHttp.FileInfo
+	if err := c.doJSON(ctx, "versions", http.MethodGet, c.restURL(versionsPath(bucket, key), nil), nil, &infos); err != nil {
+		return nil, err
+	}
+
+	versions := make([]*File, 0, len(infos))
+	for _, info := range infos {
+		versions = append(versions, newFile(info))
+	}
+
+	return versions, nil
+}
+
+// DeleteKey deletes the key inside the bucket. The bucket which keeps versions returns the delete marker
+// and keeps previous versions, otherwise the file is removed and nil is returned.
+func (c *Client) DeleteKey(ctx context.Context, bucket, key string) (*File, error) {
+
+	var info commonHttp.FileInfo
+	if err := c.doJSON(ctx, "delete key", http.MethodDelete, c.restURL(objectPath(bucket, key), nil), nil, &info); err != nil {
+		return nil, err
+	}
+
+	if info.UploadID == "" {
+		return nil, nil
+	}
+
+	return newFile(&info), nil
+}
+
+// List returns the page of committed files which names start with the prefix.
+func (c *Client) List(ctx context.Context, opts ListOptions) (*FileList, error) {
+
+	query := url.Values{}
+	if opts.Bucket != "" {
+		query.Set("bucket", opts.Bucket)
+	}
+	query.Set("prefix", opts.Prefix)
+	query.Set("offset", strconv.FormatInt(opts.Offset, 10))
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(opts.Limit, 10))
+	}
+
+	var page commonHttp.FileList
+	if err := c.doJSON(ctx, "list", http.MethodGet, c.restURL(storagePath+"/files", query), nil, &page); err != nil {
+		return nil, err
+	}
+
+	list := &FileList{
+		Files:      make([]*File, 0, len(page.Files)),
+		NextOffset: page.NextOffset,
+	}
+	for _, info := range page.Files {
+		list.Files = append(list.Files, newFile(info))
+	}
+
+	return list, nil
+}
+
+// ListAll walks all pages of the listing, opts.Offset is ignored.
+func (c *Client) ListAll(ctx context.Context, opts ListOptions) ([]*File, error) {
+
+	var files []*File
+	opts.Offset = 0
+
+	for {
+		page, err := c.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, page.Files...)
+		if page.NextOffset == 0 {
+			return files, nil
+		}
+		opts.Offset = page.NextOffset
+	}
+}
+
+// Delete removes the file from the cluster.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.doJSON(ctx, "delete", http.MethodDelete, c.restURL(storagePath+"/files/"+url.PathEscape(id), nil), nil, nil)
+}
+
+// Share changes who can read the file, the principals are used only with AccessShared.
+func (c *Client) Share(ctx context.Context, id, access string, principals ...string) (*File, error) {
+
+	var info commonHttp.FileInfo
+	err := c.doJSON(ctx, "share", http.MethodPut, c.restURL(storagePath+"/files/"+url.PathEscape(id)+"/access", nil),
+		&commonHttp.AccessRequest{Access: access, SharedWith: principals}, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(&info), nil
+}
+
+// Nodes returns the state of storage nodes.
+func (c *Client) Nodes(ctx context.Context) ([]*Node, error) {
+
+	var infos []*commonHttp.NodeInfo
+	if err := c.doJSON(ctx, "nodes", http.MethodGet, c.restURL(clusterPath+"/nodes", nil), nil, &infos); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*Node, 0, len(infos))
+	for _, info := range infos {
+		nodes = append(nodes, &Node{
+			Address:   info.Address,
+			Size:      info.Size,
+			Free:      info.Free,
+			Used:      info.Used,
+			Available: info.Available,
+			Ready:     info.Ready,
+			Error:     info.Error,
+			Queue: NodeQueue{
+				Workers:     info.Queue.Workers,
+				Queued:      info.Queue.Queued,
+				Running:     info.Queue.Running,
+				Completed:   info.Queue.Completed,
+				Failed:      info.Queue.Failed,
+				WaitLatency: time.Duration(info.Queue.WaitLatencyMs * float64(time.Millisecond)),
+				RunLatency:  time.Duration(info.Queue.RunLatencyMs * float64(time.Millisecond)),
+			},
+		})
+	}
+
+	return nodes, nil
+}
+
+// CreateSession registers the upload session, its chunks can be sent by several connections.
+// The content of the session isn't encrypted by the client.
+func (c *Client) CreateSession(ctx context.Context, name string, size int64, checksum string) (*Session, error) {
+	return c.createSession(ctx, &UploadOptions{Name: name, Checksum: checksum}, size, nil)
+}
+
+// CreateBucketSession registers the upload session of the file with the key inside the bucket.
+func (c *Client) CreateBucketSession(ctx context.Context, bucket, key string, size int64, checksum string) (*Session, error) {
+	return c.createSession(ctx, &UploadOptions{Bucket: bucket, Name: key, Checksum: checksum}, size, nil)
+}
+
+// createSession registers the session of the file described by opts with the size of the stored content
+func (c *Client) createSession(ctx context.Context, opts *UploadOptions, size int64, metadata map[string]string) (*Session, error) {
+
+	var session commonHttp.UploadSession
+	err := c.doJSON(ctx, "create session", http.MethodPost, c.restURL(storagePath+"/sessions", nil), &commonHttp.ChunkMetadata{
+		TotalFileSize: size,
+		Bucket:        opts.Bucket,
+		Filename:      opts.Name,
+		Checksum:      opts.Checksum,
+		TTLSeconds:    int64(opts.TTL / time.Second),
+		ChunkSize:     opts.ChunkSize,
+		Compression:   opts.Compression,
+		Metadata:      metadata,
+	}, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		UploadID:    session.UploadID,
+		Size:        size,
+		ChunkSize:   session.ChunkSize,
+		TotalChunks: session.TotalChunks,
+		Chunking:    session.Chunking,
+		Missing:     splitRanges(ChunkRange{First: 1, Last: session.TotalChunks}, 1),
+	}, nil
+}
+
+// Session returns the state of the upload session with the chunks which aren't stored yet.
+func (c *Client) Session(ctx context.Context, id string) (*Session, error) {
+
+	var state commonHttp.SessionState
+	err := c.doJSON(ctx, "session", http.MethodGet, c.restURL(storagePath+"/sessions/"+url.PathEscape(id), nil), nil, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		UploadID:    state.UploadID,
+		Size:        state.TotalFileSize,
+		ChunkSize:   state.ChunkSize,
+		TotalChunks: state.TotalChunks,
+		Chunking:    state.Chunking,
+		Status:      state.Status,
+		Missing:     make([]ChunkRange, 0, len(state.Missing)),
+	}
+	for _, r := range state.Missing {
+		session.Missing = append(session.Missing, ChunkRange{First: r.First, Last: r.Last})
+	}
+
+	return session, nil
+}
+
+// Commit completes the upload session, the checksum is recorded when the session was created without it.
+func (c *Client) Commit(ctx context.Context, id, checksum string) (*File, error) {
+
+	var info commonHttp.FileInfo
+	err := c.doJSON(ctx, "commit", http.MethodPost, c.restURL(storagePath+"/sessions/"+url.PathEscape(id)+"/commit", nil),
+		&commonHttp.CommitRequest{Checksum: checksum}, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(&info), nil
+}
+
+func objectPath(bucket, key string) string {
+	return storagePath + "/objects/" + keyPath(bucket, key)
+}
+
+func versionsPath(bucket, key string) string {
+	return storagePath + "/versions/" + keyPath(bucket, key)
+}
+
+// keyPath escapes segments of the key, so the slashes of the key are kept
+func keyPath(bucket, key string) string {
+
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+
+	return url.PathEscape(bucket) + "/" + strings.Join(segments, "/")
+}
+
+func newFile(info *commonHttp.FileInfo) *File {
+	return &File{
+		ID:           info.UploadID,
+		Bucket:       info.Bucket,
+		Name:         info.Filename,
+		Size:         info.TotalFileSize,
+		TotalChunks:  info.TotalChunks,
+		ChunkSize:    info.ChunkSize,
+		Chunking:     info.Chunking,
+		Compression:  info.Compression,
+		Checksum:     info.Checksum,
+		Status:       info.Status,
+		Latest:       info.Latest,
+		DeleteMarker: info.DeleteMarker,
+		Owner:        info.Owner,
+		Access:       info.Access,
+		SharedWith:   info.SharedWith,
+		Metadata:     info.Metadata,
+		CreatedAt:    info.CreatedAt,
+		CommittedAt:  info.CommittedAt,
+		ExpiresAt:    info.ExpiresAt,
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	commonHttp "node-test/internal/common/http"
+)
+
+type (
+	// uploadFlow reads messages of the upload stream which asked for credits: credits allow to send
+	// more bytes and the result ends the stream. The master grants credits while nodes keep up,
+	// so frames wait for them instead of piling up on the master.
+	uploadFlow struct {
+		conn   *websocket.Conn
+		mu     sync.Mutex
+		credit int64
+		notify chan struct{}
+		done   chan struct{}
+		result commonHttp.UploadResult
+		err    error
+	}
+
+	// uploadMessage is either the credit or the result of the upload stream
+	uploadMessage struct {
+		commonHttp.UploadResult
+		commonHttp.UploadCredit
+	}
+)
+
+func newUploadFlow(conn *websocket.Conn) *uploadFlow {
+
+	flow := &uploadFlow{
+		conn:   conn,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go flow.read()
+
+	return flow
+}
+
+func (f *uploadFlow) read() {
+	defer close(f.done)
+
+	for {
+		var message uploadMessage
+		if err := f.conn.ReadJSON(&message); err != nil {
+			f.err = closeError("upload", err)
+			return
+		}
+		if message.Bytes <= 0 {
+			f.result = message.UploadResult
+			return
+		}
+
+		f.mu.Lock()
+		f.credit += message.Bytes
+		f.mu.Unlock()
+
+		select {
+		case f.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// acquire waits until n bytes are granted and takes them
+func (f *uploadFlow) acquire(ctx context.Context, n int64) error {
+
+	for !f.take(n) {
+		select {
+		case <-f.notify:
+		case <-f.done:
+			// credits granted before the stream has ended can still be taken
+			if f.take(n) {
+				return nil
+			}
+			if f.err != nil {
+				return f.err
+			}
+			return errors.New("upload: stream has ended before the content is sent")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// take takes n bytes when they are granted
+func (f *uploadFlow) take(n int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.credit < n {
+		return false
+	}
+	f.credit -= n
+
+	return true
+}
+
+// wait returns the result of the stream once all frames are sent
+func (f *uploadFlow) wait() (*commonHttp.UploadResult, error) {
+	<-f.done
+	return &f.result, f.err
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	commonHttp "node-test/internal/common/http"
+)
+
+// Usage returns quotas of the caller and its tenant.
+func (c *Client) Usage(ctx context.Context) ([]*Quota, error) {
+
+	var infos []*commonHttp.QuotaInfo
+	if err := c.doJSON(ctx, "usage", http.MethodGet, c.restURL(storagePath+"/quota", nil), nil, &infos); err != nil {
+		return nil, err
+	}
+
+	quotas := make([]*Quota, 0, len(infos))
+	for _, info := range infos {
+		quotas = append(quotas, newQuota(info))
+	}
+
+	return quotas, nil
+}
+
+// Quota returns limits and usage of the user or the tenant, the caller must be the admin.
+func (c *Client) Quota(ctx context.Context, scope, name string) (*Quota, error) {
+
+	var info commonHttp.QuotaInfo
+	if err := c.doJSON(ctx, "quota", http.MethodGet, c.restURL(quotaPath(scope, name), nil), nil, &info); err != nil {
+		return nil, err
+	}
+
+	return newQuota(&info), nil
+}
+
+// SetQuota replaces limits of the user or the tenant, the caller must be the admin.
+func (c *Client) SetQuota(ctx context.Context, scope, name string, maxBytes, maxFiles int64) (*Quota, error) {
+
+	var info commonHttp.QuotaInfo
+	err := c.doJSON(ctx, "set quota", http.MethodPut, c.restURL(quotaPath(scope, name), nil),
+		&commonHttp.QuotaRequest{MaxBytes: maxBytes, MaxFiles: maxFiles}, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return newQuota(&info), nil
+}
+
+func quotaPath(scope, name string) string {
+	return apiPath + "/admin/quotas/" + url.PathEscape(scope) + "/" + url.PathEscape(name)
+}
+
+func newQuota(info *commonHttp.QuotaInfo) *Quota {
+	return &Quota{
+		Scope:     info.Scope,
+		Name:      info.Name,
+		MaxBytes:  info.MaxBytes,
+		MaxFiles:  info.MaxFiles,
+		Custom:    info.Custom,
+		UsedBytes: info.UsedBytes,
+		UsedFiles: info.UsedFiles,
+	}
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	// AccessPrivate allows only the owner to read the file.
+	AccessPrivate = "private"
+	// AccessShared allows the owner and the listed principals to read the file.
+	AccessShared = "shared"
+	// AccessPublic allows every authenticated principal to read the file.
+	AccessPublic = "public"
+
+	// QuotaUser is the scope of quotas of users.
+	QuotaUser = "user"
+	// QuotaTenant is the scope of quotas of tenants.
+	QuotaTenant = "tenant"
+	// QuotaBucket is the scope of quotas of buckets.
+	QuotaBucket = "bucket"
+
+	// ChunkingCDC marks files split by the master on content defined boundaries,
+	// their content is uploaded by the single stream and chunks have different sizes.
+	ChunkingCDC = "cdc"
+)
+
+type (
+	// File describes the uploaded file.
+	File struct {
+		ID           string            `json:"id"`
+		Bucket       string            `json:"bucket,omitempty"`
+		Name         string            `json:"name"` // the key of the file inside the bucket
+		Size         int64             `json:"size"`
+		TotalChunks  int64             `json:"total_chunks"`
+		ChunkSize    int64             `json:"chunk_size"`
+		Chunking     string            `json:"chunking,omitempty"`    // ChunkingCDC or fixed chunks when empty
+		Compression  string            `json:"compression,omitempty"` // the codec of new chunks, empty when they aren't compressed
+		Checksum     string            `json:"checksum,omitempty"`    // hex encoded sha256 of the content
+		Status       string            `json:"status"`
+		Latest       bool              `json:"latest,omitempty"`        // the file is the current version of its key in the bucket
+		DeleteMarker bool              `json:"delete_marker,omitempty"` // the version marks its key as deleted
+		Owner        string            `json:"owner"`
+		Access       string            `json:"access"`                // AccessPrivate, AccessShared or AccessPublic
+		SharedWith   []string          `json:"shared_with,omitempty"` // principals allowed to read the shared file
+		Metadata     map[string]string `json:"metadata,omitempty"`
+		CreatedAt    time.Time         `json:"created_at"`
+		CommittedAt  time.Time         `json:"committed_at"`
+		ExpiresAt    time.Time         `json:"expires_at,omitempty"` // zero when the file doesn't expire
+	}
+
+	FileList struct {
+		Files      []*File `json:"files"`
+		NextOffset int64   `json:"next_offset,omitempty"` // zero when there are no more files
+	}
+
+	ListOptions struct {
+		Bucket string // files of all buckets and outside of them are listed when empty
+		Prefix string
+		Offset int64
+		Limit  int64 // the master limit is used when zero
+	}
+
+	// ChunkRange is the inclusive range of chunk numbers, chunks are numbered from 1.
+	ChunkRange struct {
+		First int64
+		Last  int64
+	}
+
+	// Session is the upload session which chunks can be sent by several connections.
+	Session struct {
+		UploadID    string
+		Size        int64
+		ChunkSize   int64
+		TotalChunks int64
+		Chunking    string
+		Status      string
+		Missing     []ChunkRange // chunks which aren't stored yet
+	}
+
+	// Bucket is the namespace of files, keys of files are unique inside the bucket.
+	// Settings are applied to files uploaded after they are changed, zero limits mean no limit.
+	Bucket struct {
+		Name        string        `json:"name"`
+		Owner       string        `json:"owner"`
+		Tenant      string        `json:"tenant,omitempty"`
+		Replicas    int           `json:"replicas"`     // copies of every chunk on distinct nodes, one when zero
+		Versioning  bool          `json:"versioning"`   // previous versions of keys are kept
+		MaxVersions int           `json:"max_versions"` // noncurrent versions kept for every key, zero keeps all
+		Retention   time.Duration `json:"retention"`    // files can't be deleted before it elapses
+		// ExpireAfter limits the TTL of new files, NoncurrentExpireAfter is the time noncurrent versions are kept.
+		// Zero values keep files until they are deleted.
+		ExpireAfter           time.Duration `json:"expire_after"`
+		NoncurrentExpireAfter time.Duration `json:"noncurrent_expire_after"`
+		ChunkSize             int64         `json:"chunk_size,omitempty"`  // the size of fixed chunks of new files, the master size when zero
+		Compression           string        `json:"compression,omitempty"` // the codec of new files, the master codec when empty
+		MaxBytes              int64         `json:"max_bytes"`
+		MaxFiles              int64         `json:"max_files"`
+		DefaultAccess         string        `json:"default_access"`
+		DefaultSharedWith     []string      `json:"default_shared_with,omitempty"`
+		CreatedAt             time.Time     `json:"created_at"`
+	}
+
+	// Quota describes limits and usage of the user, the tenant or the bucket, zero limits mean no limit.
+	Quota struct {
+		Scope     string `json:"scope"` // QuotaUser, QuotaTenant or QuotaBucket
+		Name      string `json:"name"`
+		MaxBytes  int64  `json:"max_bytes"`
+		MaxFiles  int64  `json:"max_files"`
+		Custom    bool   `json:"custom"` // false when default limits apply
+		UsedBytes int64  `json:"used_bytes"`
+		UsedFiles int64  `json:"used_files"`
+	}
+
+	// DedupStats describes bytes of the file or of all files, Ratio is the share of logical bytes which aren't stored twice.
+	// CompressedBytes are bytes kept on nodes for stored bytes, CompressionRatio is the share saved by compression.
+	DedupStats struct {
+		UploadID         string  `json:"upload_id,omitempty"` // empty for all files
+		LogicalBytes     int64   `json:"logical_bytes"`
+		StoredBytes      int64   `json:"stored_bytes"`
+		CompressedBytes  int64   `json:"compressed_bytes"`
+		Ratio            float64 `json:"dedup_ratio"`
+		CompressionRatio float64 `json:"compression_ratio"`
+	}
+
+	Node struct {
+		Address   string    `json:"address"`
+		Size      int64     `json:"size"`
+		Free      int64     `json:"free"`
+		Used      int64     `json:"used"`
+		Available bool      `json:"available"`
+		Ready     bool      `json:"ready"` // the node accepts new chunks
+		Error     string    `json:"error,omitempty"`
+		Queue     NodeQueue `json:"queue"`
+	}
+
+	// NodeQueue describes requests of the master to the node, latencies are averages of completed requests.
+	NodeQueue struct {
+		Workers     int           `json:"workers"` // concurrent requests to the node
+		Queued      int           `json:"queued"`
+		Running     int           `json:"running"`
+		Completed   int64         `json:"completed"`
+		Failed      int64         `json:"failed"`
+		WaitLatency time.Duration `json:"wait_latency"`
+		RunLatency  time.Duration `json:"run_latency"`
+	}
+
+	UploadOptions struct {
+		// Bucket keeps the file with Name as its key, the file is outside of buckets when empty.
+		Bucket string
+		Name   string
+		// Size of the content, required by Upload.
+		Size int64
+		// Checksum is the hex encoded sha256 of the content. Upload computes it while streaming,
+		// UploadAt reads the content once more to compute it when it is empty.
+		Checksum string
+		// TTL removes the file when it elapses since the upload starts, zero keeps the file until it is deleted.
+		// The bucket may limit the TTL of its files.
+		TTL time.Duration
+		// ChunkSize requests the size of fixed chunks, the size of the bucket or the master is used when zero.
+		// The master limits the size and the session reports the chosen one.
+		ChunkSize int64
+		// Compression is the codec of chunks: none, gzip, zstd or snappy. The codec of the bucket
+		// or the master is used when it is empty.
+		Compression string
+		// Parallel is the count of concurrent connections used by UploadAt and ResumeUpload.
+		Parallel int
+		// Progress is called with the count of bytes of every sent chunk, it must be safe for concurrent use.
+		Progress func(n int64)
+	}
+
+	DownloadOptions struct {
+		// Parallel is the count of concurrent connections used by DownloadAt.
+		Parallel int
+		// Progress is called with the count of bytes of every received chunk, it must be safe for concurrent use.
+		Progress func(n int64)
+	}
+)
+
+// Encrypted reports whether the file is encrypted on the client.
+func (f *File) Encrypted() bool {
+	return encrypted(f.Metadata)
+}
+
+// ContentSize returns the size of the plain content, Size is the size of the stored encrypted content.
+func (f *File) ContentSize() int64 {
+	if !f.Encrypted() {
+		return f.Size
+	}
+
+	size, err := strconv.ParseInt(f.Metadata[MetadataSize], 10, 64)
+	if err != nil {
+		return f.Size
+	}
+
+	return size
+}
+
+// contentDefined reports whether the master splits the content of the session into content defined chunks
+func (s *Session) contentDefined() bool {
+	return s.Chunking == ChunkingCDC
+}
+
+func (o *UploadOptions) progress(n int) {
+	if o.Progress != nil {
+		o.Progress(int64(n))
+	}
+}
+
+func (o *DownloadOptions) progress(n int) {
+	if o.Progress != nil {
+		o.Progress(int64(n))
+	}
+}
+
+// splitRanges splits the range into at most parallel ranges of the same size
+func splitRanges(r ChunkRange, parallel int) []ChunkRange {
+
+	total := r.Last - r.First + 1
+	if total <= 0 {
+		return nil
+	}
+
+	count := int64(parallel)
+	if count < 1 {
+		count = 1
+	}
+	if count > total {
+		count = total
+	}
+
+	size := total / count
+	if total%count > 0 {
+		size++
+	}
+
+	ranges := make([]ChunkRange, 0, count)
+	for first := r.First; first <= r.Last; first += size {
+		last := first + size - 1
+		if last > r.Last {
+			last = r.Last
+		}
+		ranges = append(ranges, ChunkRange{First: first, Last: last})
+	}
+
+	return ranges
+}
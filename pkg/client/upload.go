@@ -0,0 +1,394 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	commonHttp "node-test/internal/common/http"
+)
+
+// errEncryptedChecksum is returned when the checksum is provided for the content encrypted by the client,
+// the master records the checksum of the encrypted content which is computed while uploading.
+var errEncryptedChecksum = errors.New("upload: checksum can't be provided for the encrypted content")
+
+// Upload streams opts.Size bytes of r as the new file through the single connection.
+// The failed stream can't be repeated, the returned *UploadError keeps the session
+// which can be resumed with ResumeUpload when the content is available as io.ReaderAt.
+func (c *Client) Upload(ctx context.Context, r io.Reader, opts UploadOptions) (*File, error) {
+
+	if opts.Name == "" || opts.Size < 0 {
+		return nil, errors.New("upload: name and size of the content are required")
+	}
+
+	var (
+		content  = r
+		size     = opts.Size
+		metadata map[string]string
+	)
+	if c.encryption != nil {
+		if opts.Checksum != "" {
+			return nil, errEncryptedChecksum
+		}
+
+		fc, md, err := c.encryption.newFile(opts.Size)
+		if err != nil {
+			return nil, fmt.Errorf("upload: %w", err)
+		}
+		content, size, metadata = fc.encryptReader(r), fc.size(), md
+	}
+
+	session, err := c.createSession(ctx, &opts, size, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	src := io.TeeReader(content, hash)
+
+	if session.contentDefined() {
+		if err := c.uploadContent(ctx, session, src, &opts); err != nil {
+			return nil, &UploadError{UploadID: session.UploadID, Err: err}
+		}
+	} else if session.TotalChunks > 0 {
+		all := ChunkRange{First: 1, Last: session.TotalChunks}
+		if err := c.uploadStream(ctx, session, all, src, &opts); err != nil {
+			return nil, &UploadError{UploadID: session.UploadID, Err: err}
+		}
+	}
+
+	if n, _ := r.Read(make([]byte, 1)); n > 0 {
+		return nil, &UploadError{UploadID: session.UploadID, Err: errors.New("content is longer than its size")}
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if opts.Checksum != "" && opts.Checksum != checksum {
+		return nil, &UploadError{UploadID: session.UploadID, Err: ErrChecksumMismatch}
+	}
+
+	file, err := c.Commit(ctx, session.UploadID, checksum)
+	if err != nil {
+		return nil, &UploadError{UploadID: session.UploadID, Err: err}
+	}
+
+	return file, nil
+}
+
+// UploadAt uploads opts.Size bytes of r as the new file through opts.Parallel connections.
+// Every connection sends its own range of chunks and repeats missing chunks on temporary failures.
+// When the master splits files into content defined chunks, the whole content is sent by the single connection.
+func (c *Client) UploadAt(ctx context.Context, r io.ReaderAt, opts UploadOptions) (*File, error) {
+
+	if opts.Name == "" || opts.Size < 0 {
+		return nil, errors.New("upload: name and size of the content are required")
+	}
+
+	var (
+		size     = opts.Size
+		metadata map[string]string
+	)
+	if c.encryption != nil {
+		if opts.Checksum != "" {
+			return nil, errEncryptedChecksum
+		}
+
+		fc, md, err := c.encryption.newFile(opts.Size)
+		if err != nil {
+			return nil, fmt.Errorf("upload: %w", err)
+		}
+		r, size, metadata = fc.encryptReaderAt(r), fc.size(), md
+	}
+
+	if opts.Checksum == "" {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(r, 0, size)); err != nil {
+			return nil, fmt.Errorf("upload: compute checksum: %w", err)
+		}
+		opts.Checksum = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	session, err := c.createSession(ctx, &opts, size, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := c.resume(ctx, session, r, &opts)
+	if err != nil {
+		return nil, &UploadError{UploadID: session.UploadID, Err: err}
+	}
+
+	return file, nil
+}
+
+// ResumeUpload sends chunks of the interrupted session which aren't stored yet and commits it.
+// The r must provide the same content as the original upload, the content of the encrypted
+// session is encrypted with the same parameters again.
+func (c *Client) ResumeUpload(ctx context.Context, id string, r io.ReaderAt, opts UploadOptions) (*File, error) {
+
+	file, err := c.Stat(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fc, err := c.fileCipher(file.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	if fc != nil {
+		if opts.Checksum != "" {
+			return nil, errEncryptedChecksum
+		}
+		r = fc.encryptReaderAt(r)
+	}
+
+	session, err := c.Session(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err = c.resume(ctx, session, r, &opts)
+	if err != nil {
+		return nil, &UploadError{UploadID: session.UploadID, Err: err}
+	}
+
+	return file, nil
+}
+
+func (c *Client) resume(ctx context.Context, session *Session, r io.ReaderAt, opts *UploadOptions) (*File, error) {
+
+	// content defined chunks are split by the master, the content is sent again until it is split
+	if session.contentDefined() {
+		err := c.withRetry(ctx, func() error {
+			if session.TotalChunks > 0 || session.Size == 0 {
+				return nil
+			}
+			return c.uploadContent(ctx, session, io.NewSectionReader(r, 0, session.Size), opts)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return c.Commit(ctx, session.UploadID, opts.Checksum)
+	}
+
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var ranges []ChunkRange
+	for _, missing := range session.Missing {
+		ranges = append(ranges, splitRanges(missing, parallel)...)
+	}
+
+	err := runConcurrent(ctx, ranges, parallel, func(ctx context.Context, rng ChunkRange) error {
+		return c.uploadRangeAt(ctx, session, rng, r, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Commit(ctx, session.UploadID, opts.Checksum)
+}
+
+// uploadRangeAt sends chunks of the range and on temporary failures sends the chunks
+// of the range which the master hasn't stored
+func (c *Client) uploadRangeAt(ctx context.Context, session *Session, rng ChunkRange, r io.ReaderAt, opts *UploadOptions) error {
+
+	pending := []ChunkRange{rng}
+	first := true
+
+	return c.withRetry(ctx, func() error {
+
+		if !first {
+			state, err := c.Session(ctx, session.UploadID)
+			if err != nil {
+				return err
+			}
+			pending = intersect(state.Missing, rng)
+		}
+		first = false
+
+		for _, part := range pending {
+			offset := (part.First - 1) * session.ChunkSize
+			end := part.Last * session.ChunkSize
+			if end > session.Size {
+				end = session.Size
+			}
+
+			if err := c.uploadStream(ctx, session, part, io.NewSectionReader(r, offset, end-offset), opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// uploadStream sends chunks of the range read from src through the single websocket connection
+func (c *Client) uploadStream(ctx context.Context, session *Session, rng ChunkRange, src io.Reader, opts *UploadOptions) error {
+
+	conn, release, err := c.dial(ctx, "upload", "/ws/upload", nil)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = conn.WriteJSON(&commonHttp.ChunkMetadata{
+		TotalFileSize: session.Size,
+		UploadID:      session.UploadID,
+		FirstChunk:    rng.First,
+		LastChunk:     rng.Last,
+		Credits:       true,
+	})
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	flow := newUploadFlow(conn)
+
+	buf := make([]byte, session.ChunkSize)
+	for chunkNum := rng.First; chunkNum <= rng.Last; chunkNum++ {
+		n, err := io.ReadFull(src, buf)
+		if n == 0 {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = errors.New("content is shorter than its size")
+			}
+			return fmt.Errorf("upload: read chunk %d: %w", chunkNum, err)
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("upload: read chunk %d: %w", chunkNum, err)
+		}
+
+		if err := flow.acquire(ctx, int64(n)); err != nil {
+			return err
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+			return closeError("upload", err)
+		}
+		opts.progress(n)
+	}
+
+	_, err = flow.wait()
+
+	return err
+}
+
+// uploadContent sends the whole content of the session with content defined chunks through the single
+// websocket connection, the master splits it into chunks independently of frames
+func (c *Client) uploadContent(ctx context.Context, session *Session, src io.Reader, opts *UploadOptions) error {
+
+	conn, release, err := c.dial(ctx, "upload", "/ws/upload", nil)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = conn.WriteJSON(&commonHttp.ChunkMetadata{
+		TotalFileSize: session.Size,
+		UploadID:      session.UploadID,
+		Credits:       true,
+	})
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	flow := newUploadFlow(conn)
+
+	buf := make([]byte, session.ChunkSize)
+	for sent := int64(0); sent < session.Size; {
+		n, err := io.ReadFull(src, buf[:min(int64(len(buf)), session.Size-sent)])
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = errors.New("content is shorter than its size")
+			}
+			return fmt.Errorf("upload: read content at %d: %w", sent, err)
+		}
+
+		if err := flow.acquire(ctx, int64(n)); err != nil {
+			return err
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+			return closeError("upload", err)
+		}
+		sent += int64(n)
+		opts.progress(n)
+	}
+
+	result, err := flow.wait()
+	if err != nil {
+		return err
+	}
+	session.TotalChunks = result.ChunksReceived
+
+	return nil
+}
+
+// intersect returns parts of missing ranges which belong to r
+func intersect(missing []ChunkRange, r ChunkRange) []ChunkRange {
+
+	var parts []ChunkRange
+	for _, m := range missing {
+		first, last := m.First, m.Last
+		if first < r.First {
+			first = r.First
+		}
+		if last > r.Last {
+			last = r.Last
+		}
+		if first <= last {
+			parts = append(parts, ChunkRange{First: first, Last: last})
+		}
+	}
+
+	return parts
+}
+
+// runConcurrent runs fn for every range with at most parallel goroutines,
+// the first failure cancels the rest and is returned
+func runConcurrent(ctx context.Context, ranges []ChunkRange, parallel int, fn func(ctx context.Context, r ChunkRange) error) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, parallel)
+		failOnce sync.Once
+		failure  error
+	)
+
+	for _, r := range ranges {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(r ChunkRange) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(ctx, r); err != nil {
+				failOnce.Do(func() {
+					failure = err
+					cancel()
+				})
+			}
+		}(r)
+	}
+
+	wg.Wait()
+
+	if failure != nil {
+		return failure
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSplitRanges(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		r        ChunkRange
+		parallel int
+		want     []ChunkRange
+	}{
+		{name: "even split", r: ChunkRange{First: 1, Last: 8}, parallel: 4, want: []ChunkRange{{1, 2}, {3, 4}, {5, 6}, {7, 8}}},
+		{name: "uneven split", r: ChunkRange{First: 1, Last: 10}, parallel: 3, want: []ChunkRange{{1, 4}, {5, 8}, {9, 10}}},
+		{name: "more workers than chunks", r: ChunkRange{First: 5, Last: 6}, parallel: 4, want: []ChunkRange{{5, 5}, {6, 6}}},
+		{name: "single worker", r: ChunkRange{First: 1, Last: 5}, parallel: 1, want: []ChunkRange{{1, 5}}},
+		{name: "zero workers", r: ChunkRange{First: 1, Last: 5}, parallel: 0, want: []ChunkRange{{1, 5}}},
+		{name: "empty range", r: ChunkRange{First: 3, Last: 2}, parallel: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitRanges(tt.r, tt.parallel); !slices.Equal(got, tt.want) {
+				t.Fatalf("splitRanges(%v, %v) = %v, want %v", tt.r, tt.parallel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersect(t *testing.T) {
+
+	missing := []ChunkRange{{1, 3}, {6, 6}, {9, 12}}
+
+	tests := []struct {
+		name string
+		r    ChunkRange
+		want []ChunkRange
+	}{
+		{name: "whole range", r: ChunkRange{First: 1, Last: 12}, want: missing},
+		{name: "cut edges", r: ChunkRange{First: 2, Last: 10}, want: []ChunkRange{{2, 3}, {6, 6}, {9, 10}}},
+		{name: "single missing chunk", r: ChunkRange{First: 4, Last: 7}, want: []ChunkRange{{6, 6}}},
+		{name: "nothing missing", r: ChunkRange{First: 4, Last: 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intersect(missing, tt.r); !slices.Equal(got, tt.want) {
+				t.Fatalf("intersect(%v) = %v, want %v", tt.r, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+
+	errFailure := errors.New("failure")
+	ranges := splitRanges(ChunkRange{First: 1, Last: 100}, 10)
+
+	tests := []struct {
+		name     string
+		parallel int
+		// fail is the first chunk of the range which fails
+		fail    int64
+		wantErr error
+	}{
+		{name: "all ranges", parallel: 3},
+		{name: "single worker", parallel: 1},
+		{name: "failed range", parallel: 3, fail: 41, wantErr: errFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				done    []ChunkRange
+				running atomic.Int32
+				peak    atomic.Int32
+			)
+
+			err := runConcurrent(context.Background(), ranges, tt.parallel, func(ctx context.Context, r ChunkRange) error {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+
+				if r.First == tt.fail {
+					return errFailure
+				}
+
+				mu.Lock()
+				done = append(done, r)
+				mu.Unlock()
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("run error = %v, want %v", err, tt.wantErr)
+			}
+			if p := int(peak.Load()); p > tt.parallel {
+				t.Fatalf("%v ranges ran at once, want at most %v", p, tt.parallel)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			slices.SortFunc(done, func(a, b ChunkRange) int { return int(a.First - b.First) })
+			if !slices.Equal(done, ranges) {
+				t.Fatalf("done ranges = %v, want %v", done, ranges)
+			}
+		})
+	}
+}
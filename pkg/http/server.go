@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
@@ -28,10 +29,12 @@ type EchoServer struct {
 	observer *tel.Telemetry
 }
 
+// NewEchoServer creates the server listening on port, it serves https when tlsConfig isn't nil.
 func NewEchoServer(
 	ctx context.Context,
 	port int,
 	e *echo.Echo,
+	tlsConfig *tls.Config,
 	cancel context.CancelFunc,
 ) (*EchoServer, error) {
 
@@ -41,6 +44,7 @@ func NewEchoServer(
 		ReadHeaderTimeout: echoServerReadHeaderTimeout,
 		WriteTimeout:      echoServerWriteTimeout,
 		IdleTimeout:       echoServerIdleTimeout,
+		TLSConfig:         tlsConfig,
 		ErrorLog: func() *log.Logger {
 			// error checking is muted because inside a function
 			// an error can be returned only if an incorrect logging level is entered
@@ -66,10 +70,14 @@ func NewEchoServer(
 
 func (s *EchoServer) Start() {
 
-	s.observer.Info("starting echo server", tel.String("addr", s.server.Server.Addr))
+	s.observer.Info("starting echo server",
+		tel.String("addr", s.server.Server.Addr),
+		tel.Bool("tls", s.server.Server.TLSConfig != nil),
+	)
 
 	go func() {
-		if err := s.server.Start(s.server.Server.Addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		// StartServer serves the TLS listener when the server has the TLS config, Start serves only plain http
+		if err := s.server.StartServer(s.server.Server); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.observer.Error("echo server listener closed due to the error", tel.Error(err))
 			s.cancel()
 		}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+type (
+	// TLSConfig describes the certificate of the service and the CA which signs certificates of its peers.
+	// The service works over plain http when CertFile is empty.
+	TLSConfig struct {
+		CertFile string
+		KeyFile  string
+		CAFile   string
+	}
+)
+
+// Enabled reports whether the certificate is configured.
+func (cfg TLSConfig) Enabled() bool {
+	return cfg.CertFile != ""
+}
+
+// ServerConfig builds the config of the server presenting the certificate.
+// When CAFile is set the client certificates signed by it are verified,
+// handlers decide whether the verified certificate is required.
+func (cfg TLSConfig) ServerConfig() (*tls.Config, error) {
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// ClientConfig builds the config of the client which trusts servers signed by CAFile
+// and presents the certificate when it is configured.
+func (cfg TLSConfig) ClientConfig() (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+func loadCertPool(fileName string) (*x509.CertPool, error) {
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("read CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("CA file doesn't contain PEM certificates")
+	}
+
+	return pool, nil
+}
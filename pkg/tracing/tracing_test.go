@@ -0,0 +1,179 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestRecorder installs the global tracer provider which records ended spans
+func newTestRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return recorder
+}
+
+func TestPropagation(t *testing.T) {
+
+	tests := []struct {
+		name string
+		// traced sends the trace context of the client span with the request
+		traced     bool
+		handler    echo.HandlerFunc
+		wantStatus int
+		wantCode   codes.Code
+	}{
+		{
+			name:       "continued trace",
+			traced:     true,
+			handler:    func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+			wantStatus: http.StatusOK,
+			wantCode:   codes.Unset,
+		},
+		{
+			name:       "new trace",
+			handler:    func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+			wantStatus: http.StatusOK,
+			wantCode:   codes.Unset,
+		},
+		{
+			name:       "client error",
+			traced:     true,
+			handler:    func(c echo.Context) error { return echo.NewHTTPError(http.StatusNotFound) },
+			wantStatus: http.StatusNotFound,
+			wantCode:   codes.Unset,
+		},
+		{
+			name:       "server error",
+			traced:     true,
+			handler:    func(c echo.Context) error { return c.NoContent(http.StatusBadGateway) },
+			wantStatus: http.StatusBadGateway,
+			wantCode:   codes.Error,
+		},
+		{
+			name:       "handler error",
+			traced:     true,
+			handler:    func(c echo.Context) error { return errors.New("failure") },
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   codes.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newTestRecorder(t)
+
+			var handled trace.SpanContext
+			e := echo.New()
+			e.Use(Middleware())
+			e.GET("/files/:id", func(c echo.Context) error {
+				handled = trace.SpanContextFromContext(c.Request().Context())
+				return tt.handler(c)
+			})
+
+			ctx := context.Background()
+			var client trace.Span
+			if tt.traced {
+				ctx, client = Start(ctx, "client")
+			}
+			req := httptest.NewRequest(http.MethodGet, "/files/a1", nil)
+			Inject(ctx, req.Header)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if client != nil {
+				End(client, nil)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+
+			var server sdktrace.ReadOnlySpan
+			for _, span := range recorder.Ended() {
+				if span.SpanKind() == trace.SpanKindServer {
+					server = span
+				}
+			}
+			if server == nil {
+				t.Fatalf("server span isn't recorded")
+			}
+			if server.Name() != "GET /files/:id" {
+				t.Fatalf("server span name = %v, want the route", server.Name())
+			}
+			if server.SpanContext().SpanID() != handled.SpanID() {
+				t.Fatalf("handler runs in span %v, want the server span %v", handled.SpanID(), server.SpanContext().SpanID())
+			}
+			if server.Status().Code != tt.wantCode {
+				t.Fatalf("server span status = %v, want %v", server.Status().Code, tt.wantCode)
+			}
+
+			if !tt.traced {
+				if server.Parent().IsValid() {
+					t.Fatalf("server span has the parent %v, want the new trace", server.Parent().SpanID())
+				}
+				return
+			}
+			parent := client.SpanContext()
+			if server.SpanContext().TraceID() != parent.TraceID() || server.Parent().SpanID() != parent.SpanID() {
+				t.Fatalf("server span %v/%v, want the child of the client span %v/%v",
+					server.SpanContext().TraceID(), server.Parent().SpanID(), parent.TraceID(), parent.SpanID())
+			}
+			if !server.Parent().IsRemote() {
+				t.Fatalf("parent of the server span isn't remote")
+			}
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   codes.Code
+		wantEvents int
+	}{
+		{name: "success", wantCode: codes.Unset},
+		{name: "failure", err: errors.New("failure"), wantCode: codes.Error, wantEvents: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newTestRecorder(t)
+
+			_, span := Start(context.Background(), "operation")
+			End(span, tt.err)
+
+			ended := recorder.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("%v spans ended, want 1", len(ended))
+			}
+			if got := ended[0].Status(); got.Code != tt.wantCode || tt.err != nil && got.Description != tt.err.Error() {
+				t.Fatalf("status = %+v, want %v with the error %v", got, tt.wantCode, tt.err)
+			}
+			// the error is recorded as the exception event
+			if got := len(ended[0].Events()); got != tt.wantEvents {
+				t.Fatalf("%v events, want %v", got, tt.wantEvents)
+			}
+		})
+	}
+}